@@ -0,0 +1,235 @@
+package graph_search
+
+import (
+	"math"
+)
+
+// LandmarkTable holds the shortest path costs between a few landmark nodes and every node of a graph.
+// By the triangle inequality they give lower bounds on the cost between any two nodes, which steer A*
+// searches towards their target (ALT: A*, Landmarks and Triangle inequality). Tables are plain data and
+// can be persisted with the graph.
+type LandmarkTable struct {
+	Landmarks []int32     // IDs of the landmark nodes
+	From      [][]float32 // From[i][v] is the cost from Landmarks[i] to v, +Inf if v is unreachable
+	To        [][]float32 // To[i][v] is the cost from v to Landmarks[i], +Inf if Landmarks[i] is unreachable
+
+	weight func(Edge) float32
+}
+
+// NewLandmarkTable selects landmarks by farthest selection, each one being the node farthest from the
+// ones already selected, and computes their costs to and from every node with forward and backward
+// searches. Landmarks on the outskirts of the network give the tightest bounds.
+//
+// Parameters:
+//   - g: Graph - The graph to preprocess
+//   - count: int - Number of landmarks, usually 8 to 16; capped to the number of nodes
+//   - weight: func(Edge) float32 - Cost of the edges, Edge.Weight when nil. Searches using the table
+//     must minimize the same cost
+//
+// Returns:
+//   - *LandmarkTable: The landmarks and their costs
+//   - error: ErrNegativeWeight if an edge has a negative cost
+func NewLandmarkTable(g Graph, count int, weight func(Edge) float32) (*LandmarkTable, error) {
+	if weight == nil {
+		weight = func(e Edge) float32 { return e.Weight }
+	}
+	forward, err := ToWeighted(g, weight)
+	if err != nil {
+		return nil, err
+	}
+	reverse := Graph{Nodes: g.Nodes, OutgoingEdges: g.IncomingEdges, IncomingEdges: g.OutgoingEdges}
+	backward, err := ToWeighted(reverse, weight)
+	if err != nil {
+		return nil, err
+	}
+
+	lt := &LandmarkTable{Landmarks: make([]int32, 0, count), weight: weight}
+	count = min(count, len(g.Nodes))
+	nearest := make([]float32, len(g.Nodes)) // cost from the closest landmark selected so far
+	for v := range nearest {
+		nearest[v] = float32(math.Inf(1))
+	}
+	next := int32(0)
+	for len(lt.Landmarks) < count {
+		if len(lt.Landmarks) > 0 {
+			next = farthest(nearest)
+		}
+		from, _ := forward.ShortestPaths(next)
+		to, _ := backward.ShortestPaths(next)
+		lt.Landmarks = append(lt.Landmarks, next)
+		lt.From = append(lt.From, reachedCosts(from))
+		lt.To = append(lt.To, reachedCosts(to))
+		for v, c := range lt.From[len(lt.From)-1] {
+			nearest[v] = min(nearest[v], c)
+		}
+		nearest[next] = 0
+	}
+	return lt, nil
+}
+
+// farthest returns the node with the largest finite cost, preferring nodes no landmark reaches.
+func farthest(costs []float32) int32 {
+	best, id := float32(-1), int32(0)
+	for v, c := range costs {
+		if math.IsInf(float64(c), 1) {
+			if best < float32(math.MaxFloat32) {
+				best, id = float32(math.MaxFloat32), int32(v)
+			}
+			continue
+		}
+		if c > best {
+			best, id = c, int32(v)
+		}
+	}
+	return id
+}
+
+// reachedCosts returns the costs of a search, +Inf for the nodes it did not reach.
+func reachedCosts(p WeightedPaths[float32]) []float32 {
+	costs := make([]float32, len(p.Costs))
+	for v := range costs {
+		costs[v] = float32(math.Inf(1))
+		if p.Reached(int32(v)) {
+			costs[v] = p.Costs[v]
+		}
+	}
+	return costs
+}
+
+// Bound returns a lower bound on the cost of the shortest path from one node to another.
+//
+// Parameters:
+//   - v: int32 - ID of the first node
+//   - t: int32 - ID of the second node
+//
+// Returns:
+//   - float64: The largest bound the landmarks give, 0 if none applies, +Inf if some landmark proves
+//     that t cannot be reached from v
+func (lt *LandmarkTable) Bound(v, t int32) float64 {
+	bound := 0.0
+	for i := range lt.Landmarks {
+		// d(v,t) >= d(v,l) - d(t,l) and d(v,t) >= d(l,t) - d(l,v)
+		if to := float64(lt.To[i][t]); !math.IsInf(to, 1) {
+			bound = math.Max(bound, float64(lt.To[i][v])-to)
+		}
+		if from := float64(lt.From[i][t]); !math.IsInf(from, 1) && !math.IsInf(float64(lt.From[i][v]), 1) {
+			bound = math.Max(bound, from-float64(lt.From[i][v]))
+		}
+	}
+	return bound
+}
+
+// Heuristic returns the landmark bounds as the Heuristic of A* searches, see Criteria.Heuristic. It is
+// consistent for searches minimizing the cost the table was built for, or any cost never below it.
+func (lt *LandmarkTable) Heuristic() Heuristic {
+	return func(v, target int32) float32 {
+		return float32(lt.Bound(v, target))
+	}
+}
+
+// ShortestPath answers a point-to-point query with bidirectional ALT: a forward search from the source
+// and a backward search from the target, both guided by the landmark bounds. They share the consistent
+// average potential p(v) = (Bound(v, target) - Bound(source, v)) / 2, under which both explore the same
+// reduced graph, so the search stops as soon as the smallest keys of both queues add up to the best path
+// found. It typically settles a fraction of the nodes of a unidirectional ALT search.
+//
+// Parameters:
+//   - g: Graph - The graph the table was built for
+//   - source: int32 - ID of the source node
+//   - target: int32 - ID of the target node
+//
+// Returns:
+//   - float32: The cost of the shortest path
+//   - Route: The shortest path
+//   - error: ErrNoRoute if the target cannot be reached, ErrNodeNotFound if a node is out of range
+func (lt *LandmarkTable) ShortestPath(g Graph, source, target int32) (float32, Route, error) {
+	n := int32(len(g.Nodes))
+	if source < 0 || source >= n || target < 0 || target >= n || len(lt.From) > 0 && int(n) != len(lt.From[0]) {
+		return INFINITE, Route{}, ErrNodeNotFound
+	}
+	if source == target {
+		return 0, NewRoute([]int32{source}, g), nil
+	}
+	potential := func(v int32) float64 {
+		return (lt.Bound(v, target) - lt.Bound(source, v)) / 2
+	}
+	forward := newALTSide(source, potential(source))
+	backward := newALTSide(target, -potential(target))
+
+	best, meeting := math.Inf(1), int32(-1)
+	for len(forward.queue) > 0 && len(backward.queue) > 0 {
+		if forward.queue[0].cost+backward.queue[0].cost >= best {
+			break
+		}
+		side, other, edges, sign := forward, backward, g.OutgoingEdges, 1.0
+		if backward.queue[0].cost < forward.queue[0].cost {
+			side, other, edges, sign = backward, forward, g.IncomingEdges, -1.0
+		}
+		v, ok := side.pop()
+		if !ok {
+			continue
+		}
+		for _, e := range edges[v] {
+			cost := side.costs[v] + float64(lt.weight(e))
+			if c, ok := side.costs[e.ID]; ok && c <= cost {
+				continue
+			}
+			p := sign * potential(e.ID)
+			if math.IsInf(p, 0) || math.IsNaN(p) {
+				continue // e.ID cannot be on a path from source to target
+			}
+			side.costs[e.ID], side.parents[e.ID] = cost, v
+			side.queue.push(weightedItem[float64]{node: e.ID, cost: cost + p})
+			if c, ok := other.costs[e.ID]; ok && cost+c < best {
+				best, meeting = cost+c, e.ID
+			}
+		}
+	}
+	if meeting < 0 {
+		return INFINITE, Route{}, ErrNoRoute
+	}
+
+	nodes := forward.path(meeting)
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	nodes = append(nodes, backward.path(meeting)[1:]...)
+	return float32(best), NewRoute(nodes, g), nil
+}
+
+// altSide is the state of one direction of a bidirectional ALT search.
+type altSide struct {
+	queue   weightedHeap[float64] // keyed by cost plus potential, with stale entries
+	costs   map[int32]float64
+	parents map[int32]int32
+	settled map[int32]bool
+}
+
+func newALTSide(root int32, potential float64) *altSide {
+	s := &altSide{
+		costs:   map[int32]float64{root: 0},
+		parents: map[int32]int32{root: -1},
+		settled: make(map[int32]bool),
+	}
+	s.queue.push(weightedItem[float64]{node: root, cost: potential})
+	return s
+}
+
+// pop removes the node with the smallest key, reporting false if it was already settled.
+func (s *altSide) pop() (int32, bool) {
+	item := s.queue.pop()
+	if s.settled[item.node] {
+		return 0, false
+	}
+	s.settled[item.node] = true
+	return item.node, true
+}
+
+// path returns the nodes from v back to the root of the search.
+func (s *altSide) path(v int32) []int32 {
+	nodes := make([]int32, 0)
+	for ; v >= 0; v = s.parents[v] {
+		nodes = append(nodes, v)
+	}
+	return nodes
+}
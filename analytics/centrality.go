@@ -0,0 +1,190 @@
+// Package analytics provides network-wide analyses built on top of the graph_search road graphs, such as
+// identifying the road segments most shortest paths depend on.
+package analytics
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+
+	"graph_search"
+)
+
+// CentralityOptions configures a betweenness computation.
+type CentralityOptions struct {
+	// Samples is the number of source nodes the computation starts from. When zero, or not smaller than
+	// the number of nodes, every node is used and the result is exact; otherwise sources are sampled
+	// uniformly and scores are scaled up to estimate the exact values.
+	Samples int
+
+	// Seed of the random generator picking the sampled sources.
+	Seed int64
+}
+
+// Centrality holds the betweenness scores of the nodes and edges of a graph: the number of shortest paths
+// between pairs of nodes going through them, shared equally among equal cost paths.
+type Centrality struct {
+	// Nodes[v] is the betweenness of node v. Paths starting or ending at v are not counted.
+	Nodes []float64
+
+	// Edges[v][i] is the betweenness of the edge g.OutgoingEdges[v][i].
+	Edges [][]float64
+}
+
+// EdgeScore identifies an edge of the graph by its position in the adjacency lists, along with its score.
+type EdgeScore struct {
+	From  int32   // Node the edge leaves from
+	Index int     // Position of the edge in g.OutgoingEdges[From]
+	Score float64 // Betweenness of the edge
+}
+
+// predecessor is an edge through which a node is reached on one of its shortest paths.
+type predecessor struct {
+	node  int32
+	index int
+}
+
+// Betweenness computes the betweenness of every node and edge with Brandes' algorithm, running one
+// single-source search per (sampled) source node. Edge weights are used as costs, so they must not be negative.
+//
+// Parameters:
+//   - g: graph_search.Graph - The graph to analyze
+//   - opts: CentralityOptions - Controls the sampling of source nodes
+//
+// Returns:
+//   - Centrality: The node and edge scores
+func Betweenness(g graph_search.Graph, opts CentralityOptions) Centrality {
+	n := len(g.Nodes)
+	c := Centrality{Nodes: make([]float64, n), Edges: make([][]float64, n)}
+	for v := range c.Edges {
+		c.Edges[v] = make([]float64, len(g.OutgoingEdges[v]))
+	}
+
+	sources := make([]int32, n)
+	for i := range sources {
+		sources[i] = int32(i)
+	}
+	scale := 1.0
+	if opts.Samples > 0 && opts.Samples < n {
+		rnd := rand.New(rand.NewSource(opts.Seed))
+		rnd.Shuffle(n, func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+		sources = sources[:opts.Samples]
+		scale = float64(n) / float64(opts.Samples)
+	}
+
+	b := newBrandes(n)
+	for _, s := range sources {
+		b.accumulate(g, s, c)
+	}
+	if scale != 1 {
+		for v := range c.Nodes {
+			c.Nodes[v] *= scale
+			for i := range c.Edges[v] {
+				c.Edges[v][i] *= scale
+			}
+		}
+	}
+	return c
+}
+
+// TopEdges returns the k edges with the highest betweenness, the most critical road segments of the network.
+//
+// Parameters:
+//   - k: int - Maximum number of edges to return
+//
+// Returns:
+//   - []EdgeScore: The edges sorted by decreasing score
+func (c Centrality) TopEdges(k int) []EdgeScore {
+	scores := make([]EdgeScore, 0)
+	for v, edges := range c.Edges {
+		for i, score := range edges {
+			scores = append(scores, EdgeScore{From: int32(v), Index: i, Score: score})
+		}
+	}
+	sort.SliceStable(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	if k < len(scores) {
+		scores = scores[:k]
+	}
+	return scores
+}
+
+// brandes holds the per-source state of Brandes' algorithm, reused across sources to avoid allocations.
+type brandes struct {
+	dist    []float32
+	sigma   []float64
+	delta   []float64
+	settled []bool
+	preds   [][]predecessor
+	order   []int32
+}
+
+// newBrandes allocates the state for a graph of n nodes.
+func newBrandes(n int) *brandes {
+	return &brandes{
+		dist:    make([]float32, n),
+		sigma:   make([]float64, n),
+		delta:   make([]float64, n),
+		settled: make([]bool, n),
+		preds:   make([][]predecessor, n),
+		order:   make([]int32, 0, n),
+	}
+}
+
+// accumulate runs a single-source shortest path search from s, counting the shortest paths to every
+// node, and adds the dependencies of s on every node and edge to c.
+func (b *brandes) accumulate(g graph_search.Graph, s int32, c Centrality) {
+	b.search(g, s)
+	for i := len(b.order) - 1; i >= 0; i-- {
+		w := b.order[i]
+		for _, p := range b.preds[w] {
+			share := b.sigma[p.node] / b.sigma[w] * (1 + b.delta[w])
+			c.Edges[p.node][p.index] += share
+			b.delta[p.node] += share
+		}
+		if w != s {
+			c.Nodes[w] += b.delta[w]
+		}
+	}
+}
+
+// search runs a single-source shortest path search from s, recording the cost of every node, the number
+// of shortest paths reaching it and its predecessors on them, and the settlement order.
+func (b *brandes) search(g graph_search.Graph, s int32) {
+	for v := range b.dist {
+		b.dist[v] = float32(math.Inf(1))
+		b.sigma[v], b.delta[v], b.settled[v] = 0, 0, false
+		b.preds[v] = b.preds[v][:0]
+	}
+	b.order = b.order[:0]
+	b.dist[s], b.sigma[s] = 0, 1
+
+	pq := graph_search.Create()
+	pq.Insert(graph_search.HNode{Value: s})
+	for !pq.IsEmpty() {
+		min, _ := pq.Min()
+		pq.DeleteMin()
+		v := min.Value
+		if b.settled[v] {
+			continue
+		}
+		b.settled[v] = true
+		b.order = append(b.order, v)
+
+		for i, e := range g.OutgoingEdges[v] {
+			w := e.ID
+			if b.settled[w] {
+				continue
+			}
+			cost := b.dist[v] + e.Weight
+			switch {
+			case cost < b.dist[w]:
+				b.dist[w], b.sigma[w] = cost, b.sigma[v]
+				b.preds[w] = append(b.preds[w][:0], predecessor{node: v, index: i})
+				pq.Insert(graph_search.HNode{Value: w, Cost: cost})
+			case cost == b.dist[w]:
+				b.sigma[w] += b.sigma[v]
+				b.preds[w] = append(b.preds[w], predecessor{node: v, index: i})
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package analytics
+
+import (
+	"testing"
+
+	"graph_search"
+)
+
+func TestBetweenness(t *testing.T) {
+	//   b
+	//  / \
+	// a   d -- e
+	//  \ /
+	//   c
+	g := graph_search.EmptyGraph()
+	for i := 0; i < 5; i++ {
+		g.AddNode(graph_search.Node{})
+	}
+	a, b, c, d, e := g.Nodes[0], g.Nodes[1], g.Nodes[2], g.Nodes[3], g.Nodes[4]
+	g.RelateNodes(a, b, 1, graph_search.Bidirectional, graph_search.MetaData{})
+	g.RelateNodes(a, c, 1, graph_search.Bidirectional, graph_search.MetaData{})
+	g.RelateNodes(b, d, 1, graph_search.Bidirectional, graph_search.MetaData{})
+	g.RelateNodes(c, d, 1, graph_search.Bidirectional, graph_search.MetaData{})
+	g.RelateNodes(d, e, 1, graph_search.Bidirectional, graph_search.MetaData{})
+
+	centrality := Betweenness(g, CentralityOptions{})
+
+	// d lies on every path between e and {a, b, c} in both directions, plus half of the paths b <-> c.
+	if got := centrality.Nodes[d.ID]; got != 7 {
+		t.Fatalf("got betweenness %f for d, expected 7", got)
+	}
+	// b and c share the two paths a <-> d and the two paths a <-> e.
+	if got := centrality.Nodes[b.ID]; got != 2 {
+		t.Fatalf("got betweenness %f for b, expected 2", got)
+	}
+	top := centrality.TopEdges(2)
+	for _, edge := range top {
+		if edge.From != d.ID && edge.From != e.ID {
+			t.Fatalf("got critical edge leaving %d, expected the bridge d <-> e", edge.From)
+		}
+	}
+
+	sampled := Betweenness(g, CentralityOptions{Samples: 2, Seed: 1})
+	if len(sampled.Nodes) != len(g.Nodes) {
+		t.Fatalf("got %d node scores, expected %d", len(sampled.Nodes), len(g.Nodes))
+	}
+}
+
+func TestCountShortestPaths(t *testing.T) {
+	// A 3x3 lattice with unit weights: node r*3+c reaches node 8 through C(4, 2) = 6 monotone paths.
+	g := graph_search.EmptyGraph()
+	for i := 0; i < 10; i++ {
+		g.AddNode(graph_search.Node{})
+	}
+	for r := 0; r < 3; r++ {
+		for c := 0; c < 3; c++ {
+			v := g.Nodes[r*3+c]
+			if c < 2 {
+				g.RelateNodes(v, g.Nodes[r*3+c+1], 1, graph_search.Bidirectional, graph_search.MetaData{Distance: 100})
+			}
+			if r < 2 {
+				g.RelateNodes(v, g.Nodes[r*3+c+3], 1, graph_search.Bidirectional, graph_search.MetaData{Distance: 100})
+			}
+		}
+	}
+	counts := CountShortestPaths(g, 0)
+	for v, expected := range map[int]float64{0: 1, 1: 1, 4: 2, 5: 3, 8: 6, 9: 0} {
+		if counts[v] != expected {
+			t.Errorf("got %v shortest paths to %d, expected %v", counts[v], v, expected)
+		}
+	}
+
+	o := RouteOverlap(g, []int32{0, 1, 2, 5, 8}, []int32{0, 1, 4, 5, 8})
+	if o.Shared != 200 || o.First != 400 || o.Coefficient != 0.5 || o.Jaccard != 200.0/600 {
+		t.Errorf("got %+v, expected the two routes to share 200 m of 400", o)
+	}
+	if o := RouteOverlap(g, []int32{0, 1}, []int32{1, 0}); o.Shared != 0 || o.Coefficient != 0 {
+		t.Errorf("got %+v, expected opposite directions not to overlap", o)
+	}
+}
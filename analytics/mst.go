@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"sort"
+
+	"graph_search"
+)
+
+// TreeEdge is an edge of a spanning tree, treating the road graph as undirected.
+type TreeEdge struct {
+	From   int32   // Endpoint with the lowest node ID
+	To     int32   // Endpoint with the highest node ID
+	Weight float32 // Weight of the cheapest edge between both endpoints, in either direction
+}
+
+// MinimumSpanningForest computes a minimum spanning tree of every connected component of the graph with
+// Kruskal's algorithm over the edge weights. Edge directions are ignored: a pair of nodes connected in
+// either direction is considered connected, with the weight of its cheapest edge.
+//
+// Parameters:
+//   - g: graph_search.Graph - The graph to span
+//
+// Returns:
+//   - []TreeEdge: The edges of the forest, len(g.Nodes) minus the number of components
+//   - float32: The total weight of the forest
+func MinimumSpanningForest(g graph_search.Graph) ([]TreeEdge, float32) {
+	cheapest := make(map[[2]int32]float32)
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			key := [2]int32{min(int32(from), e.ID), max(int32(from), e.ID)}
+			if key[0] == key[1] {
+				continue
+			}
+			if w, ok := cheapest[key]; !ok || e.Weight < w {
+				cheapest[key] = e.Weight
+			}
+		}
+	}
+	candidates := make([]TreeEdge, 0, len(cheapest))
+	for key, w := range cheapest {
+		candidates = append(candidates, TreeEdge{From: key[0], To: key[1], Weight: w})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		if a.From != b.From {
+			return a.From < b.From
+		}
+		return a.To < b.To
+	})
+
+	components := newDisjointSet(len(g.Nodes))
+	forest := make([]TreeEdge, 0, len(g.Nodes))
+	total := float32(0)
+	for _, e := range candidates {
+		if components.union(e.From, e.To) {
+			forest = append(forest, e)
+			total += e.Weight
+		}
+	}
+	return forest, total
+}
+
+// disjointSet is a union-find structure with path halving and union by size.
+type disjointSet struct {
+	parent []int32
+	size   []int32
+}
+
+// newDisjointSet creates n singleton sets.
+func newDisjointSet(n int) disjointSet {
+	ds := disjointSet{parent: make([]int32, n), size: make([]int32, n)}
+	for i := range ds.parent {
+		ds.parent[i], ds.size[i] = int32(i), 1
+	}
+	return ds
+}
+
+// find returns the representative of the set containing x.
+func (ds disjointSet) find(x int32) int32 {
+	for ds.parent[x] != x {
+		ds.parent[x] = ds.parent[ds.parent[x]]
+		x = ds.parent[x]
+	}
+	return x
+}
+
+// union merges the sets containing a and b, reporting false if they were already the same set.
+func (ds disjointSet) union(a, b int32) bool {
+	a, b = ds.find(a), ds.find(b)
+	if a == b {
+		return false
+	}
+	if ds.size[a] < ds.size[b] {
+		a, b = b, a
+	}
+	ds.parent[b] = a
+	ds.size[a] += ds.size[b]
+	return true
+}
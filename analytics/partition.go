@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"math"
+	"sort"
+
+	"graph_search"
+)
+
+// Partition assigns every node of a graph to one of a fixed number of cells.
+type Partition struct {
+	// Cells[v] is the cell node v belongs to, in [0, Parts).
+	Cells []int32
+
+	// Parts is the number of cells.
+	Parts int
+}
+
+// InertialBisection partitions the graph into parts cells of balanced size by recursive inertial
+// bisection: the nodes are projected onto the principal axis of their coordinates, the direction
+// along which they spread the most, and split at the weighted median, so cuts are roughly
+// perpendicular to the longest side of each region. It only looks at coordinates, which keeps it
+// fast and dependency free at the cost of larger cuts than graph-aware partitioners.
+//
+// Parameters:
+//   - g: graph_search.Graph - The graph to partition
+//   - parts: int - Number of cells, at least 1
+//
+// Returns:
+//   - Partition: The cell of every node. Cell sizes differ by at most one node per bisection level
+func InertialBisection(g graph_search.Graph, parts int) Partition {
+	parts = max(parts, 1)
+	points := make([]point, len(g.Nodes))
+	for i, n := range g.Nodes {
+		ll := n.GetPoint()
+		x, y := graph_search.LatLngToMeters(ll.Lat.Degrees(), ll.Lng.Degrees())
+		points[i] = point{id: int32(i), x: x, y: y}
+	}
+	p := Partition{Cells: make([]int32, len(g.Nodes)), Parts: parts}
+	bisect(points, 0, parts, p.Cells)
+	return p
+}
+
+// CutEdges counts the edges whose endpoints lie in different cells, the communication cost of processing
+// the cells in parallel.
+func (p Partition) CutEdges(g graph_search.Graph) int {
+	cut := 0
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			if p.Cells[from] != p.Cells[e.ID] {
+				cut++
+			}
+		}
+	}
+	return cut
+}
+
+// point is a node projected to Mercator meters.
+type point struct {
+	id   int32
+	x, y float64
+}
+
+// bisect assigns the cells [first, first+parts) to points, splitting them along their principal axis
+// in proportion to the number of cells on each side.
+func bisect(points []point, first, parts int, cells []int32) {
+	if parts == 1 || len(points) == 0 {
+		for _, p := range points {
+			cells[p.id] = int32(first)
+		}
+		return
+	}
+	ax, ay := principalAxis(points)
+	sort.Slice(points, func(i, j int) bool {
+		a, b := points[i].x*ax+points[i].y*ay, points[j].x*ax+points[j].y*ay
+		if a != b {
+			return a < b
+		}
+		return points[i].id < points[j].id
+	})
+	left := parts / 2
+	split := len(points) * left / parts
+	bisect(points[:split], first, left, cells)
+	bisect(points[split:], first+left, parts-left, cells)
+}
+
+// principalAxis returns the unit vector along which the points have the largest variance, the eigenvector
+// of the largest eigenvalue of their 2x2 covariance matrix.
+func principalAxis(points []point) (float64, float64) {
+	var mx, my float64
+	for _, p := range points {
+		mx, my = mx+p.x, my+p.y
+	}
+	mx, my = mx/float64(len(points)), my/float64(len(points))
+	var sxx, syy, sxy float64
+	for _, p := range points {
+		dx, dy := p.x-mx, p.y-my
+		sxx, syy, sxy = sxx+dx*dx, syy+dy*dy, sxy+dx*dy
+	}
+	angle := 0.5 * math.Atan2(2*sxy, sxx-syy)
+	return math.Cos(angle), math.Sin(angle)
+}
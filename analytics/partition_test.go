@@ -0,0 +1,39 @@
+package analytics
+
+import (
+	"math"
+	"testing"
+
+	"graph_search"
+)
+
+func TestMinimumSpanningForest(t *testing.T) {
+	g := graph_search.GridGraph(4, 4, 100)
+	forest, total := MinimumSpanningForest(g)
+	if len(forest) != len(g.Nodes)-1 {
+		t.Fatalf("got %d tree edges, expected %d", len(forest), len(g.Nodes)-1)
+	}
+	// Grid spacing is in Mercator meters, slightly more than ground meters away from the equator.
+	if math.Abs(float64(total)-1500) > 15 {
+		t.Fatalf("got total weight %f, expected about 1500", total)
+	}
+}
+
+func TestInertialBisection(t *testing.T) {
+	g := graph_search.GridGraph(2, 8, 100)
+	p := InertialBisection(g, 4)
+
+	sizes := make([]int, p.Parts)
+	for _, cell := range p.Cells {
+		sizes[cell]++
+	}
+	for cell, size := range sizes {
+		if size != 4 {
+			t.Fatalf("cell %d has %d nodes, expected 4", cell, size)
+		}
+	}
+	// Cutting the 2x8 grid into four 2x2 squares crosses 3 columns of 2 bidirectional edges.
+	if cut := p.CutEdges(g); cut != 12 {
+		t.Fatalf("got %d cut edges, expected 12", cut)
+	}
+}
@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"math"
+
+	"graph_search"
+)
+
+// CountShortestPaths counts the distinct shortest paths from a source to every node, the redundancy of
+// the network between them: a single shortest path means any disruption along it lengthens the trip.
+// Edge weights are used as costs, so they must not be negative; only paths of exactly equal cost count.
+//
+// Parameters:
+//   - g: graph_search.Graph - The graph to analyze
+//   - source: int32 - ID of the node the paths start from
+//
+// Returns:
+//   - []float64: The number of shortest paths from source to every node, 0 for unreachable nodes and 1
+//     for the source itself
+func CountShortestPaths(g graph_search.Graph, source int32) []float64 {
+	b := newBrandes(len(g.Nodes))
+	b.search(g, source)
+	counts := make([]float64, len(g.Nodes))
+	copy(counts, b.sigma)
+	return counts
+}
+
+// Overlap compares two routes by the length of the road they share.
+type Overlap struct {
+	Shared float64 // Distance in meters covered by both routes
+	First  float64 // Length in meters of the first route
+	Second float64 // Length in meters of the second route
+
+	// Coefficient is the overlap coefficient, Shared divided by the length of the shorter route: 1 when
+	// a route is contained in the other, 0 when they share no edge.
+	Coefficient float64
+
+	// Jaccard is Shared divided by the length covered by either route.
+	Jaccard float64
+}
+
+// RouteOverlap measures how much two routes, given as the sequences of nodes they visit, share the same
+// directed edges, e.g., to quantify the diversity of alternative routes or the redundancy of the paths
+// an assignment loads. Edges are measured by MetaData.Distance, or counted as 1 meter each when the
+// graph has no distances.
+//
+// Parameters:
+//   - g: graph_search.Graph - The graph the routes belong to
+//   - first: []int32 - Nodes of the first route, e.g., Route.Nodes
+//   - second: []int32 - Nodes of the second route
+//
+// Returns:
+//   - Overlap: The shared and total lengths and the overlap coefficients, all zero for empty routes
+func RouteOverlap(g graph_search.Graph, first, second []int32) Overlap {
+	type edge struct{ from, to int32 }
+	lengths := func(nodes []int32) map[edge]float64 {
+		edges := make(map[edge]float64)
+		for i := 1; i < len(nodes); i++ {
+			edges[edge{nodes[i-1], nodes[i]}] = edgeLength(g, nodes[i-1], nodes[i])
+		}
+		return edges
+	}
+	a, b := lengths(first), lengths(second)
+
+	o := Overlap{}
+	for e, length := range a {
+		o.First += length
+		if _, ok := b[e]; ok {
+			o.Shared += length
+		}
+	}
+	for _, length := range b {
+		o.Second += length
+	}
+	if shorter := math.Min(o.First, o.Second); shorter > 0 {
+		o.Coefficient = o.Shared / shorter
+	}
+	if union := o.First + o.Second - o.Shared; union > 0 {
+		o.Jaccard = o.Shared / union
+	}
+	return o
+}
+
+// edgeLength returns the distance of the shortest edge from one node to another, 1 if it has none, 0 if
+// there is no such edge.
+func edgeLength(g graph_search.Graph, from, to int32) float64 {
+	length := math.Inf(1)
+	for _, e := range g.OutgoingEdges[from] {
+		if e.ID == to {
+			length = math.Min(length, float64(e.Metadata.Distance))
+		}
+	}
+	switch {
+	case math.IsInf(length, 1):
+		return 0
+	case length <= 0:
+		return 1
+	}
+	return length
+}
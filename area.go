@@ -0,0 +1,96 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+var (
+	ErrEmptyArea = errors.New("no node inside the area")
+)
+
+// NodesInPolygon returns the nodes of a spatial index lying inside a polygon. Candidates come from a
+// range query around the bounding box of the polygon, then go through a point-in-polygon test.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex or BuildNodeIndexWithMetric
+//   - polygon: GeoJSONPolygon - The area, nodes in its holes are left out
+//
+// Returns:
+//   - []int32: The IDs of the nodes inside the polygon, in no particular order
+func (g Graph) NodesInPolygon(index *KDTree, polygon GeoJSONPolygon) []int32 {
+	nodes := make([]int32, 0)
+	if len(polygon.Rings) == 0 || len(polygon.Rings[0]) == 0 {
+		return nodes
+	}
+	minLat, minLng, maxLat, maxLng := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, position := range polygon.Rings[0] {
+		minLat, maxLat = math.Min(minLat, position[1]), math.Max(maxLat, position[1])
+		minLng, maxLng = math.Min(minLng, position[0]), math.Max(maxLng, position[0])
+	}
+	// The polygon lies within the convex hull of its exterior ring, so within the distance from the
+	// center of its box to the farthest vertex
+	metric := index.Metric()
+	center := NewVector(-1, geoComponents(metric, Coordinate{Lat: (minLat + maxLat) / 2, Lng: (minLng + maxLng) / 2}))
+	radius := 0.0
+	for _, position := range polygon.Rings[0] {
+		vertex := NewVector(-1, geoComponents(metric, Coordinate{Lat: position[1], Lng: position[0]}))
+		radius = math.Max(radius, metric.Distance(center, vertex))
+	}
+	for _, v := range index.RangeQuery(center, radius) {
+		if v.ID >= 0 && v.ID < len(g.Nodes) && polygon.Contains(nodeCoordinate(g.Nodes[v.ID])) {
+			nodes = append(nodes, int32(v.ID))
+		}
+	}
+	return nodes
+}
+
+// RouteToPolygon finds the least-cost route from a node to an area such as a park or a campus: the
+// nodes inside the polygon are the targets of a single search, stopped at the first of them it settles,
+// so the route ends at the closest entrance. The closures set with SetClosures are applied.
+//
+// Parameters:
+//   - source: int32 - ID of the node the route starts from
+//   - polygon: GeoJSONPolygon - The area to reach
+//   - c: Criteria - Restrictions of the search, its Source and Targets are replaced
+//
+// Returns:
+//   - BatchResult: The route to the node of the area reached first, which is the Target of its Pair;
+//     Err wraps ErrEmptyArea if no node of the index lies inside the polygon, or is ErrNodeNotFound or
+//     ErrNoRoute like for Route
+func (e *Engine) RouteToPolygon(source int32, polygon GeoJSONPolygon, c Criteria) BatchResult {
+	result := BatchResult{Pair: ODPair{Source: source, Target: -1}}
+	if source < 0 || int(source) >= len(e.graph.Nodes) {
+		result.Err = ErrNodeNotFound
+		return result
+	}
+	targets := e.graph.NodesInPolygon(e.Index(), polygon)
+	if len(targets) == 0 {
+		result.Err = fmt.Errorf("polygon %s: %w", polygon.ID, ErrEmptyArea)
+		return result
+	}
+
+	c.Source, c.Targets, c.AnyTarget = []int32{source}, targets, true
+	if c.Metrics == nil {
+		c.Metrics = e.metrics
+	}
+	if e.closures != nil {
+		c = e.closures.Apply(c)
+	}
+	response := NewDijkstra(c).Run(e.graph)
+	if response.Err != nil {
+		result.Err = response.Err
+		return result
+	}
+	settled := response.SearchSpace.Nodes
+	if len(settled) == 0 || !slices.Contains(targets, settled[len(settled)-1].Rank) {
+		result.Err = ErrNoRoute
+		return result
+	}
+	result.Pair.Target = settled[len(settled)-1].Rank
+	result.Cost, _ = response.Costs.GetCost(result.Pair.Target)
+	result.Route = response.SearchSpace.Route(int32(len(settled)-1), e.graph)
+	return result
+}
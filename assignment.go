@@ -0,0 +1,32 @@
+package graph_search
+
+// origins maps every node of the search space, by original node ID, to the source at the root of its
+// branch. Nodes are added to the search space after their parent, so a single pass in order suffices.
+func (sp SearchSpace) origins() map[int32]int32 {
+	root := make([]int32, len(sp.Nodes))
+	origins := make(map[int32]int32, len(sp.Nodes))
+	for i, n := range sp.Nodes {
+		root[i] = n.Rank
+		if len(sp.IncomingEdges[i]) > 0 {
+			root[i] = root[sp.IncomingEdges[i][0].ID]
+		}
+		origins[n.Rank] = root[i]
+	}
+	return origins
+}
+
+// Facilities groups the settled nodes by the source they were reached from, the service area of every
+// facility of a multi-source search run with Criteria.AssignToNearestSource.
+//
+// Returns:
+//   - map[int32][]int32: The nodes of every source in settlement order, sources included; empty if the
+//     search did not assign nodes to sources
+func (r Response) Facilities() map[int32][]int32 {
+	facilities := make(map[int32][]int32)
+	for _, n := range r.SearchSpace.Nodes {
+		if source, ok := r.NearestSource[n.Rank]; ok {
+			facilities[source] = append(facilities[source], n.Rank)
+		}
+	}
+	return facilities
+}
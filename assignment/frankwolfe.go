@@ -0,0 +1,260 @@
+// Package assignment loads origin-destination demand onto graph_search road graphs for transport
+// modeling: all-or-nothing assignment on shortest paths and user equilibrium with the Frank-Wolfe
+// algorithm. Edge weights are free-flow travel times and capacities are read from MetaData.Capacity.
+package assignment
+
+import (
+	"math"
+
+	"graph_search"
+)
+
+// Demand is the volume of trips between two nodes, e.g., vehicles per hour.
+type Demand struct {
+	Origin      int32
+	Destination int32
+	Volume      float64
+}
+
+// BPR is the volume-delay function of the Bureau of Public Roads: the travel time of an edge loaded
+// with volume v is t0 * (1 + Alpha * (v/c)^Beta), t0 being its free-flow time and c its capacity.
+type BPR struct {
+	Alpha float64
+	Beta  float64
+}
+
+// DefaultBPR holds the classic parameters of the BPR function.
+var DefaultBPR = BPR{Alpha: 0.15, Beta: 4}
+
+// TravelTime returns the travel time of an edge. Edges without capacity are never congested.
+func (f BPR) TravelTime(freeFlow, volume, capacity float64) float64 {
+	if capacity <= 0 {
+		return freeFlow
+	}
+	return freeFlow * (1 + f.Alpha*math.Pow(volume/capacity, f.Beta))
+}
+
+// Options configures a Frank-Wolfe assignment.
+type Options struct {
+	// Function is the volume-delay function of every edge, DefaultBPR when zero.
+	Function BPR
+
+	// MaxIterations bounds the number of Frank-Wolfe iterations, DefaultMaxIterations when zero.
+	MaxIterations int
+
+	// RelativeGap stops the iterations once the relative gap falls below it, DefaultRelativeGap when zero.
+	RelativeGap float64
+}
+
+const (
+	DefaultMaxIterations = 100
+	DefaultRelativeGap   = 1e-4
+)
+
+// Result is the outcome of an assignment.
+type Result struct {
+	// Volumes[v][i] is the volume loaded onto the edge g.OutgoingEdges[v][i].
+	Volumes [][]float64
+
+	// Times[v][i] is the travel time of the edge g.OutgoingEdges[v][i] under its volume.
+	Times [][]float64
+
+	// Unassigned is the demand between nodes that cannot reach each other, left out of Volumes.
+	Unassigned float64
+
+	// Iterations is the number of Frank-Wolfe iterations run, 0 for an all-or-nothing assignment.
+	Iterations int
+
+	// Gap is the relative gap of the final volumes: the share of the total travel time users would save
+	// by switching to the current shortest paths, 0 at equilibrium.
+	Gap float64
+}
+
+// AllOrNothing loads every demand onto the shortest path between its nodes under free-flow times,
+// ignoring congestion. It runs one search per origin.
+//
+// Parameters:
+//   - g: graph_search.Graph - The network, weighted by free-flow travel times
+//   - demands: []Demand - The trips to assign
+//
+// Returns:
+//   - Result: The volumes, with the free-flow times of the edges
+func AllOrNothing(g graph_search.Graph, demands []Demand) Result {
+	times := freeFlowTimes(g)
+	volumes, unassigned := allOrNothing(g, demands, times)
+	return Result{Volumes: volumes, Times: times, Unassigned: unassigned}
+}
+
+// FrankWolfe computes the user equilibrium of the demand, where no user can shorten a trip by switching
+// routes: every iteration loads the demand all-or-nothing on the shortest paths under the current
+// congested times, then moves the volumes towards that loading by the step minimizing the Beckmann
+// objective of the BPR function.
+//
+// Parameters:
+//   - g: graph_search.Graph - The network, weighted by free-flow travel times
+//   - demands: []Demand - The trips to assign
+//   - opts: Options - Volume-delay function and stopping criteria
+//
+// Returns:
+//   - Result: The equilibrium volumes and congested times
+func FrankWolfe(g graph_search.Graph, demands []Demand, opts Options) Result {
+	if opts.Function == (BPR{}) {
+		opts.Function = DefaultBPR
+	}
+	if opts.MaxIterations <= 0 {
+		opts.MaxIterations = DefaultMaxIterations
+	}
+	if opts.RelativeGap <= 0 {
+		opts.RelativeGap = DefaultRelativeGap
+	}
+	free := freeFlowTimes(g)
+	volumes, unassigned := allOrNothing(g, demands, free)
+	r := Result{Volumes: volumes, Unassigned: unassigned}
+	for r.Iterations < opts.MaxIterations {
+		r.Times = congestedTimes(g, free, r.Volumes, opts.Function)
+		target, _ := allOrNothing(g, demands, r.Times)
+
+		current, best := 0.0, 0.0
+		for v := range r.Volumes {
+			for i := range r.Volumes[v] {
+				current += r.Times[v][i] * r.Volumes[v][i]
+				best += r.Times[v][i] * target[v][i]
+			}
+		}
+		r.Gap = 0
+		if current > 0 {
+			r.Gap = (current - best) / current
+		}
+		if r.Gap < opts.RelativeGap {
+			break
+		}
+
+		step := lineSearch(g, free, r.Volumes, target, opts.Function)
+		for v := range r.Volumes {
+			for i := range r.Volumes[v] {
+				r.Volumes[v][i] += step * (target[v][i] - r.Volumes[v][i])
+			}
+		}
+		r.Iterations++
+	}
+	r.Times = congestedTimes(g, free, r.Volumes, opts.Function)
+	return r
+}
+
+// lineSearch returns the step in [0, 1] minimizing the Beckmann objective between the current volumes
+// and the all-or-nothing target, by bisection on its derivative.
+func lineSearch(g graph_search.Graph, free, volumes, target [][]float64, f BPR) float64 {
+	derivative := func(step float64) float64 {
+		sum := 0.0
+		for v, edges := range g.OutgoingEdges {
+			for i, e := range edges {
+				d := target[v][i] - volumes[v][i]
+				sum += d * f.TravelTime(free[v][i], volumes[v][i]+step*d, float64(e.Metadata.Capacity))
+			}
+		}
+		return sum
+	}
+	low, high := 0.0, 1.0
+	if derivative(high) <= 0 {
+		return high
+	}
+	for k := 0; k < 40; k++ {
+		middle := (low + high) / 2
+		if derivative(middle) > 0 {
+			high = middle
+		} else {
+			low = middle
+		}
+	}
+	return (low + high) / 2
+}
+
+// allOrNothing loads every demand onto its shortest path under the given edge times. It returns the
+// volumes and the demand that could not be routed.
+func allOrNothing(g graph_search.Graph, demands []Demand, times [][]float64) ([][]float64, float64) {
+	volumes := make([][]float64, len(g.OutgoingEdges))
+	weights := make([][]float32, len(g.OutgoingEdges))
+	for v := range volumes {
+		volumes[v] = make([]float64, len(g.OutgoingEdges[v]))
+		weights[v] = make([]float32, len(g.OutgoingEdges[v]))
+		for i := range weights[v] {
+			weights[v][i] = float32(times[v][i])
+		}
+	}
+
+	byOrigin := make(map[int32][]Demand)
+	origins := make([]int32, 0)
+	for _, d := range demands {
+		if _, ok := byOrigin[d.Origin]; !ok {
+			origins = append(origins, d.Origin)
+		}
+		byOrigin[d.Origin] = append(byOrigin[d.Origin], d)
+	}
+
+	unassigned := 0.0
+	for _, origin := range origins {
+		targets := make([]int32, 0, len(byOrigin[origin]))
+		for _, d := range byOrigin[origin] {
+			targets = append(targets, d.Destination)
+		}
+		response := graph_search.NewDijkstra(graph_search.Criteria{
+			Source:      []int32{origin},
+			Targets:     targets,
+			AllTargets:  true,
+			EdgeWeights: weights,
+		}).Run(g)
+		settled := make(map[int32]int32, len(response.SearchSpace.Nodes))
+		for id, n := range response.SearchSpace.Nodes {
+			settled[n.Rank] = int32(id)
+		}
+		for _, d := range byOrigin[origin] {
+			id, ok := settled[d.Destination]
+			if !ok {
+				unassigned += d.Volume
+				continue
+			}
+			path := response.SearchSpace.PathNodes(id)
+			for k := 1; k < len(path); k++ {
+				if i := cheapestEdge(g, weights, path[k-1], path[k]); i >= 0 {
+					volumes[path[k-1]][i] += d.Volume
+				}
+			}
+		}
+	}
+	return volumes, unassigned
+}
+
+// cheapestEdge returns the position of the cheapest edge from one node to another, -1 if there is none.
+func cheapestEdge(g graph_search.Graph, weights [][]float32, from, to int32) int {
+	best := -1
+	for i, e := range g.OutgoingEdges[from] {
+		if e.ID == to && (best < 0 || weights[from][i] < weights[from][best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// freeFlowTimes returns the weights of the edges of the graph.
+func freeFlowTimes(g graph_search.Graph) [][]float64 {
+	times := make([][]float64, len(g.OutgoingEdges))
+	for v, edges := range g.OutgoingEdges {
+		times[v] = make([]float64, len(edges))
+		for i, e := range edges {
+			times[v][i] = float64(e.Weight)
+		}
+	}
+	return times
+}
+
+// congestedTimes returns the travel times of the edges under the given volumes.
+func congestedTimes(g graph_search.Graph, free, volumes [][]float64, f BPR) [][]float64 {
+	times := make([][]float64, len(g.OutgoingEdges))
+	for v, edges := range g.OutgoingEdges {
+		times[v] = make([]float64, len(edges))
+		for i, e := range edges {
+			times[v][i] = f.TravelTime(free[v][i], volumes[v][i], float64(e.Metadata.Capacity))
+		}
+	}
+	return times
+}
@@ -0,0 +1,42 @@
+package assignment
+
+import (
+	"math"
+	"testing"
+
+	"graph_search"
+)
+
+func TestFrankWolfe(t *testing.T) {
+	// Two parallel roads from 0 to 1: a fast one (10) and a slow one (15), both of capacity 100.
+	g := graph_search.EmptyGraph()
+	for i := 0; i < 3; i++ {
+		g.AddNode(graph_search.Node{})
+	}
+	relate := func(from, to int32, weight float32, capacity float32) {
+		g.RelateNodes(g.Nodes[from], g.Nodes[to], weight, graph_search.LeftToRight, graph_search.MetaData{Capacity: capacity})
+	}
+	relate(0, 1, 10, 100)
+	relate(0, 1, 15, 100)
+	demands := []Demand{{Origin: 0, Destination: 1, Volume: 300}, {Origin: 0, Destination: 2, Volume: 20}}
+
+	aon := AllOrNothing(g, demands)
+	if aon.Volumes[0][0] != 300 || aon.Volumes[0][1] != 0 || aon.Unassigned != 20 {
+		t.Fatalf("all-or-nothing loaded %v with %f unassigned", aon.Volumes[0], aon.Unassigned)
+	}
+
+	r := FrankWolfe(g, demands, Options{RelativeGap: 1e-6, MaxIterations: 500})
+	if total := r.Volumes[0][0] + r.Volumes[0][1]; math.Abs(total-300) > 1e-6 {
+		t.Fatalf("got total volume %f, expected 300", total)
+	}
+	if r.Volumes[0][1] <= 0 {
+		t.Fatal("the congested fast road should shift volume onto the slow one")
+	}
+	fast, slow := r.Times[0][0], r.Times[0][1]
+	if math.Abs(fast-slow)/fast > 0.01 {
+		t.Fatalf("used roads should have equal times at equilibrium, got %f and %f", fast, slow)
+	}
+	if r.Unassigned != 20 {
+		t.Fatalf("got %f unassigned, expected 20", r.Unassigned)
+	}
+}
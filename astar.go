@@ -0,0 +1,142 @@
+package graph_search
+
+import (
+	"math"
+
+	"github.com/golang/geo/s2"
+)
+
+// Heuristic estimates the cost of the shortest path from a node to the target of a search. A* searches
+// are exact when it never overestimates that cost and never decreases by more than the cost of an edge
+// when following it (a consistent heuristic), like the straight-line distance for costs in meters.
+type Heuristic func(v, target int32) float32
+
+// StraightLineHeuristic estimates costs by the great-circle distance in meters between the nodes. It is
+// consistent for costs in meters, such as ObjectiveDistance and the Edge.Weight of graphs built from OSM.
+//
+// Parameters:
+//   - g: Graph - The graph searched
+//
+// Returns:
+//   - Heuristic: The heuristic
+func StraightLineHeuristic(g Graph) Heuristic {
+	return func(v, target int32) float32 {
+		return DistanceMeters(s2.CellID(g.Nodes[v].Location), s2.CellID(g.Nodes[target].Location))
+	}
+}
+
+// TravelTimeHeuristic estimates costs by the time in seconds needed to cover the great-circle distance
+// between the nodes at a maximum speed. It is consistent for ObjectiveTime as long as no edge is faster.
+//
+// Parameters:
+//   - g: Graph - The graph searched
+//   - maxSpeed: float32 - The largest speed of the graph in km/h
+//
+// Returns:
+//   - Heuristic: The heuristic
+func TravelTimeHeuristic(g Graph, maxSpeed float32) Heuristic {
+	distance := StraightLineHeuristic(g)
+	return func(v, target int32) float32 {
+		return distance(v, target) / (maxSpeed * MetersInAKilometer / SecondsInAnHour)
+	}
+}
+
+// FastestSpeed returns the largest speed in km/h at which the straight line between the endpoints of an
+// edge is covered at static speeds, the speed TravelTimeHeuristic needs to be consistent for ObjectiveTime.
+// It visits every edge: compute it once per graph and set Criteria.Heuristic rather than relying on the
+// default heuristic of Epsilon searches.
+//
+// Returns:
+//   - float32: The speed in km/h, +Inf if an edge takes no time, 0 for a graph without edges
+func (g Graph) FastestSpeed() float32 {
+	return g.fastestSpeed(func(e Edge) float64 { return float64(ObjectiveTime.Weight(e)) })
+}
+
+// fastestSpeed returns the largest speed in km/h at which the straight line between the endpoints of an
+// edge is covered, given the shortest time the edge can take.
+func (g Graph) fastestSpeed(seconds func(e Edge) float64) float32 {
+	fastest := 0.0
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			meters := float64(DistanceMeters(s2.CellID(g.Nodes[from].Location), s2.CellID(g.Nodes[e.ID].Location)))
+			if meters == 0 {
+				continue
+			}
+			t := seconds(e)
+			if t <= 0 {
+				return float32(math.Inf(1))
+			}
+			fastest = math.Max(fastest, meters/t)
+		}
+	}
+	return float32(fastest * SecondsInAnHour / MetersInAKilometer)
+}
+
+// profileSeconds returns the shortest time ProfileWeight may give an edge, at its fastest hour.
+func profileSeconds(e Edge) float64 {
+	fastest := float64(e.Metadata.Speed)
+	if fastest <= 0 {
+		fastest = AvgSpeedCar
+	}
+	if e.Metadata.Weekly != nil {
+		fastest = 0
+		for _, speed := range e.Metadata.Weekly.Speeds() {
+			fastest = math.Max(fastest, float64(speed))
+		}
+	} else if e.Metadata.Profile != nil {
+		fastest = 0
+		for _, speed := range e.Metadata.Profile {
+			fastest = math.Max(fastest, float64(speed))
+		}
+	}
+	if fastest <= 0 {
+		return math.Inf(1) // the edge is never open
+	}
+	return float64(e.Metadata.Distance) / (fastest * MetersInAKilometer / SecondsInAnHour)
+}
+
+// speedHeuristic returns TravelTimeHeuristic for a fastest speed, nil when there is no edge to bound.
+func speedHeuristic(g Graph, fastest float32) Heuristic {
+	if fastest <= 0 {
+		return nil
+	}
+	return TravelTimeHeuristic(g, fastest)
+}
+
+// defaultHeuristic returns the heuristic of Epsilon searches without Heuristic, nil when the costs of the
+// search are not known to be bounded by it: StraightLineHeuristic for costs in meters, that is
+// ObjectiveDistance and the Edge.Weight of graphs built from OSM or generated, and TravelTimeHeuristic at
+// the FastestSpeed of the graph for ObjectiveTime. Custom cost models and edge weights, and overlays
+// lowering some costs, have no default.
+func (search *DijkstraSearch) defaultHeuristic(g Graph) Heuristic {
+	c := search.criteria
+	if c.CostModel != nil || c.EdgeWeights != nil || c.Overlay.lowers() {
+		return nil
+	}
+	if c.Objective == ObjectiveTime {
+		return speedHeuristic(g, g.FastestSpeed())
+	}
+	return StraightLineHeuristic(g)
+}
+
+// selectHeuristic turns the search into an A* search weighted by 1 + Epsilon when the criteria set a
+// Heuristic or a positive Epsilon and a single target to stop at. Epsilon without Heuristic uses the
+// heuristic returned by fallback, and is ignored when it returns nil, so the (1 + Epsilon) bound holds.
+func (search *DijkstraSearch) selectHeuristic(fallback func() Heuristic) {
+	c := search.criteria
+	search.estimate = nil
+	if search.target < 0 || search.targets != nil || (c.Heuristic == nil && c.Epsilon <= 0) {
+		return
+	}
+	h := c.Heuristic
+	if h == nil {
+		if h = fallback(); h == nil {
+			return
+		}
+	}
+	weight := float32(1 + math.Max(c.Epsilon, 0))
+	target := search.target
+	search.estimate = func(v int32) float32 {
+		return weight * h(v, target)
+	}
+}
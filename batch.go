@@ -0,0 +1,122 @@
+package graph_search
+
+import (
+	"errors"
+	"math"
+	"runtime"
+	"sync"
+)
+
+var (
+	ErrNoRoute = errors.New("no route between source and target")
+)
+
+// BatchResult is the outcome of one origin-destination query of a batch.
+type BatchResult struct {
+	Pair  ODPair  // The query this result answers
+	Cost  float32 // Cost of the shortest path, only meaningful when Err is nil
+	Route Route   // The shortest path, only meaningful when Err is nil
+	Err   error   // ErrNodeNotFound if a node of the pair is not in the graph, ErrNoRoute if the target is unreachable
+
+	// A *SearchLimitError may also be reported in Err when the criteria set MaxSettledNodes.
+}
+
+// RouteBatch answers many origin-destination queries concurrently with Route. Every query runs its own search
+// over the shared read-only graph, so callers do not need to manage goroutines or worry about which
+// parts of the engine are safe for concurrent use.
+//
+// Parameters:
+//   - pairs: []ODPair - The queries to answer
+//   - concurrency: int - Maximum number of queries running at the same time, runtime.GOMAXPROCS(0) if not positive
+//
+// Returns:
+//   - []BatchResult: One result per pair, in the same order as pairs
+func (e *Engine) RouteBatch(pairs []ODPair, concurrency int) []BatchResult {
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	results := make([]BatchResult, len(pairs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(pairs)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Every worker reuses its queue and costs across the queries it answers.
+			c := Criteria{Heap: CreateWithCapacity(frontierCapacity(len(e.graph.Nodes))), CostArray: NewCostArray(len(e.graph.Nodes))}
+			for i := range jobs {
+				results[i] = e.Route(pairs[i], c)
+			}
+		}()
+	}
+	for i := range pairs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	return results
+}
+
+// frontierCapacity estimates the size of the priority queue of a search on a graph of n nodes. The
+// frontier of a search on a road network grows with the square root of the number of nodes.
+func frontierCapacity(n int) int {
+	return 4 * int(math.Sqrt(float64(n)))
+}
+
+// Route answers a single origin-destination query, from the route cache when it is enabled. The pair is
+// recorded in the query log whether or not it is answered from the cache. Queries
+// with a custom Criteria.CostModel, Criteria.EdgeWeights, Criteria.Heuristic or Criteria.Corridor are
+// never cached, as they cannot be compared.
+//
+// Parameters:
+//   - p: ODPair - The source and target nodes
+//   - c: Criteria - Restrictions of the search, its Source and Targets are replaced by the pair
+//
+// Returns:
+//   - BatchResult: The shortest path between the pair, or the reason why there is none
+func (e *Engine) Route(p ODPair, c Criteria) BatchResult {
+	return e.route(p, c, true)
+}
+
+// route answers a query like Route, recording it in the query log only when record is set, so
+// precomputations replaying the log do not inflate the counts they rank pairs by.
+func (e *Engine) route(p ODPair, c Criteria, record bool) BatchResult {
+	if record {
+		e.queries.Record(p)
+	}
+	if e.closures != nil {
+		c = e.closures.Apply(c)
+	}
+	key := CacheKey{Source: p.Source, Target: p.Target, Profile: profileKey(c), CostModel: e.costModel.Load()}
+	cacheable := e.cache != nil && c.CostModel == nil && c.EdgeWeights == nil && c.Heuristic == nil && c.Corridor == nil
+	if cacheable {
+		if result, ok := e.cache.Get(key); ok {
+			e.metrics.Count(MetricCacheHits, 1)
+			return result
+		}
+	}
+
+	result := BatchResult{Pair: p}
+	n := int32(len(e.graph.Nodes))
+	if p.Source < 0 || p.Source >= n || p.Target < 0 || p.Target >= n {
+		result.Err = ErrNodeNotFound
+		return result
+	}
+	c.Source, c.Targets = []int32{p.Source}, []int32{p.Target}
+	response := e.search(c)
+	settled := response.SearchSpace.Nodes
+	if response.Err != nil {
+		result.Err = response.Err
+		return result
+	}
+	if len(settled) == 0 || settled[len(settled)-1].Rank != p.Target {
+		result.Err = ErrNoRoute
+	} else {
+		result.Cost, _ = response.Costs.GetCost(p.Target)
+		result.Route = response.SearchSpace.Route(int32(len(settled)-1), e.graph)
+	}
+	if cacheable {
+		e.cache.Put(key, result)
+	}
+	return result
+}
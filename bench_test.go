@@ -0,0 +1,129 @@
+package graph_search
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"runtime"
+	"testing"
+)
+
+var benchmarkSizes = []int{32, 128, 256}
+
+func BenchmarkBuildGraph(b *testing.B) {
+	const path = "testdata/colombia-latest.osm.pbf"
+	if _, err := os.Stat(path); err != nil {
+		b.Skip("missing ", path)
+	}
+	for i := 0; i < b.N; i++ {
+		if _, err := BuildGraph(path); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKDTreeBuild(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := GridGraph(size, size, 50)
+		b.Run(fmt.Sprintf("grid-%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				g.BuildNodeIndex()
+			}
+		})
+	}
+}
+
+func BenchmarkKDTreeFindNearest(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := GridGraph(size, size, 50)
+		index := g.BuildNodeIndex()
+		x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+		rnd := rand.New(rand.NewSource(1))
+		b.Run(fmt.Sprintf("grid-%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				index.FindNearest(NewVector(-1, []float64{
+					x0 + rnd.Float64()*float64(size*50),
+					y0 + rnd.Float64()*float64(size*50),
+				}))
+			}
+		})
+	}
+}
+
+func BenchmarkHeap(b *testing.B) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < b.N; i++ {
+		h := Create()
+		for j := 0; j < 1024; j++ {
+			h.Insert(HNode{Value: int32(j), Cost: rnd.Float32()})
+		}
+		for !h.IsEmpty() {
+			_ = h.DeleteMin()
+		}
+	}
+}
+
+func BenchmarkDijkstra(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := GridGraph(size, size, 50)
+		target := int32(len(g.Nodes) - 1)
+		b.Run(fmt.Sprintf("grid-%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{target}}).Run(g)
+			}
+		})
+	}
+}
+
+func BenchmarkDijkstra_BucketQueue(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := GridGraph(size, size, 50)
+		for _, edges := range g.OutgoingEdges {
+			for i := range edges {
+				edges[i].Weight = 1
+			}
+		}
+		target := int32(len(g.Nodes) - 1)
+		b.Run(fmt.Sprintf("grid-%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{target}, BucketWidth: 1}).Run(g)
+			}
+		})
+	}
+}
+
+func BenchmarkDijkstra_RandomGeometric(b *testing.B) {
+	g := RandomGeometricGraph(10000, 5000, 120, 1)
+	for i := 0; i < b.N; i++ {
+		NewDijkstra(Criteria{Source: []int32{0}}).Run(g)
+	}
+}
+
+func BenchmarkDeltaStepping_RandomGeometric(b *testing.B) {
+	g := RandomGeometricGraph(10000, 5000, 120, 1)
+	for _, workers := range []int{1, 4, runtime.GOMAXPROCS(0)} {
+		b.Run(fmt.Sprintf("workers-%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				DeltaStepping(g, 0, 100, workers)
+			}
+		})
+	}
+}
+
+func BenchmarkDijkstra_CostStorage(b *testing.B) {
+	for _, size := range benchmarkSizes {
+		g := GridGraph(size, size, 50)
+		target := int32(len(g.Nodes) - 1)
+		b.Run(fmt.Sprintf("map/grid-%dx%d", size, size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{target}}).Run(g)
+			}
+		})
+		b.Run(fmt.Sprintf("array/grid-%dx%d", size, size), func(b *testing.B) {
+			costs := NewCostArray(len(g.Nodes))
+			for i := 0; i < b.N; i++ {
+				NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{target}, CostArray: costs}).Run(g)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package graph_search
+
+import "github.com/qedus/osmpbf"
+
+// BikeInfrastructure is the kind of cycling facility along an edge, from the cycleway, cycleway:left,
+// cycleway:right, cycleway:both and bicycle tags of its way.
+type BikeInfrastructure uint8
+
+const (
+	BikeMixed  BikeInfrastructure = iota // No facility, bikes ride in mixed traffic
+	BikeShared                           // Shared lane markings or bus lanes open to bikes
+	BikeLane                             // Painted bike lane
+	BikeTrack                            // Protected track, cycleway or path designated for bikes
+)
+
+// bikeInfrastructure returns the best cycling facility of a way on either side.
+func bikeInfrastructure(w osmpbf.Way) BikeInfrastructure {
+	if w.Tags[Highway] == Cycleway || w.Tags[Bicycle] == Designated {
+		return BikeTrack
+	}
+	best := BikeMixed
+	for _, key := range []string{Cycleway, CyclewayLeft, CyclewayRight, CyclewayBoth} {
+		switch w.Tags[key] {
+		case Track, OppositeTrack:
+			best = max(best, BikeTrack)
+		case Lane, OppositeLane:
+			best = max(best, BikeLane)
+		case SharedLane, ShareBusway:
+			best = max(best, BikeShared)
+		}
+	}
+	return best
+}
+
+// Road classes by the traffic bikes share them with.
+var (
+	quietRoads = map[string]bool{
+		Residential: true, LivingStreet: true, Service: true, Unclassified: true, Track: true, Path: true,
+		Cycleway: true, Footway: true, Pedestrian: true,
+	}
+	busyRoads = map[string]bool{
+		Primary: true, PrimaryLink: true, Trunk: true, TrunkLink: true, Motorway: true, MotorwayLink: true,
+	}
+)
+
+// TrafficStress rates the level of traffic stress of an edge for cyclists, from 1, suitable for children,
+// to 4, tolerated only by confident riders. Protected tracks are level 1, painted lanes level 2 on quiet
+// and medium roads and 3 on busy ones, and mixed traffic is level 2 on quiet roads, 3 on tertiary and
+// secondary roads and 4 on primary roads and faster.
+//
+// Parameters:
+//   - m: MetaData - Metadata of the edge
+//
+// Returns:
+//   - int: The level of traffic stress, from 1 to 4
+func TrafficStress(m MetaData) int {
+	switch {
+	case m.Cycleway == BikeTrack:
+		return 1
+	case m.Cycleway == BikeLane && !busyRoads[m.RoadType]:
+		return 2
+	case m.Cycleway == BikeLane:
+		return 3
+	case quietRoads[m.RoadType]:
+		return 2
+	case busyRoads[m.RoadType]:
+		return 4
+	}
+	return 3
+}
+
+// stressFactors is the factor the cost of an edge is multiplied by for every level of traffic stress when
+// the rider has no tolerance for stress at all.
+var stressFactors = [...]float64{1: 0.8, 2: 1, 3: 1.6, 4: 3}
+
+// BikeStressFactor returns the factor scaling the travel time of an edge for a rider with a given
+// tolerance to traffic stress: protected infrastructure is discounted and busy roads penalized, the more
+// so the lower the tolerance.
+//
+// Parameters:
+//   - m: MetaData - Metadata of the edge
+//   - tolerance: float64 - Tolerance of the rider, from 0 for riders avoiding traffic at all costs to 1
+//     for riders only minimizing travel time. Values out of range are clamped
+//
+// Returns:
+//   - float64: The factor, between 0.8 and 3, 1 with a full tolerance
+func BikeStressFactor(m MetaData, tolerance float64) float64 {
+	aversion := 1 - min(max(tolerance, 0), 1)
+	return 1 + aversion*(stressFactors[TrafficStress(m)]-1)
+}
+
+// BikeCostModel returns a cost model minimizing the travel time of bike trips weighted by their traffic
+// stress, for graphs built for bikes.
+//
+// Parameters:
+//   - tolerance: float64 - Tolerance of the rider to traffic stress, see BikeStressFactor
+//
+// Returns:
+//   - CostModel: The cost model, in seconds
+func BikeCostModel(tolerance float64) CostModel {
+	return func(e Edge) float32 {
+		return float32(edgeDuration(e) * BikeStressFactor(e.Metadata, tolerance))
+	}
+}
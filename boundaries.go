@@ -0,0 +1,288 @@
+package graph_search
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/golang/geo/s2"
+	"github.com/qedus/osmpbf"
+)
+
+// Admin levels of the boundaries imported by default.
+const (
+	CountryLevel = 2
+	RegionLevel  = 4
+)
+
+// Boundary is an administrative area read from the boundary relations of OSM, e.g., a country or one of
+// its states.
+type Boundary struct {
+	Code  string // ISO 3166-1 code of countries, ISO 3166-2 code of regions, name when the relation has neither
+	Name  string // Name of the area
+	Level int    // Admin level of the area, CountryLevel for countries and RegionLevel for their regions
+
+	// Closed rings of the outer and inner ways of the relation, as [lng, lat] positions like GeoJSON.
+	// Holes are told apart from outer rings by their nesting.
+	Rings [][][]float64
+
+	polygon *s2.Polygon
+}
+
+// Contains reports whether a point lies inside the area.
+func (b *Boundary) Contains(p s2.Point) bool {
+	return b.shape().ContainsPoint(p)
+}
+
+// shape returns the area as an s2 polygon, built on first use.
+func (b *Boundary) shape() *s2.Polygon {
+	if b.polygon == nil {
+		loops := make([]*s2.Loop, 0, len(b.Rings))
+		for _, ring := range b.Rings {
+			points := make([]s2.Point, 0, len(ring))
+			for _, position := range ring[:len(ring)-1] { // s2 loops are implicitly closed
+				points = append(points, s2.PointFromLatLng(s2.LatLngFromDegrees(position[1], position[0])))
+			}
+			loop := s2.LoopFromPoints(points)
+			loop.Normalize()
+			loops = append(loops, loop)
+		}
+		b.polygon = s2.PolygonFromLoops(loops)
+	}
+	return b.polygon
+}
+
+// BoundaryBuilder assembles boundaries from the relations, ways and nodes of an OSM file. OSM files list
+// nodes first and relations last, so it is fed in three passes: the relations, then the ways, then the
+// nodes, each pass only keeping what the previous ones referenced.
+type BoundaryBuilder struct {
+	levels    map[int]bool
+	relations []*osmpbf.Relation
+	ways      map[int64][]int64   // node IDs of the member ways
+	nodes     map[int64][]float64 // [lng, lat] of the nodes of the member ways
+}
+
+// NewBoundaryBuilder creates a builder keeping the administrative boundaries of the given admin levels.
+//
+// Parameters:
+//   - levels: ...int - Admin levels to keep, CountryLevel and RegionLevel if none
+//
+// Returns:
+//   - *BoundaryBuilder: An empty builder
+func NewBoundaryBuilder(levels ...int) *BoundaryBuilder {
+	if len(levels) == 0 {
+		levels = []int{CountryLevel, RegionLevel}
+	}
+	b := &BoundaryBuilder{levels: make(map[int]bool), ways: make(map[int64][]int64), nodes: make(map[int64][]float64)}
+	for _, l := range levels {
+		b.levels[l] = true
+	}
+	return b
+}
+
+// AddRelation keeps a relation if it is an administrative boundary of a wanted level.
+func (b *BoundaryBuilder) AddRelation(r *osmpbf.Relation) {
+	level, err := strconv.Atoi(r.Tags[AdminLevel])
+	if r.Tags[BoundaryKey] != Administrative || err != nil || !b.levels[level] {
+		return
+	}
+	b.relations = append(b.relations, r)
+	for _, m := range r.Members {
+		if m.Type == osmpbf.WayType {
+			b.ways[m.ID] = nil
+		}
+	}
+}
+
+// AddWay keeps the nodes of a way if it is a member of a kept relation.
+func (b *BoundaryBuilder) AddWay(w *osmpbf.Way) {
+	if _, ok := b.ways[w.ID]; !ok {
+		return
+	}
+	b.ways[w.ID] = w.NodeIDs
+	for _, id := range w.NodeIDs {
+		b.nodes[id] = nil
+	}
+}
+
+// AddNode keeps the location of a node if it belongs to a kept way.
+func (b *BoundaryBuilder) AddNode(n *osmpbf.Node) {
+	if _, ok := b.nodes[n.ID]; ok {
+		b.nodes[n.ID] = []float64{n.Lon, n.Lat}
+	}
+}
+
+// Boundaries joins the member ways of every kept relation into closed rings.
+//
+// Returns:
+//   - []Boundary: The boundaries, in the order their relations were added. Member ways that do not close
+//     into rings, e.g., because the file is a clipped extract, are left out, and relations left without
+//     rings are dropped
+func (b *BoundaryBuilder) Boundaries() []Boundary {
+	boundaries := make([]Boundary, 0, len(b.relations))
+	for _, r := range b.relations {
+		level, _ := strconv.Atoi(r.Tags[AdminLevel])
+		boundary := Boundary{Code: r.Tags[ISO3166Region], Name: r.Tags[Name], Level: level}
+		if level == CountryLevel {
+			boundary.Code = r.Tags[ISO3166Country]
+		}
+		if boundary.Code == "" {
+			boundary.Code = boundary.Name
+		}
+		segments := make([][]int64, 0, len(r.Members))
+		for _, m := range r.Members {
+			if nodes := b.ways[m.ID]; m.Type == osmpbf.WayType && len(nodes) > 1 {
+				segments = append(segments, nodes)
+			}
+		}
+		for _, ring := range joinRings(segments) {
+			positions := make([][]float64, 0, len(ring))
+			for _, id := range ring {
+				if p := b.nodes[id]; p != nil {
+					positions = append(positions, p)
+				}
+			}
+			if len(positions) >= 4 {
+				boundary.Rings = append(boundary.Rings, positions)
+			}
+		}
+		if len(boundary.Rings) > 0 {
+			boundaries = append(boundaries, boundary)
+		}
+	}
+	return boundaries
+}
+
+// joinRings chains ways sharing their end nodes into closed rings, reversing them as needed.
+//
+// Parameters:
+//   - segments: [][]int64 - Node IDs of the ways
+//
+// Returns:
+//   - [][]int64: The closed rings, whose first and last node are the same. Chains that cannot be closed
+//     are dropped
+func joinRings(segments [][]int64) [][]int64 {
+	used := make([]bool, len(segments))
+	ends := make(map[int64][]int)
+	for i, s := range segments {
+		ends[s[0]] = append(ends[s[0]], i)
+		ends[s[len(s)-1]] = append(ends[s[len(s)-1]], i)
+	}
+	rings := make([][]int64, 0)
+	for i, s := range segments {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		ring := append([]int64(nil), s...)
+		for ring[0] != ring[len(ring)-1] {
+			last, next := ring[len(ring)-1], -1
+			for _, j := range ends[last] {
+				if !used[j] {
+					next = j
+					break
+				}
+			}
+			if next < 0 {
+				break
+			}
+			used[next] = true
+			segment := segments[next]
+			if segment[0] != last {
+				segment = append([]int64(nil), segment...)
+				for a, z := 0, len(segment)-1; a < z; a, z = a+1, z-1 {
+					segment[a], segment[z] = segment[z], segment[a]
+				}
+			}
+			ring = append(ring, segment[1:]...)
+		}
+		if ring[0] == ring[len(ring)-1] {
+			rings = append(rings, ring)
+		}
+	}
+	return rings
+}
+
+// ReadBoundaries reads the administrative boundaries of an OSM PBF file. It takes three passes over the
+// file, see BoundaryBuilder.
+//
+// Parameters:
+//   - path: string - File path to the OSM PBF file to process
+//   - levels: ...int - Admin levels to keep, CountryLevel and RegionLevel if none
+//
+// Returns:
+//   - []Boundary: The boundaries whose rings could be closed
+//   - error: The error encountered while reading the file, if any
+func ReadBoundaries(path string, levels ...int) ([]Boundary, error) {
+	b := NewBoundaryBuilder(levels...)
+	for pass := 0; pass < 3; pass++ {
+		decoder, file, err := openAndDecodePBF(path)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			obj, err := decoder.Decode()
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				file.Close()
+				return nil, err
+			}
+			switch obj := obj.(type) {
+			case *osmpbf.Relation:
+				if pass == 0 {
+					b.AddRelation(obj)
+				}
+			case *osmpbf.Way:
+				if pass == 1 {
+					b.AddWay(obj)
+				}
+			case *osmpbf.Node:
+				if pass == 2 {
+					b.AddNode(obj)
+				}
+			}
+		}
+		file.Close()
+	}
+	return b.Boundaries(), nil
+}
+
+// AssignBoundaries tags every edge with the country and the region containing the node it leads to, in
+// MetaData.Country and MetaData.Region, so searches can be kept inside a country with Criteria.Country and
+// callers can apply country-specific defaults. Edges crossing a border thus belong to the country they
+// enter. Nodes outside every boundary leave the edges leading to them untagged.
+//
+// Parameters:
+//   - boundaries: []Boundary - The boundaries, countries at CountryLevel and regions at any other level.
+//     When boundaries of a kind overlap, the first one containing a node wins
+func (g *Graph) AssignBoundaries(boundaries []Boundary) {
+	countries := make([]string, len(g.Nodes))
+	regions := make([]string, len(g.Nodes))
+	for i := range boundaries {
+		b := &boundaries[i]
+		codes := regions
+		if b.Level == CountryLevel {
+			codes = countries
+		}
+		bound := b.shape().RectBound()
+		for id, n := range g.Nodes {
+			if codes[id] != "" {
+				continue
+			}
+			p := s2.CellID(n.Location).Point()
+			if bound.ContainsPoint(p) && b.Contains(p) {
+				codes[id] = b.Code
+			}
+		}
+	}
+	for from := range g.OutgoingEdges {
+		for i, e := range g.OutgoingEdges[from] {
+			g.OutgoingEdges[from][i].Metadata.Country, g.OutgoingEdges[from][i].Metadata.Region = countries[e.ID], regions[e.ID]
+		}
+	}
+	for to := range g.IncomingEdges {
+		for i := range g.IncomingEdges[to] {
+			g.IncomingEdges[to][i].Metadata.Country, g.IncomingEdges[to][i].Metadata.Region = countries[to], regions[to]
+		}
+	}
+}
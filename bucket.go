@@ -0,0 +1,124 @@
+package graph_search
+
+import "math"
+
+// MaxBucketWeight is the largest edge weight for which SuggestBucketWidth recommends a bucket queue.
+// Above it the number of buckets scanned per settled node outweighs the gain over the binary heap.
+const MaxBucketWeight = 1 << 16
+
+// PriorityQueue is the frontier used by the searches to pick the next node to settle.
+// Heap and BucketQueue implement it.
+type PriorityQueue interface {
+	Insert(n HNode)
+	Min() (HNode, error)
+	DeleteMin() error
+	IsEmpty() bool
+	Len() int
+}
+
+// BucketQueue is a monotone priority queue implementing Dial's algorithm: HNodes are stored in buckets
+// of fixed cost width and extracted by scanning buckets in increasing order. Insert and DeleteMin are O(1)
+// and Min is amortized O(1) over a search, as the cursor never moves backwards.
+//
+// The order is exact for any width: Min picks the cheapest element of the current bucket, so a width larger
+// than the smallest edge weight stays correct but makes Min scan more elements per bucket. With every cost a
+// multiple of the width (e.g., integer seconds with width 1) a bucket holds a single cost and Min is O(1).
+type BucketQueue struct {
+	buckets []HNodes
+	width   float32
+	cursor  int
+	size    int
+}
+
+// NewBucketQueue creates an empty bucket queue.
+//
+// Parameters:
+//   - width: float32 - Cost range covered by each bucket, must be positive
+//
+// Returns:
+//   - *BucketQueue: An empty queue
+func NewBucketQueue(width float32) *BucketQueue {
+	return &BucketQueue{
+		buckets: make([]HNodes, 0),
+		width:   width,
+	}
+}
+
+// Insert adds an element to the bucket matching its cost plus estimate. Costs lower than the current minimum are
+// placed in the current bucket, since Dijkstra never inserts below the last extracted cost.
+func (q *BucketQueue) Insert(n HNode) {
+	i := int((n.Cost + n.Estimate) / q.width)
+	if i < q.cursor {
+		i = q.cursor
+	}
+	for len(q.buckets) <= i {
+		q.buckets = append(q.buckets, nil)
+	}
+	q.buckets[i] = append(q.buckets[i], n)
+	q.size++
+}
+
+// Min returns the cheapest element of the lowest non-empty bucket, moving it to the front of the bucket
+// so DeleteMin removes it. Elements of equal cost are returned in insertion order.
+func (q *BucketQueue) Min() (HNode, error) {
+	if q.IsEmpty() {
+		return HNode{}, ErrHeapEmpty
+	}
+	for len(q.buckets[q.cursor]) == 0 {
+		q.buckets[q.cursor] = nil
+		q.cursor++
+	}
+	bucket := q.buckets[q.cursor]
+	best := 0
+	for i := 1; i < len(bucket); i++ {
+		if bucket[i].Cost+bucket[i].Estimate < bucket[best].Cost+bucket[best].Estimate {
+			best = i
+		}
+	}
+	if best != 0 {
+		n := bucket[best]
+		copy(bucket[1:best+1], bucket[:best])
+		bucket[0] = n
+	}
+	return bucket[0], nil
+}
+
+// DeleteMin removes the element returned by Min.
+func (q *BucketQueue) DeleteMin() error {
+	if _, err := q.Min(); err != nil {
+		return err
+	}
+	q.buckets[q.cursor] = q.buckets[q.cursor][1:]
+	q.size--
+	return nil
+}
+
+// IsEmpty reports whether the queue has no elements.
+func (q *BucketQueue) IsEmpty() bool {
+	return q.size == 0
+}
+
+// Len returns the number of elements in the queue.
+func (q *BucketQueue) Len() int {
+	return q.size
+}
+
+// SuggestBucketWidth inspects the edge weights of a graph and returns 1 when they are all non-negative
+// integers not larger than MaxBucketWeight, the case where a BucketQueue beats the binary heap.
+//
+// Parameters:
+//   - g: Graph - The graph to inspect
+//
+// Returns:
+//   - float32: 1 if the graph is suitable for Dial's algorithm, 0 otherwise
+func SuggestBucketWidth(g Graph) float32 {
+	for _, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			w := float64(e.Weight)
+			if w < 0 || w > MaxBucketWeight || w != math.Trunc(w) {
+				return 0
+			}
+		}
+	}
+	return 1
+}
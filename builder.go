@@ -0,0 +1,149 @@
+package graph_search
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	ErrBuilderFinalized = errors.New("graph builder already finalized")
+)
+
+// builderShards is the number of independently locked edge shards of a GraphBuilder.
+const builderShards = 64
+
+// GraphBuilder assembles a Graph from several goroutines, e.g., workers processing PBF ways in parallel.
+// Graph methods are not safe for concurrent use; GraphBuilder serializes node creation and spreads edges
+// over shards locked independently by node ID, so concurrent RelateNodes calls rarely contend. Once every
+// goroutine is done, Finalize turns it into a regular Graph.
+type GraphBuilder struct {
+	mu        sync.RWMutex // guards nodes and finalized
+	nodes     []Node
+	finalized bool
+
+	shards [builderShards]builderShard
+}
+
+// builderShard holds the edges of the nodes whose ID is congruent to its index modulo builderShards.
+type builderShard struct {
+	mu       sync.Mutex
+	outgoing map[int32][]Edge
+	incoming map[int32][]Edge
+}
+
+// NewGraphBuilder creates an empty builder.
+//
+// Returns:
+//   - *GraphBuilder: A builder ready for concurrent use
+func NewGraphBuilder() *GraphBuilder {
+	b := &GraphBuilder{nodes: make([]Node, 0)}
+	for i := range b.shards {
+		b.shards[i].outgoing = make(map[int32][]Edge)
+		b.shards[i].incoming = make(map[int32][]Edge)
+	}
+	return b
+}
+
+// AddNode adds a node and assigns it the next ID. It is safe for concurrent use.
+//
+// Parameters:
+//   - n: Node - The node to add, its ID is overwritten
+//
+// Returns:
+//   - int32: The ID assigned to the node
+//   - error: ErrBuilderFinalized if Finalize was called, ErrTooManyNodes if the graph is full
+func (b *GraphBuilder) AddNode(n Node) (int32, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finalized {
+		return 0, ErrBuilderFinalized
+	}
+	if err := checkNodeCount(int64(len(b.nodes)) + 1); err != nil {
+		return 0, err
+	}
+	n.ID = int32(len(b.nodes))
+	b.nodes = append(b.nodes, n)
+	return n.ID, nil
+}
+
+// RelateNodes creates edges between two nodes according to the direction, like Graph.RelateNodes. It is
+// safe for concurrent use.
+//
+// Parameters:
+//   - u: int32 - ID of the first node
+//   - v: int32 - ID of the second node
+//   - weight: float32 - The weight of the edge(s)
+//   - dir: EdgeDirection - The direction of the relationship
+//   - metaData: MetaData - Additional information about the edge(s)
+//
+// Returns:
+//   - error: ErrBuilderFinalized if Finalize was called, ErrNodeNotFound if a node was not added yet
+func (b *GraphBuilder) RelateNodes(u, v int32, weight float32, dir EdgeDirection, metaData MetaData) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.finalized {
+		return ErrBuilderFinalized
+	}
+	n := int32(len(b.nodes))
+	if u < 0 || u >= n || v < 0 || v >= n {
+		return ErrNodeNotFound
+	}
+	if dir == Bidirectional || dir == LeftToRight {
+		b.addEdge(u, v, weight, metaData)
+	}
+	if dir == Bidirectional || dir == RightToLeft {
+		b.addEdge(v, u, weight, metaData)
+	}
+	return nil
+}
+
+// addEdge records the directed edge from -> to in the outgoing shard of from and the incoming shard of to.
+func (b *GraphBuilder) addEdge(from, to int32, weight float32, metaData MetaData) {
+	s := &b.shards[from%builderShards]
+	s.mu.Lock()
+	s.outgoing[from] = append(s.outgoing[from], Edge{ID: to, Weight: weight, Metadata: metaData})
+	s.mu.Unlock()
+
+	s = &b.shards[to%builderShards]
+	s.mu.Lock()
+	s.incoming[to] = append(s.incoming[to], Edge{ID: from, Weight: weight, Metadata: metaData})
+	s.mu.Unlock()
+}
+
+// Finalize builds the Graph. Adjacency lists are sorted with Graph.SortEdges, so the result does not depend
+// on the order concurrent calls were served in. The builder cannot be used afterwards.
+//
+// Returns:
+//   - Graph: The assembled graph
+//   - error: ErrBuilderFinalized if Finalize was already called
+func (b *GraphBuilder) Finalize() (Graph, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.finalized {
+		return EmptyGraph(), ErrBuilderFinalized
+	}
+	b.finalized = true
+
+	g := Graph{
+		Nodes:         b.nodes,
+		OutgoingEdges: make(Relations, len(b.nodes)),
+		IncomingEdges: make(Relations, len(b.nodes)),
+	}
+	for id := range b.nodes {
+		s := &b.shards[id%builderShards]
+		g.OutgoingEdges[id] = s.outgoing[int32(id)]
+		g.IncomingEdges[id] = s.incoming[int32(id)]
+		if g.OutgoingEdges[id] == nil {
+			g.OutgoingEdges[id] = make([]Edge, 0)
+		}
+		if g.IncomingEdges[id] == nil {
+			g.IncomingEdges[id] = make([]Edge, 0)
+		}
+	}
+	for i := range b.shards {
+		b.shards[i].outgoing, b.shards[i].incoming = nil, nil
+	}
+	b.nodes = nil
+	g.SortEdges()
+	return g, nil
+}
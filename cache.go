@@ -0,0 +1,171 @@
+package graph_search
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+)
+
+// CacheKey identifies a cached route. Two queries with the same key are guaranteed to have the same answer.
+type CacheKey struct {
+	Source    int32  // Snapped source node
+	Target    int32  // Snapped target node
+	Profile   string // Restrictions the route honors, as built by profileKey
+	CostModel uint64 // Version of the edge weights, bumped by Engine.InvalidateRoutes
+}
+
+// RouteCache is a fixed-size least recently used cache of query results. It is safe for concurrent use.
+type RouteCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List // Front is the most recently used entry
+	entries  map[CacheKey]*list.Element
+}
+
+// cacheEntry is the value stored in the elements of RouteCache.order.
+type cacheEntry struct {
+	key    CacheKey
+	result BatchResult
+}
+
+// NewRouteCache creates an empty cache holding at most capacity results.
+func NewRouteCache(capacity int) *RouteCache {
+	return &RouteCache{
+		capacity: max(capacity, 1),
+		order:    list.New(),
+		entries:  make(map[CacheKey]*list.Element),
+	}
+}
+
+// Get returns the result cached for a key and marks it as recently used.
+func (c *RouteCache) Get(key CacheKey) (BatchResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return BatchResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*cacheEntry).result, true
+}
+
+// Put stores a result, evicting the least recently used one if the cache is full.
+func (c *RouteCache) Put(key CacheKey, result BatchResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		el.Value.(*cacheEntry).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, result: result})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached results.
+func (c *RouteCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// Purge removes every cached result.
+func (c *RouteCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	clear(c.entries)
+}
+
+// RemoveIf removes every cached result for which drop returns true and returns how many were removed.
+func (c *RouteCache) RemoveIf(drop func(CacheKey, BatchResult) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	removed := 0
+	for key, el := range c.entries {
+		if drop(key, el.Value.(*cacheEntry).result) {
+			c.order.Remove(el)
+			delete(c.entries, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// EnableRouteCache makes Route and RouteBatch remember up to capacity results, so repeated identical
+// queries are answered without searching. Callers changing edge weights while the engine is in use must
+// call InvalidateEdge or InvalidateRoutes afterwards.
+func (e *Engine) EnableRouteCache(capacity int) {
+	e.cache = NewRouteCache(capacity)
+}
+
+// RouteCache returns the route cache of the engine, nil if caching is disabled.
+func (e *Engine) RouteCache() *RouteCache {
+	return e.cache
+}
+
+// InvalidateRoutes drops every cached route. It is the hook to call after overlaying new weights on
+// many edges at once, e.g., when replaying an EventLog. Bumping the cost model also keeps searches that
+// started before the call from caching their now stale result under a key later queries would use.
+func (e *Engine) InvalidateRoutes() {
+	e.costModel.Add(1)
+	if e.cache != nil {
+		e.cache.Purge()
+	}
+}
+
+// InvalidateEdge drops the cached routes traversing the edge from -> to, the hook to call after the
+// weight of a single edge changed. Cached failures are dropped too, as the change may open new routes.
+//
+// Returns:
+//   - int: The number of cached results removed
+func (e *Engine) InvalidateEdge(from, to int32) int {
+	if e.cache == nil {
+		return 0
+	}
+	return e.cache.RemoveIf(func(_ CacheKey, r BatchResult) bool {
+		if r.Err != nil {
+			return true
+		}
+		for i := 0; i+1 < len(r.Route.Nodes); i++ {
+			if r.Route.Nodes[i] == from && r.Route.Nodes[i+1] == to {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// profileKey summarizes the criteria fields that change which edges a search may use.
+func profileKey(c Criteria) string {
+	key := fmt.Sprintf("objective=%s,ferries=%t,tolls=%t", c.Objective, !c.AvoidFerries, !c.AvoidTolls)
+	if c.Country != "" {
+		key += ",country=" + c.Country
+	}
+	if c.Vehicle != nil {
+		key += fmt.Sprintf(",vehicle=%+v", *c.Vehicle)
+	}
+	if c.ExcludedNodes.Int != nil && c.ExcludedNodes.Sign() != 0 {
+		key += ",nodes=" + c.ExcludedNodes.Text(62)
+	}
+	if c.ExcludedEdges != nil && c.ExcludedEdges.bits.Sign() != 0 {
+		key += ",edges=" + c.ExcludedEdges.bits.Text(62)
+	}
+	if c.Epsilon > 0 {
+		key += fmt.Sprintf(",epsilon=%g", c.Epsilon)
+	}
+	if c.SurfaceTradeoff > 0 {
+		key += fmt.Sprintf(",surface=%g", c.SurfaceTradeoff)
+	}
+	if c.DetourFactor > 0 {
+		key += fmt.Sprintf(",detour=%g", c.DetourFactor)
+	}
+	if c.Overlay != nil {
+		key += fmt.Sprintf(",overlay=%d", c.Overlay.version)
+	}
+	return key
+}
@@ -0,0 +1,352 @@
+package graph_search
+
+import (
+	"math"
+	"sort"
+
+	"github.com/golang/geo/s2"
+)
+
+// cchLeafSize is the number of nodes under which the nested dissection stops splitting cells.
+const cchLeafSize = 16
+
+// CCH is the metric-independent part of a Customizable Contraction Hierarchy. It only depends on the
+// topology of the graph: a node order computed by nested dissection and the chordal supergraph obtained
+// by contracting the nodes in that order. It is built once; new weights are applied with Customize,
+// which is much faster than recontracting, so live traffic and alternative profiles can share it.
+type CCH struct {
+	graph Graph
+
+	rank []int32 // rank[v] is the position of node v in the contraction order
+
+	// The upward arcs of node v, towards nodes of higher rank, are heads[first[v]:first[v+1]], sorted by
+	// node ID. Arcs are undirected: both directions of an arc are weighted by the metrics.
+	first []int32
+	heads []int32
+
+	// lower[v] lists the arcs reaching v from nodes of lower rank, used to unpack shortcuts.
+	lower [][]int32
+
+	tails []int32 // tails[a] is the lower endpoint of arc a
+}
+
+// CCHMetric is a CCH customized with a set of edge weights, ready to answer queries.
+type CCHMetric struct {
+	cch *CCH
+
+	up   []float32 // up[a] is the weight of arc a from its tail to its head
+	down []float32 // down[a] is the weight of arc a from its head to its tail
+
+	baseUp   []float32 // Weight of the cheapest original edge from tail to head, +Inf if none
+	baseDown []float32 // Weight of the cheapest original edge from head to tail, +Inf if none
+}
+
+// NewCCH runs the metric-independent preprocessing of a Customizable Contraction Hierarchy. The node
+// order comes from a nested dissection by coordinates: cells are recursively split at the median of
+// their widest axis and the nodes on the boundary of the split are contracted last, which keeps the
+// number of shortcuts low on road networks.
+//
+// Parameters:
+//   - g: Graph - The graph to preprocess; later customizations must keep its nodes and edges
+//
+// Returns:
+//   - *CCH: The contraction hierarchy topology
+func NewCCH(g Graph) *CCH {
+	n := len(g.Nodes)
+	neighbors := make([]map[int32]struct{}, n)
+	for v := range neighbors {
+		neighbors[v] = make(map[int32]struct{})
+	}
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			if int32(from) != e.ID {
+				neighbors[from][e.ID] = struct{}{}
+				neighbors[e.ID][int32(from)] = struct{}{}
+			}
+		}
+	}
+
+	c := &CCH{graph: g, rank: make([]int32, n)}
+	nodes := make([]int32, n)
+	for i := range nodes {
+		nodes[i] = int32(i)
+	}
+	for r, v := range dissect(g, nodes, neighbors) {
+		c.rank[v] = int32(r)
+	}
+
+	order := make([]int32, n)
+	for v, r := range c.rank {
+		order[r] = int32(v)
+	}
+	upward := make([]map[int32]struct{}, n)
+	for v := range upward {
+		upward[v] = make(map[int32]struct{})
+		for u := range neighbors[v] {
+			if c.rank[u] > c.rank[v] {
+				upward[v][u] = struct{}{}
+			}
+		}
+	}
+	for _, v := range order {
+		for a := range upward[v] {
+			for b := range upward[v] {
+				if c.rank[a] < c.rank[b] {
+					upward[a][b] = struct{}{}
+				}
+			}
+		}
+	}
+
+	c.first = make([]int32, n+1)
+	c.lower = make([][]int32, n)
+	for v := 0; v < n; v++ {
+		heads := make([]int32, 0, len(upward[v]))
+		for u := range upward[v] {
+			heads = append(heads, u)
+		}
+		sort.Slice(heads, func(i, j int) bool { return heads[i] < heads[j] })
+		for _, u := range heads {
+			c.lower[u] = append(c.lower[u], int32(len(c.heads)))
+			c.heads = append(c.heads, u)
+			c.tails = append(c.tails, int32(v))
+		}
+		c.first[v+1] = int32(len(c.heads))
+	}
+	return c
+}
+
+// Arcs returns the number of arcs of the hierarchy, original edges and shortcuts together.
+func (c *CCH) Arcs() int {
+	return len(c.heads)
+}
+
+// Customize computes the weights of every arc of the hierarchy for a metric. It enumerates the lower
+// triangles of every arc once, so it takes a fraction of the time of a full contraction and can be
+// rerun whenever weights change, e.g., for every live traffic update.
+//
+// Parameters:
+//   - weight: func(Edge) float32 - Weight of an edge of the preprocessed graph, Edge.Weight when nil.
+//     Returning +Inf closes the edge
+//
+// Returns:
+//   - *CCHMetric: The customized hierarchy
+func (c *CCH) Customize(weight func(Edge) float32) *CCHMetric {
+	if weight == nil {
+		weight = func(e Edge) float32 { return e.Weight }
+	}
+	m := &CCHMetric{
+		cch:      c,
+		up:       make([]float32, len(c.heads)),
+		down:     make([]float32, len(c.heads)),
+		baseUp:   make([]float32, len(c.heads)),
+		baseDown: make([]float32, len(c.heads)),
+	}
+	inf := float32(math.Inf(1))
+	for a := range m.baseUp {
+		m.baseUp[a], m.baseDown[a] = inf, inf
+	}
+	for from, edges := range c.graph.OutgoingEdges {
+		for _, e := range edges {
+			w := weight(e)
+			if a, ok := c.arc(int32(from), e.ID); ok {
+				if c.tails[a] == int32(from) {
+					m.baseUp[a] = min(m.baseUp[a], w)
+				} else {
+					m.baseDown[a] = min(m.baseDown[a], w)
+				}
+			}
+		}
+	}
+	copy(m.up, m.baseUp)
+	copy(m.down, m.baseDown)
+
+	order := make([]int32, len(c.rank))
+	for v, r := range c.rank {
+		order[r] = int32(v)
+	}
+	for _, v := range order {
+		for i := c.first[v]; i < c.first[v+1]; i++ {
+			for j := c.first[v]; j < c.first[v+1]; j++ {
+				a, b := c.heads[i], c.heads[j]
+				if c.rank[a] >= c.rank[b] {
+					continue
+				}
+				ab, _ := c.arc(a, b)
+				// a -> v -> b improves a -> b, and b -> v -> a improves b -> a.
+				m.up[ab] = min(m.up[ab], m.down[i]+m.up[j])
+				m.down[ab] = min(m.down[ab], m.down[j]+m.up[i])
+			}
+		}
+	}
+	return m
+}
+
+// ShortestPath answers a point-to-point query with two upward searches, forward from the source and
+// backward from the target, meeting at the highest ranked node of the shortest path.
+//
+// Parameters:
+//   - source: int32 - ID of the source node
+//   - target: int32 - ID of the target node
+//
+// Returns:
+//   - float32: The cost of the shortest path
+//   - Route: The shortest path in the original graph, shortcuts unpacked
+//   - error: ErrNoRoute if the target cannot be reached, ErrNodeNotFound if a node is out of range
+func (m *CCHMetric) ShortestPath(source, target int32) (float32, Route, error) {
+	c := m.cch
+	if source < 0 || int(source) >= len(c.rank) || target < 0 || int(target) >= len(c.rank) {
+		return INFINITE, Route{}, ErrNodeNotFound
+	}
+	forward, forwardArc := m.upwardSearch(source, m.up)
+	backward, backwardArc := m.upwardSearch(target, m.down)
+
+	best, meeting := float32(math.Inf(1)), int32(-1)
+	for v, cost := range forward {
+		if other, ok := backward[v]; ok && cost+other < best {
+			best, meeting = cost+other, v
+		}
+	}
+	if meeting < 0 {
+		return INFINITE, Route{}, ErrNoRoute
+	}
+
+	up := []int32{meeting}
+	for v := meeting; v != source; {
+		v = c.tails[forwardArc[v]]
+		up = append(up, v)
+	}
+	nodes := make([]int32, 0)
+	for i := len(up) - 1; i > 0; i-- {
+		nodes = m.unpack(up[i], up[i-1], nodes)
+	}
+	for v := meeting; v != target; {
+		next := c.tails[backwardArc[v]]
+		nodes = m.unpack(v, next, nodes)
+		v = next
+	}
+	return best, NewRoute(append(nodes, target), c.graph), nil
+}
+
+// upwardSearch runs a Dijkstra search from a node following arcs towards higher ranks only, weighted by
+// up for the forward search or down for the backward search. It returns the cost of every reached node
+// and the arc it was reached through.
+func (m *CCHMetric) upwardSearch(from int32, weights []float32) (map[int32]float32, map[int32]int32) {
+	c := m.cch
+	costs := map[int32]float32{from: 0}
+	arcs := make(map[int32]int32)
+	settled := make(map[int32]bool)
+	pq := Create()
+	pq.Insert(HNode{Value: from})
+	for !pq.IsEmpty() {
+		min, _ := pq.Min()
+		pq.DeleteMin()
+		if settled[min.Value] {
+			continue
+		}
+		settled[min.Value] = true
+		for a := c.first[min.Value]; a < c.first[min.Value+1]; a++ {
+			u, cost := c.heads[a], min.Cost+weights[a]
+			if known, ok := costs[u]; !math.IsInf(float64(cost), 1) && (!ok || cost < known) {
+				costs[u], arcs[u] = cost, a
+				pq.Insert(HNode{Value: u, Cost: cost})
+			}
+		}
+	}
+	return costs, arcs
+}
+
+// unpack appends to nodes the original path going from x to y through the arc between them, x included
+// and y excluded, recursively replacing shortcuts with the lower triangle they were derived from.
+func (m *CCHMetric) unpack(x, y int32, nodes []int32) []int32 {
+	c := m.cch
+	a, _ := c.arc(x, y)
+	w, base := m.up[a], m.baseUp[a]
+	if c.tails[a] != x {
+		w, base = m.down[a], m.baseDown[a]
+	}
+	if w == base {
+		return append(nodes, x)
+	}
+	for _, lx := range c.lower[x] {
+		v := c.tails[lx]
+		if c.rank[v] > c.rank[y] {
+			continue
+		}
+		ly, ok := c.arc(v, y)
+		if !ok {
+			continue
+		}
+		// x -> v follows lx downwards and v -> y follows ly upwards.
+		if m.down[lx]+m.up[ly] == w {
+			return m.unpack(v, y, m.unpack(x, v, nodes))
+		}
+	}
+	return append(nodes, x)
+}
+
+// arc returns the index of the arc between two nodes, stored at the endpoint of lower rank.
+func (c *CCH) arc(x, y int32) (int32, bool) {
+	if c.rank[x] > c.rank[y] {
+		x, y = y, x
+	}
+	heads := c.heads[c.first[x]:c.first[x+1]]
+	i := sort.Search(len(heads), func(i int) bool { return heads[i] >= y })
+	if i < len(heads) && heads[i] == y {
+		return c.first[x] + int32(i), true
+	}
+	return 0, false
+}
+
+// dissect returns the nodes in contraction order by recursive coordinate bisection: both halves first,
+// then the separator, made of the nodes of the first half adjacent to the second one.
+func dissect(g Graph, nodes []int32, neighbors []map[int32]struct{}) []int32 {
+	if len(nodes) <= cchLeafSize {
+		return nodes
+	}
+	xs, ys := make(map[int32]float64, len(nodes)), make(map[int32]float64, len(nodes))
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, v := range nodes {
+		p := s2.CellID(g.Nodes[v].Location).LatLng()
+		xs[v], ys[v] = LatLngToMeters(p.Lat.Degrees(), p.Lng.Degrees())
+		minX, maxX = math.Min(minX, xs[v]), math.Max(maxX, xs[v])
+		minY, maxY = math.Min(minY, ys[v]), math.Max(maxY, ys[v])
+	}
+	key := xs
+	if maxY-minY > maxX-minX {
+		key = ys
+	}
+	sorted := append([]int32(nil), nodes...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if key[sorted[i]] != key[sorted[j]] {
+			return key[sorted[i]] < key[sorted[j]]
+		}
+		return sorted[i] < sorted[j]
+	})
+	half := len(sorted) / 2
+	right := make(map[int32]struct{}, len(sorted)-half)
+	for _, v := range sorted[half:] {
+		right[v] = struct{}{}
+	}
+	left, separator := make([]int32, 0, half), make([]int32, 0)
+	for _, v := range sorted[:half] {
+		boundary := false
+		for u := range neighbors[v] {
+			if _, ok := right[u]; ok {
+				boundary = true
+				break
+			}
+		}
+		if boundary {
+			separator = append(separator, v)
+		} else {
+			left = append(left, v)
+		}
+	}
+	if len(left) == 0 {
+		return sorted
+	}
+	order := dissect(g, left, neighbors)
+	order = append(order, dissect(g, sorted[half:], neighbors)...)
+	return append(order, separator...)
+}
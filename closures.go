@@ -0,0 +1,338 @@
+package graph_search
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/geo/s2"
+)
+
+var (
+	ErrInvalidClosure = errors.New("invalid closure")
+	ErrUnknownClosure = errors.New("unknown closure")
+)
+
+// Closure is an incident reported by a live feed, such as a crash, roadworks or a flooded area, which
+// closes or slows down part of the network for a while. It targets either the edges between two nodes,
+// every edge of an OSM way, or every edge touching a polygon. Like Event, it references nodes by their
+// S2 cell location, which survives graph rebuilds.
+type Closure struct {
+	ID string // Identifier of the incident in the feed, pushing a closure with the same ID replaces it
+
+	From      uint64        // S2 cell ID of the source node of the closed edges
+	To        uint64        // S2 cell ID of the destination node of the closed edges
+	Direction EdgeDirection // Direction of the edges between From and To affected by the closure
+
+	WayID   int64         // OSM ID of the closed way, when From and To are not set
+	Polygon [][][]float64 // GeoJSON rings of the closed area, when neither nodes nor a way are set
+
+	Start time.Time // Moment the closure takes effect, immediately if zero
+	End   time.Time // Moment the closure expires, never if zero
+
+	// Factor multiplies the cost of the affected edges, e.g., 2 for a lane closure halving the speed.
+	// Zero closes them.
+	Factor float32
+}
+
+// ActiveAt reports whether the closure applies at the given moment.
+func (c Closure) ActiveAt(t time.Time) bool {
+	return !t.Before(c.Start) && (c.End.IsZero() || t.Before(c.End))
+}
+
+// factor returns the factor the closure scales the cost of its edges by.
+func (c Closure) factor() float32 {
+	if c.Factor <= 0 {
+		return float32(math.Inf(1))
+	}
+	return c.Factor
+}
+
+// ClosureFeed receives the closures of an incident feed. Services consuming a Kafka topic or serving a
+// webhook push every message they receive; ReadClosures does so for NDJSON payloads.
+type ClosureFeed interface {
+	// Push adds a closure, or replaces the closure with the same ID.
+	Push(c Closure) error
+
+	// Remove lifts a closure before it expires.
+	Remove(id string) error
+}
+
+// ClosureStore is a ClosureFeed applying the closures it receives to the searches of a graph as a
+// WeightOverlay. Expired closures are dropped on their own, so routing recovers without redeploys. It is
+// safe for concurrent use.
+type ClosureStore struct {
+	graph     Graph
+	locations map[uint64]int32
+
+	mu       sync.Mutex
+	closures map[string]storedClosure
+	overlay  *WeightOverlay
+	from     time.Time // overlay holds the closures active from this moment...
+	until    time.Time // ...until this one, excluded; zero when no closure starts or ends later
+	stale    bool      // closures changed since overlay was built
+}
+
+// storedClosure is a closure along with the edges it resolved to when it was pushed.
+type storedClosure struct {
+	Closure
+	edges []edgePosition
+}
+
+// edgePosition locates the i-th outgoing edge of a node.
+type edgePosition struct {
+	from int32
+	i    int
+}
+
+// NewClosureStore creates a store without closures for the given graph.
+//
+// Parameters:
+//   - g: Graph - The graph closures apply to. It must not be mutated while the store is in use
+//
+// Returns:
+//   - *ClosureStore: An empty store
+func NewClosureStore(g Graph) *ClosureStore {
+	return &ClosureStore{graph: g, locations: g.locationIndex(), closures: make(map[string]storedClosure)}
+}
+
+// Push resolves a closure to the edges of the graph and stores it, replacing the closure with the same
+// ID. A closure that already expired lifts the closure with its ID, so feeds can cancel incidents by
+// sending them again with a past End.
+//
+// Parameters:
+//   - c: Closure - The closure to store
+//
+// Returns:
+//   - error: An error wrapping ErrInvalidClosure if the closure has no ID, no target or ends before it
+//     starts, ErrNodeNotFound or ErrEdgeNotFound if it matches nothing in the graph
+func (s *ClosureStore) Push(c Closure) error {
+	if c.ID == "" {
+		return fmt.Errorf("%w: missing ID", ErrInvalidClosure)
+	}
+	if !c.End.IsZero() && !c.End.After(c.Start) {
+		return fmt.Errorf("closure %s: %w: ends before it starts", c.ID, ErrInvalidClosure)
+	}
+	if !c.End.IsZero() && !time.Now().Before(c.End) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.delete(c.ID)
+		return nil
+	}
+	edges, err := s.resolve(c)
+	if err != nil {
+		return fmt.Errorf("closure %s: %w", c.ID, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closures[c.ID] = storedClosure{Closure: c, edges: edges}
+	s.stale = true
+	return nil
+}
+
+// Remove lifts a closure.
+//
+// Parameters:
+//   - id: string - ID of the closure
+//
+// Returns:
+//   - error: ErrUnknownClosure if no closure has that ID
+func (s *ClosureStore) Remove(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.delete(id) {
+		return fmt.Errorf("closure %s: %w", id, ErrUnknownClosure)
+	}
+	return nil
+}
+
+// delete removes a closure, reporting false if there was none with that ID.
+func (s *ClosureStore) delete(id string) bool {
+	if _, ok := s.closures[id]; !ok {
+		return false
+	}
+	delete(s.closures, id)
+	s.stale = true
+	return true
+}
+
+// Closures returns the stored closures that did not expire yet, sorted by ID.
+func (s *ClosureStore) Closures() []Closure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	closures := make([]Closure, 0, len(s.closures))
+	for _, c := range s.closures {
+		closures = append(closures, c.Closure)
+	}
+	sort.Slice(closures, func(i, j int) bool { return closures[i].ID < closures[j].ID })
+	return closures
+}
+
+// Overlay returns the weight overlay of the closures active at a moment. The overlay is rebuilt only
+// when closures are pushed or removed, or when one of them starts or ends, so consecutive queries share
+// it, as well as their cached routes.
+//
+// Parameters:
+//   - at: time.Time - The moment the closures must be active at, usually the departure of the trip
+//
+// Returns:
+//   - *WeightOverlay: The overlay to set in Criteria.Overlay, nil if no closure is active
+func (s *ClosureStore) Overlay(at time.Time) *WeightOverlay {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(time.Now())
+	if !s.stale && !at.Before(s.from) && (s.until.IsZero() || at.Before(s.until)) {
+		return s.overlay
+	}
+
+	s.overlay, s.from, s.until, s.stale = nil, time.Time{}, time.Time{}, false
+	for _, c := range s.closures {
+		// The overlay stays valid until the next closure start or end around the moment.
+		for _, t := range []time.Time{c.Start, c.End} {
+			if t.IsZero() {
+				continue
+			}
+			if !at.Before(t) && t.After(s.from) {
+				s.from = t
+			}
+			if at.Before(t) && (s.until.IsZero() || t.Before(s.until)) {
+				s.until = t
+			}
+		}
+		if !c.ActiveAt(at) {
+			continue
+		}
+		if s.overlay == nil {
+			s.overlay = NewWeightOverlay(s.graph)
+		}
+		for _, e := range c.edges {
+			s.overlay.Scale(e.from, e.i, c.factor())
+		}
+	}
+	return s.overlay
+}
+
+// Apply sets the overlay of the closures active at the departure of the criteria, or now if it has
+// none, unless the criteria already carry an overlay.
+//
+// Parameters:
+//   - c: Criteria - The criteria of the search
+//
+// Returns:
+//   - Criteria: The criteria with the closures applied
+func (s *ClosureStore) Apply(c Criteria) Criteria {
+	if c.Overlay != nil {
+		return c
+	}
+	at := c.Departure
+	if at.IsZero() {
+		at = time.Now()
+	}
+	c.Overlay = s.Overlay(at)
+	return c
+}
+
+// expire drops the closures that ended before now.
+func (s *ClosureStore) expire(now time.Time) {
+	for id, c := range s.closures {
+		if !c.End.IsZero() && !now.Before(c.End) {
+			s.delete(id)
+		}
+	}
+}
+
+// resolve finds the edges of the graph a closure targets.
+func (s *ClosureStore) resolve(c Closure) ([]edgePosition, error) {
+	g := s.graph
+	edges := make([]edgePosition, 0)
+	switch {
+	case c.From != 0 || c.To != 0:
+		from, ok1 := s.locations[c.From]
+		to, ok2 := s.locations[c.To]
+		if !ok1 || !ok2 {
+			return nil, ErrNodeNotFound
+		}
+		g.forEachDirection(from, to, c.Direction, func(a, b int32) bool {
+			for i, e := range g.OutgoingEdges[a] {
+				if e.ID == b {
+					edges = append(edges, edgePosition{a, i})
+				}
+			}
+			return true
+		})
+	case c.WayID != 0:
+		for v, out := range g.OutgoingEdges {
+			for i, e := range out {
+				if e.Metadata.WayID == c.WayID {
+					edges = append(edges, edgePosition{int32(v), i})
+				}
+			}
+		}
+	case len(c.Polygon) > 0:
+		if len(c.Polygon[0]) < 3 {
+			return nil, ErrInvalidPolygon
+		}
+		polygon := GeoJSONPolygon{ID: c.ID, Rings: c.Polygon}
+		inside := make([]bool, len(g.Nodes))
+		for _, n := range g.Nodes {
+			p := s2.CellID(n.Location).LatLng()
+			inside[n.ID] = polygon.Contains(Coordinate{Lat: p.Lat.Degrees(), Lng: p.Lng.Degrees()})
+		}
+		for v, out := range g.OutgoingEdges {
+			for i, e := range out {
+				if inside[v] || inside[e.ID] {
+					edges = append(edges, edgePosition{int32(v), i})
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: no nodes, way or polygon", ErrInvalidClosure)
+	}
+	if len(edges) == 0 {
+		return nil, ErrEdgeNotFound
+	}
+	return edges, nil
+}
+
+// ReadClosures pushes the closures of a newline-delimited JSON stream to a feed, one Closure per line,
+// e.g., the body of a webhook call or a batch of Kafka messages.
+//
+// Parameters:
+//   - r: io.Reader - The NDJSON stream
+//   - feed: ClosureFeed - Where the closures are pushed
+//
+// Returns:
+//   - int: The number of closures pushed
+//   - error: The first malformed line or rejected closure, wrapped with its line number
+func ReadClosures(r io.Reader, feed ClosureFeed) (int, error) {
+	pushed := 0
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var c Closure
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return pushed, fmt.Errorf("closure line %d: %w", line, err)
+		}
+		if err := feed.Push(c); err != nil {
+			return pushed, fmt.Errorf("closure line %d: %w", line, err)
+		}
+		pushed++
+	}
+	return pushed, scanner.Err()
+}
+
+// SetClosures makes the engine apply the closures of a store to every query that does not set its own
+// Criteria.Overlay. It must be called before the engine starts serving queries.
+func (e *Engine) SetClosures(s *ClosureStore) {
+	e.closures = s
+}
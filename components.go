@@ -0,0 +1,91 @@
+package graph_search
+
+// Components partitions the nodes of a graph into strongly connected components: two nodes are in the same
+// component when each can be reached from the other. Road networks have one giant component and many
+// small ones, e.g., private service roads or parking aisles cut off by the extract or by one-way tagging
+// errors; routes from or to a small component are mostly unreachable.
+type Components struct {
+	Of      []int32 // Of[v] is the component of node v
+	Sizes   []int32 // Sizes[c] is the number of nodes of component c
+	Largest int32   // The component with the most nodes, -1 for an empty graph
+}
+
+// StronglyConnectedComponents computes the strongly connected components of the graph with an iterative
+// version of Tarjan's algorithm, in linear time and without recursion, so it runs on country graphs.
+//
+// Returns:
+//   - Components: The component of every node
+func (g Graph) StronglyConnectedComponents() Components {
+	n := len(g.Nodes)
+	c := Components{Of: make([]int32, n), Largest: -1}
+	index := make([]int32, n) // discovery order + 1, 0 for unvisited nodes
+	low := make([]int32, n)
+	onStack := make([]bool, n)
+	stack := make([]int32, 0)
+	next := int32(1)
+
+	type frame struct {
+		v    int32
+		edge int // index of the next outgoing edge to explore
+	}
+	for root := range g.Nodes {
+		if index[root] != 0 {
+			continue
+		}
+		calls := []frame{{v: int32(root)}}
+		index[root], low[root] = next, next
+		next++
+		stack = append(stack, int32(root))
+		onStack[root] = true
+
+		for len(calls) > 0 {
+			top := &calls[len(calls)-1]
+			v := top.v
+			if top.edge < len(g.OutgoingEdges[v]) {
+				w := g.OutgoingEdges[v][top.edge].ID
+				top.edge++
+				if index[w] == 0 {
+					index[w], low[w] = next, next
+					next++
+					stack = append(stack, w)
+					onStack[w] = true
+					calls = append(calls, frame{v: w})
+				} else if onStack[w] {
+					low[v] = min(low[v], index[w])
+				}
+				continue
+			}
+
+			calls = calls[:len(calls)-1]
+			if len(calls) > 0 {
+				parent := calls[len(calls)-1].v
+				low[parent] = min(low[parent], low[v])
+			}
+			if low[v] != index[v] {
+				continue
+			}
+			id := int32(len(c.Sizes))
+			size := int32(0)
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				c.Of[w] = id
+				size++
+				if w == v {
+					break
+				}
+			}
+			c.Sizes = append(c.Sizes, size)
+			if c.Largest < 0 || size > c.Sizes[c.Largest] {
+				c.Largest = id
+			}
+		}
+	}
+	return c
+}
+
+// InLargest reports whether a node belongs to the largest component.
+func (c *Components) InLargest(id int32) bool {
+	return id >= 0 && int(id) < len(c.Of) && c.Of[id] == c.Largest
+}
@@ -0,0 +1,234 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qedus/osmpbf"
+)
+
+var (
+	ErrInvalidCondition = errors.New("invalid conditional restriction")
+)
+
+// TimeWindow is a weekly recurring period, such as the "Mo-Fr 07:00-09:00" of a conditional restriction.
+type TimeWindow struct {
+	Days  uint8  // Days of the week the window starts on, bit 0 being Monday; 0 for every day
+	Start uint16 // Minutes after midnight the window starts at
+	End   uint16 // Minutes after midnight the window ends at, up to 1440; before Start when it spans midnight
+}
+
+// Contains reports whether a moment falls within the window, in the location of the moment.
+func (w TimeWindow) Contains(t time.Time) bool {
+	minute, day := uint16(t.Hour()*60+t.Minute()), (int(t.Weekday())+6)%7
+	on := func(d int) bool { return w.Days == 0 || w.Days&(1<<d) != 0 }
+	if w.Start <= w.End {
+		return on(day) && minute >= w.Start && minute < w.End
+	}
+	return on(day) && minute >= w.Start || on((day+6)%7) && minute < w.End
+}
+
+// ConditionalAccess holds the time windows restricting the use of an edge, parsed from the
+// access:conditional and oneway:conditional tags of its way.
+type ConditionalAccess struct {
+	Closed   []TimeWindow // The edge may not be used during these windows
+	OpenOnly []TimeWindow // The edge may only be used during these windows, when there are any
+}
+
+// OpenAt reports whether the edge may be used at a moment. A nil ConditionalAccess is always open.
+func (a *ConditionalAccess) OpenAt(t time.Time) bool {
+	if a == nil {
+		return true
+	}
+	for _, w := range a.Closed {
+		if w.Contains(t) {
+			return false
+		}
+	}
+	if len(a.OpenOnly) == 0 {
+		return true
+	}
+	for _, w := range a.OpenOnly {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// usuallyOpen reports whether searches ignoring time may use the edge: edges only open during some windows
+// are skipped, edges closed during some windows are not.
+func (a *ConditionalAccess) usuallyOpen() bool {
+	return a == nil || len(a.OpenOnly) == 0
+}
+
+// ConditionalRule is one "value @ condition" clause of a conditional tag.
+type ConditionalRule struct {
+	Value   string       // Value of the tag while the condition holds, e.g., "no"
+	Windows []TimeWindow // Windows in which the condition holds
+}
+
+// ParseConditional parses the value of a conditional tag, e.g., "no @ (Mo-Fr 07:00-09:00,16:00-18:00);
+// yes @ (Sa 10:00-12:00)". Days are given as ranges or lists of Mo, Tu, We, Th, Fr, Sa and Su, and apply to
+// every day when omitted. Clauses whose conditions are not time windows, such as weight or wet, are skipped.
+//
+// Parameters:
+//   - value: string - Value of the tag
+//
+// Returns:
+//   - []ConditionalRule: The time-based clauses, in tag order
+//   - error: An error wrapping ErrInvalidCondition if a clause has no @ or a malformed time
+func ParseConditional(value string) ([]ConditionalRule, error) {
+	rules := make([]ConditionalRule, 0, 1)
+	for _, clause := range splitOutsideParens(value) {
+		v, condition, ok := strings.Cut(clause, "@")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q has no condition", ErrInvalidCondition, clause)
+		}
+		condition = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(condition), "("), ")")
+		rule := ConditionalRule{Value: strings.TrimSpace(v)}
+		for _, c := range strings.Split(condition, ";") {
+			windows, err := parseTimeWindows(strings.TrimSpace(c))
+			if err != nil {
+				return nil, err
+			}
+			rule.Windows = append(rule.Windows, windows...)
+		}
+		if len(rule.Windows) > 0 {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// splitOutsideParens splits a conditional tag into its clauses at the semicolons outside parentheses.
+func splitOutsideParens(value string) []string {
+	clauses := make([]string, 0, 1)
+	depth, start := 0, 0
+	for i, r := range value {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ';':
+			if depth == 0 {
+				clauses = append(clauses, value[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if strings.TrimSpace(value[start:]) != "" {
+		clauses = append(clauses, value[start:])
+	}
+	return clauses
+}
+
+// weekdays are the opening_hours abbreviations of the days of the week, from Monday.
+var weekdays = []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"}
+
+// parseTimeWindows parses a condition like "Mo-Fr,Su 07:00-09:00,16:00-18:00", returning no window when
+// the condition is not a time condition.
+func parseTimeWindows(condition string) ([]TimeWindow, error) {
+	days, times, found := strings.Cut(condition, " ")
+	if !found {
+		days, times = "", condition
+	}
+	if !strings.Contains(times, ":") {
+		return nil, nil
+	}
+	var mask uint8
+	for _, span := range strings.Split(days, ",") {
+		if span == "" {
+			continue
+		}
+		first, last, _ := strings.Cut(span, "-")
+		if last == "" {
+			last = first
+		}
+		from, to := dayIndex(first), dayIndex(last)
+		if from < 0 || to < 0 {
+			return nil, nil // public holidays and other non-weekday selectors
+		}
+		for d := from; ; d = (d + 1) % 7 {
+			mask |= 1 << d
+			if d == to {
+				break
+			}
+		}
+	}
+
+	windows := make([]TimeWindow, 0, 1)
+	for _, span := range strings.Split(strings.TrimSpace(times), ",") {
+		start, end, ok := strings.Cut(span, "-")
+		s, err1 := parseClock(start)
+		e, err2 := parseClock(end)
+		if !ok || err1 != nil || err2 != nil {
+			return nil, fmt.Errorf("%w: time %q", ErrInvalidCondition, span)
+		}
+		windows = append(windows, TimeWindow{Days: mask, Start: s, End: e})
+	}
+	return windows, nil
+}
+
+// dayIndex returns the index of a weekday abbreviation from Monday, -1 if it is not one.
+func dayIndex(day string) int {
+	for i, d := range weekdays {
+		if d == day {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseClock parses a hh:mm time into minutes after midnight, accepting 24:00.
+func parseClock(clock string) (uint16, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(clock), ":")
+	hours, err1 := strconv.Atoi(h)
+	minutes, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hours < 0 || minutes < 0 || minutes > 59 || hours*60+minutes > 24*60 {
+		return 0, ErrInvalidCondition
+	}
+	return uint16(hours*60 + minutes), nil
+}
+
+// wayAccess derives the conditional access of the edges of a way along and against its direction.
+// access:conditional=no closes both directions during its windows; oneway:conditional=yes closes the
+// backward direction, and oneway:conditional=no opens the backward direction of a one-way way, during
+// theirs. Malformed tags are ignored.
+//
+// Returns:
+//   - *ConditionalAccess: Access of the forward edges, nil when unrestricted
+//   - *ConditionalAccess: Access of the backward edges, nil when unrestricted. Its OpenOnly windows are set
+//     when the way is one-way but may be traveled backwards during some windows
+func wayAccess(w osmpbf.Way, dir EdgeDirection) (*ConditionalAccess, *ConditionalAccess) {
+	var forward, backward ConditionalAccess
+	if rules, err := ParseConditional(w.Tags[AccessConditional]); err == nil {
+		for _, r := range rules {
+			if r.Value == No || r.Value == Private {
+				forward.Closed = append(forward.Closed, r.Windows...)
+				backward.Closed = append(backward.Closed, r.Windows...)
+			}
+		}
+	}
+	if rules, err := ParseConditional(w.Tags[OnewayConditional]); err == nil {
+		for _, r := range rules {
+			switch {
+			case r.Value == Yes && dir == Bidirectional:
+				backward.Closed = append(backward.Closed, r.Windows...)
+			case r.Value == No && dir == LeftToRight:
+				backward.OpenOnly = append(backward.OpenOnly, r.Windows...)
+			}
+		}
+	}
+	access := func(a ConditionalAccess) *ConditionalAccess {
+		if len(a.Closed) == 0 && len(a.OpenOnly) == 0 {
+			return nil
+		}
+		return &a
+	}
+	return access(forward), access(backward)
+}
@@ -46,6 +46,7 @@ const (
 const (
 	No            = "no"
 	Oneway        = "oneway"
+	OnewayBicycle = "oneway:bicycle"
 	Opposite      = "opposite"
 	OppositeLane  = "opposite_lane"
 	OppositeTrack = "opposite_track"
@@ -57,14 +58,75 @@ const (
 	Bicycle  = "bicycle"
 	Bike     = "bike"
 	Drive    = "drive"
+	Foot     = "foot"
 	MaxSpeed = "maxspeed"
+	Name     = "name"
+	RouteKey = "route"
+	Ferry    = "ferry"
+	Toll     = "toll"
+)
+
+// Administrative Boundaries
+const (
+	AdminLevel     = "admin_level"
+	Administrative = "administrative"
+	BoundaryKey    = "boundary"
+	ISO3166Country = "ISO3166-1"
+	ISO3166Region  = "ISO3166-2"
+)
+
+// Conditional Restrictions
+const (
+	AccessConditional = "access:conditional"
+	OnewayConditional = "oneway:conditional"
+	Private           = "private"
+)
+
+// Relations
+const (
+	Restriction = "restriction"
+	TypeKey     = "type"
+)
+
+// Lanes
+const (
+	Lanes     = "lanes"
+	TurnLanes = "turn:lanes"
+)
+
+// Cycling Infrastructure
+const (
+	CyclewayBoth  = "cycleway:both"
+	CyclewayLeft  = "cycleway:left"
+	CyclewayRight = "cycleway:right"
+	Designated    = "designated"
+	Lane          = "lane"
+	ShareBusway   = "share_busway"
+	SharedLane    = "shared_lane"
+)
+
+// Vehicle Restrictions
+const (
+	HGV       = "hgv"
+	Hazmat    = "hazmat"
+	MaxHeight = "maxheight"
+	MaxWeight = "maxweight"
+	MaxWidth  = "maxwidth"
+)
+
+const (
+	KilogramsInATonne = 1000
+	MetersPerFoot     = 0.3048
+	InchesPerFoot     = 12
 )
 
 // SurfaceType constants
 const (
+	Asphalt      = "asphalt"
 	Bricks       = "bricks"
 	Cement       = "cement"
 	Clay         = "clay"
+	Concrete     = "concrete"
 	Cobblestone  = "cobblestone"
 	Compacted    = "compacted"
 	Dirt         = "dirt"
@@ -76,11 +138,13 @@ const (
 	Ground       = "ground"
 	Metal        = "metal"
 	Mud          = "mud"
+	Paved        = "paved"
 	PavingStones = "paving_stones"
 	Pebblestone  = "pebblestone"
 	Rocky        = "rocky"
 	Sand         = "sand"
 	Sett         = "sett"
+	Smoothness   = "smoothness"
 	Surface      = "surface"
 	Stone        = "stone"
 	Tartan       = "tartan"
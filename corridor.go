@@ -0,0 +1,74 @@
+package graph_search
+
+import "math"
+
+// Corridor is the set of nodes lying within a buffer around a reference line, e.g., the route planned
+// before a closure. Set as Criteria.Corridor, it keeps a search near the line: routes recomputed around
+// a closure follow the planned corridor instead of crossing the whole region.
+type Corridor struct {
+	inside []bool // inside[v] is set when node v lies within the buffer
+	size   int
+}
+
+// NewCorridor collects the nodes within a distance of a polyline with range queries on the node index,
+// sampled along every segment of the line.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex or BuildNodeIndexWithMetric
+//   - line: []Coordinate - The reference line, e.g., the coordinates of the planned route
+//   - width: float64 - Largest distance in meters between a node of the corridor and the line
+//
+// Returns:
+//   - *Corridor: The nodes of the index within width meters of the line
+func (g Graph) NewCorridor(index *KDTree, line []Coordinate, width float64) *Corridor {
+	c := &Corridor{inside: make([]bool, len(g.Nodes))}
+	if len(line) == 0 {
+		return c
+	}
+	if len(line) == 1 {
+		line = []Coordinate{line[0], line[0]}
+	}
+	for k := 1; k < len(line); k++ {
+		a, b := line[k-1], line[k]
+		ax, ay := LatLngToMeters(a.Lat, a.Lng)
+		bx, by := LatLngToMeters(b.Lat, b.Lng)
+		// Projected meters are stretched by 1/cos(latitude), as are widths in them
+		scale := math.Cos((a.Lat + b.Lat) / 2 * math.Pi / 180)
+		step := math.Max(width/scale, 1)
+		samples := max(int(math.Ceil(math.Hypot(bx-ax, by-ay)/step)), 1)
+		// Every point of the segment is within step/2 of a sample, so the nodes within width of the
+		// segment are within width + step/2 of a sample
+		radius := width/scale + step/2
+		if _, ok := index.Metric().(HaversineMetric); ok {
+			radius *= scale
+		}
+		for s := 0; s <= samples; s++ {
+			ratio := float64(s) / float64(samples)
+			lat, lng := MetersToLatLng(ax+(bx-ax)*ratio, ay+(by-ay)*ratio)
+			center := NewVector(-1, geoComponents(index.Metric(), Coordinate{Lat: lat, Lng: lng}))
+			for _, v := range index.RangeQuery(center, radius) {
+				if v.ID < 0 || v.ID >= len(g.Nodes) || c.inside[v.ID] {
+					continue
+				}
+				p := nodeCoordinate(g.Nodes[v.ID])
+				x, y := LatLngToMeters(p.Lat, p.Lng)
+				d := segmentDistance([2]float64{x, y}, [2]float64{ax, ay}, [2]float64{bx, by})
+				if d*math.Cos(p.Lat*math.Pi/180) <= width {
+					c.inside[v.ID] = true
+					c.size++
+				}
+			}
+		}
+	}
+	return c
+}
+
+// Contains reports whether a node lies within the corridor.
+func (c *Corridor) Contains(id int32) bool {
+	return id >= 0 && int(id) < len(c.inside) && c.inside[id]
+}
+
+// Len returns the number of nodes of the corridor.
+func (c *Corridor) Len() int {
+	return c.size
+}
@@ -0,0 +1,97 @@
+package graph_search
+
+// CostArray stores search costs in a slice indexed by node ID instead of a map. Every entry is stamped
+// with the generation it was written in, so Reset invalidates all of them in O(1) and the array can be
+// reused across searches without being cleared or reallocated.
+//
+// A CostArray must not be shared by concurrent searches.
+type CostArray struct {
+	costs      []float32
+	stamps     []uint32 // stamps[id] == generation when costs[id] was set in the current generation
+	generation uint32
+	touched    []int32 // IDs set in the current generation, in the order they were first set
+}
+
+// NewCostArray creates a cost array for a graph with n nodes.
+//
+// Parameters:
+//   - n: int - Number of nodes, node IDs must be lower than n
+//
+// Returns:
+//   - *CostArray: An empty cost array
+func NewCostArray(n int) *CostArray {
+	return &CostArray{
+		costs:      make([]float32, n),
+		stamps:     make([]uint32, n),
+		generation: 1,
+		touched:    make([]int32, 0),
+	}
+}
+
+// Get returns the cost of a node.
+//
+// Parameters:
+//   - id: int32 - ID of the node
+//
+// Returns:
+//   - float32: The cost of the node, INFINITE if it is not set
+//   - bool: false if the cost of the node was not set since the last Reset
+func (a *CostArray) Get(id int32) (float32, bool) {
+	if id < 0 || int(id) >= len(a.stamps) || a.stamps[id] != a.generation {
+		return INFINITE, false
+	}
+	return a.costs[id], true
+}
+
+// Set sets the cost of a node. The node ID must be lower than the size of the array.
+//
+// Parameters:
+//   - id: int32 - ID of the node
+//   - cost: float32 - Cost of the node
+func (a *CostArray) Set(id int32, cost float32) {
+	if a.stamps[id] != a.generation {
+		a.stamps[id] = a.generation
+		a.touched = append(a.touched, id)
+	}
+	a.costs[id] = cost
+}
+
+// Len returns the number of nodes whose cost is set.
+func (a *CostArray) Len() int {
+	return len(a.touched)
+}
+
+// Reset forgets every cost in constant time by starting a new generation.
+func (a *CostArray) Reset() {
+	a.generation++
+	if a.generation == 0 {
+		// The stamps wrapped around: clear them once so stale entries are not mistaken for new ones.
+		clear(a.stamps)
+		a.generation = 1
+	}
+	a.touched = a.touched[:0]
+}
+
+// Costs copies the costs set since the last Reset into a Costs map.
+func (a *CostArray) Costs() Costs {
+	costs := make(Costs, len(a.touched))
+	for _, id := range a.touched {
+		costs[id] = a.costs[id]
+	}
+	return costs
+}
+
+// grow extends the array so it can hold n nodes, keeping the current costs.
+func (a *CostArray) grow(n int) {
+	if n > len(a.stamps) {
+		a.costs = append(a.costs, make([]float32, n-len(a.costs))...)
+		a.stamps = append(a.stamps, make([]uint32, n-len(a.stamps))...)
+	}
+}
+
+// denseIDs reports whether the node IDs of a graph are the positions of the nodes, as AddNode assigns
+// them, so they can index a CostArray. Only the bounds are checked to keep the test constant time.
+func denseIDs(g Graph) bool {
+	n := len(g.Nodes)
+	return n > 0 && g.Nodes[0].ID == 0 && g.Nodes[n-1].ID == int32(n-1)
+}
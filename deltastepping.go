@@ -0,0 +1,163 @@
+package graph_search
+
+import (
+	"math"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// parallelFrontier is the frontier size under which delta-stepping relaxes a phase on the calling
+// goroutine, as spawning workers for a handful of nodes costs more than it saves.
+const parallelFrontier = 256
+
+// DeltaStepping computes the shortest path cost from source to every node of the graph with the
+// delta-stepping algorithm of Meyer and Sanders. Nodes are kept in buckets of width delta; all nodes of
+// the current bucket are relaxed in parallel, light edges (weight <= delta) repeatedly until the bucket
+// stays empty, and heavy edges once afterwards. Small deltas behave like Dijkstra, large ones like
+// Bellman-Ford; a delta close to the average edge weight is usually a good trade-off.
+//
+// Parameters:
+//   - g: Graph - The graph to search, edge weights must not be negative
+//   - source: int32 - ID of the node the search starts from
+//   - delta: float32 - Width of the buckets, must be positive
+//   - workers: int - Number of goroutines relaxing edges, runtime.GOMAXPROCS(0) if not positive
+//
+// Returns:
+//   - []float32: The cost of reaching every node, indexed by node ID, +Inf for unreachable nodes
+func DeltaStepping(g Graph, source int32, delta float32, workers int) []float32 {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	ds := deltaStepping{
+		g:      g,
+		delta:  delta,
+		dist:   make([]uint32, len(g.Nodes)),
+		queued: make([]int, len(g.Nodes)),
+	}
+	for i := range ds.dist {
+		ds.dist[i] = math.Float32bits(float32(math.Inf(1)))
+		ds.queued[i] = -1
+	}
+	ds.dist[source] = math.Float32bits(0)
+	ds.push([]int32{source})
+
+	settled := make([]int32, 0)
+	for i := 0; i < len(ds.buckets); i++ {
+		settled = settled[:0]
+		for len(ds.buckets[i]) > 0 {
+			frontier := ds.take(i)
+			settled = append(settled, frontier...)
+			ds.push(ds.relax(frontier, true, workers))
+		}
+		ds.push(ds.relax(settled, false, workers))
+	}
+
+	costs := make([]float32, len(ds.dist))
+	for i, bits := range ds.dist {
+		costs[i] = math.Float32frombits(bits)
+	}
+	return costs
+}
+
+// deltaStepping holds the state of a delta-stepping search.
+type deltaStepping struct {
+	g       Graph
+	delta   float32
+	dist    []uint32  // Tentative costs as float32 bits, updated atomically by the workers
+	buckets [][]int32 // buckets[i] holds the nodes whose tentative cost is in [i*delta, (i+1)*delta)
+	queued  []int     // queued[v] is the bucket v was last pushed to, -1 if none
+}
+
+// cost returns the tentative cost of a node.
+func (ds *deltaStepping) cost(v int32) float32 {
+	return math.Float32frombits(atomic.LoadUint32(&ds.dist[v]))
+}
+
+// bucket returns the index of the bucket matching a cost.
+func (ds *deltaStepping) bucket(cost float32) int {
+	return int(cost / ds.delta)
+}
+
+// take empties bucket i, returning the nodes it held whose cost still belongs to it.
+func (ds *deltaStepping) take(i int) []int32 {
+	frontier := make([]int32, 0, len(ds.buckets[i]))
+	for _, v := range ds.buckets[i] {
+		if ds.queued[v] == i {
+			ds.queued[v] = -1
+			frontier = append(frontier, v)
+		}
+	}
+	ds.buckets[i] = nil
+	return frontier
+}
+
+// push moves the nodes whose cost improved into the bucket matching their new cost.
+func (ds *deltaStepping) push(improved []int32) {
+	for _, v := range improved {
+		i := ds.bucket(ds.cost(v))
+		if ds.queued[v] == i {
+			continue
+		}
+		for len(ds.buckets) <= i {
+			ds.buckets = append(ds.buckets, nil)
+		}
+		ds.buckets[i] = append(ds.buckets[i], v)
+		ds.queued[v] = i
+	}
+}
+
+// relax relaxes the light or heavy edges leaving the given nodes, splitting them among workers,
+// and returns the nodes whose cost improved. A node may appear more than once.
+func (ds *deltaStepping) relax(nodes []int32, light bool, workers int) []int32 {
+	if len(nodes) < parallelFrontier || workers == 1 {
+		return ds.relaxRange(nodes, light, nil)
+	}
+	results := make([][]int32, workers)
+	chunk := (len(nodes) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		lo, hi := min(w*chunk, len(nodes)), min((w+1)*chunk, len(nodes))
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			results[w] = ds.relaxRange(nodes[lo:hi], light, nil)
+		}(w)
+	}
+	wg.Wait()
+	improved := make([]int32, 0)
+	for _, r := range results {
+		improved = append(improved, r...)
+	}
+	return improved
+}
+
+// relaxRange relaxes the light or heavy edges leaving nodes, appending improved nodes to improved.
+func (ds *deltaStepping) relaxRange(nodes []int32, light bool, improved []int32) []int32 {
+	for _, v := range nodes {
+		cost := ds.cost(v)
+		for _, e := range ds.g.OutgoingEdges[v] {
+			if (e.Weight <= ds.delta) != light {
+				continue
+			}
+			if ds.lower(e.ID, cost+e.Weight) {
+				improved = append(improved, e.ID)
+			}
+		}
+	}
+	return improved
+}
+
+// lower atomically sets the tentative cost of v to cost if it is an improvement, reporting whether it was.
+func (ds *deltaStepping) lower(v int32, cost float32) bool {
+	bits := math.Float32bits(cost)
+	for {
+		current := atomic.LoadUint32(&ds.dist[v])
+		if cost >= math.Float32frombits(current) {
+			return false
+		}
+		if atomic.CompareAndSwapUint32(&ds.dist[v], current, bits) {
+			return true
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package graph_search
+
+import "math"
+
+// detourEllipse restricts a search to the ellipses whose foci are a source and a target of the query:
+// a node v is explored only if, for some pair, the straight-line distance from the source to v plus the
+// one from v to the target is at most DetourFactor times the straight-line distance of the pair.
+type detourEllipse struct {
+	pairs []ellipseFoci
+}
+
+// ellipseFoci is one source and target pair of a query, in projected meters.
+type ellipseFoci struct {
+	sx, sy, tx, ty float64
+	limit          float64 // Largest sum of the distances to the foci inside the ellipse
+}
+
+// newDetourEllipse builds the ellipses of a query.
+//
+// Returns:
+//   - *detourEllipse: The ellipses, nil when the criteria set no DetourFactor or no target
+func newDetourEllipse(g Graph, c Criteria) *detourEllipse {
+	if c.DetourFactor <= 0 || len(c.Targets) == 0 {
+		return nil
+	}
+	factor := math.Max(c.DetourFactor, 1)
+	d := &detourEllipse{pairs: make([]ellipseFoci, 0, len(c.Source)*len(c.Targets))}
+	for _, s := range c.Source {
+		for _, t := range c.Targets {
+			sx, sy := nodeMeters(g, s)
+			tx, ty := nodeMeters(g, t)
+			d.pairs = append(d.pairs, ellipseFoci{sx, sy, tx, ty, factor * math.Hypot(tx-sx, ty-sy)})
+		}
+	}
+	return d
+}
+
+// allows reports whether a node lies inside one of the ellipses. Every node is allowed by a nil ellipse
+// and nodes without a valid location are always allowed.
+func (d *detourEllipse) allows(g Graph, id int32) bool {
+	if d == nil {
+		return true
+	}
+	x, y := nodeMeters(g, id)
+	for _, p := range d.pairs {
+		if !(math.Hypot(x-p.sx, y-p.sy)+math.Hypot(p.tx-x, p.ty-y) > p.limit) {
+			return true
+		}
+	}
+	return false
+}
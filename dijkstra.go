@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/golang/geo/s2"
 )
@@ -23,6 +24,126 @@ type Criteria struct {
 	// Targets contains the IDs of destination nodes for the search.
 	// Multiple targets enable finding paths to several destinations in one search operation.
 	Targets []int32
+
+	// AnyTarget stops the search at the first of Targets it settles, the cheapest one to reach, instead
+	// of at Targets[0], e.g., to route to the closest entrance of an area. Heuristic and Epsilon are
+	// ignored when there are several targets.
+	AnyTarget bool
+
+	// AllTargets keeps the search going until every node of Targets is settled instead of stopping at
+	// Targets[0], then stops: one-to-many queries do not scan the rest of the graph once the last target
+	// is found. The costs of the targets are final; those of the other nodes may not be. Heuristic and
+	// Epsilon are ignored when there are several targets.
+	AllTargets bool
+
+	// Departure is the moment the trip starts. It is only used by time-dependent searches
+	// to evaluate speed profiles and conditional restrictions; a zero value means midnight.
+	Departure time.Time
+
+	// Traffic supplies the speeds of time-dependent searches when set. Edges it has no speed for fall
+	// back to the weight function of the search.
+	Traffic TrafficProvider
+
+	// AvoidFerries excludes ferry crossings from the search.
+	AvoidFerries bool
+
+	// AvoidTolls excludes toll roads from the search.
+	AvoidTolls bool
+
+	// Country keeps the search inside a country when set: only edges whose MetaData.Country matches it
+	// are followed, which requires the graph to be tagged by AssignBoundaries.
+	Country string
+
+	// Vehicle describes the dimensions of the routed vehicle. When set, edges the vehicle
+	// cannot legally traverse are excluded from the search.
+	Vehicle *Vehicle
+
+	// ExcludedNodes and ExcludedEdges simulate closures, such as flooded roads or parades, without
+	// mutating the shared graph: the search never enters an excluded node nor follows an excluded edge.
+	// Source nodes are searched from even when excluded. A zero ExcludedNodes excludes nothing.
+	ExcludedNodes Bitset
+	ExcludedEdges *EdgeSet
+
+	// Overlay multiplies the cost of some edges after the cost model, e.g., the closures of a ClosureStore.
+	// Edges it scales by an infinite factor are excluded.
+	Overlay *WeightOverlay
+
+	// DetourFactor prunes the nodes whose straight-line distance from the source plus the one to the
+	// target exceeds DetourFactor times the straight-line distance between them when positive, e.g., 1.5;
+	// factors below 1 count as 1. Searches explore an ellipse around the query instead of a disc, much faster on long
+	// queries; routes are only optimal among those staying in the ellipse, so too small a factor misses
+	// detours around rivers or mountains. It requires Targets and is ignored without them.
+	DetourFactor float64
+
+	// Corridor restricts the search to the nodes within a buffer around a reference line when set, see
+	// NewCorridor. Sources are searched from even outside it; targets outside it are never reached.
+	Corridor *Corridor
+
+	// Epsilon turns the search into a weighted A* search towards the first target when positive: nodes are
+	// settled by cost plus (1 + Epsilon) times the Heuristic estimate of the cost left. The route found
+	// costs at most (1 + Epsilon) times the optimum, for a fraction of the settled nodes, which suits
+	// matrix computations where exactness does not matter. Costs of other settled nodes are not exact.
+	Epsilon float64
+
+	// Heuristic estimates the cost left to the target of A* searches. When Epsilon is set without it,
+	// StraightLineHeuristic is used for costs in meters and TravelTimeHeuristic for ObjectiveTime and
+	// time-dependent searches; Epsilon is ignored for other costs, e.g., a CostModel. Setting it with a
+	// zero Epsilon runs an exact A* search.
+	Heuristic Heuristic
+
+	// SurfaceTradeoff makes the search prefer good surfaces when positive: the cost of every edge is
+	// multiplied by 1 + SurfaceTradeoff * (1 - EdgeQuality). With a tradeoff of 1, a route on smooth
+	// asphalt is preferred to a gravel track up to 1.6 times as long.
+	SurfaceTradeoff float64
+
+	// Objective selects the precomputed edge weight minimized by the search: Edge.Weight by default,
+	// or the distance or travel time of the edges.
+	Objective Objective
+
+	// EdgeWeights replaces Edge.Weight by position when set: EdgeWeights[from][i] is the cost of the i-th
+	// outgoing edge of from, and an infinite weight closes the edge. MultiProfileGraph.Criteria sets it to
+	// the weights of a profile. It takes precedence over Objective.
+	EdgeWeights [][]float32
+
+	// CostModel replaces the edge weights as the cost minimized by the search when set,
+	// e.g., PercentileTravelTime(0.9) for on-time arrival guarantees. It takes precedence over Objective.
+	CostModel CostModel
+
+	// Comparator orders the nodes waiting to be settled. ByCost, which breaks cost ties
+	// deterministically, is used when nil.
+	Comparator Comparator
+
+	// CostArray stores the costs of the search in a slice indexed by node ID instead of a map when set.
+	// Every run resets it, so passing the same array to consecutive searches avoids reallocating it.
+	// Searches without targets, which settle most of the graph, allocate one automatically when the node
+	// IDs of the graph are dense.
+	CostArray *CostArray
+
+	// Heap is reset and used as the priority queue of the search when set, so consecutive searches
+	// reuse its memory. It is reordered by Comparator and ignored when BucketWidth is set.
+	Heap *Heap
+
+	// BucketWidth selects a BucketQueue (Dial's algorithm) with buckets of the given cost width instead
+	// of the binary heap when positive. Any width settles nodes in cost order, but widths above the
+	// smallest edge weight put more nodes in each bucket and slow the search down. See SuggestBucketWidth.
+	BucketWidth float32
+
+	// MaxSettledNodes aborts the search once it settled that many nodes without reaching its target when
+	// positive, so a single pathological query, e.g., after a bad snap, cannot exhaust the memory of a
+	// shared server. Response.Err is then a *SearchLimitError.
+	MaxSettledNodes int
+
+	// Metrics receives the number of settled nodes, the maximum queue size and the latency of the
+	// search when set.
+	Metrics Metrics
+
+	// AssignToNearestSource records, for every settled node, the source it was reached from in
+	// Response.NearestSource, partitioning the network by nearest facility in multi-source searches.
+	AssignToNearestSource bool
+
+	// Debug records the settlement order, frontier sizes and relaxation counts of the search
+	// in Response.Trace.
+	Debug bool
 }
 
 // PathCost represents the cost associated with reaching a specific node in the graph.
@@ -71,6 +192,8 @@ type SearchSpace Graph
 //
 //	coords := searchSpace.PathCoord(targetID, originalGraph)
 //	// coords might contain: [[lng1,lat1], [lng2,lat2], ...]
+//
+// Deprecated: Use SearchSpace.Route, which returns a typed Route exposing Coordinates, LngLat, Length and Duration.
 func (sp SearchSpace) PathCoord(target int32, g Graph) [][]float64 {
 	queue := list.New()
 	queue.PushBack(target)
@@ -128,6 +251,21 @@ type Response struct {
 	// Costs maps each node ID to its final computed cost from the source
 	// This map contains the shortest path costs for all reached nodes
 	Costs Costs
+
+	// Trace records how the search progressed, only set when Criteria.Debug is enabled
+	Trace *SearchTrace
+
+	// NearestSource maps every settled node to the source it was reached from, only set when
+	// Criteria.AssignToNearestSource is enabled
+	NearestSource map[int32]int32
+
+	// Err is a *SearchLimitError when the search was aborted by Criteria.MaxSettledNodes, in which case
+	// the other fields hold the nodes settled so far, nil otherwise
+	Err error
+
+	// Stats counts the nodes settled, the edges scanned and relaxed, and the largest size of the priority
+	// queue, with the wall time of the search
+	Stats SearchStats
 }
 
 // DijkstraSearch implements Dijkstra's shortest path algorithm with additional constraints
@@ -136,7 +274,7 @@ type Response struct {
 type DijkstraSearch struct {
 	// pq is a priority queue that manages nodes to visit based on their current costs
 	// It ensures that nodes are processed in order of increasing cost
-	pq *Heap
+	pq PriorityQueue
 
 	// visited tracks which nodes have been processed using a bitset for memory efficiency
 	visited Bitset
@@ -148,12 +286,37 @@ type DijkstraSearch struct {
 	// costs maps each node to its current best known cost from the source
 	costs Costs
 
+	// dense replaces costs when the search runs on a CostArray, nil otherwise
+	dense *CostArray
+
 	// sources tracks which nodes are designated as starting points using a bitset
 	sources Bitset
 
 	// target stores the ID of the destination node (-1 if no specific target)
 	// A specific target allows early termination when the destination is reached
 	target int32
+
+	// targets holds the targets not settled yet when the criteria set AnyTarget or AllTargets, nil otherwise
+	targets map[int32]struct{}
+
+	// criteria keeps the options the search was created with, used to decide which edges may be traversed
+	criteria Criteria
+
+	// trace records the settlement order when debugging is enabled, nil otherwise
+	trace *SearchTrace
+
+	// ellipse prunes the nodes too far off the straight line between sources and targets when the
+	// criteria set a DetourFactor, nil otherwise
+	ellipse *detourEllipse
+
+	// estimate returns the A* estimate of the cost left from a node to the target, nil for Dijkstra
+	estimate func(v int32) float32
+
+	// err is the reason the search was aborted, nil if it ran to completion
+	err error
+
+	// pauseAt is the cost above which RunUntil stops settling nodes, +Inf otherwise
+	pauseAt float32
 }
 
 // NewDijkstra creates and initializes a new DijkstraSearch instance with the specified criteria.
@@ -187,18 +350,39 @@ func NewDijkstra(c Criteria) DijkstraSearch {
 	if len(c.Targets) > 0 {
 		target = c.Targets[0]
 	}
+	var pq PriorityQueue = c.Heap
+	if c.Heap != nil {
+		c.Heap.Reset()
+		c.Heap.less = CreateWithComparator(c.Comparator).less
+	} else {
+		pq = CreateWithComparator(c.Comparator)
+	}
+	if c.BucketWidth > 0 {
+		pq = NewBucketQueue(c.BucketWidth)
+	}
 	search := DijkstraSearch{
-		pq:       Create(),
+		pq:       pq,
 		visited:  NewBigInt(),
 		previous: EmptyGraph(),
 		costs:    make(Costs, 0),
 		sources:  NewBigInt(),
 		target:   target,
+		criteria: c,
+		pauseAt:  float32(math.Inf(1)),
+	}
+	if c.Debug {
+		search.trace = &SearchTrace{Settled: make([]TraceEntry, 0)}
+	}
+	if (c.AnyTarget || c.AllTargets) && len(c.Targets) > 1 {
+		search.targets = make(map[int32]struct{}, len(c.Targets))
+		for _, t := range c.Targets {
+			search.targets[t] = struct{}{}
+		}
 	}
 
 	for _, s := range c.Source {
 		search.costs[s] = 0
-		search.pq.Insert(HNode{Value: s, Cost: 0, Depth: 0, Previous: 0})
+		search.pq.Insert(HNode{Value: s, Cost: 0, Depth: 0, Previous: -1})
 		search.sources.Set(s, true)
 	}
 
@@ -225,29 +409,74 @@ func NewDijkstra(c Criteria) DijkstraSearch {
 //   - The priority queue is empty (all reachable nodes processed)
 //   - Maximum hop count is reached (if specified in criteria)
 func (search DijkstraSearch) Run(g Graph) Response {
+	return search.run(g)
+}
+
+// run executes the search in place, so RunUntil can capture its state once it pauses.
+func (search *DijkstraSearch) run(g Graph) Response {
+	stats := newSearchStats()
+	defer func() { stats.report(search.criteria.Metrics) }()
+	search.selectCosts(g)
+	search.ellipse = newDetourEllipse(g, search.criteria)
+	search.selectHeuristic(func() Heuristic { return search.defaultHeuristic(g) })
+
 	currentID := int32(0)
 	for !search.isFinished() {
+		stats.observeQueue(search.pq)
 		min, _ := search.pq.Min()
-		if !search.wasVisited(min.Value) {
+		if min.Cost > search.pauseAt {
+			break
+		}
+		settled := !search.wasVisited(min.Value)
+		if settled && search.exceedsLimit(stats.settled) {
+			break
+		}
+		if settled {
 			currentID = search.addPrevious()
+			stats.settled++
 		}
 		search.visited.Set(min.Value, true)
 
 		if search.reachTarget(min.Value) {
-			return Response{
-				SearchSpace: SearchSpace(search.previous),
-				Costs:       search.costs,
+			search.trace.record(g, min, search.pq.Len(), 0)
+			break
+		}
+		relaxed := 0
+		for i, e := range g.Outgoing(min.Value) {
+			stats.scanned++
+			if !search.traversable(min.Value, i, e) || !search.ellipse.allows(g, e.ID) || !e.Metadata.Access.usuallyOpen() {
+				continue
+			}
+			if search.Relax(g.Nodes[e.ID], currentID, search.edgeCost(min.Value, i, e), e.Metadata.Distance) {
+				relaxed++
 			}
 		}
-		for _, e := range g.OutgoingEdges[min.Value] {
-			search.Relax(g.Nodes[e.ID], currentID, e.Weight, e.Metadata.Distance)
+		stats.relaxed += relaxed
+		if settled {
+			search.trace.record(g, min, search.pq.Len(), relaxed)
 		}
 		search.pq.DeleteMin()
 	}
-	return Response{
+	r := search.response()
+	r.Stats = stats.summary()
+	return r
+}
+
+// response builds the Response returned once the search is over.
+func (search DijkstraSearch) response() Response {
+	r := Response{
 		SearchSpace: SearchSpace(search.previous),
 		Costs:       search.costs,
+		Trace:       search.trace,
+		Err:         search.err,
+	}
+	if search.dense != nil {
+		r.Costs = search.dense.Costs()
 	}
+	if search.criteria.AssignToNearestSource {
+		r.NearestSource = r.SearchSpace.origins()
+	}
+	return r
 }
 
 // addPrevious adds the current node to the path tree and creates the appropriate
@@ -259,12 +488,12 @@ func (search DijkstraSearch) Run(g Graph) Response {
 // The method performs the following operations:
 //  1. Retrieves the minimum cost node from the priority queue
 //  2. Adds it to the previous graph structure
-//  3. Creates an edge from its parent if necessary
+//  3. Creates an edge from its parent, unless it is a source
 //  4. Updates the path cost information
 func (search *DijkstraSearch) addPrevious() int32 {
 	min, _ := search.pq.Min()
 	currentID := search.previous.AddNode(Node{Rank: min.Value})
-	if min.Previous != currentID {
+	if min.Previous >= 0 {
 		search.previous.RelateNodes(Node{ID: min.Previous}, Node{ID: currentID}, min.Cost, LeftToRight, MetaData{Distance: min.Dist})
 	}
 	return currentID
@@ -280,23 +509,127 @@ func (search *DijkstraSearch) addPrevious() int32 {
 //   - w: float32 - The time-based weight of the edge being considered
 //   - distance: float32 - The physical distance weight of the edge
 //
+// Returns:
+//   - bool: true if a shorter path to v was found
+//
 // The method performs the following steps:
 //  1. Checks if the destination node has been visited
 //  2. Calculates the new potential path cost
 //  3. Compares with the existing cost
 //  4. Updates the cost and priority queue if a shorter path is found
-func (search DijkstraSearch) Relax(v Node, currentID int32, w, distance float32) {
+func (search DijkstraSearch) Relax(v Node, currentID int32, w, distance float32) bool {
 	min, _ := search.pq.Min()
 	if !search.wasVisited(v.ID) {
-		cost := search.costs[min.Value]
+		cost, _ := search.cost(min.Value)
 		currentPathValue := cost + w
 		currentDistancePathValue := cost + distance
-		edgeC, _ := search.costs.GetCost(v.ID)
+		edgeC, _ := search.cost(v.ID)
 		if currentPathValue < edgeC {
-			search.costs[v.ID] = currentPathValue
-			search.pq.Insert(HNode{Value: v.ID, Cost: currentPathValue, Depth: min.Depth + 1, Previous: currentID, Dist: currentDistancePathValue})
+			search.setCost(v.ID, currentPathValue)
+			var estimate float32
+			if search.estimate != nil {
+				// Weighted estimates may shrink along an edge; keeping keys monotone (pathmax) ensures
+				// the node being expanded stays the minimum of the queue until it is deleted.
+				estimate = max(search.estimate(v.ID), min.Cost+min.Estimate-currentPathValue)
+			}
+			search.pq.Insert(HNode{Value: v.ID, Cost: currentPathValue, Depth: min.Depth + 1, Previous: currentID, Dist: currentDistancePathValue, Estimate: estimate})
+			return true
 		}
 	}
+	return false
+}
+
+// selectCosts moves the costs of the search to a CostArray when the criteria provide one, or when the
+// search has no target and the node IDs of the graph are dense.
+//
+// Parameters:
+//   - g: Graph - The graph the search is about to run on
+func (search *DijkstraSearch) selectCosts(g Graph) {
+	a := search.criteria.CostArray
+	if a == nil {
+		if search.target >= 0 || !denseIDs(g) {
+			return
+		}
+		a = NewCostArray(len(g.Nodes))
+	}
+	a.Reset()
+	a.grow(len(g.Nodes))
+	for id, c := range search.costs {
+		a.Set(id, c)
+	}
+	search.dense = a
+}
+
+// cost returns the best known cost of a node, INFINITE and false if it was not reached yet.
+func (search DijkstraSearch) cost(id int32) (float32, bool) {
+	if search.dense != nil {
+		return search.dense.Get(id)
+	}
+	c, ok := search.costs[id]
+	if !ok {
+		return INFINITE, false
+	}
+	return c, true
+}
+
+// setCost records a better cost for a node.
+func (search DijkstraSearch) setCost(id int32, c float32) {
+	if search.dense != nil {
+		search.dense.Set(id, c)
+		return
+	}
+	search.costs[id] = c
+}
+
+// traversable reports whether an edge may be used under the search criteria.
+//
+// Parameters:
+//   - from: int32 - The node the edge leaves from
+//   - i: int - Position of the edge in the outgoing edges of from
+//   - e: Edge - The edge about to be relaxed
+//
+// Returns:
+//   - bool: false if the edge or the node it leads to is excluded, if the edge is a ferry or toll road
+//     the criteria asks to avoid, if the vehicle of the criteria is not allowed on it, if its weight in
+//     EdgeWeights is infinite, or if it leaves the Corridor of the criteria, true otherwise
+func (search DijkstraSearch) traversable(from int32, i int, e Edge) bool {
+	if search.criteria.excluded(from, i, e) {
+		return false
+	}
+	if search.criteria.AvoidFerries && e.Metadata.Ferry {
+		return false
+	}
+	if search.criteria.AvoidTolls && e.Metadata.Toll {
+		return false
+	}
+	if search.criteria.Country != "" && e.Metadata.Country != search.criteria.Country {
+		return false
+	}
+	if search.criteria.Vehicle != nil && !search.criteria.Vehicle.CanTraverse(e.Metadata) {
+		return false
+	}
+	if w := search.criteria.EdgeWeights; w != nil && math.IsInf(float64(w[from][i]), 1) {
+		return false
+	}
+	if search.criteria.Corridor != nil && !search.criteria.Corridor.Contains(e.ID) {
+		return false
+	}
+	return true
+}
+
+// edgeCost returns the cost of traversing the i-th outgoing edge of a node under the cost model of the
+// criteria.
+func (search DijkstraSearch) edgeCost(from int32, i int, e Edge) float32 {
+	var cost float32
+	switch {
+	case search.criteria.CostModel != nil:
+		cost = search.criteria.CostModel(e)
+	case search.criteria.EdgeWeights != nil:
+		cost = search.criteria.EdgeWeights[from][i]
+	default:
+		cost = search.criteria.Objective.Weight(e)
+	}
+	return cost * search.criteria.overlayFactor(from, i) * search.criteria.surfaceFactor(e)
 }
 
 // reachTarget determines if the current node being processed is the target node,
@@ -306,9 +639,17 @@ func (search DijkstraSearch) Relax(v Node, currentID int32, w, distance float32)
 //   - currentValue: int32 - The ID of the current node being processed
 //
 // Returns:
-//   - bool: true if the current node is the target node and a target was specified,
-//     false otherwise or if no target was specified (target < 0)
+//   - bool: true if the current node is the target node, one of the targets when the criteria set
+//     AnyTarget, or the last target left when they set AllTargets, false otherwise or if no target was
+//     specified (target < 0)
 func (search DijkstraSearch) reachTarget(currentValue int32) bool {
+	if search.targets != nil {
+		if _, ok := search.targets[currentValue]; !ok || search.criteria.AnyTarget {
+			return ok
+		}
+		delete(search.targets, currentValue)
+		return len(search.targets) == 0
+	}
 	return search.target >= 0 && currentValue == search.target
 }
 
@@ -1,7 +1,16 @@
 package graph_search
 
 import (
+	"bytes"
+	"errors"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/qedus/osmpbf"
 )
 
 func TestConditionalDijkstra_ShortestPath(t *testing.T) {
@@ -36,3 +45,1120 @@ func TestConditionalDijkstra_ShortestPath(t *testing.T) {
 	}
 
 }
+
+func TestTimeDependentDijkstra_DepartureTime(t *testing.T) {
+	nodeA, nodeB, nodeC := Node{ID: 0}, Node{ID: 1}, Node{ID: 2}
+	g := EmptyGraph()
+	for _, n := range []Node{nodeA, nodeB, nodeC} {
+		g.AddNode(n)
+	}
+
+	rushHour := SpeedProfile{}
+	for h := range rushHour {
+		rushHour[h] = 36
+	}
+	rushHour[8] = 3.6
+
+	// a --1000m (36 km/h, 3.6 km/h at 8am)--> c
+	// a --1000m (18 km/h)--> b --1000m (18 km/h)--> c
+	g.RelateNodes(nodeA, nodeC, 1000, LeftToRight, MetaData{Distance: 1000, Profile: &rushHour})
+	g.RelateNodes(nodeA, nodeB, 1000, LeftToRight, MetaData{Distance: 1000, Speed: 18})
+	g.RelateNodes(nodeB, nodeC, 1000, LeftToRight, MetaData{Distance: 1000, Speed: 18})
+
+	for _, tc := range []struct {
+		hour     int
+		expected float32
+	}{
+		{hour: 2, expected: 100},
+		{hour: 8, expected: 400},
+	} {
+		response := NewTimeDependentDijkstra(Criteria{
+			Source:    []int32{0},
+			Targets:   []int32{2},
+			Departure: time.Date(2024, 1, 1, tc.hour, 0, 0, 0, time.UTC),
+		}, nil).Run(g)
+		c, _ := response.Costs.GetCost(2)
+		if c != tc.expected {
+			t.Fatalf("leaving at %d: got %f, expected %f", tc.hour, c, tc.expected)
+		}
+	}
+}
+
+func TestConditionalDijkstra_EdgeBasedGraph(t *testing.T) {
+	nodeA, nodeB, nodeC, nodeD := Node{ID: 0}, Node{ID: 1}, Node{ID: 2}, Node{ID: 3}
+	g := EmptyGraph()
+	for _, n := range []Node{nodeA, nodeB, nodeC, nodeD} {
+		g.AddNode(n)
+	}
+
+	// a --1--> b --1--> d
+	//          |        ^
+	//          1        5
+	//          v        |
+	//          c -------+
+	g.RelateNodes(nodeA, nodeB, 1, LeftToRight, MetaData{})
+	g.RelateNodes(nodeB, nodeD, 1, LeftToRight, MetaData{})
+	g.RelateNodes(nodeB, nodeC, 1, LeftToRight, MetaData{})
+	g.RelateNodes(nodeC, nodeD, 5, LeftToRight, MetaData{})
+
+	// Forbid turning from a onto b -> d.
+	eg, err := g.EdgeExpanded(func(from, via, to int32) (float32, bool) {
+		return 0, !(from == 0 && via == 1 && to == 3)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := NewDijkstra(eg.Criteria(Criteria{Source: []int32{0}, Targets: []int32{3}})).Run(eg.Graph)
+
+	expectedDistance := float32(7.0)
+	c, _ := response.Costs.GetCost(eg.ArrivalNode(3))
+	if expectedDistance != c {
+		t.Fatalf("got %f, expected %f", c, expectedDistance)
+	}
+	target := response.SearchSpace.Nodes[len(response.SearchSpace.Nodes)-1].ID
+	path := eg.OriginalPath(response.SearchSpace.PathNodes(target))
+	if len(path) != 4 || path[2] != 2 {
+		t.Fatalf("got path %v, expected [0 1 2 3]", path)
+	}
+}
+
+func TestDijkstra_AvoidFerriesAndTolls(t *testing.T) {
+	g := EmptyGraph()
+	nodes := make([]Node, 5)
+	for i := range nodes {
+		nodes[i] = Node{ID: g.AddNode(Node{})}
+	}
+
+	//   +--1 (ferry)--> b --1--+
+	//   |                      v
+	//   a --2 (toll)--> c --1--> e
+	//   |                      ^
+	//   +--10---------> d --1--+
+	g.RelateNodes(nodes[0], nodes[1], 1, LeftToRight, MetaData{Ferry: true})
+	g.RelateNodes(nodes[0], nodes[2], 2, LeftToRight, MetaData{Toll: true})
+	g.RelateNodes(nodes[0], nodes[3], 10, LeftToRight, MetaData{})
+	for _, n := range nodes[1:4] {
+		g.RelateNodes(n, nodes[4], 1, LeftToRight, MetaData{})
+	}
+
+	for _, tc := range []struct {
+		avoidFerries, avoidTolls bool
+		expected                 float32
+	}{
+		{expected: 2},
+		{avoidFerries: true, expected: 3},
+		{avoidTolls: true, expected: 2},
+		{avoidFerries: true, avoidTolls: true, expected: 11},
+	} {
+		response := NewDijkstra(Criteria{
+			Source: []int32{0}, Targets: []int32{4}, AvoidFerries: tc.avoidFerries, AvoidTolls: tc.avoidTolls,
+		}).Run(g)
+		if c, _ := response.Costs.GetCost(4); c != tc.expected {
+			t.Errorf("avoiding ferries %t and tolls %t: got %f, expected %f", tc.avoidFerries, tc.avoidTolls, c, tc.expected)
+		}
+	}
+}
+
+func TestConditionalDijkstra_BucketQueue(t *testing.T) {
+	g := EmptyGraph()
+	for i := 0; i < 6; i++ {
+		g.AddNode(Node{})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1, Bidirectional, MetaData{})
+	g.RelateNodes(g.Nodes[0], g.Nodes[4], 2, Bidirectional, MetaData{})
+	g.RelateNodes(g.Nodes[4], g.Nodes[5], 2, Bidirectional, MetaData{})
+	g.RelateNodes(g.Nodes[5], g.Nodes[3], 2, Bidirectional, MetaData{})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 1, Bidirectional, MetaData{})
+	g.RelateNodes(g.Nodes[2], g.Nodes[3], 1, Bidirectional, MetaData{})
+
+	width := SuggestBucketWidth(g)
+	if width != 1 {
+		t.Fatalf("got bucket width %f, expected 1", width)
+	}
+	heap := NewDijkstra(Criteria{Source: []int32{0}}).Run(g)
+	buckets := NewDijkstra(Criteria{Source: []int32{0}, BucketWidth: width}).Run(g)
+	for id, expected := range heap.Costs {
+		if c, _ := buckets.Costs.GetCost(id); c != expected {
+			t.Fatalf("node %d: got %f, expected %f", id, c, expected)
+		}
+	}
+}
+
+func TestConditionalDijkstra_WideBuckets(t *testing.T) {
+	g := EmptyGraph()
+	for i := 0; i < 4; i++ {
+		g.AddNode(Node{})
+	}
+	// Every node falls in the first bucket, and the expensive edge to 2 is inserted before the cheap
+	// path through 1.
+	g.RelateNodes(g.Nodes[0], g.Nodes[2], 3, LeftToRight, MetaData{})
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1, LeftToRight, MetaData{})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 1, LeftToRight, MetaData{})
+	g.RelateNodes(g.Nodes[2], g.Nodes[3], 1, LeftToRight, MetaData{})
+
+	heap := NewDijkstra(Criteria{Source: []int32{0}}).Run(g)
+	buckets := NewDijkstra(Criteria{Source: []int32{0}, BucketWidth: 10}).Run(g)
+	for id, expected := range heap.Costs {
+		if c, _ := buckets.Costs.GetCost(id); c != expected {
+			t.Fatalf("node %d: got %f, expected %f", id, c, expected)
+		}
+	}
+	for i, n := range buckets.SearchSpace.Nodes {
+		if n.Rank != heap.SearchSpace.Nodes[i].Rank {
+			t.Fatalf("settled node %d at position %d, expected %d", n.Rank, i, heap.SearchSpace.Nodes[i].Rank)
+		}
+	}
+}
+
+func TestConditionalDijkstra_Debug(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	response := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{8}, Debug: true}).Run(g)
+	if response.Trace == nil {
+		t.Fatal("expected a trace")
+	}
+	settled := response.Trace.Settled
+	if len(settled) != len(response.SearchSpace.Nodes) {
+		t.Fatalf("got %d trace entries, expected %d", len(settled), len(response.SearchSpace.Nodes))
+	}
+	if settled[0].Node != 0 || settled[len(settled)-1].Node != 8 {
+		t.Fatalf("got first %d and last %d settled, expected 0 and 8", settled[0].Node, settled[len(settled)-1].Node)
+	}
+	for i := 1; i < len(settled); i++ {
+		if settled[i].Cost < settled[i-1].Cost {
+			t.Fatalf("node %d settled at %f after a node settled at %f", settled[i].Node, settled[i].Cost, settled[i-1].Cost)
+		}
+	}
+	if fc := response.DebugGeoJSON(); len(fc.Features) != len(settled) {
+		t.Fatalf("got %d features, expected %d", len(fc.Features), len(settled))
+	}
+}
+
+func TestConditionalDijkstra_Stats(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	stats := NewDijkstra(Criteria{Source: []int32{0}}).Run(g).Stats
+	// Every node is settled once and all 24 edges of the grid are scanned from their settled source.
+	if stats.Settled != 9 || stats.Scanned < 24 || stats.Relaxed < 8 || stats.MaxHeapSize == 0 {
+		t.Fatalf("got %+v, expected 9 nodes settled, 24 edges scanned and 8 relaxations at least", stats)
+	}
+
+	targeted := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{1}}).Run(g).Stats
+	if targeted.Settled >= stats.Settled || targeted.Scanned >= stats.Scanned {
+		t.Errorf("got %+v for a close target, expected less work than %+v", targeted, stats)
+	}
+}
+
+func TestGraph_Reachable(t *testing.T) {
+	g := RandomGeometricGraph(200, 2000, 300, 7)
+	full := NewDijkstra(Criteria{Source: []int32{0}}).Run(g)
+
+	budget := float32(800)
+	r := g.Reachable(0, budget)
+	for id, expected := range full.Costs {
+		c, ok := r.Cost(id)
+		if ok != (expected <= budget) {
+			t.Fatalf("node %d with cost %f: reachable = %v", id, expected, ok)
+		}
+		if ok && c != expected {
+			t.Fatalf("node %d: got %f, expected %f", id, c, expected)
+		}
+	}
+	if r.Len() == 0 || r.Len() == len(g.Nodes) {
+		t.Fatalf("got %d reachable nodes, expected the budget to cut the search", r.Len())
+	}
+}
+
+func TestDeltaStepping(t *testing.T) {
+	g := RandomGeometricGraph(2000, 5000, 250, 3)
+	expected := NewDijkstra(Criteria{Source: []int32{0}}).Run(g).Costs
+
+	for _, workers := range []int{1, 4} {
+		costs := DeltaStepping(g, 0, 150, workers)
+		for id, c := range costs {
+			want, err := expected.GetCost(int32(id))
+			if err != nil {
+				want = float32(math.Inf(1))
+			}
+			if c != want && math.Abs(float64(c-want)) > 1e-2 {
+				t.Fatalf("%d workers, node %d: got %f, expected %f", workers, id, c, want)
+			}
+		}
+	}
+}
+
+func TestCCH_Customize(t *testing.T) {
+	g := RandomGeometricGraph(600, 3000, 250, 5)
+	cch := NewCCH(g)
+
+	check := func(m *CCHMetric, weight func(Edge) float32) {
+		weighted := EmptyGraph()
+		for _, n := range g.Nodes {
+			weighted.AddNode(n)
+		}
+		for from, edges := range g.OutgoingEdges {
+			for _, e := range edges {
+				weighted.RelateNodes(g.Nodes[from], g.Nodes[e.ID], weight(e), LeftToRight, e.Metadata)
+			}
+		}
+		expected := NewDijkstra(Criteria{Source: []int32{3}}).Run(weighted).Costs
+		for _, target := range []int32{0, 42, 250, 599} {
+			want, err := expected.GetCost(target)
+			cost, route, cchErr := m.ShortestPath(3, target)
+			if (err != nil) != (cchErr != nil) {
+				t.Fatalf("target %d: got error %v, expected %v", target, cchErr, err)
+			}
+			if err != nil {
+				continue
+			}
+			if math.Abs(float64(cost-want)) > 1e-2 {
+				t.Fatalf("target %d: got cost %f, expected %f", target, cost, want)
+			}
+			if route.Nodes[0] != 3 || route.Nodes[len(route.Nodes)-1] != target || len(route.Edges) != len(route.Nodes)-1 {
+				t.Fatalf("target %d: got route %v", target, route.Nodes)
+			}
+		}
+	}
+	check(cch.Customize(nil), func(e Edge) float32 { return e.Weight })
+
+	congested := func(e Edge) float32 { return e.Weight * float32(1+e.ID%3) }
+	check(cch.Customize(congested), congested)
+}
+
+func TestCCHMetric_Matrix(t *testing.T) {
+	g := RandomGeometricGraph(400, 3000, 250, 9)
+	g.AddNode(Node{}) // isolated node 400
+	m := NewCCH(g).Customize(nil)
+
+	sources := []int32{0, 17, 123, 399, 400}
+	targets := []int32{5, 17, 250, 400}
+	matrix, err := m.Matrix(sources, targets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, s := range sources {
+		row, _ := g.OneToMany(s, targets, Criteria{})
+		for j := range targets {
+			got, want := float64(matrix[i][j]), float64(row[j])
+			if math.IsInf(got, 1) != math.IsInf(want, 1) || (!math.IsInf(want, 1) && math.Abs(got-want) > 1e-2) {
+				t.Errorf("cost from %d to %d: got %f, expected %f", s, targets[j], got, want)
+			}
+		}
+	}
+	if _, err := m.Matrix([]int32{0}, []int32{401}); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("got %v, expected ErrNodeNotFound", err)
+	}
+}
+
+func TestHubLabels_DistanceHL(t *testing.T) {
+	g := RandomGeometricGraph(300, 3000, 300, 21)
+	g.RelateNodes(g.Nodes[0], g.Nodes[299], 1, LeftToRight, MetaData{}) // a cheap one-way shortcut
+	g.AddNode(Node{})                                                   // isolated node 300
+	congested := func(e Edge) float32 { return e.Weight * float32(1+e.ID%3) }
+	hl := NewCCH(g).Customize(congested).HubLabels()
+
+	weighted := EmptyGraph()
+	for _, n := range g.Nodes {
+		weighted.AddNode(n)
+	}
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			weighted.RelateNodes(g.Nodes[from], g.Nodes[e.ID], congested(e), LeftToRight, e.Metadata)
+		}
+	}
+	targets := []int32{0, 7, 150, 299, 300}
+	for _, s := range []int32{0, 33, 299, 300} {
+		row, _ := weighted.OneToMany(s, targets, Criteria{})
+		for j, target := range targets {
+			cost, err := hl.DistanceHL(s, target)
+			if math.IsInf(float64(row[j]), 1) {
+				if !errors.Is(err, ErrNoRoute) {
+					t.Errorf("%d to %d: got %v, expected ErrNoRoute", s, target, err)
+				}
+				continue
+			}
+			if err != nil || math.Abs(float64(cost-row[j])) > 1e-2 {
+				t.Errorf("%d to %d: got %f and %v, expected %f", s, target, cost, err, row[j])
+			}
+		}
+	}
+	if _, err := hl.DistanceHL(0, 301); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("got %v, expected ErrNodeNotFound", err)
+	}
+}
+
+func TestConditionalDijkstra_Exclusions(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	base, _ := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{2}}).Run(g).Costs.GetCost(2)
+
+	closed := NewBigInt()
+	closed.Set(1, true)
+	detour, _ := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{2}, ExcludedNodes: closed}).Run(g).Costs.GetCost(2)
+	if detour <= base {
+		t.Fatalf("got cost %f avoiding node 1, expected more than %f", detour, base)
+	}
+
+	edges := NewEdgeSet(g)
+	edges.Add(g, 1, 2)
+	edges.Add(g, 5, 2)
+	response := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{2}, ExcludedEdges: edges}).Run(g)
+	if _, err := response.Costs.GetCost(2); err == nil {
+		t.Fatal("node 2 should be unreachable once both edges entering it are closed")
+	}
+	if len(g.OutgoingEdges[1]) != 3 {
+		t.Fatal("exclusions must not mutate the graph")
+	}
+}
+
+func TestConditionalDijkstra_PercentileTravelTime(t *testing.T) {
+	// a --fast but unreliable--> b, a --slightly slower, reliable--> c --> b
+	g := EmptyGraph()
+	for i := 0; i < 3; i++ {
+		g.AddNode(Node{})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1000, LeftToRight, MetaData{Distance: 1000, Speed: 36, TravelTimeVariance: 400})
+	g.RelateNodes(g.Nodes[0], g.Nodes[2], 600, LeftToRight, MetaData{Distance: 600, Speed: 36})
+	g.RelateNodes(g.Nodes[2], g.Nodes[1], 600, LeftToRight, MetaData{Distance: 600, Speed: 36})
+
+	for _, tc := range []struct {
+		percentile float64
+		expected   []int32
+	}{{0.5, []int32{0, 1}}, {0.9, []int32{0, 2, 1}}} {
+		r := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{1}, CostModel: PercentileTravelTime(tc.percentile)}).Run(g)
+		route := r.SearchSpace.Route(int32(len(r.SearchSpace.Nodes)-1), g)
+		if len(route.Nodes) != len(tc.expected) {
+			t.Fatalf("percentile %.1f: got route %v, expected %v", tc.percentile, route.Nodes, tc.expected)
+		}
+		if p90 := route.TravelTimePercentile(0.9); tc.percentile == 0.9 && p90 != 120 {
+			t.Fatalf("got 90th percentile %f for the reliable route, expected 120", p90)
+		}
+	}
+}
+
+func TestConditionalDijkstra_Objective(t *testing.T) {
+	// a --short, slow--> b, a --long, fast--> c --> b, built with hop counts as weights
+	g := EmptyGraph()
+	for i := 0; i < 3; i++ {
+		g.AddNode(Node{})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1, LeftToRight, MetaData{Distance: 1000, Speed: 20})
+	g.RelateNodes(g.Nodes[0], g.Nodes[2], 1, LeftToRight, MetaData{Distance: 800, Duration: 30})
+	g.RelateNodes(g.Nodes[2], g.Nodes[1], 1, LeftToRight, MetaData{Distance: 800, Speed: 100})
+
+	for _, tc := range []struct {
+		objective Objective
+		expected  []int32
+		cost      float32
+	}{{ObjectiveWeight, []int32{0, 1}, 1}, {ObjectiveDistance, []int32{0, 1}, 1000}, {ObjectiveTime, []int32{0, 2, 1}, 58.8}} {
+		r := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{1}, Objective: tc.objective}).Run(g)
+		route := r.SearchSpace.Route(int32(len(r.SearchSpace.Nodes)-1), g)
+		cost, _ := r.Costs.GetCost(1)
+		if len(route.Nodes) != len(tc.expected) || math.Abs(float64(cost-tc.cost)) > 1e-3 {
+			t.Fatalf("%s: got route %v with cost %f, expected %v with cost %f", tc.objective, route.Nodes, cost, tc.expected, tc.cost)
+		}
+	}
+}
+
+func TestConditionalDijkstra_AssignToNearestSource(t *testing.T) {
+	g := GridGraph(1, 7, 100)
+	response := NewDijkstra(Criteria{Source: []int32{0, 6}, AssignToNearestSource: true}).Run(g)
+
+	for node, expected := range map[int32]int32{0: 0, 1: 0, 2: 0, 4: 6, 5: 6, 6: 6} {
+		if got := response.NearestSource[node]; got != expected {
+			t.Fatalf("node %d: got source %d, expected %d", node, got, expected)
+		}
+	}
+	facilities := response.Facilities()
+	if len(facilities[0])+len(facilities[6]) != 7 {
+		t.Fatalf("got facilities %v, expected every node to be assigned", facilities)
+	}
+	if path := response.SearchSpace.PathNodes(int32(len(response.SearchSpace.Nodes) - 1)); len(path) > 4 {
+		t.Fatalf("got path %v, expected it to start at the nearest source", path)
+	}
+}
+
+func TestGraph_IsochroneRings(t *testing.T) {
+	g := RandomGeometricGraph(2000, 5000, 250, 11)
+	thresholds := []time.Duration{3 * time.Minute, time.Minute, 2 * time.Minute}
+	rings := g.IsochroneRings(0, thresholds, 150)
+	if len(rings) != 3 || rings[0].Threshold != time.Minute || rings[2].MinThreshold != 2*time.Minute {
+		t.Fatalf("got rings %+v, expected them sorted by threshold", rings)
+	}
+
+	inside := func(polygon [][]Coordinate, p Coordinate) bool {
+		in := false
+		for _, ring := range polygon {
+			for i := 0; i+1 < len(ring); i++ {
+				a, b := ring[i], ring[i+1]
+				if (a.Lat > p.Lat) != (b.Lat > p.Lat) && p.Lng < a.Lng+(p.Lat-a.Lat)*(b.Lng-a.Lng)/(b.Lat-a.Lat) {
+					in = !in
+				}
+			}
+		}
+		return in
+	}
+	reach := g.reachable(0, float32(3*time.Minute.Seconds()), func(e Edge) float32 { return float32(edgeDuration(e)) })
+	for _, v := range reach.Nodes {
+		cost, _ := reach.Cost(v)
+		p := nodeCoordinate(g.Nodes[v])
+		covering := make([]int, 0)
+		for k, ring := range rings {
+			for _, polygon := range ring.Polygons {
+				if inside(polygon, p) {
+					covering = append(covering, k)
+				}
+			}
+		}
+		if len(covering) != 1 {
+			t.Fatalf("node %d is covered by rings %v, expected exactly one", v, covering)
+		}
+		if k := covering[0]; k > 0 && cost <= float32(rings[k].MinThreshold.Seconds()) {
+			t.Fatalf("node %d reached in %fs lies in ring %d", v, cost, covering[0])
+		}
+	}
+}
+
+func TestConditionalDijkstra_CostArray(t *testing.T) {
+	g := RandomGeometricGraph(500, 2000, 250, 5)
+	costs := NewCostArray(len(g.Nodes))
+	for _, source := range []int32{0, 7, 42} {
+		expected := NewDijkstra(Criteria{Source: []int32{source}, Targets: []int32{499}}).Run(g).Costs
+		got := NewDijkstra(Criteria{Source: []int32{source}, Targets: []int32{499}, CostArray: costs}).Run(g).Costs
+		if len(got) != len(expected) || costs.Len() != len(expected) {
+			t.Fatalf("source %d: got %d costs, expected %d", source, len(got), len(expected))
+		}
+		for id, c := range expected {
+			if got[id] != c {
+				t.Fatalf("source %d, node %d: got %f, expected %f", source, id, got[id], c)
+			}
+		}
+	}
+
+	costs.generation = math.MaxUint32
+	costs.Reset()
+	if _, ok := costs.Get(0); ok || costs.Len() != 0 {
+		t.Fatal("costs survived a reset wrapping the generation around")
+	}
+}
+
+func TestWeightedGraph_ShortestPaths(t *testing.T) {
+	// A long chain of tiny weights: float32 sums drift, float64 and integer milliseconds do not.
+	const n = 100000
+	chain := NewWeightedGraph[float64](n)
+	millis := NewWeightedGraph[int64](n)
+	for v := int32(0); v+1 < n; v++ {
+		_ = chain.AddEdge(v, v+1, 0.1)
+		_ = millis.AddEdge(v, v+1, 100)
+	}
+	paths, err := chain.ShortestPaths(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := paths.Costs[n-1]; math.Abs(got-9999.9) > 1e-6 {
+		t.Fatalf("got float64 cost %f, expected 9999.9", got)
+	}
+	exact, _ := millis.ShortestPaths(0)
+	if got := exact.Costs[n-1]; got != 9999900 {
+		t.Fatalf("got %d ms, expected 9999900", got)
+	}
+
+	g := GridGraph(4, 4, 100)
+	weighted, err := ToWeighted(g, func(e Edge) float64 { return float64(e.Weight) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := NewDijkstra(Criteria{Source: []int32{0}}).Run(g).Costs
+	result, _ := weighted.ShortestPaths(0)
+	for id, c := range expected {
+		if math.Abs(result.Costs[id]-float64(c)) > 1e-2 {
+			t.Fatalf("node %d: got %f, expected %f", id, result.Costs[id], c)
+		}
+	}
+	if path := result.Path(15); len(path) == 0 || path[0] != 0 || path[len(path)-1] != 15 {
+		t.Fatalf("got path %v from 0 to 15", path)
+	}
+	if err := weighted.AddEdge(0, 1, -1); !errors.Is(err, ErrNegativeWeight) {
+		t.Fatalf("got %v, expected ErrNegativeWeight", err)
+	}
+}
+
+func TestMultiProfileGraph_Route(t *testing.T) {
+	m, err := NewMultiProfileGraph()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := map[int64]int32{1: 0, 2: 0, 3: 0}
+	for id := int64(1); id <= 3; id++ {
+		m.AddNode(&osmpbf.Node{ID: id, Lat: 6.2 + float64(id)*0.001, Lon: -75.58}, nodes)
+	}
+	m.AddWay(&osmpbf.Way{ID: 1, NodeIDs: []int64{1, 2}, Tags: map[string]string{Highway: Motorway}}, nodes)
+	m.AddWay(&osmpbf.Way{ID: 2, NodeIDs: []int64{2, 3}, Tags: map[string]string{Highway: Footway}}, nodes)
+	m.AddWay(&osmpbf.Way{ID: 3, NodeIDs: []int64{1, 3}, Tags: map[string]string{Highway: Residential, Oneway: Yes}}, nodes)
+	m.AddWay(&osmpbf.Way{ID: 4, NodeIDs: []int64{1, 2}, Tags: map[string]string{Highway: Steps, Foot: No}}, nodes)
+
+	edges := 0
+	for _, out := range m.Graph.OutgoingEdges {
+		edges += len(out)
+	}
+	if edges != 6 {
+		t.Fatalf("got %d edges, expected 6", edges)
+	}
+
+	a, b, c := nodes[1], nodes[2], nodes[3]
+	distance := m.Graph.OutgoingEdges[a][1].Metadata.Distance
+	tests := []struct {
+		profile        TravelProfile
+		source, target int32
+		cost           float32 // -1 when unreachable
+	}{
+		{ProfileCar, a, c, distance / (SpeedCar / 3.6)},
+		{ProfileBike, a, c, distance / (SpeedBike / 3.6)},
+		{ProfileFoot, c, a, distance / (SpeedFoot / 3.6)},
+		{ProfileBike, c, a, -1},
+		{ProfileCar, c, b, -1},
+		{ProfileBike, b, c, -1},
+	}
+	for _, tc := range tests {
+		r, err := m.Route(tc.profile, Criteria{Source: []int32{tc.source}, Targets: []int32{tc.target}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		cost, err := r.Costs.GetCost(tc.target)
+		if tc.cost < 0 {
+			if err == nil {
+				t.Fatalf("%s: %d reached %d at cost %f, expected no route", tc.profile, tc.source, tc.target, cost)
+			}
+			continue
+		}
+		if err != nil || math.Abs(float64(cost-tc.cost)) > 1e-2 {
+			t.Fatalf("%s: got cost %f (%v) from %d to %d, expected %f", tc.profile, cost, err, tc.source, tc.target, tc.cost)
+		}
+	}
+
+	if _, err := m.Route("horse", Criteria{Source: []int32{a}}); !errors.Is(err, ErrUnknownProfile) {
+		t.Fatalf("got %v, expected ErrUnknownProfile", err)
+	}
+}
+
+func TestMultiProfileGraph_ApplyGrades(t *testing.T) {
+	m, err := NewMultiProfileGraph(ProfileBike, ProfileFoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := map[int64]int32{1: 0, 2: 0}
+	for id := int64(1); id <= 2; id++ {
+		m.AddNode(&osmpbf.Node{ID: id, Lat: 6.2 + float64(id)*0.001, Lon: -75.58}, nodes)
+	}
+	m.AddWay(&osmpbf.Way{ID: 1, NodeIDs: []int64{1, 2}, Tags: map[string]string{Highway: Residential}}, nodes)
+	low, high := nodes[1], nodes[2]
+	distance := float64(m.Graph.OutgoingEdges[low][0].Metadata.Distance)
+	// 10% climb from the southern node to the northern one.
+	dem := ElevationFunc(func(c Coordinate) (float64, bool) {
+		return (c.Lat - 6.201) / 0.001 * distance / 10, true
+	})
+	cost := func(p TravelProfile, from, to int32) float64 {
+		r, err := m.Route(p, Criteria{Source: []int32{from}, Targets: []int32{to}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, _ := r.Costs.GetCost(to)
+		return float64(c)
+	}
+	flatBike := cost(ProfileBike, low, high)
+
+	if err := m.ApplyGrades(dem, nil); err != nil {
+		t.Fatal(err)
+	}
+	up, down := cost(ProfileFoot, low, high), cost(ProfileFoot, high, low)
+	want := distance / (SpeedFoot * math.Exp(-0.35) / 3.6)
+	if math.Abs(up-want) > 0.5 || down >= up {
+		t.Fatalf("got %.1f s up and %.1f s down on foot, expected %.1f s up and less down", up, down, want)
+	}
+	if up, down := cost(ProfileBike, low, high), cost(ProfileBike, high, low); up <= 2*flatBike || down >= flatBike {
+		t.Fatalf("got %.1f s up and %.1f s down by bike, %.1f s on flat ground", up, down, flatBike)
+	}
+
+	if err := m.ApplyGrades(dem, map[TravelProfile]GradeModel{ProfileCar: ToblerHiking}); !errors.Is(err, ErrUnknownProfile) {
+		t.Fatalf("got %v, expected ErrUnknownProfile", err)
+	}
+}
+
+func TestMultiProfileGraph_BikeStressTolerance(t *testing.T) {
+	via := func(tolerance float64) int32 {
+		m, err := NewMultiProfileGraph(ProfileBike)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m.BikeStressTolerance = tolerance
+		nodes := map[int64]int32{1: 0, 2: 0, 3: 0}
+		m.AddNode(&osmpbf.Node{ID: 1, Lat: 6.201, Lon: -75.58}, nodes)
+		m.AddNode(&osmpbf.Node{ID: 2, Lat: 6.202, Lon: -75.58}, nodes)
+		m.AddNode(&osmpbf.Node{ID: 3, Lat: 6.2015, Lon: -75.5795}, nodes)
+		m.AddWay(&osmpbf.Way{ID: 1, NodeIDs: []int64{1, 2}, Tags: map[string]string{Highway: Primary}}, nodes)
+		m.AddWay(&osmpbf.Way{ID: 2, NodeIDs: []int64{1, 3, 2}, Tags: map[string]string{Highway: Cycleway}}, nodes)
+		r, err := m.Route(ProfileBike, Criteria{Source: []int32{nodes[1]}, Targets: []int32{nodes[2]}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		route := r.SearchSpace.Route(int32(len(r.SearchSpace.Nodes)-1), m.Graph)
+		if len(route.Nodes) == 3 {
+			return route.Nodes[1]
+		}
+		return -1
+	}
+	if got := via(1); got != -1 {
+		t.Fatalf("got a detour via %d with full tolerance, expected the direct primary road", got)
+	}
+	if got := via(0); got != 2 {
+		t.Fatalf("got route via %d without tolerance, expected the cycleway via node 2", got)
+	}
+
+	lane := wayMetaData(&osmpbf.Way{Tags: map[string]string{Highway: Secondary, CyclewayRight: Lane}}, 100, SpeedBike, nil)
+	if lane.Cycleway != BikeLane || TrafficStress(lane) != 2 {
+		t.Fatalf("got %v at stress %d, expected a bike lane at stress 2", lane.Cycleway, TrafficStress(lane))
+	}
+}
+
+func TestParseConditional(t *testing.T) {
+	rules, err := ParseConditional("no @ (Mo-Fr 07:00-09:00,16:00-18:00; Sa 22:00-02:00); yes @ (weight < 3.5)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 1 || rules[0].Value != No || len(rules[0].Windows) != 3 {
+		t.Fatalf("got %+v, expected one no rule with 3 windows", rules)
+	}
+	access := ConditionalAccess{Closed: rules[0].Windows}
+	for _, tc := range []struct {
+		at   time.Time
+		open bool
+	}{
+		{time.Date(2024, 5, 6, 8, 30, 0, 0, time.UTC), false}, // Monday
+		{time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC), true},
+		{time.Date(2024, 5, 11, 8, 30, 0, 0, time.UTC), true},  // Saturday
+		{time.Date(2024, 5, 11, 23, 0, 0, 0, time.UTC), false}, // Saturday night...
+		{time.Date(2024, 5, 12, 1, 0, 0, 0, time.UTC), false},  // ...until Sunday 02:00
+		{time.Date(2024, 5, 13, 1, 0, 0, 0, time.UTC), true},
+	} {
+		if got := access.OpenAt(tc.at); got != tc.open {
+			t.Errorf("%v: got open %t, expected %t", tc.at, got, tc.open)
+		}
+	}
+	if _, err := ParseConditional("no @ (Mo 07:00-9)"); !errors.Is(err, ErrInvalidCondition) {
+		t.Fatalf("got %v, expected ErrInvalidCondition", err)
+	}
+}
+
+func TestTimeDependentDijkstra_ConditionalAccess(t *testing.T) {
+	// A school street 1 - 2 closed on weekday mornings, and a detour 1 - 3 - 2.
+	g := EmptyGraph()
+	nodes := map[int64]int32{1: 0, 2: 0, 3: 0, 4: 0}
+	for id, c := range map[int64][2]float64{1: {6.2, -75.58}, 2: {6.201, -75.58}, 3: {6.2005, -75.579}, 4: {6.202, -75.58}} {
+		buildNode(&g, &osmpbf.Node{ID: id, Lat: c[0], Lon: c[1]}, nodes, nil)
+	}
+	ways := make(map[int64][]int32)
+	for _, w := range []osmpbf.Way{
+		{ID: 1, NodeIDs: []int64{1, 2}, Tags: map[string]string{Highway: Residential, AccessConditional: "no @ (Mo-Fr 07:00-10:00)"}},
+		{ID: 2, NodeIDs: []int64{1, 3, 2}, Tags: map[string]string{Highway: Residential}},
+		{ID: 3, NodeIDs: []int64{2, 4}, Tags: map[string]string{Highway: Residential, Oneway: Yes, OnewayConditional: "no @ (Sa,Su 00:00-24:00)"}},
+	} {
+		buildWay(&g, &w, nodes, ways, nil)
+	}
+	a, b, c, d := nodes[1], nodes[2], nodes[3], nodes[4]
+
+	route := func(departure time.Time, source, target int32) []int32 {
+		r := NewTimeDependentDijkstra(Criteria{Source: []int32{source}, Targets: []int32{target}, Departure: departure}, nil).Run(g)
+		if _, err := r.Costs.GetCost(target); err != nil {
+			return nil
+		}
+		return r.SearchSpace.PathNodes(int32(len(r.SearchSpace.Nodes) - 1))
+	}
+	monday, saturday := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC), time.Date(2024, 5, 11, 8, 0, 0, 0, time.UTC)
+	if path := route(monday, a, b); len(path) != 3 || path[1] != c {
+		t.Fatalf("got %v on Monday morning, expected the detour via %d", path, c)
+	}
+	if path := route(monday.Add(4*time.Hour), a, b); len(path) != 2 {
+		t.Fatalf("got %v on Monday noon, expected the direct street", path)
+	}
+	if path := route(monday, d, b); path != nil {
+		t.Fatalf("got %v against the one-way street on Monday", path)
+	}
+	if path := route(saturday, d, b); len(path) != 2 {
+		t.Fatalf("got %v against the one-way street on Saturday, expected it to be open", path)
+	}
+	if _, err := NewDijkstra(Criteria{Source: []int32{d}, Targets: []int32{b}}).Run(g).Costs.GetCost(b); err == nil {
+		t.Fatal("a search ignoring time used the weekend contraflow")
+	}
+}
+
+func TestTimeDependentDijkstra_Traffic(t *testing.T) {
+	g := EmptyGraph()
+	for i := 0; i < 3; i++ {
+		g.AddNode(Node{})
+	}
+	// a --1000m (36 km/h)--> c, a --1000m (18 km/h)--> b --1000m (18 km/h)--> c
+	g.RelateNodes(g.Nodes[0], g.Nodes[2], 1000, LeftToRight, MetaData{Distance: 1000, Speed: 36})
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1000, LeftToRight, MetaData{Distance: 1000, Speed: 18})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 1000, LeftToRight, MetaData{Distance: 1000, Speed: 18})
+
+	jam := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	traffic := NewMemoryTraffic(time.Hour)
+	traffic.Set(EdgeID{From: 0, To: 2}, jam.Add(30*time.Minute), 3.6)
+	if speed := traffic.GetSpeed(EdgeID{From: 0, To: 2}, jam); speed != 3.6 {
+		t.Fatalf("got speed %f in the jam window, expected 3.6", speed)
+	}
+
+	for _, tc := range []struct {
+		departure time.Time
+		expected  float32
+	}{
+		{departure: jam, expected: 400},
+		{departure: jam.Add(time.Hour), expected: 100},
+	} {
+		response := NewTimeDependentDijkstra(Criteria{
+			Source:    []int32{0},
+			Targets:   []int32{2},
+			Departure: tc.departure,
+			Traffic:   traffic,
+		}, nil).Run(g)
+		if c, _ := response.Costs.GetCost(2); c != tc.expected {
+			t.Fatalf("leaving at %s: got %f, expected %f", tc.departure, c, tc.expected)
+		}
+	}
+}
+
+func TestLoadSpeedProfiles(t *testing.T) {
+	g := EmptyGraph()
+	for i := 0; i < 3; i++ {
+		g.AddNode(Node{})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[2], 1000, LeftToRight, MetaData{Distance: 1000})
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1000, LeftToRight, MetaData{Distance: 1000})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 1000, LeftToRight, MetaData{Distance: 1000})
+
+	// a -> c is jammed on Monday from 8 to 9am, a -> b -> c has the same 18 km/h every hour.
+	week := make([]string, HoursInAWeek)
+	for h := range week {
+		week[h] = "36"
+	}
+	week[8] = "3.6"
+	day := strings.TrimSuffix(strings.Repeat("18,", 24), ",")
+	csv := "from,to,speeds\n0,2," + strings.Join(week, ",") + "\n0,1," + day + "\n1,2," + day + "\n5,9," + day + "\n"
+	loaded, err := LoadSpeedProfiles(&g, strings.NewReader(csv))
+	if err != nil || loaded != 3 {
+		t.Fatalf("got %d profiles loaded (%v), expected 3", loaded, err)
+	}
+	weekly := g.OutgoingEdges[0][0].Metadata.Weekly
+	if speeds := weekly.Speeds(); speeds[8] != 3.6 || speeds[167] != 36 || len(weekly.Runs) > 12 {
+		t.Fatalf("got speeds %v from %d bytes", speeds, len(weekly.Runs))
+	}
+
+	path := filepath.Join(t.TempDir(), "graph.gob")
+	if err := g.Serialize(path); err != nil {
+		t.Fatal(err)
+	}
+	g = Deserialize(path)
+	monday := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		departure time.Time
+		expected  float32
+	}{
+		{departure: monday, expected: 400},
+		{departure: monday.Add(time.Hour), expected: 100},
+		{departure: monday.Add(24 * time.Hour), expected: 100},
+	} {
+		response := NewTimeDependentDijkstra(Criteria{Source: []int32{0}, Targets: []int32{2}, Departure: tc.departure}, nil).Run(g)
+		if c, _ := response.Costs.GetCost(2); c != tc.expected {
+			t.Fatalf("leaving at %s: got %f, expected %f", tc.departure, c, tc.expected)
+		}
+	}
+
+	if _, err := LoadSpeedProfiles(&g, strings.NewReader("0,2,36,36\n")); !errors.Is(err, ErrInvalidSpeedProfile) {
+		t.Fatalf("got %v, expected ErrInvalidSpeedProfile", err)
+	}
+}
+
+func TestLandmarkTable_ShortestPath(t *testing.T) {
+	g := RandomGeometricGraph(300, 2000, 250, 7)
+	// Make some edges one-way so the backward search differs from the forward one.
+	for v := int32(0); v < int32(len(g.Nodes)); v += 7 {
+		if out := g.OutgoingEdges[v]; len(out) > 0 {
+			g.RemoveEdge(v, out[0].ID)
+		}
+	}
+	lt, err := NewLandmarkTable(g, 8, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lt.Landmarks) != 8 {
+		t.Fatalf("got %d landmarks, expected 8", len(lt.Landmarks))
+	}
+
+	for i := 0; i < 50; i++ {
+		source, target := int32(i*13%len(g.Nodes)), int32(i*31%len(g.Nodes))
+		expected, reachErr := NewDijkstra(Criteria{Source: []int32{source}, Targets: []int32{target}}).Run(g).Costs.GetCost(target)
+		cost, route, err := lt.ShortestPath(g, source, target)
+		if reachErr != nil {
+			if !errors.Is(err, ErrNoRoute) {
+				t.Fatalf("%d -> %d: got %v, expected ErrNoRoute", source, target, err)
+			}
+			continue
+		}
+		if err != nil || math.Abs(float64(cost-expected)) > 1e-2 {
+			t.Fatalf("%d -> %d: got cost %f (%v), expected %f", source, target, cost, err, expected)
+		}
+		if route.Nodes[0] != source || route.Nodes[len(route.Nodes)-1] != target {
+			t.Fatalf("%d -> %d: got route %v", source, target, route.Nodes)
+		}
+		if b := lt.Bound(source, target); b > float64(expected)+1e-2 {
+			t.Fatalf("%d -> %d: bound %f exceeds cost %f", source, target, b, expected)
+		}
+	}
+}
+
+func TestConditionalDijkstra_DetourFactor(t *testing.T) {
+	g := GridGraph(20, 20, 100)
+	c := Criteria{Source: []int32{0}, Targets: []int32{19}}
+	exact := NewDijkstra(c).Run(g)
+	c.DetourFactor = 1.05
+	pruned := NewDijkstra(c).Run(g)
+
+	want, _ := exact.Costs.GetCost(19)
+	got, err := pruned.Costs.GetCost(19)
+	if err != nil || math.Abs(float64(got-want)) > 1e-3 {
+		t.Fatalf("got cost %f (%v), expected %f", got, err, want)
+	}
+	if len(pruned.SearchSpace.Nodes) >= len(exact.SearchSpace.Nodes)/2 {
+		t.Fatalf("settled %d nodes with the ellipse, %d without", len(pruned.SearchSpace.Nodes), len(exact.SearchSpace.Nodes))
+	}
+
+	// Cut the bottom row: the only way around goes through the second row, outside a thin ellipse.
+	g.RemoveEdge(9, 10)
+	c.DetourFactor = 1.001
+	if _, err := NewDijkstra(c).Run(g).Costs.GetCost(19); err == nil {
+		t.Fatal("found a route leaving the ellipse")
+	}
+}
+
+func TestConditionalDijkstra_Epsilon(t *testing.T) {
+	g := GridGraph(30, 30, 100)
+	c := Criteria{Source: []int32{0}, Targets: []int32{899}}
+	exact := NewDijkstra(c).Run(g)
+	optimal, _ := exact.Costs.GetCost(899)
+
+	c.Heuristic = StraightLineHeuristic(g)
+	astar := NewDijkstra(c).Run(g)
+	if cost, err := astar.Costs.GetCost(899); err != nil || math.Abs(float64(cost-optimal)) > 1e-2 {
+		t.Fatalf("got A* cost %f (%v), expected %f", cost, err, optimal)
+	}
+
+	c.Heuristic, c.Epsilon = nil, 0.5
+	approximate := NewDijkstra(c).Run(g)
+	cost, err := approximate.Costs.GetCost(899)
+	if err != nil || cost > 1.5*optimal {
+		t.Fatalf("got cost %f (%v), expected at most %f", cost, err, 1.5*optimal)
+	}
+	settled := len(approximate.SearchSpace.Nodes)
+	if settled >= len(astar.SearchSpace.Nodes) || len(astar.SearchSpace.Nodes) >= len(exact.SearchSpace.Nodes) {
+		t.Fatalf("settled %d nodes with epsilon, %d with A*, %d with Dijkstra", settled, len(astar.SearchSpace.Nodes), len(exact.SearchSpace.Nodes))
+	}
+}
+
+func TestDijkstraSearch_RunUntil(t *testing.T) {
+	g := GridGraph(15, 15, 100)
+	c := Criteria{Source: []int32{0}}
+	full := NewDijkstra(c).Run(g)
+	var farthest float32
+	for _, cost := range full.Costs {
+		farthest = max(farthest, cost)
+	}
+
+	paused, state := NewDijkstra(c).RunUntil(g, farthest/3)
+	if len(paused.SearchSpace.Nodes) == 0 || len(paused.SearchSpace.Nodes) >= len(full.SearchSpace.Nodes) || len(state.Frontier) == 0 {
+		t.Fatalf("settled %d of %d nodes with a frontier of %d", len(paused.SearchSpace.Nodes), len(full.SearchSpace.Nodes), len(state.Frontier))
+	}
+	for _, n := range paused.SearchSpace.Nodes {
+		if cost := full.Costs[n.Rank]; cost > farthest/3 {
+			t.Fatalf("settled node %d costing %f before pausing at %f", n.Rank, cost, farthest/3)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := state.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeSearchState(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, state = ResumeDijkstra(decoded, c).RunUntil(g, 2*farthest/3)
+	resumed := ResumeDijkstra(state, c).Run(g)
+	if len(resumed.SearchSpace.Nodes) != len(full.SearchSpace.Nodes) {
+		t.Fatalf("settled %d nodes after resuming, expected %d", len(resumed.SearchSpace.Nodes), len(full.SearchSpace.Nodes))
+	}
+	for id, want := range full.Costs {
+		if got := resumed.Costs[id]; math.Abs(float64(got-want)) > 1e-3 {
+			t.Fatalf("node %d: got cost %f after resuming, expected %f", id, got, want)
+		}
+	}
+}
+
+func TestConditionalDijkstra_SurfaceTradeoff(t *testing.T) {
+	g := EmptyGraph()
+	for i := 0; i < 4; i++ {
+		g.AddNode(Node{})
+	}
+	gravel := MetaData{Distance: 100, RoadType: Track, Surface: Gravel}
+	asphalt := MetaData{Distance: 120, RoadType: Residential, Surface: Asphalt}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 100, LeftToRight, gravel)
+	g.RelateNodes(g.Nodes[1], g.Nodes[3], 100, LeftToRight, gravel)
+	g.RelateNodes(g.Nodes[0], g.Nodes[2], 120, LeftToRight, asphalt)
+	g.RelateNodes(g.Nodes[2], g.Nodes[3], 120, LeftToRight, asphalt)
+
+	c := Criteria{Source: []int32{0}, Targets: []int32{3}}
+	for _, tc := range []struct {
+		tradeoff float64
+		via      int32
+		score    float64
+	}{{0, 1, 0.4}, {1, 2, 1}} {
+		c.SurfaceTradeoff = tc.tradeoff
+		r := NewDijkstra(c).Run(g)
+		route := r.SearchSpace.Route(int32(len(r.SearchSpace.Nodes)-1), g)
+		if len(route.Nodes) != 3 || route.Nodes[1] != tc.via {
+			t.Fatalf("tradeoff %g: got route %v, expected one via %d", tc.tradeoff, route.Nodes, tc.via)
+		}
+		q := route.Quality()
+		if math.Abs(q.Score-tc.score) > 1e-6 || q.Worst > q.Score || len(q.Surfaces) != 1 {
+			t.Fatalf("tradeoff %g: got quality %+v, expected score %g", tc.tradeoff, q, tc.score)
+		}
+	}
+}
+
+func TestGraph_AlternativeRoute(t *testing.T) {
+	g := GridGraph(4, 4, 100)
+	c := Criteria{Source: []int32{0}, Targets: []int32{15}}
+	prior := NewDijkstra(c).Run(g)
+	first := prior.SearchSpace.PathNodes(int32(len(prior.SearchSpace.Nodes) - 1))
+
+	closures := NewWeightOverlay(g)
+	closures.Scale(12, 0, 3)
+	c.Overlay = closures
+	avoid := g.AvoidRoute(prior, c, 0)
+	if want := len(first) - 1 + closures.Len(); closures.Len() != 1 || avoid.Overlay.Len() != want {
+		t.Fatalf("got %d and %d penalized edges, expected the closure to stay unchanged and %d edges", closures.Len(), avoid.Overlay.Len(), want)
+	}
+
+	alternative := g.AlternativeRoute(prior, Criteria{Source: []int32{0}, Targets: []int32{15}}, 0)
+	second := alternative.SearchSpace.PathNodes(int32(len(alternative.SearchSpace.Nodes) - 1))
+	if second[len(second)-1] != 15 || len(second) != len(first) {
+		t.Fatalf("got alternative %v, expected another shortest path to 15", second)
+	}
+	shared := 0
+	for i := 1; i < len(first); i++ {
+		for j := 1; j < len(second); j++ {
+			if first[i-1] == second[j-1] && first[i] == second[j] {
+				shared++
+			}
+		}
+	}
+	if shared > 1 {
+		t.Errorf("routes %v and %v share %d edges, expected a different alternative", first, second, shared)
+	}
+}
+
+func TestConditionalDijkstra_Corridor(t *testing.T) {
+	// The planned route runs along the southern street of a 5x5 grid, whose middle edge is closed.
+	g := GridGraph(5, 5, 100)
+	line := []Coordinate{nodeCoordinate(g.Nodes[0]), nodeCoordinate(g.Nodes[4])}
+	corridor := g.NewCorridor(g.BuildNodeIndex(), line, 120)
+	if corridor.Len() != 10 || !corridor.Contains(7) || corridor.Contains(12) {
+		t.Fatalf("got %d nodes in the corridor, expected the two southern streets", corridor.Len())
+	}
+	if haversine := g.NewCorridor(g.BuildNodeIndexWithMetric(HaversineMetric{}), line, 120); haversine.Len() != corridor.Len() {
+		t.Fatalf("got %d nodes with a haversine index, expected %d", haversine.Len(), corridor.Len())
+	}
+
+	closed := NewEdgeSet(g)
+	closed.Add(g, 2, 3)
+	c := Criteria{Source: []int32{0}, Targets: []int32{4}, ExcludedEdges: closed, Corridor: corridor}
+	response := NewDijkstra(c).Run(g)
+	path := response.SearchSpace.PathNodes(int32(len(response.SearchSpace.Nodes) - 1))
+	if path[len(path)-1] != 4 {
+		t.Fatalf("got path %v, expected a detour to 4", path)
+	}
+	for _, v := range path {
+		if !corridor.Contains(v) {
+			t.Errorf("path %v leaves the corridor at %d", path, v)
+		}
+	}
+	for _, n := range response.SearchSpace.Nodes {
+		if !corridor.Contains(n.Rank) {
+			t.Fatalf("settled %d outside the corridor", n.Rank)
+		}
+	}
+}
+
+func TestGraph_OneToMany(t *testing.T) {
+	g := GridGraph(10, 10, 100)
+	full := NewDijkstra(Criteria{Source: []int32{0}}).Run(g)
+
+	targets := []int32{11, 1, 20, 11}
+	costs, err := g.OneToMany(0, targets, Criteria{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, target := range targets {
+		if expected, _ := full.Costs.GetCost(target); costs[i] != expected {
+			t.Errorf("got cost %v to %d, expected %v", costs[i], target, expected)
+		}
+	}
+	bounded := NewDijkstra(Criteria{Source: []int32{0}, Targets: targets, AllTargets: true}).Run(g)
+	if settled := len(bounded.SearchSpace.Nodes); settled >= 20 {
+		t.Errorf("settled %d nodes, expected the search to stop after the last target", settled)
+	}
+
+	g.AddNode(Node{}) // isolated node 100
+	costs, _ = g.OneToMany(0, []int32{100, 99}, Criteria{})
+	if !math.IsInf(float64(costs[0]), 1) || math.IsInf(float64(costs[1]), 1) {
+		t.Errorf("got costs %v, expected the isolated node only to be unreachable", costs)
+	}
+}
+
+func TestConditionalDijkstra_EpsilonTimeBound(t *testing.T) {
+	g := RandomGeometricGraph(2000, 5000, 200, 3)
+	rnd := rand.New(rand.NewSource(3))
+	for v := range g.OutgoingEdges {
+		for i := range g.OutgoingEdges[v] {
+			speeds := [24]float32{}
+			for h := range speeds {
+				speeds[h] = float32(20 + rnd.Intn(100))
+			}
+			g.OutgoingEdges[v][i].Metadata.Speed = float32(10 + rnd.Intn(120))
+			g.OutgoingEdges[v][i].Metadata.Profile = (*SpeedProfile)(&speeds)
+		}
+	}
+	departure := time.Date(2024, 5, 6, 8, 0, 0, 0, time.UTC)
+	const epsilon = 0.1
+	for k := 0; k < 20; k++ {
+		source, target := int32(rnd.Intn(len(g.Nodes))), int32(rnd.Intn(len(g.Nodes)))
+		c := Criteria{Source: []int32{source}, Targets: []int32{target}, Objective: ObjectiveTime}
+		optimal, err := NewDijkstra(c).Run(g).Costs.GetCost(target)
+		if err != nil {
+			continue // disconnected pair
+		}
+		c.Epsilon = epsilon
+		if cost, _ := NewDijkstra(c).Run(g).Costs.GetCost(target); cost > (1+epsilon)*optimal+1e-3 {
+			t.Fatalf("%d -> %d: got cost %f, more than %f times the optimum %f", source, target, cost, 1+epsilon, optimal)
+		}
+
+		c = Criteria{Source: []int32{source}, Targets: []int32{target}, Departure: departure}
+		optimal, _ = NewTimeDependentDijkstra(c, nil).Run(g).Costs.GetCost(target)
+		c.Epsilon = epsilon
+		if cost, _ := NewTimeDependentDijkstra(c, nil).Run(g).Costs.GetCost(target); cost > (1+epsilon)*optimal+1e-3 {
+			t.Fatalf("%d -> %d: got time-dependent cost %f, more than %f times the optimum %f", source, target, cost, 1+epsilon, optimal)
+		}
+	}
+
+	// Without a known bound on the costs, Epsilon is ignored and the search stays exact.
+	c := Criteria{Source: []int32{0}, Targets: []int32{1}, CostModel: PercentileTravelTime(0.5), Epsilon: epsilon}
+	if search := NewDijkstra(c); search.defaultHeuristic(g) != nil {
+		t.Fatal("expected no default heuristic for a cost model")
+	}
+}
@@ -0,0 +1,159 @@
+package graph_search
+
+import (
+	"fmt"
+	"math"
+)
+
+// Maneuver identifies the action a driver has to perform at the start of a Step.
+type Maneuver string
+
+const (
+	Depart          Maneuver = "depart"
+	Continue        Maneuver = "continue"
+	TurnSlightLeft  Maneuver = "turn slight left"
+	TurnLeft        Maneuver = "turn left"
+	TurnSharpLeft   Maneuver = "turn sharp left"
+	TurnSlightRight Maneuver = "turn slight right"
+	TurnRight       Maneuver = "turn right"
+	TurnSharpRight  Maneuver = "turn sharp right"
+	UTurn           Maneuver = "make a u-turn"
+	Arrive          Maneuver = "arrive"
+)
+
+// Turn angle thresholds, in degrees, used to classify maneuvers.
+const (
+	StraightAngle     = 20
+	SlightTurnAngle   = 60
+	TurnAngle         = 120
+	SharpTurnAngle    = 170
+	IntersectionAngle = 60
+)
+
+// Step is a single instruction of a route: a maneuver performed at a location and the road taken after it.
+type Step struct {
+	Maneuver Maneuver   // Action to perform
+	Name     string     // Name of the road taken after the maneuver, empty if unnamed
+	RoadType string     // Type of the road taken after the maneuver
+	Location Coordinate // Where the maneuver takes place
+	Distance float64    // Distance in meters traveled since the previous maneuver
+	Bearing  float64    // Bearing in degrees of the road taken after the maneuver
+	Lanes    LaneInfo   // Lanes of the road leading to the maneuver, for lane assistance; 0 when unknown
+}
+
+// Instruction renders the step as a human readable sentence, e.g. "turn left onto Calle 10 in 250 m".
+func (s Step) Instruction() string {
+	road := s.Name
+	if road == "" {
+		road = "unnamed " + s.RoadType
+	}
+	switch s.Maneuver {
+	case Depart:
+		return fmt.Sprintf("head %s on %s", compassDirection(s.Bearing), road)
+	case Arrive:
+		return fmt.Sprintf("arrive at destination in %.0f m", s.Distance)
+	case Continue:
+		return fmt.Sprintf("continue onto %s in %.0f m", road, s.Distance)
+	}
+	return fmt.Sprintf("%s onto %s in %.0f m", s.Maneuver, road, s.Distance)
+}
+
+// Directions produces turn-by-turn steps for the route found by a search from its source to a target.
+//
+// Parameters:
+//   - r: Response - Result of a search run on g
+//   - g: Graph - The graph the search was run on
+//   - target: int32 - ID of the destination node in g
+//
+// Returns:
+//   - []Step: The maneuvers from the departure to the arrival, empty if the search did not settle the
+//     target or the route has no edges
+func Directions(r Response, g Graph, target int32) []Step {
+	index, ok := r.SearchSpace.settledIndex(target)
+	if !ok {
+		return nil
+	}
+	route := r.SearchSpace.Route(index, g)
+	path, edges := route.Nodes, route.Edges
+	if len(edges) == 0 {
+		return nil
+	}
+
+	steps := make([]Step, 0)
+	distance := 0.0
+	for i, e := range edges {
+		from, to := path[i], path[i+1]
+		heading := bearing(g.Nodes[from].GetPoint(), g.Nodes[to].GetPoint())
+		maneuver, lanes := Depart, LaneInfo(0)
+		if i > 0 {
+			previous := edges[i-1]
+			incoming := bearing(g.Nodes[path[i-1]].GetPoint(), g.Nodes[from].GetPoint())
+			turn := turnAngle(incoming, heading)
+			sameRoad := previous.Metadata.Name == e.Metadata.Name && previous.Metadata.RoadType == e.Metadata.RoadType
+			decision := len(g.OutgoingEdges[from]) > 2 && math.Abs(turn) > IntersectionAngle
+			if sameRoad && !decision {
+				distance += float64(e.Metadata.Distance)
+				continue
+			}
+			maneuver, lanes = classifyTurn(turn), previous.Metadata.Lanes
+		}
+		steps = append(steps, Step{
+			Maneuver: maneuver,
+			Name:     e.Metadata.Name,
+			RoadType: e.Metadata.RoadType,
+			Location: nodeCoordinate(g.Nodes[from]),
+			Distance: distance,
+			Bearing:  heading,
+			Lanes:    lanes,
+		})
+		distance = float64(e.Metadata.Distance)
+	}
+	return append(steps, Step{
+		Maneuver: Arrive,
+		Location: nodeCoordinate(g.Nodes[path[len(path)-1]]),
+		Distance: distance,
+	})
+}
+
+// classifyTurn maps a signed turn angle (positive to the right) to a maneuver.
+func classifyTurn(angle float64) Maneuver {
+	abs := math.Abs(angle)
+	switch {
+	case abs < StraightAngle:
+		return Continue
+	case abs >= SharpTurnAngle:
+		return UTurn
+	case angle > 0 && abs < SlightTurnAngle:
+		return TurnSlightRight
+	case angle > 0 && abs < TurnAngle:
+		return TurnRight
+	case angle > 0:
+		return TurnSharpRight
+	case abs < SlightTurnAngle:
+		return TurnSlightLeft
+	case abs < TurnAngle:
+		return TurnLeft
+	}
+	return TurnSharpLeft
+}
+
+// turnAngle returns the signed difference between two bearings, normalized to (-180, 180].
+func turnAngle(incoming, outgoing float64) float64 {
+	angle := math.Mod(outgoing-incoming+540, 360) - 180
+	if angle == -180 {
+		return 180
+	}
+	return angle
+}
+
+// compassDirection names the cardinal or intercardinal direction of a bearing.
+func compassDirection(b float64) string {
+	directions := []string{"north", "northeast", "east", "southeast", "south", "southwest", "west", "northwest"}
+	return directions[int(math.Mod(b+22.5, 360)/45)%len(directions)]
+}
+
+// nodeCoordinate returns the location of a node as a Coordinate.
+func nodeCoordinate(n Node) Coordinate {
+	p := n.GetPoint()
+	return Coordinate{Lat: p.Lat.Degrees(), Lng: p.Lng.Degrees()}
+}
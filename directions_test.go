@@ -0,0 +1,221 @@
+package graph_search
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestDirections(t *testing.T) {
+	g := EmptyGraph()
+	for _, c := range []Coordinate{{6.2000, -75.5800}, {6.2000, -75.5790}, {6.2000, -75.5780}, {6.2010, -75.5780},
+		{6.1990, -75.5780}, {6.2000, -75.5770}} {
+		g.AddNode(Node{Location: coordinatesToCellID(c.Lat, c.Lng)})
+	}
+	relate := func(a, b int, name string) {
+		d := DistanceMeters(s2.CellID(g.Nodes[a].Location), s2.CellID(g.Nodes[b].Location))
+		g.RelateNodes(g.Nodes[a], g.Nodes[b], d, Bidirectional, MetaData{Distance: d, RoadType: Residential, Name: name})
+	}
+
+	//              3
+	//              |
+	// 0 ---- 1 --- 2 ---- 5
+	//              |
+	//              4
+	relate(0, 1, "Calle 10")
+	relate(1, 2, "Calle 10")
+	relate(2, 5, "Calle 10")
+	relate(2, 3, "Carrera 43")
+	relate(2, 4, "Carrera 43")
+
+	response := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{3}}).Run(g)
+	expected := []string{
+		"head east on Calle 10",
+		"turn left onto Carrera 43 in 221 m",
+		"arrive at destination in 111 m",
+	}
+	steps := Directions(response, g, 3)
+	if len(steps) != len(expected) {
+		t.Fatalf("got %d steps, expected %d", len(steps), len(expected))
+	}
+	for i, s := range steps {
+		if s.Instruction() != expected[i] {
+			t.Errorf("step %d: got %q, expected %q", i, s.Instruction(), expected[i])
+		}
+	}
+	if steps := Directions(NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{1}}).Run(g), g, 3); steps != nil {
+		t.Errorf("got %d steps to node 3, which the search did not reach", len(steps))
+	}
+
+	// A toll road runs along Calle 10 between 0 and 1; the free road is longer but the cost model avoids tolls.
+	d := DistanceMeters(s2.CellID(g.Nodes[0].Location), s2.CellID(g.Nodes[1].Location))
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], d/2, LeftToRight, MetaData{Distance: d / 2, RoadType: Residential, Name: "Autopista", Toll: true})
+	avoidTolls := func(e Edge) float32 {
+		if e.Metadata.Toll {
+			return e.Weight * 10
+		}
+		return e.Weight
+	}
+	response = NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{3}, CostModel: avoidTolls}).Run(g)
+	if steps := Directions(response, g, 3); len(steps) == 0 || steps[0].Name != "Calle 10" {
+		t.Errorf("got steps %+v, expected to depart on Calle 10 as the search did", steps)
+	}
+}
+
+func TestParseLanes(t *testing.T) {
+	lanes := ParseLanes("", "left|through|through;right")
+	turns := lanes.Turns()
+	if lanes.Count() != 3 || len(turns) != 3 || turns[0] != LaneLeft || turns[2] != LaneThrough|LaneRight {
+		t.Fatalf("got %d lanes %v, expected left, through and through;right", lanes.Count(), turns)
+	}
+	if s := turns[2].String(); s != "through;right" {
+		t.Fatalf("got %q, expected through;right", s)
+	}
+
+	lanes = ParseLanes("8", "left|left|none|through|through|through|right|right")
+	if turns := lanes.Turns(); lanes.Count() != 8 || len(turns) != MaxTurnLanes || turns[2] != 0 || turns[5] != LaneThrough {
+		t.Fatalf("got %d lanes %v, expected 8 lanes and %d indications", lanes.Count(), turns, MaxTurnLanes)
+	}
+	if lanes := ParseLanes("2", ""); lanes.Count() != 2 || lanes.Turns() != nil {
+		t.Fatalf("got %d lanes %v, expected 2 lanes without indications", lanes.Count(), lanes.Turns())
+	}
+}
+
+func TestRoute_Simplify(t *testing.T) {
+	// East along the first row of the grid, then north along the last column, with a 3 m kink at node 2.
+	g := GridGraph(5, 5, 100)
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	lat, lng := MetersToLatLng(x0+200, y0+3)
+	g.Nodes[2].Location = coordinatesToCellID(lat, lng)
+	r := NewRoute([]int32{0, 1, 2, 3, 4, 9, 14}, g)
+
+	simplified := r.Simplify(5)
+	coordinates := r.Coordinates()
+	expected := []Coordinate{coordinates[0], coordinates[4], coordinates[6]}
+	if !reflect.DeepEqual(simplified, expected) {
+		t.Errorf("Simplify(5) kept %d coordinates, expected the start, the corner and the end", len(simplified))
+	}
+	// The sides of the kink deviate 1.5 m from the lines around it; only node 9 lies on a straight line.
+	if kept := r.Simplify(1); len(kept) != 6 {
+		t.Errorf("Simplify(1) kept %d coordinates, expected 6", len(kept))
+	}
+	if kept := r.Simplify(0); len(kept) != len(coordinates) {
+		t.Errorf("Simplify(0) kept %d coordinates, expected all %d", len(kept), len(coordinates))
+	}
+}
+
+func TestDestinationPoint(t *testing.T) {
+	start := SyntheticOrigin
+	for _, heading := range []float64{0, 45, 90, 200, 359} {
+		end := DestinationPoint(start, heading, 1000)
+		d := DistanceMeters(s2.CellID(coordinatesToCellID(start.Lat, start.Lng)), s2.CellID(coordinatesToCellID(end.Lat, end.Lng)))
+		if math.Abs(float64(d)-1000) > 1 {
+			t.Errorf("heading %.0f: got %.1f m, expected 1000 m", heading, d)
+		}
+		if b := Bearing(start, end); math.Abs(turnAngle(heading, b)) > 0.1 {
+			t.Errorf("heading %.0f: got bearing %.2f back to the start point", heading, b)
+		}
+	}
+
+	g := GridGraph(1, 3, 100)
+	r := NewRoute([]int32{0, 1, 2}, g)
+	coordinates := r.Coordinates()
+	if p := PointAlongRoute(r, float64(r.Edges[0].Metadata.Distance)); math.Abs(p.Lat-coordinates[1].Lat) > 1e-9 || math.Abs(p.Lng-coordinates[1].Lng) > 1e-9 {
+		t.Errorf("got %+v at the end of the first edge, expected node 1 at %+v", p, coordinates[1])
+	}
+	middle := PointAlongRoute(r, r.Length()/2)
+	if math.Abs(Bearing(coordinates[0], middle)-90) > 0.1 || math.Abs(middle.Lng-coordinates[1].Lng) > 1e-6 {
+		t.Errorf("got %+v halfway, expected node 1 at %+v", middle, coordinates[1])
+	}
+	if PointAlongRoute(r, -5) != coordinates[0] || PointAlongRoute(r, 1e6) != coordinates[2] {
+		t.Error("distances outside the route are not clamped to its ends")
+	}
+}
+
+func TestEncodePolyline(t *testing.T) {
+	// Example from the Google encoded polyline algorithm documentation.
+	coordinates := []Coordinate{{Lat: 38.5, Lng: -120.2}, {Lat: 40.7, Lng: -120.95}, {Lat: 43.252, Lng: -126.453}}
+	if got := EncodePolyline(coordinates, 5); got != "_p~iF~ps|U_ulLnnqC_mqNvxq`@" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRoute_ETAAt(t *testing.T) {
+	g := GridGraph(1, 4, 100)
+	for _, edges := range g.OutgoingEdges {
+		for i := range edges {
+			edges[i].Metadata.Speed = 36 // 10 m/s
+		}
+	}
+	route := NewRoute([]int32{0, 1, 2, 3}, g)
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	lat, lng := MetersToLatLng(x0+150, y0+5)
+	departure := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	etas := route.ETAAt(Coordinate{Lat: lat, Lng: lng}, departure)
+	if len(etas) != 2 || etas[0].Node != 2 || etas[1].Node != 3 {
+		t.Fatalf("got %+v, expected nodes 2 and 3 ahead", etas)
+	}
+	if math.Abs(etas[1].Distance-150) > 2 || math.Abs(etas[1].Duration-15) > 0.2 {
+		t.Fatalf("got %.1f m and %.1f s to the destination, expected 150 m and 15 s", etas[1].Distance, etas[1].Duration)
+	}
+	if etas[0].Arrival.Sub(departure).Round(time.Second) != 5*time.Second {
+		t.Fatalf("got arrival %v at node 2, expected 5 s after departure", etas[0].Arrival)
+	}
+
+	lat, lng = MetersToLatLng(x0+40, y0-5)
+	distance, duration := route.DistanceBetween(Coordinate{Lat: lat, Lng: lng}, etas[0].Location)
+	if math.Abs(distance-160) > 2 || math.Abs(duration-16) > 0.2 {
+		t.Fatalf("got %.1f m and %.1f s between the positions, expected 160 m and 16 s", distance, duration)
+	}
+}
+
+func TestRoute_ElevationProfile(t *testing.T) {
+	g := GridGraph(1, 5, 100)
+	route := NewRoute([]int32{0, 1, 2, 3, 4}, g)
+	x0, _ := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	elevations := []float64{100, 110, 104, -1, 120} // -1: no data
+	dem := ElevationFunc(func(c Coordinate) (float64, bool) {
+		x, _ := LatLngToMeters(c.Lat, c.Lng)
+		e := elevations[int(math.Round((x-x0)/100))]
+		return e, e >= 0
+	})
+
+	profile := route.ElevationProfile(dem)
+	if len(profile.Points) != 4 {
+		t.Fatalf("got %d points, expected 4", len(profile.Points))
+	}
+	if last := profile.Points[3]; math.Abs(last.Distance-400) > 5 || last.Elevation != 120 {
+		t.Fatalf("got last point %+v, expected 120 m at 400 m", last)
+	}
+	if profile.Ascent != 26 || profile.Descent != 6 {
+		t.Fatalf("got ascent %.1f and descent %.1f, expected 26 and 6", profile.Ascent, profile.Descent)
+	}
+}
+
+func TestResponse_CostRaster(t *testing.T) {
+	g := GridGraph(10, 10, 100)
+	r := NewDijkstra(Criteria{Source: []int32{0}}).Run(g)
+	raster := r.CostRaster(g, 50)
+	// 900 m covered by 50 m cells, give or take the rounding of node locations to S2 cells.
+	if raster.Width < 18 || raster.Width > 19 || raster.Height < 18 || raster.Height > 19 || len(raster.Values) != raster.Width*raster.Height {
+		t.Fatalf("got a %dx%d raster with %d values, expected 19x19", raster.Width, raster.Height, len(raster.Values))
+	}
+
+	for _, id := range []int32{0, 55, 99} {
+		c := nodeCoordinate(g.Nodes[id])
+		expected, _ := r.Costs.GetCost(id)
+		got, ok := raster.At(c)
+		if !ok || math.Abs(float64(got-expected)) > 50 {
+			t.Errorf("node %d: got %f, %v from the raster, expected about %f", id, got, ok, expected)
+		}
+	}
+	var buf bytes.Buffer
+	if err := raster.WritePNG(&buf, 0); err != nil || buf.Len() == 0 {
+		t.Fatalf("got %d bytes, %v", buf.Len(), err)
+	}
+}
@@ -0,0 +1,61 @@
+package graph_search
+
+// DefaultRoutePenalty is the factor the cost of the edges of a prior route is multiplied by when
+// AlternativeRoute is given no penalty.
+const DefaultRoutePenalty = 2
+
+// AvoidRoute returns a copy of the criteria where the edges of the route a prior search found to the
+// first target cost penalty times more, on top of the Overlay of the criteria, which is left unchanged.
+// A search run with them avoids the prior route where a detour costs less than the penalty, yielding a
+// meaningfully different alternative without the cost of a k-shortest paths search. Applying it to the
+// criteria it returns penalizes several prior routes at once.
+//
+// Parameters:
+//   - prior: Response - A search that reached the first target of c
+//   - c: Criteria - The criteria of the search
+//   - penalty: float32 - Factor applied to the cost of the edges of the prior route, DefaultRoutePenalty
+//     when not above 1
+//
+// Returns:
+//   - Criteria: The criteria with the edges of the prior route penalized, c itself if c has no target
+//     or the prior search did not reach it
+func (g Graph) AvoidRoute(prior Response, c Criteria, penalty float32) Criteria {
+	settled := prior.SearchSpace.Nodes
+	if len(c.Targets) == 0 || len(settled) == 0 || settled[len(settled)-1].Rank != c.Targets[0] {
+		return c
+	}
+	nodes := prior.SearchSpace.PathNodes(int32(len(settled) - 1))
+	if penalty <= 1 {
+		penalty = DefaultRoutePenalty
+	}
+	overlay := NewWeightOverlay(g)
+	if c.Overlay != nil {
+		overlay = c.Overlay.clone()
+	}
+	for k := 1; k < len(nodes); k++ {
+		from, to := nodes[k-1], nodes[k]
+		for i, e := range g.OutgoingEdges[from] {
+			if e.ID == to {
+				overlay.Scale(from, i, overlay.Factor(from, i)*penalty)
+			}
+		}
+	}
+	c.Overlay = overlay
+	return c
+}
+
+// AlternativeRoute runs the search of the criteria again with the edges of the route of a prior search
+// penalized, see AvoidRoute. The costs of the returned response include the penalties; measure the
+// alternative with its Route instead.
+//
+// Parameters:
+//   - prior: Response - A search that reached the first target of c
+//   - c: Criteria - The criteria of the prior search
+//   - penalty: float32 - Factor applied to the cost of the edges of the prior route, DefaultRoutePenalty
+//     when not above 1
+//
+// Returns:
+//   - Response: The result of the penalized search
+func (g Graph) AlternativeRoute(prior Response, c Criteria, penalty float32) Response {
+	return NewDijkstra(g.AvoidRoute(prior, c, penalty)).Run(g)
+}
@@ -0,0 +1,157 @@
+package graph_search
+
+import "fmt"
+
+// TurnCostFunc decides whether the turn from -> via -> to is allowed and what it costs.
+//
+// Parameters:
+//   - from: int32 - Node where the incoming edge starts
+//   - via: int32 - Node where the turn takes place
+//   - to: int32 - Node where the outgoing edge ends
+//
+// Returns:
+//   - float32: Extra cost added when the turn is made
+//   - bool: false if the turn is forbidden
+type TurnCostFunc func(from, via, to int32) (float32, bool)
+
+// NoUTurns is a TurnCostFunc allowing every turn for free except going straight back where the vehicle came from.
+func NoUTurns(from, via, to int32) (float32, bool) {
+	return 0, from != to
+}
+
+// ExpandedNode describes what an expanded node of an EdgeBasedGraph stands for in the original graph.
+type ExpandedNode struct {
+	From int32 // Node where the directed edge starts, or the arrival node itself for arrival nodes
+	To   int32 // Node where the directed edge ends, or the arrival node itself for arrival nodes
+}
+
+// EdgeBasedGraph is the edge-expanded version of a node-based Graph: every directed edge u->v of the original
+// graph becomes a node, and every allowed turn u->v->w becomes an edge between the nodes of u->v and v->w.
+// Turn costs and restrictions can therefore be expressed as plain edge weights, and the embedded Graph can
+// be searched with the existing Dijkstra without modification.
+//
+// An extra arrival node is added for every original node, so a search can stop as soon as a node is reached
+// regardless of the edge it is reached through. Expanded node IDs are laid out as:
+//   - [0, E): directed edges, E being the number of edges of the original graph
+//   - [E, E+N): arrival nodes, N being the number of nodes of the original graph
+type EdgeBasedGraph struct {
+	Graph
+
+	// Expanded maps every expanded node ID to the element of the original graph it represents.
+	Expanded []ExpandedNode
+
+	// edges is the number of directed edges of the original graph
+	edges int32
+
+	// firstEdge[v] is the ID of the expanded node of the first edge leaving v in the original graph
+	firstEdge []int32
+}
+
+// EdgeExpanded builds the edge-based representation of the graph.
+//
+// Parameters:
+//   - turn: TurnCostFunc - Cost and legality of every turn, NoUTurns is used when nil
+//
+// Returns:
+//   - EdgeBasedGraph: The expanded graph. Traversing the expanded node of u->v costs the weight of u->v, so
+//     path costs are the same as in the original graph plus the cost of the turns taken
+//   - error: An error wrapping ErrTooManyNodes, returned before allocating anything, when the expanded graph
+//     would have more than MaxNodes nodes. It has one node per edge and per node of g
+func (g Graph) EdgeExpanded(turn TurnCostFunc) (EdgeBasedGraph, error) {
+	if turn == nil {
+		turn = NoUTurns
+	}
+	size := int64(len(g.Nodes))
+	for _, edges := range g.OutgoingEdges {
+		size += int64(len(edges))
+	}
+	if err := checkNodeCount(size); err != nil {
+		return EdgeBasedGraph{Graph: EmptyGraph()}, fmt.Errorf("edge expansion: %w", err)
+	}
+	eg := EdgeBasedGraph{
+		Graph:     EmptyGraph(),
+		Expanded:  make([]ExpandedNode, 0),
+		firstEdge: make([]int32, len(g.Nodes)),
+	}
+
+	for _, n := range g.Nodes {
+		eg.firstEdge[n.ID] = int32(len(eg.Expanded))
+		for _, e := range g.OutgoingEdges[n.ID] {
+			eg.AddNode(Node{Location: n.Location, Rank: n.Rank})
+			eg.Expanded = append(eg.Expanded, ExpandedNode{From: n.ID, To: e.ID})
+		}
+	}
+	eg.edges = int32(len(eg.Expanded))
+	for _, n := range g.Nodes {
+		eg.AddNode(Node{Location: n.Location, Rank: n.Rank})
+		eg.Expanded = append(eg.Expanded, ExpandedNode{From: n.ID, To: n.ID})
+	}
+
+	for _, u := range g.Nodes {
+		for i, in := range g.OutgoingEdges[u.ID] {
+			current := eg.Nodes[eg.firstEdge[u.ID]+int32(i)]
+			eg.RelateNodes(current, eg.Nodes[eg.ArrivalNode(in.ID)], in.Weight, LeftToRight, in.Metadata)
+			for j, out := range g.OutgoingEdges[in.ID] {
+				cost, allowed := turn(u.ID, in.ID, out.ID)
+				if !allowed {
+					continue
+				}
+				next := eg.Nodes[eg.firstEdge[in.ID]+int32(j)]
+				eg.RelateNodes(current, next, in.Weight+cost, LeftToRight, in.Metadata)
+			}
+		}
+	}
+	return eg, nil
+}
+
+// ArrivalNode returns the expanded node reached when arriving at the original node v.
+func (eg EdgeBasedGraph) ArrivalNode(v int32) int32 {
+	return eg.edges + v
+}
+
+// DepartureNodes returns the expanded nodes a search leaving from the original node v starts at.
+func (eg EdgeBasedGraph) DepartureNodes(v int32) []int32 {
+	departures := make([]int32, 0)
+	for id := eg.firstEdge[v]; id < eg.edges && eg.Expanded[id].From == v; id++ {
+		departures = append(departures, id)
+	}
+	return departures
+}
+
+// Criteria translates search criteria expressed with original node IDs into the expanded graph, so the
+// same query can be run on both representations.
+//
+// Parameters:
+//   - c: Criteria - Criteria referencing nodes of the original graph
+//
+// Returns:
+//   - Criteria: A copy of c whose sources are the departure nodes and whose targets are the arrival nodes
+func (eg EdgeBasedGraph) Criteria(c Criteria) Criteria {
+	sources := make([]int32, 0)
+	for _, s := range c.Source {
+		sources = append(sources, eg.DepartureNodes(s)...)
+	}
+	targets := make([]int32, 0, len(c.Targets))
+	for _, t := range c.Targets {
+		targets = append(targets, eg.ArrivalNode(t))
+	}
+	c.Source, c.Targets = sources, targets
+	return c
+}
+
+// OriginalPath maps a sequence of expanded node IDs, as returned by SearchSpace.PathNodes, back to the
+// sequence of nodes visited in the original graph.
+func (eg EdgeBasedGraph) OriginalPath(expanded []int32) []int32 {
+	path := make([]int32, 0, len(expanded))
+	for _, id := range expanded {
+		if n := eg.Expanded[id].From; len(path) == 0 || path[len(path)-1] != n {
+			path = append(path, n)
+		}
+	}
+	if len(expanded) > 0 {
+		if last := eg.Expanded[expanded[len(expanded)-1]].To; path[len(path)-1] != last {
+			path = append(path, last)
+		}
+	}
+	return path
+}
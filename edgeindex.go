@@ -0,0 +1,248 @@
+package graph_search
+
+import (
+	"container/heap"
+	"math"
+	"sort"
+)
+
+// edgeIndexCapacity is the largest number of children of a node of an EdgeIndex.
+const edgeIndexCapacity = 16
+
+// EdgeIndex is an R-tree over the bounding boxes of the edges of a graph, in Web Mercator meters. Unlike
+// the node index built by BuildNodeIndex, it finds the closest edge whatever the length of the edges, so a
+// coordinate in the middle of a long motorway segment is snapped onto it, and it answers viewport queries
+// for the edges crossing a map view. It is bulk-loaded with the Sort-Tile-Recursive algorithm and is
+// immutable: rebuild it when the graph changes.
+type EdgeIndex struct {
+	graph Graph
+	root  *rtreeNode
+	size  int
+}
+
+// DirectedEdge is an edge of the graph together with the node it leaves from.
+type DirectedEdge struct {
+	From int32 // ID of the node where the edge starts
+	Edge Edge  // The edge, its ID is the node where it ends
+}
+
+// rect is an axis-aligned rectangle in projected meters.
+type rect struct {
+	minX, minY, maxX, maxY float64
+}
+
+// rtreeNode is a node of an EdgeIndex: inner nodes have children, leaves have entries.
+type rtreeNode struct {
+	box      rect
+	children []*rtreeNode
+	entries  []edgeEntry
+}
+
+// edgeEntry locates an edge by its source node and its position in the adjacency list of the source.
+type edgeEntry struct {
+	box  rect
+	from int32
+	pos  int32
+}
+
+// BuildEdgeIndex creates an R-tree over the bounding boxes of every edge of the graph.
+//
+// Returns:
+//   - *EdgeIndex: A spatial index of the graph's edges for nearest-edge and viewport queries
+func (g Graph) BuildEdgeIndex() *EdgeIndex {
+	entries := make([]edgeEntry, 0)
+	for from := range g.OutgoingEdges {
+		a := g.Nodes[from].vector().Components
+		for pos, e := range g.OutgoingEdges[from] {
+			b := g.Nodes[e.ID].vector().Components
+			box := rect{minX: a[0], minY: a[1], maxX: a[0], maxY: a[1]}.extend(b[0], b[1])
+			entries = append(entries, edgeEntry{box: box, from: int32(from), pos: int32(pos)})
+		}
+	}
+	index := &EdgeIndex{graph: g, size: len(entries)}
+	if len(entries) == 0 {
+		return index
+	}
+
+	level := make([]*rtreeNode, 0)
+	for _, group := range strPack(entries, func(e edgeEntry) rect { return e.box }) {
+		leaf := &rtreeNode{box: group[0].box, entries: group}
+		for _, e := range group[1:] {
+			leaf.box = leaf.box.union(e.box)
+		}
+		level = append(level, leaf)
+	}
+	for len(level) > 1 {
+		parents := make([]*rtreeNode, 0)
+		for _, group := range strPack(level, func(n *rtreeNode) rect { return n.box }) {
+			parent := &rtreeNode{box: group[0].box, children: group}
+			for _, child := range group[1:] {
+				parent.box = parent.box.union(child.box)
+			}
+			parents = append(parents, parent)
+		}
+		level = parents
+	}
+	index.root = level[0]
+	return index
+}
+
+// strPack groups items into nodes of at most edgeIndexCapacity items with the Sort-Tile-Recursive
+// algorithm: items are sorted by the x of their center and cut into vertical slices, then every slice is
+// sorted by y and cut into groups, so the groups are square tiles overlapping little.
+func strPack[T any](items []T, box func(T) rect) [][]T {
+	center := func(item T, axis int) float64 {
+		b := box(item)
+		if axis == 0 {
+			return b.minX + b.maxX
+		}
+		return b.minY + b.maxY
+	}
+	sort.Slice(items, func(i, j int) bool { return center(items[i], 0) < center(items[j], 0) })
+
+	groups := int(math.Ceil(float64(len(items)) / edgeIndexCapacity))
+	sliceSize := int(math.Ceil(math.Sqrt(float64(groups)))) * edgeIndexCapacity
+	packed := make([][]T, 0, groups)
+	for start := 0; start < len(items); start += sliceSize {
+		slice := items[start:min(start+sliceSize, len(items))]
+		sort.Slice(slice, func(i, j int) bool { return center(slice[i], 1) < center(slice[j], 1) })
+		for i := 0; i < len(slice); i += edgeIndexCapacity {
+			packed = append(packed, slice[i:min(i+edgeIndexCapacity, len(slice))])
+		}
+	}
+	return packed
+}
+
+// Len returns the number of edges in the index.
+func (t *EdgeIndex) Len() int {
+	return t.size
+}
+
+// InBox returns the edges whose bounding box overlaps a bounding box, e.g., the edges to draw in a map
+// view. Edges crossing a corner of the box without entering it may be returned too.
+//
+// Parameters:
+//   - box: BoundingBox - The area to search
+//
+// Returns:
+//   - []DirectedEdge: The edges overlapping the box, in no particular order
+func (t *EdgeIndex) InBox(box BoundingBox) []DirectedEdge {
+	edges := make([]DirectedEdge, 0)
+	if t.root == nil {
+		return edges
+	}
+	minX, minY := LatLngToMeters(box.Min.Lat, box.Min.Lng)
+	maxX, maxY := LatLngToMeters(box.Max.Lat, box.Max.Lng)
+	query := rect{minX: minX, minY: minY, maxX: maxX, maxY: maxY}
+
+	stack := []*rtreeNode{t.root}
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, child := range n.children {
+			if child.box.intersects(query) {
+				stack = append(stack, child)
+			}
+		}
+		for _, e := range n.entries {
+			if e.box.intersects(query) {
+				edges = append(edges, t.edge(e))
+			}
+		}
+	}
+	return edges
+}
+
+// Nearest returns the k edges closest to a coordinate, closest first, projecting the coordinate onto each
+// of them. The tree is searched best-first, so only the nodes closer than the k-th edge found are visited,
+// however long the edges are. Edges are ranked by their distance in projected meters, which matches the
+// ranking by true distance everywhere but across very large areas.
+//
+// Parameters:
+//   - c: Coordinate - The coordinate to snap
+//   - k: int - Maximum number of edges
+//
+// Returns:
+//   - []EdgeSnap: The projections onto the k closest edges, fewer if the graph has fewer edges
+func (t *EdgeIndex) Nearest(c Coordinate, k int) []EdgeSnap {
+	snaps := make([]EdgeSnap, 0, max(min(k, t.size), 0))
+	if t.root == nil || k <= 0 {
+		return snaps
+	}
+	x, y := LatLngToMeters(c.Lat, c.Lng)
+	point := NewVector(-1, []float64{x, y})
+
+	queue := &rtreeQueue{{node: t.root, distance: t.root.box.distance(x, y)}}
+	for queue.Len() > 0 && len(snaps) < k {
+		item := heap.Pop(queue).(rtreeItem)
+		switch {
+		case item.node == nil:
+			item.snap.Input = c
+			snaps = append(snaps, item.snap)
+		case item.node.entries != nil:
+			for _, e := range item.node.entries {
+				edge := t.edge(e)
+				a := t.graph.Nodes[edge.From].vector().Components
+				b := t.graph.Nodes[edge.Edge.ID].vector().Components
+				heap.Push(queue, rtreeItem{
+					snap:     t.graph.projectOntoEdge(point, edge.From, edge.Edge),
+					distance: segmentDistance([2]float64{x, y}, [2]float64{a[0], a[1]}, [2]float64{b[0], b[1]}),
+				})
+			}
+		default:
+			for _, child := range item.node.children {
+				heap.Push(queue, rtreeItem{node: child, distance: child.box.distance(x, y)})
+			}
+		}
+	}
+	return snaps
+}
+
+// edge returns the edge an entry refers to.
+func (t *EdgeIndex) edge(e edgeEntry) DirectedEdge {
+	return DirectedEdge{From: e.from, Edge: t.graph.OutgoingEdges[e.from][e.pos]}
+}
+
+// extend returns the smallest rectangle containing r and a point.
+func (r rect) extend(x, y float64) rect {
+	return rect{minX: math.Min(r.minX, x), minY: math.Min(r.minY, y), maxX: math.Max(r.maxX, x), maxY: math.Max(r.maxY, y)}
+}
+
+// union returns the smallest rectangle containing r and o.
+func (r rect) union(o rect) rect {
+	return r.extend(o.minX, o.minY).extend(o.maxX, o.maxY)
+}
+
+// intersects reports whether r and o overlap, borders included.
+func (r rect) intersects(o rect) bool {
+	return r.minX <= o.maxX && o.minX <= r.maxX && r.minY <= o.maxY && o.minY <= r.maxY
+}
+
+// distance returns the distance from a point to the closest point of r, 0 if the point lies inside.
+func (r rect) distance(x, y float64) float64 {
+	dx := math.Max(math.Max(r.minX-x, 0), x-r.maxX)
+	dy := math.Max(math.Max(r.minY-y, 0), y-r.maxY)
+	return math.Hypot(dx, dy)
+}
+
+// rtreeItem is a node of the tree, or a projection onto an edge when node is nil, waiting in the queue of
+// a nearest-edge search.
+type rtreeItem struct {
+	node     *rtreeNode
+	snap     EdgeSnap
+	distance float64 // Distance in projected meters to the coordinate searched
+}
+
+// rtreeQueue orders the items of a nearest-edge search by distance, implementing heap.Interface.
+type rtreeQueue []rtreeItem
+
+func (q rtreeQueue) Len() int           { return len(q) }
+func (q rtreeQueue) Less(i, j int) bool { return q[i].distance < q[j].distance }
+func (q rtreeQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *rtreeQueue) Push(x any)        { *q = append(*q, x.(rtreeItem)) }
+func (q *rtreeQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
@@ -0,0 +1,186 @@
+package graph_search
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/s2"
+)
+
+var (
+	ErrMissingColumn = errors.New("missing edge list column")
+)
+
+// Edge list column names recognized in header rows.
+const (
+	ColumnFrom     = "from"
+	ColumnTo       = "to"
+	ColumnWeight   = "weight"
+	ColumnFromLat  = "from_lat"
+	ColumnFromLng  = "from_lng"
+	ColumnToLat    = "to_lat"
+	ColumnToLng    = "to_lng"
+	ColumnDistance = "distance"
+)
+
+// edgeListColumns is the column layout assumed for edge lists without header.
+var edgeListColumns = []string{ColumnFrom, ColumnTo, ColumnWeight, ColumnFromLat, ColumnFromLng, ColumnToLat, ColumnToLng}
+
+// EdgeListOptions describes the layout of an edge list read by LoadEdgeList.
+type EdgeListOptions struct {
+	// Comma is the field separator, ',' when zero. Use '\t' for TSV files.
+	Comma rune
+
+	// Header reports whether the first row names the columns. Columns are then matched by name
+	// (from, to, weight, from_lat, from_lng, to_lat, to_lng, distance) in any order and unknown
+	// columns are ignored. Without header, rows are read as from,to,weight[,from_lat,from_lng,to_lat,to_lng].
+	Header bool
+
+	// Directed makes every row a one-way edge; rows are bidirectional edges otherwise.
+	Directed bool
+}
+
+// LoadEdgeList builds a graph from a CSV or TSV edge list, so networks not coming from OSM, such as
+// logistics networks or abstract graphs, can be searched and indexed like road graphs. Node identifiers
+// are arbitrary strings, numbered in order of first appearance. Coordinates are optional; nodes without
+// them are placed at latitude and longitude 0, and edge distances fall back to the weight.
+//
+// Parameters:
+//   - r: io.Reader - Source of the edge list
+//   - opts: EdgeListOptions - Layout of the edge list
+//
+// Returns:
+//   - Graph: The graph described by the edge list
+//   - []string: The identifier of every node in the file, indexed by internal node ID
+//   - error: An error wrapping the line number if a row is malformed
+func LoadEdgeList(r io.Reader, opts EdgeListOptions) (Graph, []string, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = ','
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	columns := make(map[string]int)
+	for i, name := range edgeListColumns {
+		columns[name] = i
+	}
+	if opts.Header {
+		header, err := reader.Read()
+		if err != nil {
+			return EmptyGraph(), nil, err
+		}
+		clear(columns)
+		for i, name := range header {
+			columns[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		for _, required := range []string{ColumnFrom, ColumnTo, ColumnWeight} {
+			if _, ok := columns[required]; !ok {
+				return EmptyGraph(), nil, fmt.Errorf("%w %q", ErrMissingColumn, required)
+			}
+		}
+	}
+
+	direction := Bidirectional
+	if opts.Directed {
+		direction = LeftToRight
+	}
+	g := EmptyGraph()
+	labels := make([]string, 0)
+	ids := make(map[string]int32)
+	node := func(label string) Node {
+		if id, ok := ids[label]; ok {
+			return g.Nodes[id]
+		}
+		id := g.AddNode(Node{Location: coordinatesToCellID(0, 0)})
+		ids[label] = id
+		labels = append(labels, label)
+		return g.Nodes[id]
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return EmptyGraph(), nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		row := edgeListRow{record: record, columns: columns}
+
+		from, to := row.text(ColumnFrom), row.text(ColumnTo)
+		if from == "" || to == "" {
+			return EmptyGraph(), nil, fmt.Errorf("edge list line %d: %w %q or %q", line, ErrMissingColumn, ColumnFrom, ColumnTo)
+		}
+		weight, err := row.number(ColumnWeight)
+		if err != nil {
+			return EmptyGraph(), nil, fmt.Errorf("edge list line %d: %w", line, err)
+		}
+		if err := checkNodeCount(int64(len(labels)) + 2); err != nil {
+			return EmptyGraph(), nil, fmt.Errorf("edge list line %d: %w", line, err)
+		}
+		a, b := node(from), node(to)
+		for _, end := range []struct {
+			node     Node
+			lat, lng string
+		}{{a, ColumnFromLat, ColumnFromLng}, {b, ColumnToLat, ColumnToLng}} {
+			if !row.has(end.lat) || !row.has(end.lng) {
+				continue
+			}
+			lat, err1 := row.number(end.lat)
+			lng, err2 := row.number(end.lng)
+			if err := errors.Join(err1, err2); err != nil {
+				return EmptyGraph(), nil, fmt.Errorf("edge list line %d: %w", line, err)
+			}
+			g.Nodes[end.node.ID].Location = coordinatesToCellID(lat, lng)
+		}
+
+		distance := float32(weight)
+		if row.has(ColumnDistance) {
+			d, err := row.number(ColumnDistance)
+			if err != nil {
+				return EmptyGraph(), nil, fmt.Errorf("edge list line %d: %w", line, err)
+			}
+			distance = float32(d)
+		} else if row.has(ColumnFromLat) && row.has(ColumnToLat) {
+			distance = DistanceMeters(s2.CellID(g.Nodes[a.ID].Location), s2.CellID(g.Nodes[b.ID].Location))
+		}
+		g.RelateNodes(a, b, float32(weight), direction, MetaData{Distance: distance})
+	}
+	return g, labels, nil
+}
+
+// edgeListRow gives access by column name to the fields of an edge list record.
+type edgeListRow struct {
+	record  []string
+	columns map[string]int
+}
+
+// has reports whether the row has a non-empty value for the column.
+func (r edgeListRow) has(column string) bool {
+	return r.text(column) != ""
+}
+
+// text returns the trimmed value of a column, empty if the row does not have it.
+func (r edgeListRow) text(column string) string {
+	i, ok := r.columns[column]
+	if !ok || i >= len(r.record) {
+		return ""
+	}
+	return strings.TrimSpace(r.record[i])
+}
+
+// number parses the value of a column as a float.
+func (r edgeListRow) number(column string) (float64, error) {
+	if !r.has(column) {
+		return 0, fmt.Errorf("%w %q", ErrMissingColumn, column)
+	}
+	return strconv.ParseFloat(r.text(column), 64)
+}
@@ -0,0 +1,31 @@
+package graph_search
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadEdgeList(t *testing.T) {
+	input := "to\tfrom\tweight\tcomment\n" +
+		"depot\twarehouse\t4\tmain road\n" +
+		"depot\tstore\t1\t\n" +
+		"# closed for maintenance\n" +
+		"store\twarehouse\t1\t\n"
+	g, labels, err := LoadEdgeList(strings.NewReader(input), EdgeListOptions{Comma: '\t', Header: true, Directed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || labels[0] != "warehouse" {
+		t.Fatalf("got labels %v", labels)
+	}
+	response := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{1}}).Run(g)
+	if c, _ := response.Costs.GetCost(1); c != 2 {
+		t.Fatalf("got cost %f from warehouse to depot, expected 2", c)
+	}
+
+	_, _, err = LoadEdgeList(strings.NewReader("a,b\n"), EdgeListOptions{})
+	if !errors.Is(err, ErrMissingColumn) {
+		t.Fatalf("got %v for a row without weight, expected ErrMissingColumn", err)
+	}
+}
@@ -0,0 +1,60 @@
+package graph_search
+
+// ElevationProvider supplies the elevation of the terrain, e.g., from an SRTM or Copernicus DEM tile set.
+type ElevationProvider interface {
+	// Elevation returns the elevation in meters above sea level at a location, false when the provider has
+	// no data for it.
+	Elevation(c Coordinate) (float64, bool)
+}
+
+// ElevationFunc adapts an ordinary function to an ElevationProvider.
+type ElevationFunc func(c Coordinate) (float64, bool)
+
+// Elevation calls f(c).
+func (f ElevationFunc) Elevation(c Coordinate) (float64, bool) {
+	return f(c)
+}
+
+// ElevationPoint is a sample of an elevation profile.
+type ElevationPoint struct {
+	Distance  float64 // Distance in meters from the start of the route
+	Elevation float64 // Elevation in meters above sea level
+}
+
+// ElevationProfile is the elevation along a route, the data behind the elevation chart under a route.
+type ElevationProfile struct {
+	Points  []ElevationPoint // One sample per node of the route with a known elevation, by increasing distance
+	Ascent  float64          // Sum of the climbs between consecutive samples, in meters
+	Descent float64          // Sum of the drops between consecutive samples, in meters, as a positive number
+}
+
+// ElevationProfile samples the elevation of every node of the route.
+//
+// Parameters:
+//   - p: ElevationProvider - Source of the elevations. Nodes it has no data for are left out of the profile
+//
+// Returns:
+//   - ElevationProfile: The samples along the route with the total ascent and descent, without samples
+//     when the provider knows none of the nodes
+func (r Route) ElevationProfile(p ElevationProvider) ElevationProfile {
+	profile := ElevationProfile{Points: make([]ElevationPoint, 0, len(r.coordinates))}
+	distance := 0.0
+	for i, c := range r.coordinates {
+		if i > 0 {
+			distance += float64(r.Edges[i-1].Metadata.Distance)
+		}
+		elevation, ok := p.Elevation(c)
+		if !ok {
+			continue
+		}
+		if n := len(profile.Points); n > 0 {
+			if climb := elevation - profile.Points[n-1].Elevation; climb > 0 {
+				profile.Ascent += climb
+			} else {
+				profile.Descent -= climb
+			}
+		}
+		profile.Points = append(profile.Points, ElevationPoint{Distance: distance, Elevation: elevation})
+	}
+	return profile
+}
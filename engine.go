@@ -0,0 +1,312 @@
+package graph_search
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Engine bundles a graph with the auxiliary structures needed to answer routing queries, so servers
+// embedding the package have a single entry point instead of orchestrating graph, index and searches.
+type Engine struct {
+	graph    Graph
+	queries  *QueryLog
+	warmup   WarmupProgress
+	warmupMu sync.Mutex
+	metrics  Metrics
+
+	cache     *RouteCache
+	costModel atomic.Uint64
+	closures  *ClosureStore
+
+	indexOnce sync.Once
+	index     *KDTree
+
+	landmarksOnce sync.Once
+	landmarks     *LandmarkTable
+	landmarksErr  error
+
+	componentsOnce sync.Once
+	components     *Components
+
+	edgeIndexOnce sync.Once
+	edgeIndex     *EdgeIndex
+}
+
+// DefaultLandmarkCount is the number of landmarks of the table built by Engine.Landmarks.
+const DefaultLandmarkCount = 16
+
+// NewEngine creates an Engine over the given graph. Its spatial indexes and landmark table are built on
+// the first query needing them, safely when queries run concurrently; call Warmup to build them upfront.
+//
+// Parameters:
+//   - g: Graph - The graph used to answer queries. It must not be mutated while the engine is in use
+//
+// Returns:
+//   - *Engine: An engine ready to answer queries
+func NewEngine(g Graph) *Engine {
+	return &Engine{
+		graph:   g,
+		queries: NewQueryLog(),
+		metrics: NopMetrics{},
+	}
+}
+
+// Warmup builds the spatial indexes and the landmark table of the engine, so servers pay for them at
+// startup instead of on the first queries. It runs IndexWarmup and LandmarkWarmup with RunWarmup, so its
+// progress is reported by WarmupProgress like the runs of the warm-up scheduler, which takes the same
+// tasks. Calling it again, or concurrently with queries, is safe.
+//
+// Returns:
+//   - error: The error of the construction of the landmark table, e.g., ErrNegativeWeight
+func (e *Engine) Warmup() error {
+	return e.RunWarmup(context.Background(), []WarmupTask{IndexWarmup(), LandmarkWarmup()})
+}
+
+// SetMetrics configures where the engine reports query and snapping measurements.
+// It must be called before the engine starts serving queries.
+func (e *Engine) SetMetrics(m Metrics) {
+	if m == nil {
+		m = NopMetrics{}
+	}
+	e.metrics = m
+}
+
+// Graph returns the graph the engine answers queries on.
+func (e *Engine) Graph() Graph {
+	return e.graph
+}
+
+// Index returns the spatial index of the graph nodes, built on the first call.
+func (e *Engine) Index() *KDTree {
+	e.indexOnce.Do(func() {
+		e.index = e.graph.BuildNodeIndex()
+	})
+	return e.index
+}
+
+// Landmarks returns the landmark table of the graph, built on the first call with DefaultLandmarkCount
+// landmarks over Edge.Weight. It gives lower bounds for searches minimizing the edge weights, such as
+// the ones of RouteALT.
+//
+// Returns:
+//   - *LandmarkTable: The landmark table, nil on error
+//   - error: ErrNegativeWeight if an edge has a negative weight
+func (e *Engine) Landmarks() (*LandmarkTable, error) {
+	e.landmarksOnce.Do(func() {
+		e.landmarks, e.landmarksErr = NewLandmarkTable(e.graph, DefaultLandmarkCount, nil)
+	})
+	return e.landmarks, e.landmarksErr
+}
+
+// RouteALT answers an origin-destination query minimizing Edge.Weight with the landmarks of the engine,
+// which settles far fewer nodes than Route on large graphs. Without closures it runs the bidirectional
+// ALT search of LandmarkTable.ShortestPath and shares the route cache with Route queries without
+// restrictions. The closures set with SetClosures only raise costs or remove edges, so the landmark
+// bounds still hold and it runs an A* search guided by them through Route instead, unless an overlay
+// lowers some costs, in which case it runs a plain Route search.
+//
+// Parameters:
+//   - p: ODPair - The source and target nodes
+//
+// Returns:
+//   - BatchResult: The shortest path between the pair, or the reason why there is none. Err is the
+//     error of Landmarks if the landmark table cannot be built
+func (e *Engine) RouteALT(p ODPair) BatchResult {
+	lt, err := e.Landmarks()
+	if err != nil {
+		return BatchResult{Pair: p, Err: err}
+	}
+	if e.closures != nil {
+		c := e.closures.Apply(Criteria{})
+		if !c.Overlay.lowers() {
+			c.Heuristic = lt.Heuristic()
+		}
+		return e.Route(p, c)
+	}
+
+	key := CacheKey{Source: p.Source, Target: p.Target, Profile: profileKey(Criteria{}), CostModel: e.costModel.Load()}
+	if e.cache != nil {
+		if result, ok := e.cache.Get(key); ok {
+			e.metrics.Count(MetricCacheHits, 1)
+			return result
+		}
+	}
+	e.queries.Record(p)
+	result := BatchResult{Pair: p}
+	result.Cost, result.Route, result.Err = lt.ShortestPath(e.graph, p.Source, p.Target)
+	if errors.Is(result.Err, ErrNodeNotFound) {
+		return result
+	}
+	if e.cache != nil {
+		e.cache.Put(key, result)
+	}
+	return result
+}
+
+// Queries returns the log of origin-destination pairs answered by the engine.
+func (e *Engine) Queries() *QueryLog {
+	return e.queries
+}
+
+// ShortestPath runs a Dijkstra search with the given criteria and records every
+// source/target pair of the query in the query log. The closures set with SetClosures are applied.
+//
+// Parameters:
+//   - c: Criteria - Search parameters
+//
+// Returns:
+//   - Response: The result of the search
+func (e *Engine) ShortestPath(c Criteria) Response {
+	for _, s := range c.Source {
+		for _, t := range c.Targets {
+			e.queries.Record(ODPair{Source: s, Target: t})
+		}
+	}
+	return e.search(c)
+}
+
+// search runs a Dijkstra search like ShortestPath without recording the query.
+func (e *Engine) search(c Criteria) Response {
+	if c.Metrics == nil {
+		c.Metrics = e.metrics
+	}
+	if e.closures != nil {
+		c = e.closures.Apply(c)
+	}
+	start := time.Now()
+	response := NewDijkstra(c).Run(e.graph)
+	getLogger().Debug("query answered", "sources", len(c.Source), "targets", len(c.Targets),
+		"settled", len(response.SearchSpace.Nodes), "duration", time.Since(start))
+	return response
+}
+
+// Snap projects a coordinate onto the closest edge of the graph within DefaultSnapRadius and reports
+// the snap distance to the engine metrics.
+//
+// Parameters:
+//   - c: Coordinate - The coordinate to snap
+//
+// Returns:
+//   - EdgeSnap: The projection onto the closest edge
+//   - error: ErrNoCandidates if no edge lies near the coordinate
+func (e *Engine) Snap(c Coordinate) (EdgeSnap, error) {
+	return e.SnapWithOptions(c, SnapOptions{})
+}
+
+// SnapWithOptions projects a coordinate onto the closest edge of the graph like Snap, honoring the
+// search radius and vehicle heading of the options.
+//
+// Parameters:
+//   - c: Coordinate - The coordinate to snap
+//   - opts: SnapOptions - Search radius and heading of the vehicle
+//
+// Returns:
+//   - EdgeSnap: The projection onto the chosen edge
+//   - error: ErrNoCandidates if no edge lies near the coordinate
+func (e *Engine) SnapWithOptions(c Coordinate, opts SnapOptions) (EdgeSnap, error) {
+	snap, err := e.graph.SnapToEdgeWithOptions(e.Index(), c, opts)
+	if err == nil {
+		e.metrics.Observe(MetricSnapDistance, snap.Distance)
+	}
+	return snap, err
+}
+
+// NearestSnaps returns up to k candidate snaps of a coordinate, closest first, see Graph.NearestSnaps.
+//
+// Parameters:
+//   - c: Coordinate - The coordinate to snap
+//   - k: int - Maximum number of candidates
+//   - opts: SnapOptions - Search radius, heading and component restriction
+//
+// Returns:
+//   - []EdgeSnap: The candidates, at most k
+func (e *Engine) NearestSnaps(c Coordinate, k int, opts SnapOptions) []EdgeSnap {
+	return e.graph.NearestSnaps(e.Index(), c, k, opts)
+}
+
+// Components returns the strongly connected components of the graph, computed on the first call. Set
+// them as SnapOptions.Components to keep snaps on the largest component.
+func (e *Engine) Components() *Components {
+	e.componentsOnce.Do(func() {
+		c := e.graph.StronglyConnectedComponents()
+		e.components = &c
+	})
+	return e.components
+}
+
+// EdgeIndex returns the R-tree over the edges of the graph, built on the first call.
+func (e *Engine) EdgeIndex() *EdgeIndex {
+	e.edgeIndexOnce.Do(func() {
+		e.edgeIndex = e.graph.BuildEdgeIndex()
+	})
+	return e.edgeIndex
+}
+
+// ODPair identifies an origin-destination query by its source and target node IDs.
+type ODPair struct {
+	Source int32
+	Target int32
+}
+
+// QueryLog counts how many times each origin-destination pair was queried. It is safe for concurrent use.
+type QueryLog struct {
+	mu     sync.Mutex
+	counts map[ODPair]int
+}
+
+// NewQueryLog returns an empty QueryLog.
+func NewQueryLog() *QueryLog {
+	return &QueryLog{counts: make(map[ODPair]int)}
+}
+
+// Record increments the counter of a pair.
+func (l *QueryLog) Record(p ODPair) {
+	l.mu.Lock()
+	l.counts[p]++
+	l.mu.Unlock()
+}
+
+// Count returns how many times a pair was recorded.
+func (l *QueryLog) Count(p ODPair) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.counts[p]
+}
+
+// Len returns the number of distinct pairs recorded.
+func (l *QueryLog) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.counts)
+}
+
+// Top returns the n most frequently queried pairs, most popular first. Ties are broken by source and
+// target IDs so the result is deterministic.
+func (l *QueryLog) Top(n int) []ODPair {
+	l.mu.Lock()
+	counts := make(map[ODPair]int, len(l.counts))
+	pairs := make([]ODPair, 0, len(l.counts))
+	for p, c := range l.counts {
+		counts[p] = c
+		pairs = append(pairs, p)
+	}
+	l.mu.Unlock()
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if counts[pairs[i]] != counts[pairs[j]] {
+			return counts[pairs[i]] > counts[pairs[j]]
+		}
+		if pairs[i].Source != pairs[j].Source {
+			return pairs[i].Source < pairs[j].Source
+		}
+		return pairs[i].Target < pairs[j].Target
+	})
+	if n < len(pairs) {
+		pairs = pairs[:n]
+	}
+	return pairs
+}
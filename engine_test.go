@@ -0,0 +1,612 @@
+package graph_search
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestEngine_RouteBatch(t *testing.T) {
+	g := GridGraph(4, 4, 100)
+	g.AddNode(Node{}) // isolated node 16
+	e := NewEngine(g)
+
+	pairs := []ODPair{{Source: 0, Target: 15}, {Source: 5, Target: 6}, {Source: 0, Target: 16}, {Source: 0, Target: 99}}
+	results := e.RouteBatch(pairs, 3)
+
+	for i, expected := range []int{7, 2} {
+		r := results[i]
+		if r.Err != nil {
+			t.Fatalf("pair %v: unexpected error %v", r.Pair, r.Err)
+		}
+		if len(r.Route.Nodes) != expected || r.Route.Nodes[0] != r.Pair.Source || r.Route.Nodes[expected-1] != r.Pair.Target {
+			t.Fatalf("pair %v: got route %v", r.Pair, r.Route.Nodes)
+		}
+	}
+	if !errors.Is(results[2].Err, ErrNoRoute) {
+		t.Fatalf("got %v for an unreachable target, expected ErrNoRoute", results[2].Err)
+	}
+	if !errors.Is(results[3].Err, ErrNodeNotFound) {
+		t.Fatalf("got %v for an unknown target, expected ErrNodeNotFound", results[3].Err)
+	}
+}
+
+func TestEngine_RouteCache(t *testing.T) {
+	e := NewEngine(GridGraph(3, 3, 100))
+	e.EnableRouteCache(2)
+
+	first := e.Route(ODPair{Source: 0, Target: 8}, Criteria{})
+	e.Route(ODPair{Source: 0, Target: 8}, Criteria{})
+	if e.Queries().Top(1)[0] != first.Pair || e.RouteCache().Len() != 1 {
+		t.Fatalf("got %d cached routes, expected the repeated query to hit the cache", e.RouteCache().Len())
+	}
+	e.Route(ODPair{Source: 0, Target: 8}, Criteria{AvoidTolls: true})
+	e.Route(ODPair{Source: 2, Target: 1}, Criteria{})
+	if e.RouteCache().Len() != 2 {
+		t.Fatalf("got %d cached routes, expected the capacity to be honored", e.RouteCache().Len())
+	}
+
+	if removed := e.InvalidateEdge(2, 1); removed != 1 {
+		t.Fatalf("got %d invalidated routes, expected 1", removed)
+	}
+	e.InvalidateRoutes()
+	if e.RouteCache().Len() != 0 {
+		t.Fatalf("got %d cached routes after invalidation", e.RouteCache().Len())
+	}
+
+	// Queries answered from the cache are still counted by the query log.
+	e = NewEngine(GridGraph(3, 3, 100))
+	e.EnableRouteCache(10)
+	expected := []ODPair{{Source: 6, Target: 2}, {Source: 2, Target: 1}, {Source: 0, Target: 8}}
+	for i, p := range expected {
+		for n := 0; n < len(expected)-i; n++ {
+			e.Route(p, Criteria{})
+		}
+	}
+	if top := e.Queries().Top(3); !reflect.DeepEqual(top, expected) {
+		t.Fatalf("got top pairs %v, expected %v ranked by their 3, 2 and 1 queries", top, expected)
+	}
+}
+
+// recordingMetrics is a Metrics implementation keeping every measurement.
+type recordingMetrics struct {
+	mu       sync.Mutex
+	counts   map[string]float64
+	observed map[string][]float64
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{counts: make(map[string]float64), observed: make(map[string][]float64)}
+}
+
+func (m *recordingMetrics) Count(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[name] += value
+}
+
+func (m *recordingMetrics) Observe(name string, value float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observed[name] = append(m.observed[name], value)
+}
+
+func TestEngine_Metrics(t *testing.T) {
+	// A 200 m street running east.
+	g := EmptyGraph()
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	for _, p := range [][2]float64{{0, 0}, {100, 0}, {200, 0}} {
+		lat, lng := MetersToLatLng(x0+p[0], y0+p[1])
+		g.AddNode(Node{Location: coordinatesToCellID(lat, lng)})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 100, LeftToRight, MetaData{Distance: 100})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 100, LeftToRight, MetaData{Distance: 100})
+
+	m := newRecordingMetrics()
+	NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{2}, Metrics: m}).Run(g)
+	if m.counts[MetricQueries] != 1 {
+		t.Fatalf("got %v queries, expected 1", m.counts[MetricQueries])
+	}
+	if settled := m.observed[MetricNodesSettled]; len(settled) != 1 || settled[0] != 3 {
+		t.Fatalf("got settled nodes %v, expected one search settling 3 nodes", settled)
+	}
+	if size := m.observed[MetricQueueSize]; len(size) != 1 || size[0] < 1 || size[0] > 3 {
+		t.Fatalf("got queue sizes %v, expected one size between 1 and 3", size)
+	}
+	if latency := m.observed[MetricQueryLatency]; len(latency) != 1 || latency[0] < 0 || latency[0] > 10 {
+		t.Fatalf("got latencies %v, expected one latency under 10 s", latency)
+	}
+
+	m = newRecordingMetrics()
+	e := NewEngine(g)
+	e.SetMetrics(m)
+	e.EnableRouteCache(1)
+	lat, lng := MetersToLatLng(x0+80, y0+30)
+	if _, err := e.Snap(Coordinate{Lat: lat, Lng: lng}); err != nil {
+		t.Fatal(err)
+	}
+	if distance := m.observed[MetricSnapDistance]; len(distance) != 1 || math.Abs(distance[0]-30) > 2 {
+		t.Fatalf("got snap distances %v, expected one snap about 30 m away", distance)
+	}
+	e.Route(ODPair{Source: 0, Target: 2}, Criteria{})
+	e.Route(ODPair{Source: 0, Target: 2}, Criteria{})
+	if m.counts[MetricCacheHits] != 1 || m.counts[MetricQueries] != 1 {
+		t.Fatalf("got %v cache hits and %v searches, expected the repeated route to hit the cache",
+			m.counts[MetricCacheHits], m.counts[MetricQueries])
+	}
+	if len(m.observed[MetricNodesSettled]) != 1 || len(m.observed[MetricQueryLatency]) != 1 {
+		t.Fatalf("got %d settled and %d latency samples, expected only the first route to search",
+			len(m.observed[MetricNodesSettled]), len(m.observed[MetricQueryLatency]))
+	}
+}
+
+func TestEngine_SnapWithBearing(t *testing.T) {
+	// A divided road running east-west: the northern carriageway, 20 m north, goes west.
+	g := EmptyGraph()
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	for _, p := range [][2]float64{{0, 0}, {200, 0}, {0, 20}, {200, 20}} {
+		lat, lng := MetersToLatLng(x0+p[0], y0+p[1])
+		g.AddNode(Node{Location: coordinatesToCellID(lat, lng)})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 200, LeftToRight, MetaData{Distance: 200})
+	g.RelateNodes(g.Nodes[3], g.Nodes[2], 200, LeftToRight, MetaData{Distance: 200})
+	e := NewEngine(g)
+
+	lat, lng := MetersToLatLng(x0+100, y0+12)
+	position := Coordinate{Lat: lat, Lng: lng}
+	if snap, _ := e.Snap(position); snap.From != 3 {
+		t.Fatalf("got edge %d->%d, expected the closest carriageway 3->2", snap.From, snap.To)
+	}
+	snap, err := e.SnapWithOptions(position, SnapOptions{UseBearing: true, Bearing: 85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.From != 0 || snap.To != 1 {
+		t.Fatalf("got edge %d->%d, expected the eastbound carriageway 0->1", snap.From, snap.To)
+	}
+}
+
+func TestTracker_Update(t *testing.T) {
+	// Calle 10 runs east through 0 -> 1 -> 2 -> 3 and splits at 3. Calle 11 runs parallel 30 m north
+	// through 6 -> 7 -> 8 and becomes a primary road after 8.
+	g := EmptyGraph()
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	at := func(x, y float64) Coordinate {
+		lat, lng := MetersToLatLng(x0+x, y0+y)
+		return Coordinate{Lat: lat, Lng: lng}
+	}
+	for _, p := range [][2]float64{{0, 0}, {200, 0}, {400, 0}, {600, 0}, {800, 0}, {600, 200}, {0, 30}, {200, 30}, {400, 30}, {600, 30}} {
+		c := at(p[0], p[1])
+		g.AddNode(Node{Location: coordinatesToCellID(c.Lat, c.Lng)})
+	}
+	relate := func(a, b int32, name, roadType string) {
+		g.RelateNodes(g.Nodes[a], g.Nodes[b], 200, LeftToRight, MetaData{Distance: 200, Name: name, RoadType: roadType})
+	}
+	relate(0, 1, "Calle 10", Residential)
+	relate(1, 2, "Calle 10", Residential)
+	relate(2, 3, "Calle 10", Residential)
+	relate(3, 4, "Calle 10", Residential)
+	relate(3, 5, "Carrera 43", Residential)
+	relate(6, 7, "Calle 11", Residential)
+	relate(7, 8, "Calle 11", Residential)
+	relate(8, 9, "Calle 11", Primary)
+	tracker := NewTracker(g, g.BuildNodeIndex())
+	if _, ok := tracker.Current(); ok || tracker.DistanceToNextManeuver() != 0 {
+		t.Fatal("expected no position before the first fix")
+	}
+
+	steps := []struct {
+		x, y     float64
+		from, to int32
+		road     string
+		maneuver float64
+	}{
+		{50, 5, 0, 1, "Calle 10", 550},   // 150 m to node 1, then 400 m to the split at node 3
+		{150, 18, 0, 1, "Calle 10", 450}, // Calle 11 is closer, but not closer by the transition penalty
+		{250, 2, 1, 2, "Calle 10", 350},  // continues on the next edge of the street
+		{300, 32, 7, 8, "Calle 11", 100}, // jumps to the parallel road, which changes type at node 8
+	}
+	for i, step := range steps {
+		snap, err := tracker.Update(at(step.x, step.y))
+		if err != nil {
+			t.Fatalf("fix %d: %v", i, err)
+		}
+		if snap.From != step.from || snap.To != step.to || tracker.Road().Name != step.road {
+			t.Fatalf("fix %d: got edge %d->%d on %q, expected %d->%d on %q", i, snap.From, snap.To, tracker.Road().Name, step.from, step.to, step.road)
+		}
+		if d := tracker.DistanceToNextManeuver(); math.Abs(d-step.maneuver) > 2 {
+			t.Errorf("fix %d: got %.1f m to the next maneuver, expected %.0f m", i, d, step.maneuver)
+		}
+	}
+
+	if _, err := tracker.Update(at(5000, 5000)); !errors.Is(err, ErrNoCandidates) {
+		t.Fatalf("got %v for a fix away from every road, expected ErrNoCandidates", err)
+	}
+	if current, ok := tracker.Current(); !ok || current.From != 7 || current.To != 8 {
+		t.Fatalf("got %+v, expected the previous position to be kept", current)
+	}
+}
+
+func TestNavigationSession_Reroute(t *testing.T) {
+	// a -> b -> c -> d along the planned route, with a detour b -> e -> f -> c to the north.
+	g := EmptyGraph()
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	at := func(x, y float64) Coordinate {
+		lat, lng := MetersToLatLng(x0+x, y0+y)
+		return Coordinate{Lat: lat, Lng: lng}
+	}
+	for _, p := range [][2]float64{{0, 0}, {100, 0}, {200, 0}, {300, 0}, {100, 100}, {200, 100}} {
+		c := at(p[0], p[1])
+		g.AddNode(Node{Location: coordinatesToCellID(c.Lat, c.Lng)})
+	}
+	for _, edge := range [][2]int32{{0, 1}, {1, 2}, {2, 3}, {1, 4}, {4, 5}, {5, 2}} {
+		from, to := g.Nodes[edge[0]], g.Nodes[edge[1]]
+		distance := DistanceMeters(s2.CellID(from.Location), s2.CellID(to.Location))
+		g.RelateNodes(from, to, distance, LeftToRight, MetaData{Distance: distance})
+	}
+
+	session, err := NewNavigationSession(NewEngine(g), 0, 3, Criteria{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, _ := session.Update(at(50, 5))
+	if !update.OnRoute || update.Remaining < 240 {
+		t.Fatalf("got %+v, expected to be on route with about 250 m left", update)
+	}
+	if update, _ = session.Update(at(105, 40)); update.OnRoute || update.Rerouted {
+		t.Fatalf("got %+v, expected a single stray fix to be tolerated", update)
+	}
+	update, _ = session.Update(at(105, 60))
+	if !update.Rerouted {
+		t.Fatalf("got %+v, expected a reroute", update)
+	}
+	expected := []int32{1, 4, 5, 2, 3}
+	if fmt.Sprint(update.Route.Nodes) != fmt.Sprint(expected) {
+		t.Fatalf("got route %v, expected %v", update.Route.Nodes, expected)
+	}
+}
+
+func TestEngine_NearestRoad(t *testing.T) {
+	// Two parallel streets 100 m apart.
+	g := EmptyGraph()
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	for _, p := range [][2]float64{{0, 0}, {200, 0}, {0, 100}, {200, 100}} {
+		lat, lng := MetersToLatLng(x0+p[0], y0+p[1])
+		g.AddNode(Node{Location: coordinatesToCellID(lat, lng)})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 200, Bidirectional, MetaData{Distance: 200, Name: "Calle 10", RoadType: Residential, WayID: 10})
+	g.RelateNodes(g.Nodes[2], g.Nodes[3], 200, Bidirectional, MetaData{Distance: 200, Name: "Carrera 43A", RoadType: Primary, WayID: 43})
+	e := NewEngine(g)
+
+	lat, lng := MetersToLatLng(x0+80, y0+70)
+	road, err := e.NearestRoad(Coordinate{Lat: lat, Lng: lng})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if road.Name != "Carrera 43A" || road.RoadType != Primary || road.WayID != 43 || math.Abs(road.Distance-30) > 2 {
+		t.Fatalf("got %+v, expected Carrera 43A about 30 m away", road)
+	}
+}
+
+func TestEngine_Closures(t *testing.T) {
+	g := GridGraph(2, 3, 100)
+	e := NewEngine(g)
+	e.EnableRouteCache(8)
+	store := NewClosureStore(g)
+	e.SetClosures(store)
+	base := e.Route(ODPair{Source: 0, Target: 2}, Criteria{})
+	if base.Err != nil {
+		t.Fatal(base.Err)
+	}
+
+	x, y := LatLngToMeters(nodeCoordinate(g.Nodes[4]).Lat, nodeCoordinate(g.Nodes[4]).Lng)
+	ring := make([][]float64, 0, 5)
+	for _, d := range [][2]float64{{-20, -20}, {20, -20}, {20, 20}, {-20, 20}, {-20, -20}} {
+		lat, lng := MetersToLatLng(x+d[0], y+d[1])
+		ring = append(ring, []float64{lng, lat})
+	}
+	var feed bytes.Buffer
+	encoder := json.NewEncoder(&feed)
+	_ = encoder.Encode(Closure{ID: "crash", From: g.Nodes[1].Location, To: g.Nodes[2].Location, Direction: LeftToRight})
+	_ = encoder.Encode(Closure{ID: "flood", Polygon: [][][]float64{ring}, Start: time.Now().Add(time.Hour)})
+	if pushed, err := ReadClosures(&feed, store); err != nil || pushed != 2 {
+		t.Fatalf("got %d closures pushed (%v), expected 2", pushed, err)
+	}
+
+	detour := e.Route(ODPair{Source: 0, Target: 2}, Criteria{})
+	if detour.Err != nil || detour.Cost < 1.9*base.Cost {
+		t.Fatalf("got cost %f (%v) with the crash, expected a detour from %f", detour.Cost, detour.Err, base.Cost)
+	}
+	later := e.Route(ODPair{Source: 0, Target: 2}, Criteria{Departure: time.Now().Add(2 * time.Hour)})
+	if !errors.Is(later.Err, ErrNoRoute) {
+		t.Fatalf("got %v during the flood, expected ErrNoRoute", later.Err)
+	}
+
+	if err := store.Remove("crash"); err != nil {
+		t.Fatal(err)
+	}
+	if r := e.Route(ODPair{Source: 0, Target: 2}, Criteria{}); r.Cost != base.Cost {
+		t.Fatalf("got cost %f once the crash is cleared, expected %f", r.Cost, base.Cost)
+	}
+	if err := store.Push(Closure{ID: "flood", WayID: 1, End: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if closures := store.Closures(); len(closures) != 0 {
+		t.Fatalf("got %d closures, expected the expired flood to be lifted", len(closures))
+	}
+	if err := store.Push(Closure{ID: "empty"}); !errors.Is(err, ErrInvalidClosure) {
+		t.Fatalf("got %v, expected ErrInvalidClosure", err)
+	}
+}
+
+func TestEngine_MaxSettledNodes(t *testing.T) {
+	g := GridGraph(10, 10, 100)
+	g.AddNode(Node{}) // isolated node 100, a typical bad snap
+	e := NewEngine(g)
+
+	r := e.Route(ODPair{Source: 0, Target: 100}, Criteria{MaxSettledNodes: 20})
+	var limit *SearchLimitError
+	if !errors.As(r.Err, &limit) || !errors.Is(r.Err, ErrSearchLimit) || limit.Limit != 20 {
+		t.Fatalf("got %v, expected a SearchLimitError", r.Err)
+	}
+	if r := e.Route(ODPair{Source: 0, Target: 11}, Criteria{MaxSettledNodes: 20}); r.Err != nil {
+		t.Fatalf("got %v for a nearby target", r.Err)
+	}
+	response := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{100}, MaxSettledNodes: 20}).Run(g)
+	if len(response.SearchSpace.Nodes) != 20 || response.Err == nil {
+		t.Fatalf("settled %d nodes (%v), expected 20 and an error", len(response.SearchSpace.Nodes), response.Err)
+	}
+}
+
+func TestEngine_RouteBetween(t *testing.T) {
+	e := NewEngine(GridGraph(3, 3, 100))
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	at := func(x, y float64) Coordinate {
+		lat, lng := MetersToLatLng(x0+x, y0+y)
+		return Coordinate{Lat: lat, Lng: lng}
+	}
+
+	r, err := e.RouteBetween(at(30, -5), at(170, 205), RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Route.Nodes[0] != 0 || r.Route.Nodes[1] != 1 || r.Route.Nodes[len(r.Route.Nodes)-1] != 8 {
+		t.Errorf("got route %v, expected to leave along 0->1 and arrive along 7->8", r.Route.Nodes)
+	}
+	if math.Abs(r.Distance-340) > 5 {
+		t.Errorf("got %.1f m, expected about 340 m between the snapped points", r.Distance)
+	}
+	coordinates := r.Route.Coordinates()
+	if coordinates[0] != r.Origin.Point || coordinates[len(coordinates)-1] != r.Destination.Point {
+		t.Errorf("route geometry does not start and end at the snapped points")
+	}
+
+	// Both points on the same street, the destination behind the origin: no U-turn around the block.
+	r, err = e.RouteBetween(at(80, -5), at(20, -5), RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(r.Route.Nodes) != "[1 0]" || math.Abs(r.Distance-60) > 2 {
+		t.Errorf("got route %v of %.1f m, expected [1 0] of about 60 m", r.Route.Nodes, r.Distance)
+	}
+
+	if _, err := e.RouteBetween(at(30, -5), at(5000, 5000), RouteOptions{}); !errors.Is(err, ErrNoCandidates) {
+		t.Errorf("got %v for a destination far from the graph, expected ErrNoCandidates", err)
+	}
+}
+
+func TestEngine_MaxSnapDistance(t *testing.T) {
+	e := NewEngine(GridGraph(3, 3, 100))
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	at := func(x, y float64) Coordinate {
+		lat, lng := MetersToLatLng(x0+x, y0+y)
+		return Coordinate{Lat: lat, Lng: lng}
+	}
+	opts := RouteOptions{Snap: SnapOptions{MaxSnapDistance: 50}}
+
+	r, err := e.RouteBetween(at(30, -20), at(170, 205), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := r.Origin.Diagnostics(); d.Input != at(30, -20) || math.Abs(d.Distance-20) > 2 || d.Snapped != r.Origin.Point {
+		t.Errorf("got origin diagnostics %+v, expected a snap about 20 m away", d)
+	}
+
+	_, err = e.RouteBetween(at(30, -20), at(3000, 100), opts)
+	var snapErr *SnapDistanceError
+	if !errors.As(err, &snapErr) || !errors.Is(err, ErrSnapTooFar) {
+		t.Fatalf("got %v, expected a *SnapDistanceError", err)
+	}
+	if snapErr.MaxDistance != 50 || math.Abs(snapErr.Closest.Distance-2800) > 50 {
+		t.Errorf("got %+v, expected the closest road about 2800 m away", snapErr)
+	}
+}
+
+func TestEngine_NearestSnapsInLargestComponent(t *testing.T) {
+	// A 3x3 grid and, just south of its first street, a service road connected to nothing.
+	g := GridGraph(3, 3, 100)
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	at := func(x, y float64) Coordinate {
+		lat, lng := MetersToLatLng(x0+x, y0+y)
+		return Coordinate{Lat: lat, Lng: lng}
+	}
+	for _, p := range []Coordinate{at(20, -20), at(80, -20)} {
+		g.AddNode(Node{Location: coordinatesToCellID(p.Lat, p.Lng)})
+	}
+	g.RelateNodes(g.Nodes[9], g.Nodes[10], 60, Bidirectional, MetaData{Distance: 60, RoadType: Service})
+	e := NewEngine(g)
+
+	components := e.Components()
+	if len(components.Sizes) != 2 || components.Sizes[components.Largest] != 9 || components.InLargest(9) {
+		t.Fatalf("got component sizes %v, expected the grid and the service road", components.Sizes)
+	}
+
+	position := at(50, -15)
+	if snap, _ := e.Snap(position); snap.Edge.Metadata.RoadType != Service {
+		t.Fatalf("got edge %d->%d, expected the closest service road", snap.From, snap.To)
+	}
+	snaps := e.NearestSnaps(position, 3, SnapOptions{Components: components})
+	if len(snaps) != 3 {
+		t.Fatalf("got %d candidates, expected 3", len(snaps))
+	}
+	for i, snap := range snaps {
+		if !components.InLargest(snap.From) || !components.InLargest(snap.To) {
+			t.Errorf("candidate %d is on edge %d->%d, outside the largest component", i, snap.From, snap.To)
+		}
+		if i > 0 && snap.Distance < snaps[i-1].Distance {
+			t.Errorf("candidates are not sorted by distance")
+		}
+	}
+	if _, err := e.RouteBetween(position, at(200, 200), RouteOptions{Snap: SnapOptions{Components: components}}); err != nil {
+		t.Errorf("got %v, expected a route from the grid", err)
+	}
+}
+
+func TestEngine_LazyIndexes(t *testing.T) {
+	e := NewEngine(GridGraph(5, 5, 100))
+
+	// Concurrent first queries share a single index.
+	indexes := make(chan *KDTree, 8)
+	for i := 0; i < cap(indexes); i++ {
+		go func() {
+			if _, err := e.Snap(nodeCoordinate(e.Graph().Nodes[12])); err != nil {
+				t.Error(err)
+			}
+			indexes <- e.Index()
+		}()
+	}
+	first := <-indexes
+	for i := 1; i < cap(indexes); i++ {
+		if index := <-indexes; index != first {
+			t.Fatal("got several node indexes, expected one built on the first query")
+		}
+	}
+
+	if err := e.Warmup(); err != nil {
+		t.Fatal(err)
+	}
+	if e.Index() != first {
+		t.Error("Warmup rebuilt the node index")
+	}
+	if p := e.WarmupProgress(); p.Runs != 1 || p.Completed != 2 {
+		t.Errorf("got progress %+v, expected Warmup to run its tasks like the scheduler", p)
+	}
+	lt, err := e.Landmarks()
+	if err != nil || len(lt.Landmarks) != DefaultLandmarkCount {
+		t.Fatalf("got %v landmarks and %v, expected %d landmarks", lt, err, DefaultLandmarkCount)
+	}
+	expected := e.Route(ODPair{Source: 0, Target: 24}, Criteria{}).Cost
+	if cost, _, err := lt.ShortestPath(e.Graph(), 0, 24); err != nil || math.Abs(float64(cost-expected)) > 1e-3 {
+		t.Errorf("got cost %v and %v, expected %v", cost, err, expected)
+	}
+}
+
+func TestEngine_RouteALT(t *testing.T) {
+	g := GridGraph(10, 10, 100)
+	reference := NewEngine(g)
+	e := NewEngine(g)
+	e.EnableRouteCache(10)
+	pairs := []ODPair{{Source: 0, Target: 99}, {Source: 9, Target: 90}, {Source: 45, Target: 54}}
+	for _, p := range pairs {
+		r := e.RouteALT(p)
+		expected := reference.Route(p, Criteria{})
+		if r.Err != nil || math.Abs(float64(r.Cost-expected.Cost)) > 1e-2 {
+			t.Fatalf("%+v: got cost %f and %v, expected %f", p, r.Cost, r.Err, expected.Cost)
+		}
+		if nodes := r.Route.Nodes; nodes[0] != p.Source || nodes[len(nodes)-1] != p.Target || len(r.Route.Edges) != len(nodes)-1 {
+			t.Fatalf("%+v: got route %v", p, nodes)
+		}
+	}
+	if n := e.RouteCache().Len(); n != len(pairs) {
+		t.Fatalf("got %d cached routes, expected %d", n, len(pairs))
+	}
+	if r := e.Route(pairs[0], Criteria{}); r.Cost != e.RouteALT(pairs[0]).Cost || e.RouteCache().Len() != len(pairs) {
+		t.Fatal("expected Route to share the cached ALT routes")
+	}
+	if top := e.Queries().Top(1); len(top) != 1 {
+		t.Fatal("expected RouteALT to record its queries")
+	}
+	if r := e.RouteALT(ODPair{Source: 0, Target: 1000}); !errors.Is(r.Err, ErrNodeNotFound) {
+		t.Fatalf("got %v, expected ErrNodeNotFound", r.Err)
+	}
+
+	// A closure on the first edge of the bottom row is honored by the landmark guided search.
+	store := NewClosureStore(g)
+	if err := store.Push(Closure{ID: "works", From: g.Nodes[0].Location, To: g.Nodes[1].Location, Direction: Bidirectional}); err != nil {
+		t.Fatal(err)
+	}
+	closed := NewEngine(g)
+	closed.SetClosures(store)
+	r := closed.RouteALT(ODPair{Source: 0, Target: 9})
+	expected := closed.Route(ODPair{Source: 0, Target: 9}, Criteria{})
+	if r.Err != nil || math.Abs(float64(r.Cost-expected.Cost)) > 1e-2 || r.Route.Nodes[1] == 1 {
+		t.Fatalf("got route %v of cost %f (%v), expected a detour of cost %f", r.Route.Nodes, r.Cost, r.Err, expected.Cost)
+	}
+}
+
+func TestEngine_RouteToPolygon(t *testing.T) {
+	e := NewEngine(GridGraph(5, 5, 100))
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	square := func(minX, minY, maxX, maxY float64) GeoJSONPolygon {
+		ring := make([][]float64, 0)
+		for _, p := range [][2]float64{{minX, minY}, {maxX, minY}, {maxX, maxY}, {minX, maxY}, {minX, minY}} {
+			lat, lng := MetersToLatLng(x0+p[0], y0+p[1])
+			ring = append(ring, []float64{lng, lat})
+		}
+		return GeoJSONPolygon{ID: "area", Rings: [][][]float64{ring}}
+	}
+
+	// The north-east block of the grid holds nodes 18, 19, 23 and 24; 18 is the closest to node 0.
+	area := square(250, 250, 450, 450)
+	if nodes := e.Graph().NodesInPolygon(e.Index(), area); len(nodes) != 4 {
+		t.Fatalf("got nodes %v inside the area, expected 4", nodes)
+	}
+	result := e.RouteToPolygon(0, area, Criteria{})
+	if result.Err != nil || result.Pair.Target != 18 {
+		t.Fatalf("got target %d and %v, expected 18", result.Pair.Target, result.Err)
+	}
+	if expected := e.Route(ODPair{Source: 0, Target: 18}, Criteria{}).Cost; result.Cost != expected {
+		t.Errorf("got cost %v, expected %v", result.Cost, expected)
+	}
+
+	if result := e.RouteToPolygon(0, square(30, 30, 70, 70), Criteria{}); !errors.Is(result.Err, ErrEmptyArea) {
+		t.Errorf("got %v, expected ErrEmptyArea for an area between streets", result.Err)
+	}
+}
+
+func TestEngine_Simulate(t *testing.T) {
+	e := NewEngine(GridGraph(5, 5, 100))
+	report, err := e.Simulate(context.Background(), SimulationConfig{Queries: 20, Concurrency: 2, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Queries != 20 || report.Errors != 0 {
+		t.Fatalf("got %d queries with %d errors, expected 20 without errors", report.Queries, report.Errors)
+	}
+	if report.P50 > report.P90 || report.P90 > report.P99 || report.P99 > report.Max {
+		t.Fatalf("percentiles are not ordered: %+v", report)
+	}
+
+	latencies := make([]time.Duration, 0, 100)
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	r := summarizeLatencies(latencies, time.Second)
+	if r.P50 != 50*time.Millisecond || r.P90 != 90*time.Millisecond || r.P99 != 99*time.Millisecond || r.QPS != 100 {
+		t.Fatalf("got %+v, expected percentiles 50ms, 90ms and 99ms at 100 QPS", r)
+	}
+
+	if _, err := NewEngine(EmptyGraph()).RandomTrips(1, BoundingBox{}, rand.New(rand.NewSource(1))); !errors.Is(err, ErrNoTrips) {
+		t.Fatalf("got %v, expected ErrNoTrips on an empty graph", err)
+	}
+}
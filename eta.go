@@ -0,0 +1,120 @@
+package graph_search
+
+import (
+	"math"
+	"time"
+)
+
+// WaypointETA is the estimated arrival at an upcoming node of a route.
+type WaypointETA struct {
+	Node     int32      // ID of the node
+	Location Coordinate // Location of the node
+	Distance float64    // Distance in meters left to travel to reach the node
+	Duration float64    // Time in seconds left to travel to reach the node
+	Arrival  time.Time  // Estimated arrival time at the node
+}
+
+// CumulativeDurations returns, for every node of the route, the travel time in seconds from the start of
+// the route to the node, as computed by Duration.
+func (r Route) CumulativeDurations() []float64 {
+	cumulative := make([]float64, len(r.Nodes))
+	for i, e := range r.Edges {
+		cumulative[i+1] = cumulative[i] + edgeDuration(e)
+	}
+	return cumulative
+}
+
+// ETAAt projects a position onto the route and estimates the arrival at every node still ahead of it.
+//
+// Parameters:
+//   - position: Coordinate - Current position of the vehicle, usually close to the route
+//   - departure: time.Time - Moment the vehicle is at position, arrival times are relative to it
+//
+// Returns:
+//   - []WaypointETA: The nodes after the projected position, the destination last, empty if the route
+//     has no edges
+func (r Route) ETAAt(position Coordinate, departure time.Time) []WaypointETA {
+	if len(r.Edges) == 0 {
+		return nil
+	}
+	segment, ratio := r.project(position)
+
+	cumulative := r.CumulativeDurations()
+	e := r.Edges[segment]
+	distance := (1 - ratio) * float64(e.Metadata.Distance)
+	elapsed := cumulative[segment] + ratio*edgeDuration(e)
+	etas := make([]WaypointETA, 0, len(r.Nodes)-segment-1)
+	for i := segment + 1; i < len(r.Nodes); i++ {
+		if i > segment+1 {
+			distance += float64(r.Edges[i-1].Metadata.Distance)
+		}
+		duration := cumulative[i] - elapsed
+		etas = append(etas, WaypointETA{
+			Node:     r.Nodes[i],
+			Location: r.coordinates[i],
+			Distance: distance,
+			Duration: duration,
+			Arrival:  departure.Add(time.Duration(duration * float64(time.Second))),
+		})
+	}
+	return etas
+}
+
+// DistanceBetween projects two coordinates onto the route and measures the route between them, e.g., the
+// distance a passenger rode for fare calculation.
+//
+// Parameters:
+//   - a: Coordinate - The first position, usually close to the route
+//   - b: Coordinate - The second position, usually close to the route
+//
+// Returns:
+//   - float64: The distance in meters along the route from a to b, negative if b lies before a
+//   - float64: The travel time in seconds along the route from a to b, negative if b lies before a
+func (r Route) DistanceBetween(a, b Coordinate) (float64, float64) {
+	if len(r.Edges) == 0 {
+		return 0, 0
+	}
+	distanceA, durationA := r.along(r.project(a))
+	distanceB, durationB := r.along(r.project(b))
+	return distanceB - distanceA, durationB - durationA
+}
+
+// project finds the point of the route closest to a coordinate, in projected meters.
+//
+// Returns:
+//   - int: Index of the edge holding the closest point
+//   - float64: Position of the closest point along that edge, from 0 at its start to 1 at its end
+func (r Route) project(c Coordinate) (int, float64) {
+	x, y := LatLngToMeters(c.Lat, c.Lng)
+	point := NewVector(-1, []float64{x, y})
+
+	segment, ratio, best := 0, 0.0, math.Inf(1)
+	for i := range r.Edges {
+		a, b := r.coordinates[i], r.coordinates[i+1]
+		ax, ay := LatLngToMeters(a.Lat, a.Lng)
+		bx, by := LatLngToMeters(b.Lat, b.Lng)
+		start := NewVector(-1, []float64{ax, ay})
+		direction := NewVector(-1, []float64{bx - ax, by - ay})
+		t := 0.0
+		if length := direction.Dot(direction); length > 0 {
+			t = math.Min(math.Max(point.Subtract(start).Dot(direction)/length, 0), 1)
+		}
+		offset := point.Subtract(start.Add(direction.Scale(t)))
+		if d := offset.Dot(offset); d < best {
+			segment, ratio, best = i, t, d
+		}
+	}
+	return segment, ratio
+}
+
+// along returns the distance in meters and the travel time in seconds from the start of the route to a
+// point of one of its edges.
+func (r Route) along(segment int, ratio float64) (float64, float64) {
+	distance, duration := 0.0, 0.0
+	for _, e := range r.Edges[:segment] {
+		distance += float64(e.Metadata.Distance)
+		duration += edgeDuration(e)
+	}
+	e := r.Edges[segment]
+	return distance + ratio*float64(e.Metadata.Distance), duration + ratio*edgeDuration(e)
+}
@@ -0,0 +1,295 @@
+package graph_search
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang/geo/s2"
+)
+
+var (
+	ErrUnknownEvent = errors.New("unknown event type")
+	ErrNodeNotFound = errors.New("node not found")
+	ErrEdgeNotFound = errors.New("edge not found")
+)
+
+// EventType identifies the kind of mutation recorded in an EventLog.
+type EventType string
+
+const (
+	CloseEdge    EventType = "close_edge"    // Removes the edge between two nodes
+	ChangeSpeed  EventType = "change_speed"  // Overrides the speed of the edge, and its weight on graphs weighted by travel time
+	AddConnector EventType = "add_connector" // Adds a new edge between two existing nodes
+)
+
+// Event represents a single operator mutation applied to a graph.
+// Nodes are referenced by their S2 cell location instead of their internal ID, because internal IDs
+// are reassigned every time the graph is rebuilt from a fresh OSM extract while locations are stable.
+type Event struct {
+	Type      EventType     // Kind of mutation
+	From      uint64        // S2 cell ID of the source node
+	To        uint64        // S2 cell ID of the destination node
+	Direction EdgeDirection // Direction of the edges affected by the mutation
+	Speed     float32       // New speed in km/h for ChangeSpeed and AddConnector events
+	RoadType  string        // Road type for AddConnector events
+	Time      time.Time     // Moment the mutation was recorded
+}
+
+// EventLog is an append-only log of graph mutations persisted as newline-delimited JSON.
+type EventLog struct {
+	path string
+}
+
+// NewEventLog returns an EventLog backed by the file at the given path. The file is created on the first Append.
+func NewEventLog(path string) *EventLog {
+	return &EventLog{path: path}
+}
+
+// Append writes an event at the end of the log.
+//
+// Parameters:
+//   - e: Event - The mutation to record. A zero Time is replaced by the current time
+//
+// Returns:
+//   - error: nil if the event was persisted, otherwise the encountered error
+func (l *EventLog) Append(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Events reads every event stored in the log in the order they were appended.
+//
+// Returns:
+//   - []Event: The recorded events, empty if the log file does not exist yet
+//   - error: An error if the file cannot be read or contains a malformed line
+func (l *EventLog) Events() ([]Event, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := make([]Event, 0)
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("event log line %d: %w", line, err)
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// ReplayOptions describes the graph an EventLog is replayed onto.
+type ReplayOptions struct {
+	// Weighting is the objective the edge weights of the graph were computed with. With ObjectiveTime,
+	// ChangeSpeed events scale the weight of the edges by the change of their travel time. Otherwise,
+	// e.g., for the distance weights of graphs built from OSM, weights do not depend on speed and are
+	// kept, so only ObjectiveTime and time-dependent searches see the change.
+	Weighting Objective
+}
+
+// Replay applies every event of the log, in order, onto the given graph, keeping its edge weights on
+// speed changes like ReplayWithOptions with the zero ReplayOptions. It is meant to be used right after
+// building a base graph from a fresh OSM extract so operator customizations survive the rebuild.
+//
+// Parameters:
+//   - g: *Graph - The graph to mutate
+//
+// Returns:
+//   - int: The number of events applied
+//   - error: The first error encountered, wrapped with the position of the failing event
+func (l *EventLog) Replay(g *Graph) (int, error) {
+	return l.ReplayWithOptions(g, ReplayOptions{})
+}
+
+// ReplayWithOptions applies every event of the log, in order, onto the given graph like Replay, updating
+// the edge weights according to the weighting of the graph.
+//
+// Parameters:
+//   - g: *Graph - The graph to mutate
+//   - opts: ReplayOptions - How the edge weights of g were computed
+//
+// Returns:
+//   - int: The number of events applied
+//   - error: The first error encountered, wrapped with the position of the failing event
+func (l *EventLog) ReplayWithOptions(g *Graph, opts ReplayOptions) (int, error) {
+	events, err := l.Events()
+	if err != nil {
+		return 0, err
+	}
+	locations := g.locationIndex()
+	for i, e := range events {
+		if err := g.apply(e, locations, opts); err != nil {
+			return i, fmt.Errorf("event %d (%s): %w", i, e.Type, err)
+		}
+	}
+	return len(events), nil
+}
+
+// Apply performs a single mutation on the graph. Edge weights are kept on speed changes, as in Replay.
+//
+// Parameters:
+//   - e: Event - The mutation to apply
+//
+// Returns:
+//   - error: ErrNodeNotFound if an endpoint does not exist in the graph, ErrEdgeNotFound if the mutation
+//     targets a missing edge, or ErrUnknownEvent for unsupported event types
+func (g *Graph) Apply(e Event) error {
+	return g.apply(e, g.locationIndex(), ReplayOptions{})
+}
+
+// apply performs a mutation using a precomputed location index.
+func (g *Graph) apply(e Event, locations map[uint64]int32, opts ReplayOptions) error {
+	from, ok1 := locations[e.From]
+	to, ok2 := locations[e.To]
+	if !ok1 || !ok2 {
+		return ErrNodeNotFound
+	}
+
+	switch e.Type {
+	case CloseEdge:
+		if !g.forEachDirection(from, to, e.Direction, g.RemoveEdge) {
+			return ErrEdgeNotFound
+		}
+	case ChangeSpeed:
+		changed := g.forEachDirection(from, to, e.Direction, func(a, b int32) bool {
+			return g.updateEdge(a, b, func(edge *Edge) { edge.changeSpeed(e.Speed, opts.Weighting == ObjectiveTime) })
+		})
+		if !changed {
+			return ErrEdgeNotFound
+		}
+	case AddConnector:
+		distance := DistanceMeters(s2.CellID(e.From), s2.CellID(e.To))
+		meta := MetaData{Distance: distance, RoadType: e.RoadType}
+		meta.setSpeed(e.Speed)
+		g.RelateNodes(g.Nodes[from], g.Nodes[to], distance, e.Direction, meta)
+	default:
+		return ErrUnknownEvent
+	}
+	return nil
+}
+
+// changeSpeed changes the speed of an edge. When timed is set, the weight of the edge is a travel time and
+// is scaled by the change of its ObjectiveTime weight, so adjustments made on top of the travel time, e.g.,
+// by a cost model, are preserved; otherwise the weight does not depend on speed and is kept.
+func (e *Edge) changeSpeed(speed float32, timed bool) {
+	before := ObjectiveTime.Weight(*e)
+	e.Metadata.setSpeed(speed)
+	if !timed {
+		return
+	}
+	if after := ObjectiveTime.Weight(*e); before > 0 {
+		e.Weight *= after / before
+	} else {
+		e.Weight = after
+	}
+}
+
+// setSpeed changes the speed of an edge in km/h and recomputes its Duration, so ObjectiveTime follows the
+// new speed. A speed that is not positive clears Duration, which then falls back to AvgSpeedCar.
+func (m *MetaData) setSpeed(speed float32) {
+	m.Speed = speed
+	m.Duration = 0
+	if speed > 0 {
+		m.Duration = m.Distance / (speed * MetersInAKilometer / SecondsInAnHour)
+	}
+}
+
+// RemoveEdge deletes every directed edge going from one node to another, keeping the incoming
+// and outgoing adjacency lists consistent.
+//
+// Parameters:
+//   - from: int32 - ID of the source node
+//   - to: int32 - ID of the destination node
+//
+// Returns:
+//   - bool: true if at least one edge was removed
+func (g *Graph) RemoveEdge(from, to int32) bool {
+	removed := false
+	outgoing := g.OutgoingEdges[from][:0]
+	for _, e := range g.OutgoingEdges[from] {
+		if e.ID == to {
+			removed = true
+			continue
+		}
+		outgoing = append(outgoing, e)
+	}
+	g.OutgoingEdges[from] = outgoing
+
+	incoming := g.IncomingEdges[to][:0]
+	for _, e := range g.IncomingEdges[to] {
+		if e.ID == from {
+			continue
+		}
+		incoming = append(incoming, e)
+	}
+	g.IncomingEdges[to] = incoming
+	return removed
+}
+
+// updateEdge applies fn to every directed edge going from one node to another in both adjacency lists.
+func (g *Graph) updateEdge(from, to int32, fn func(*Edge)) bool {
+	updated := false
+	for i := range g.OutgoingEdges[from] {
+		if g.OutgoingEdges[from][i].ID == to {
+			fn(&g.OutgoingEdges[from][i])
+			updated = true
+		}
+	}
+	for i := range g.IncomingEdges[to] {
+		if g.IncomingEdges[to][i].ID == from {
+			fn(&g.IncomingEdges[to][i])
+		}
+	}
+	return updated
+}
+
+// forEachDirection calls fn for the directed pairs described by dir and reports whether any call succeeded.
+func (g *Graph) forEachDirection(a, b int32, dir EdgeDirection, fn func(from, to int32) bool) bool {
+	switch dir {
+	case LeftToRight:
+		return fn(a, b)
+	case RightToLeft:
+		return fn(b, a)
+	default:
+		ab := fn(a, b)
+		ba := fn(b, a)
+		return ab || ba
+	}
+}
+
+// locationIndex maps the S2 cell location of every node to its internal ID.
+func (g *Graph) locationIndex() map[uint64]int32 {
+	index := make(map[uint64]int32, len(g.Nodes))
+	for _, n := range g.Nodes {
+		index[n.Location] = n.ID
+	}
+	return index
+}
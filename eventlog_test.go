@@ -0,0 +1,124 @@
+package graph_search
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/geo/s2"
+)
+
+func TestEventLog_Replay(t *testing.T) {
+	build := func() Graph {
+		g := EmptyGraph()
+		for _, c := range []Coordinate{{6.20, -75.58}, {6.21, -75.58}, {6.21, -75.57}} {
+			g.AddNode(Node{Location: coordinatesToCellID(c.Lat, c.Lng)})
+		}
+		g.RelateNodes(g.Nodes[0], g.Nodes[1], 1, Bidirectional, MetaData{Speed: 50})
+		g.RelateNodes(g.Nodes[1], g.Nodes[2], 1, Bidirectional, MetaData{Speed: 50})
+		return g
+	}
+	base := build()
+
+	log := NewEventLog(filepath.Join(t.TempDir(), "events.ndjson"))
+	events := []Event{
+		{Type: CloseEdge, From: base.Nodes[0].Location, To: base.Nodes[1].Location, Direction: Bidirectional},
+		{Type: ChangeSpeed, From: base.Nodes[1].Location, To: base.Nodes[2].Location, Direction: LeftToRight, Speed: 10},
+		{Type: AddConnector, From: base.Nodes[0].Location, To: base.Nodes[2].Location, Direction: LeftToRight},
+	}
+	for _, e := range events {
+		if err := log.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g := build()
+	n, err := log.Replay(&g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(events) {
+		t.Fatalf("got %d events applied, expected %d", n, len(events))
+	}
+	if len(g.OutgoingEdges[0]) != 1 || g.OutgoingEdges[0][0].ID != 2 {
+		t.Fatalf("unexpected outgoing edges for node 0: %v", g.OutgoingEdges[0])
+	}
+	if s := g.OutgoingEdges[1][len(g.OutgoingEdges[1])-1].Metadata.Speed; s != 10 {
+		t.Fatalf("got speed %f, expected 10", s)
+	}
+}
+
+func TestEventLog_ReplaySpeedChange(t *testing.T) {
+	// A square 0-1-3 / 0-2-3 with precomputed durations, like graphs built from OSM: the route through
+	// node 1 is the fastest until its first street is slowed down.
+	build := func(timeWeighted bool) Graph {
+		g := EmptyGraph()
+		for _, c := range []Coordinate{{6.20, -75.58}, {6.21, -75.58}, {6.20, -75.57}, {6.21, -75.57}} {
+			g.AddNode(Node{Location: coordinatesToCellID(c.Lat, c.Lng)})
+		}
+		relate := func(a, b int32, speed float32) {
+			distance := DistanceMeters(s2.CellID(g.Nodes[a].Location), s2.CellID(g.Nodes[b].Location))
+			meta := MetaData{Speed: speed, Distance: distance, Duration: distance / (speed * MetersInAKilometer / SecondsInAnHour)}
+			weight := distance
+			if timeWeighted {
+				weight = meta.Duration
+			}
+			g.RelateNodes(g.Nodes[a], g.Nodes[b], weight, Bidirectional, meta)
+		}
+		relate(0, 1, 80)
+		relate(1, 3, 80)
+		relate(0, 2, 50)
+		relate(2, 3, 50)
+		return g
+	}
+	route := func(g Graph, o Objective) []int32 {
+		r := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{3}, Objective: o}).Run(g)
+		return r.SearchSpace.PathNodes(int32(len(r.SearchSpace.Nodes) - 1))
+	}
+	base := build(false)
+	if path := route(base, ObjectiveTime); path[1] != 1 {
+		t.Fatalf("got path %v, expected it through node 1", path)
+	}
+
+	log := NewEventLog(filepath.Join(t.TempDir(), "events.ndjson"))
+	event := Event{Type: ChangeSpeed, From: base.Nodes[0].Location, To: base.Nodes[1].Location, Direction: Bidirectional, Speed: 10}
+	if err := log.Append(event); err != nil {
+		t.Fatal(err)
+	}
+	g := build(false)
+	if _, err := log.Replay(&g); err != nil {
+		t.Fatal(err)
+	}
+	if path := route(g, ObjectiveTime); path[1] != 2 {
+		t.Fatalf("got path %v after slowing down 0-1, expected it through node 2", path)
+	}
+	if w := g.OutgoingEdges[0][0].Weight; w != base.OutgoingEdges[0][0].Weight {
+		t.Fatalf("got weight %f, expected the distance %f to be kept", w, base.OutgoingEdges[0][0].Weight)
+	}
+
+	// On a graph weighted by travel time, the weights follow the speed and so does static routing.
+	timed := build(true)
+	if path := route(timed, ObjectiveWeight); path[1] != 1 {
+		t.Fatalf("got path %v, expected it through node 1", path)
+	}
+	if _, err := log.ReplayWithOptions(&timed, ReplayOptions{Weighting: ObjectiveTime}); err != nil {
+		t.Fatal(err)
+	}
+	if path := route(timed, ObjectiveWeight); path[1] != 2 {
+		t.Fatalf("got path %v after slowing down 0-1, expected it through node 2", path)
+	}
+
+	// A distance weight equal to the travel time, as at 3.6 km/h, is still a distance and is kept.
+	slow := EmptyGraph()
+	for i := 0; i < 2; i++ {
+		slow.AddNode(Node{Location: base.Nodes[i].Location})
+	}
+	distance := DistanceMeters(s2.CellID(slow.Nodes[0].Location), s2.CellID(slow.Nodes[1].Location))
+	slow.RelateNodes(slow.Nodes[0], slow.Nodes[1], distance, Bidirectional, MetaData{Speed: 3.6, Distance: distance, Duration: distance})
+	if _, err := log.Replay(&slow); err != nil {
+		t.Fatal(err)
+	}
+	if e := slow.OutgoingEdges[0][0]; e.Weight != distance || math.Abs(float64(e.Metadata.Duration-distance*0.36)) > 1e-3 {
+		t.Fatalf("got weight %f and duration %f, expected the distance %f and a duration of %f", e.Weight, e.Metadata.Duration, distance, distance*0.36)
+	}
+}
@@ -0,0 +1,168 @@
+package graph_search
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// EdgeSet is a set of directed edges of a graph, backed by a Bitset with one bit per edge. Edges are
+// numbered by their position in the adjacency lists: the i-th outgoing edge of node v has number
+// offsets[v] + i.
+type EdgeSet struct {
+	offsets []int32
+	bits    Bitset
+}
+
+// NewEdgeSet creates an empty set of edges of the given graph.
+//
+// Parameters:
+//   - g: Graph - The graph the edges belong to. The set must not be used once edges are added to or
+//     removed from it
+//
+// Returns:
+//   - *EdgeSet: An empty set
+func NewEdgeSet(g Graph) *EdgeSet {
+	offsets := make([]int32, len(g.OutgoingEdges)+1)
+	for v, edges := range g.OutgoingEdges {
+		offsets[v+1] = offsets[v] + int32(len(edges))
+	}
+	return &EdgeSet{offsets: offsets, bits: NewBigInt()}
+}
+
+// Add adds every edge going from one node to another, reporting false if there is none.
+func (s *EdgeSet) Add(g Graph, from, to int32) bool {
+	found := false
+	for i, e := range g.OutgoingEdges[from] {
+		if e.ID == to {
+			s.bits.Set(s.offsets[from]+int32(i), true)
+			found = true
+		}
+	}
+	return found
+}
+
+// AddWay adds every edge built from the given OSM way, e.g., a flooded road or a parade route.
+//
+// Returns:
+//   - int: The number of edges added
+func (s *EdgeSet) AddWay(g Graph, wayID int64) int {
+	added := 0
+	for v, edges := range g.OutgoingEdges {
+		for i, e := range edges {
+			if e.Metadata.WayID == wayID {
+				s.bits.Set(s.offsets[v]+int32(i), true)
+				added++
+			}
+		}
+	}
+	return added
+}
+
+// Contains reports whether the i-th outgoing edge of node from belongs to the set.
+func (s *EdgeSet) Contains(from int32, i int) bool {
+	return s.bits.Exists(s.offsets[from] + int32(i))
+}
+
+// overlayVersions numbers the successive states of every WeightOverlay, so cached routes computed with
+// an overlay are never served for another one.
+var overlayVersions atomic.Uint64
+
+// WeightOverlay multiplies the cost of a few edges of a graph, e.g., the slowdowns and closures of an
+// incident feed, without copying the weights of the whole graph. Edges are numbered like in EdgeSet.
+type WeightOverlay struct {
+	edges   *EdgeSet
+	factors map[int32]float32
+	version uint64
+}
+
+// NewWeightOverlay creates an overlay leaving every edge of the given graph unchanged.
+//
+// Parameters:
+//   - g: Graph - The graph the edges belong to. The overlay must not be used once edges are added to or
+//     removed from it
+//
+// Returns:
+//   - *WeightOverlay: An empty overlay
+func NewWeightOverlay(g Graph) *WeightOverlay {
+	return &WeightOverlay{edges: NewEdgeSet(g), factors: make(map[int32]float32), version: overlayVersions.Add(1)}
+}
+
+// Scale multiplies the cost of the i-th outgoing edge of node from by a factor. An infinite factor
+// closes the edge. When an edge is scaled several times, the largest factor wins, so overlapping
+// incidents do not compound.
+func (o *WeightOverlay) Scale(from int32, i int, factor float32) {
+	n := o.edges.offsets[from] + int32(i)
+	if current, ok := o.factors[n]; ok && current >= factor {
+		return
+	}
+	o.edges.bits.Set(n, true)
+	o.factors[n] = factor
+	o.version = overlayVersions.Add(1)
+}
+
+// Factor returns the factor the cost of the i-th outgoing edge of node from is multiplied by, 1 if the
+// overlay does not hold the edge.
+func (o *WeightOverlay) Factor(from int32, i int) float32 {
+	if !o.edges.Contains(from, i) {
+		return 1
+	}
+	return o.factors[o.edges.offsets[from]+int32(i)]
+}
+
+// lowers reports whether the overlay scales the cost of an edge by a factor below 1, so heuristics
+// bounding the unscaled costs may overestimate. A nil overlay lowers nothing.
+func (o *WeightOverlay) lowers() bool {
+	if o == nil {
+		return false
+	}
+	for _, factor := range o.factors {
+		if factor < 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of the overlay that can be scaled without altering o.
+func (o *WeightOverlay) clone() *WeightOverlay {
+	c := &WeightOverlay{
+		edges:   &EdgeSet{offsets: o.edges.offsets, bits: NewBigInt()},
+		factors: make(map[int32]float32, len(o.factors)),
+		version: overlayVersions.Add(1),
+	}
+	for n, factor := range o.factors {
+		c.edges.bits.Set(n, true)
+		c.factors[n] = factor
+	}
+	return c
+}
+
+// Len returns the number of edges held by the overlay.
+func (o *WeightOverlay) Len() int {
+	return len(o.factors)
+}
+
+// closed reports whether the overlay closes the i-th outgoing edge of node from.
+func (o *WeightOverlay) closed(from int32, i int) bool {
+	return math.IsInf(float64(o.Factor(from, i)), 1)
+}
+
+// overlayFactor returns the factor the overlay of the criteria applies to the i-th outgoing edge of
+// node from, 1 without overlay.
+func (c Criteria) overlayFactor(from int32, i int) float32 {
+	if c.Overlay == nil {
+		return 1
+	}
+	return c.Overlay.Factor(from, i)
+}
+
+// excluded reports whether the criteria exclude the i-th outgoing edge of node from, or the node it leads to.
+func (c Criteria) excluded(from int32, i int, e Edge) bool {
+	if c.ExcludedNodes.Int != nil && c.ExcludedNodes.Exists(e.ID) {
+		return true
+	}
+	if c.Overlay != nil && c.Overlay.closed(from, i) {
+		return true
+	}
+	return c.ExcludedEdges != nil && c.ExcludedEdges.Contains(from, i)
+}
@@ -0,0 +1,110 @@
+package graph_search
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// MaxDOTNodes is the largest graph WriteDOT renders. Graphviz layouts become unreadable, and very slow,
+// well before reaching country-sized graphs.
+const MaxDOTNodes = 5000
+
+var (
+	ErrGraphTooLarge = errors.New("graph too large to export")
+)
+
+// WriteDOT writes the graph in the Graphviz DOT language, for visual inspection of small graphs such as
+// test fixtures or extracts. Nodes are labeled with their ID and pinned at their Mercator position, in
+// meters from the south-west corner of the graph, so the drawing (with neato -n) looks like a map;
+// edges are labeled with their weight.
+//
+// Parameters:
+//   - w: io.Writer - Destination of the DOT document
+//
+// Returns:
+//   - error: ErrGraphTooLarge if the graph has more than MaxDOTNodes nodes, or the error returned by w
+func (g Graph) WriteDOT(w io.Writer) error {
+	if len(g.Nodes) > MaxDOTNodes {
+		return fmt.Errorf("%w: %d nodes, DOT export is limited to %d", ErrGraphTooLarge, len(g.Nodes), MaxDOTNodes)
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "digraph G {")
+	fmt.Fprintln(bw, "  node [shape=point];")
+	xs, ys := make([]float64, len(g.Nodes)), make([]float64, len(g.Nodes))
+	minX, minY := math.Inf(1), math.Inf(1)
+	for i, n := range g.Nodes {
+		c := nodeCoordinate(n)
+		xs[i], ys[i] = LatLngToMeters(c.Lat, c.Lng)
+		minX, minY = math.Min(minX, xs[i]), math.Min(minY, ys[i])
+	}
+	for i, n := range g.Nodes {
+		fmt.Fprintf(bw, "  %d [xlabel=\"%d\", pos=\"%.1f,%.1f\"];\n", n.ID, n.ID, xs[i]-minX, ys[i]-minY)
+	}
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			fmt.Fprintf(bw, "  %d -> %d [label=%q];\n", from, e.ID, strconv.FormatFloat(float64(e.Weight), 'f', -1, 32))
+		}
+	}
+	fmt.Fprintln(bw, "}")
+	return bw.Flush()
+}
+
+// WriteNeo4jCSV writes the graph as a pair of CSV files in the format of the neo4j-admin bulk importer:
+// one file of Intersection nodes and one of ROAD relationships, with typed headers. The files can be
+// loaded with
+//
+//	neo4j-admin database import full --nodes=Intersection=nodes.csv --relationships=ROAD=edges.csv
+//
+// Parameters:
+//   - nodes: io.Writer - Destination of the nodes file
+//   - edges: io.Writer - Destination of the relationships file
+//
+// Returns:
+//   - error: The first error returned by a writer
+func (g Graph) WriteNeo4jCSV(nodes, edges io.Writer) error {
+	nw := csv.NewWriter(nodes)
+	nw.Write([]string{"id:ID", "lat:double", "lng:double", "osm_id:long", ":LABEL"})
+	for _, n := range g.Nodes {
+		c := nodeCoordinate(n)
+		osmID := ""
+		if id, ok := g.OSMID(n.ID); ok {
+			osmID = strconv.FormatInt(id, 10)
+		}
+		nw.Write([]string{
+			strconv.Itoa(int(n.ID)),
+			strconv.FormatFloat(c.Lat, 'f', -1, 64),
+			strconv.FormatFloat(c.Lng, 'f', -1, 64),
+			osmID,
+			"Intersection",
+		})
+	}
+	nw.Flush()
+	if err := nw.Error(); err != nil {
+		return err
+	}
+
+	ew := csv.NewWriter(edges)
+	ew.Write([]string{":START_ID", ":END_ID", "weight:float", "distance:float", "speed:float", "road_type", "name", "way_id:long", ":TYPE"})
+	for from, list := range g.OutgoingEdges {
+		for _, e := range list {
+			ew.Write([]string{
+				strconv.Itoa(from),
+				strconv.Itoa(int(e.ID)),
+				strconv.FormatFloat(float64(e.Weight), 'f', -1, 32),
+				strconv.FormatFloat(float64(e.Metadata.Distance), 'f', -1, 32),
+				strconv.FormatFloat(float64(e.Metadata.Speed), 'f', -1, 32),
+				e.Metadata.RoadType,
+				e.Metadata.Name,
+				strconv.FormatInt(e.Metadata.WayID, 10),
+				"ROAD",
+			})
+		}
+	}
+	ew.Flush()
+	return ew.Error()
+}
@@ -0,0 +1,421 @@
+package graph_search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// protoFields splits a protobuf message into its length-delimited fields, keyed by field number.
+func protoFields(t *testing.T, b []byte) map[int][][]byte {
+	fields := make(map[int][][]byte)
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		b = b[n:]
+		switch key & 0x7 {
+		case 0:
+			_, n = binary.Uvarint(b)
+			b = b[n:]
+		case 1:
+			b = b[8:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			fields[int(key>>3)] = append(fields[int(key>>3)], b[n:n+int(length)])
+			b = b[n+int(length):]
+		default:
+			t.Fatalf("unexpected wire type %d", key&0x7)
+		}
+	}
+	return fields
+}
+
+// thriftReader decodes Thrift compact protocol structs into maps keyed by field id, holding int64 for
+// integers, []byte for binaries, []interface{} for lists and map[int16]interface{} for structs.
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.t.Fatalf("invalid varint at offset %d", r.pos)
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) structure() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	id := int16(0)
+	for {
+		header := r.data[r.pos]
+		r.pos++
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta > 0 {
+			id += delta
+		} else {
+			v := r.varint()
+			id = int16(v>>1) ^ -int16(v&1)
+		}
+		fields[id] = r.value(header & 0x0F)
+	}
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		v := r.varint()
+		return int64(v>>1) ^ -int64(v&1)
+	case thriftBinary:
+		n := int(r.varint())
+		r.pos += n
+		return r.data[r.pos-n : r.pos]
+	case thriftList:
+		header := r.data[r.pos]
+		r.pos++
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(header & 0x0F)
+		}
+		return list
+	case thriftStruct:
+		return r.structure()
+	}
+	r.t.Fatalf("unexpected thrift type %d at offset %d", typ, r.pos)
+	return nil
+}
+
+func TestGeoTable_WriteParquet(t *testing.T) {
+	routes := [][][]float64{
+		{{-75.58, 6.20}, {-75.57, 6.21}},
+		{{-75.56, 6.22}, {-75.55, 6.23}, {-75.54, 6.25}},
+	}
+	table := NewRouteTable()
+	for i, path := range routes {
+		if err := table.AddRoute(int64(i+7), path, float32(10*(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := table.AddLineString(nil, "nine", 1.0); !errors.Is(err, ErrColumnMismatch) {
+		t.Fatalf("got %v for a string id, expected ErrColumnMismatch", err)
+	}
+
+	var buf bytes.Buffer
+	if err := table.WriteParquet(&buf); err != nil {
+		t.Fatal(err)
+	}
+	file := buf.Bytes()
+	if string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatalf("got magic bytes %q and %q, expected PAR1", file[:4], file[len(file)-4:])
+	}
+	length := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &thriftReader{t: t, data: file[len(file)-8-length : len(file)-8]}
+	meta := footer.structure()
+	if footer.pos != length {
+		t.Fatalf("decoded %d bytes of a %d bytes footer", footer.pos, length)
+	}
+
+	schema := meta[2].([]interface{})
+	expected := []struct {
+		name     string
+		physical int64
+	}{{"geometry", parquetByteArray}, {"id", parquetInt64}, {"cost", parquetDouble}}
+	if len(schema) != len(expected)+1 || schema[0].(map[int16]interface{})[5] != int64(len(expected)) {
+		t.Fatalf("got schema %v, expected a root with %d columns", schema, len(expected))
+	}
+	for i, c := range expected {
+		element := schema[i+1].(map[int16]interface{})
+		if string(element[4].([]byte)) != c.name || element[1] != c.physical {
+			t.Errorf("schema element %d: got %s of type %v, expected %s of type %d", i, element[4], element[1], c.name, c.physical)
+		}
+	}
+	if meta[3] != int64(2) {
+		t.Fatalf("got %v rows, expected 2", meta[3])
+	}
+
+	kv := meta[5].([]interface{})[0].(map[int16]interface{})
+	var geo struct {
+		PrimaryColumn string `json:"primary_column"`
+		Columns       map[string]struct {
+			Encoding      string    `json:"encoding"`
+			GeometryTypes []string  `json:"geometry_types"`
+			BBox          []float64 `json:"bbox"`
+		} `json:"columns"`
+	}
+	if err := json.Unmarshal(kv[2].([]byte), &geo); err != nil || string(kv[1].([]byte)) != "geo" {
+		t.Fatalf("got key %q and %v, expected the geo metadata", kv[1], err)
+	}
+	column := geo.Columns[geo.PrimaryColumn]
+	if geo.PrimaryColumn != "geometry" || column.Encoding != "WKB" || !reflect.DeepEqual(column.GeometryTypes, []string{"LineString"}) ||
+		!reflect.DeepEqual(column.BBox, []float64{-75.58, 6.20, -75.54, 6.25}) {
+		t.Fatalf("got geo metadata %+v", geo)
+	}
+
+	// Decode the data page of every column chunk of the row group.
+	rowGroup := meta[4].([]interface{})[0].(map[int16]interface{})
+	chunks := rowGroup[1].([]interface{})
+	pages := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		chunkMeta := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+		if chunkMeta[5] != int64(2) || string(chunkMeta[3].([]interface{})[0].([]byte)) != expected[i].name {
+			t.Fatalf("column chunk %d: got %v values of %q", i, chunkMeta[5], chunkMeta[3])
+		}
+		page := &thriftReader{t: t, data: file, pos: int(chunkMeta[9].(int64))}
+		header := page.structure()
+		if header[5].(map[int16]interface{})[1] != int64(2) {
+			t.Fatalf("column chunk %d: got a page of %v values, expected 2", i, header[5].(map[int16]interface{})[1])
+		}
+		pages[i] = file[page.pos : page.pos+int(header[2].(int64))]
+	}
+
+	geometries := pages[0]
+	for i, path := range routes {
+		n := int(binary.LittleEndian.Uint32(geometries))
+		wkb := geometries[4 : 4+n]
+		geometries = geometries[4+n:]
+		if wkb[0] != 1 || binary.LittleEndian.Uint32(wkb[1:]) != wkbLineStringType || int(binary.LittleEndian.Uint32(wkb[5:])) != len(path) {
+			t.Fatalf("route %d: got WKB header %v, expected a little endian line string of %d points", i, wkb[:9], len(path))
+		}
+		for j, p := range path {
+			x := math.Float64frombits(binary.LittleEndian.Uint64(wkb[9+16*j:]))
+			y := math.Float64frombits(binary.LittleEndian.Uint64(wkb[17+16*j:]))
+			if x != p[0] || y != p[1] {
+				t.Errorf("route %d, point %d: got (%f, %f), expected %v", i, j, x, y, p)
+			}
+		}
+	}
+	for i := range routes {
+		id := int64(binary.LittleEndian.Uint64(pages[1][8*i:]))
+		cost := math.Float64frombits(binary.LittleEndian.Uint64(pages[2][8*i:]))
+		if id != int64(i+7) || cost != float64(10*(i+1)) {
+			t.Errorf("row %d: got id %d and cost %f", i, id, cost)
+		}
+	}
+}
+
+func TestRoute_GPX(t *testing.T) {
+	g := GridGraph(1, 4, 100)
+	for v, edges := range g.OutgoingEdges {
+		for i := range edges {
+			edges[i].Metadata.Speed = float32(18 * (v + 1)) // 5, 10 and 15 m/s eastwards
+		}
+	}
+	departure := time.Date(2024, 5, 1, 8, 0, 0, 0, time.FixedZone("COT", -5*3600))
+	response := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{3}}).Run(g)
+	route := response.SearchSpace.Route(int32(len(response.SearchSpace.Nodes)-1), g)
+
+	content, err := response.ToGPX(g, departure)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName xml.Name
+		Points  []struct {
+			Lat  string `xml:"lat,attr"`
+			Lon  string `xml:"lon,attr"`
+			Time string `xml:"time"`
+		} `xml:"trk>trkseg>trkpt"`
+	}
+	if err := xml.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.XMLName.Space != "http://www.topografix.com/GPX/1/1" || doc.XMLName.Local != "gpx" {
+		t.Fatalf("got root element %v, expected a GPX 1.1 document", doc.XMLName)
+	}
+	coordinates := route.Coordinates()
+	if len(doc.Points) != len(coordinates) || len(coordinates) != 4 {
+		t.Fatalf("got %d track points, expected the 4 nodes of the route", len(doc.Points))
+	}
+
+	elapsed := 0.0
+	for i, p := range doc.Points {
+		lat, _ := strconv.ParseFloat(p.Lat, 64)
+		lon, _ := strconv.ParseFloat(p.Lon, 64)
+		if lat != coordinates[i].Lat || lon != coordinates[i].Lng {
+			t.Errorf("point %d: got lat %s and lon %s, expected %+v", i, p.Lat, p.Lon, coordinates[i])
+		}
+		if i > 0 {
+			elapsed += float64(route.Edges[i-1].Metadata.Distance) / float64(5*i)
+		}
+		at, err := time.Parse(time.RFC3339, p.Time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if offset := at.Sub(departure).Seconds(); math.Abs(offset-elapsed) > 1 {
+			t.Errorf("point %d: got %s, %.0f s after departure, expected %.1f s", i, p.Time, offset, elapsed)
+		}
+	}
+}
+
+func TestGraph_WriteDOT(t *testing.T) {
+	g := EmptyGraph()
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	positions := [][2]float64{{0, 0}, {100, 0}, {100, 50}}
+	for _, p := range positions {
+		lat, lng := MetersToLatLng(x0+p[0], y0+p[1])
+		g.AddNode(Node{Location: coordinatesToCellID(lat, lng)})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 100, Bidirectional, MetaData{Distance: 100})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 12.5, LeftToRight, MetaData{Distance: 50})
+
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "digraph G {" || lines[len(lines)-1] != "}" {
+		t.Fatalf("got %q, expected a digraph", buf.String())
+	}
+	nodes, edges := 0, make([]string, 0)
+	for _, line := range lines[1 : len(lines)-1] {
+		var id, label int
+		var x, y float64
+		if n, _ := fmt.Sscanf(line, "  %d [xlabel=\"%d\", pos=\"%f,%f\"];", &id, &label, &x, &y); n == 4 {
+			if id != label || math.Abs(x-positions[id][0]) > 0.5 || math.Abs(y-positions[id][1]) > 0.5 {
+				t.Errorf("got node line %q, expected node %d at %v", line, id, positions[id])
+			}
+			nodes++
+		} else if strings.Contains(line, "->") {
+			edges = append(edges, line)
+		}
+	}
+	expected := []string{`  0 -> 1 [label="100"];`, `  1 -> 0 [label="100"];`, `  1 -> 2 [label="12.5"];`}
+	if nodes != 3 || !reflect.DeepEqual(edges, expected) {
+		t.Fatalf("got %d nodes and edges %q, expected 3 nodes and %q", nodes, edges, expected)
+	}
+
+	large := EmptyGraph()
+	for i := 0; i <= MaxDOTNodes; i++ {
+		large.AddNode(Node{})
+	}
+	buf.Reset()
+	if err := large.WriteDOT(&buf); !errors.Is(err, ErrGraphTooLarge) || buf.Len() > 0 {
+		t.Fatalf("got %v and %d bytes for %d nodes, expected ErrGraphTooLarge", err, buf.Len(), len(large.Nodes))
+	}
+}
+
+func TestGraph_WriteNeo4jCSV(t *testing.T) {
+	g := GridGraph(1, 2, 100)
+	g.OSMIDs = []int64{901, 902}
+	g.OutgoingEdges[0][0].Metadata.Name = `Calle "10", Sur`
+	g.OutgoingEdges[0][0].Metadata.WayID = 77
+
+	var nodes, edges bytes.Buffer
+	if err := g.WriteNeo4jCSV(&nodes, &edges); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(edges.String(), `"Calle ""10"", Sur"`) {
+		t.Fatalf("got %q, expected the way name quoted", edges.String())
+	}
+
+	nodeRows, err := csv.NewReader(&nodes).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header := []string{"id:ID", "lat:double", "lng:double", "osm_id:long", ":LABEL"}; !reflect.DeepEqual(nodeRows[0], header) {
+		t.Fatalf("got node header %q, expected %q", nodeRows[0], header)
+	}
+	c := nodeCoordinate(g.Nodes[1])
+	if row := nodeRows[2]; len(nodeRows) != 3 || row[0] != "1" || row[3] != "902" || row[4] != "Intersection" ||
+		row[1] != strconv.FormatFloat(c.Lat, 'f', -1, 64) || row[2] != strconv.FormatFloat(c.Lng, 'f', -1, 64) {
+		t.Fatalf("got node rows %q", nodeRows)
+	}
+
+	edgeRows, err := csv.NewReader(&edges).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := []string{":START_ID", ":END_ID", "weight:float", "distance:float", "speed:float", "road_type", "name", "way_id:long", ":TYPE"}
+	if !reflect.DeepEqual(edgeRows[0], header) {
+		t.Fatalf("got edge header %q, expected %q", edgeRows[0], header)
+	}
+	if row := edgeRows[1]; len(edgeRows) != 3 || row[0] != "0" || row[1] != "1" || row[6] != `Calle "10", Sur` || row[7] != "77" || row[8] != "ROAD" {
+		t.Fatalf("got edge rows %q", edgeRows)
+	}
+}
+
+func TestTileRenderer_Tile(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	sp := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{8}}).Run(g).SearchSpace
+	route := sp.Route(int32(len(sp.Nodes)-1), g)
+
+	z := 10
+	mx, my := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	size := 2 * mercatorHalfSize / float64(int(1)<<z)
+	x, y := int(math.Floor((mx+mercatorHalfSize)/size)), int(math.Floor((mercatorHalfSize-my)/size))
+
+	tile, err := NewTileRenderer(g).Tile(z, x, y, route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	features := make(map[string]int)
+	for _, layer := range protoFields(t, tile)[3] {
+		fields := protoFields(t, layer)
+		features[string(fields[1][0])] = len(fields[2])
+	}
+	if features[MVTEdgesLayer] != 12 || features[MVTRoutesLayer] != 1 {
+		t.Fatalf("got features %v, expected 12 edges and 1 route", features)
+	}
+
+	if _, err := NewTileRenderer(g).Tile(z, x+1, y); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTileRenderer(g).Tile(2, 4, 0); err == nil {
+		t.Fatal("expected an error for a tile outside the zoom level")
+	}
+}
+
+func TestEngine_BatchFlow(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	e := NewEngine(g)
+	pairs := []ODPair{{Source: 0, Target: 2}, {Source: 0, Target: 2}, {Source: 3, Target: 5}, {Source: 0, Target: 99}}
+	u := e.BatchFlow(pairs, 2)
+	if u.Routed != 3 || u.Failed != 1 {
+		t.Fatalf("got %d routed and %d failed, expected 3 and 1", u.Routed, u.Failed)
+	}
+	if u.Max() != 2 {
+		t.Fatalf("got a maximum count of %d, expected 2", u.Max())
+	}
+
+	total := int64(0)
+	for _, counts := range u.Counts {
+		for _, c := range counts {
+			total += c
+		}
+	}
+	fc := u.GeoJSON()
+	if total != 6 || len(fc.Features) != 4 {
+		t.Fatalf("got %d traversals over %d features, expected 6 over 4", total, len(fc.Features))
+	}
+
+	z := 10
+	mx, my := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	size := 2 * mercatorHalfSize / float64(int(1)<<z)
+	x, y := int(math.Floor((mx+mercatorHalfSize)/size)), int(math.Floor((mercatorHalfSize-my)/size))
+	tile, err := NewTileRenderer(g).UsageTile(z, x, y, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layers := protoFields(t, tile)[3]
+	if len(layers) != 1 || len(protoFields(t, layers[0])[2]) != 4 {
+		t.Fatal("expected one usage layer with 4 edges")
+	}
+}
@@ -0,0 +1,62 @@
+package flow
+
+import (
+	"math"
+	"testing"
+
+	"graph_search"
+)
+
+func TestMaxFlow(t *testing.T) {
+	// s1 --3--> a --2--> t
+	// s2 --4--> b --5--> t
+	//           a --2--> b
+	g := graph_search.EmptyGraph()
+	for i := 0; i < 5; i++ {
+		g.AddNode(graph_search.Node{})
+	}
+	s1, s2, a, b, sink := g.Nodes[0], g.Nodes[1], g.Nodes[2], g.Nodes[3], g.Nodes[4]
+	relate := func(from, to graph_search.Node, capacity float32) {
+		g.RelateNodes(from, to, 1, graph_search.LeftToRight, graph_search.MetaData{Capacity: capacity})
+	}
+	relate(s1, a, 3)
+	relate(s2, b, 4)
+	relate(a, sink, 2)
+	relate(b, sink, 5)
+	relate(a, b, 2)
+
+	r := MaxFlow(g, []int32{s1.ID, s2.ID}, []int32{sink.ID})
+	if r.Value != 7 {
+		t.Fatalf("got flow %f, expected 7", r.Value)
+	}
+	for v, edges := range g.OutgoingEdges {
+		for i, e := range edges {
+			if f := r.Flow[v][i]; f < 0 || f > float64(e.Metadata.Capacity) {
+				t.Fatalf("edge %d->%d carries %f over capacity %f", v, e.ID, f, e.Metadata.Capacity)
+			}
+		}
+	}
+	if r.SourceSide[sink.ID] {
+		t.Fatal("the sink should not be on the source side of the cut")
+	}
+}
+
+func TestMaxFlowOverlappingSourceAndSink(t *testing.T) {
+	g := graph_search.EmptyGraph()
+	for i := 0; i < 2; i++ {
+		g.AddNode(graph_search.Node{})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 1, graph_search.LeftToRight, graph_search.MetaData{Capacity: 5})
+
+	r := MaxFlow(g, []int32{0}, []int32{0, 1})
+	if !math.IsInf(r.Value, 1) {
+		t.Fatalf("got flow %f, expected +Inf for a node that is both a source and a sink", r.Value)
+	}
+	for v, flows := range r.Flow {
+		for i, f := range flows {
+			if math.IsNaN(f) || f < 0 || f > 5 {
+				t.Fatalf("edge %d of node %d carries %f", i, v, f)
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package graph_search
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/qedus/osmpbf"
+)
+
+func FuzzBuildWay(f *testing.F) {
+	f.Add("residential", "yes", "3.5 t", "13'6\"", "2,5", "no", "Calle 10", int64(3))
+	f.Add("motorway", "-1", "", "", "", "", "", int64(-1))
+	f.Add("", "", "kg", "'", "ft", "yes", "", int64(0))
+	f.Fuzz(func(t *testing.T, highway, oneway, maxWeight, maxHeight, maxWidth, hgv, name string, nodeCount int64) {
+		g := EmptyGraph()
+		nodes := make(map[int64]int32)
+		for i := int64(0); i < 4; i++ {
+			nodes[i] = g.AddNode(Node{Location: coordinatesToCellID(6.2+float64(i)*0.001, -75.58)})
+		}
+		way := &osmpbf.Way{
+			ID: 1,
+			Tags: map[string]string{
+				Highway: highway, Oneway: oneway, MaxWeight: maxWeight, MaxHeight: maxHeight,
+				MaxWidth: maxWidth, HGV: hgv, Name: name,
+			},
+		}
+		for i := int64(0); i < nodeCount%8; i++ {
+			way.NodeIDs = append(way.NodeIDs, i%6)
+		}
+		buildWay(&g, way, nodes, make(map[int64][]int32), nil)
+		for _, edges := range g.OutgoingEdges {
+			for _, e := range edges {
+				m := e.Metadata
+				if m.MaxWeight < 0 || m.MaxHeight < 0 || m.MaxWidth < 0 || math.IsNaN(float64(m.MaxWeight)) {
+					t.Fatalf("invalid restriction parsed: %+v", m)
+				}
+			}
+		}
+	})
+}
+
+func FuzzDeserialize(f *testing.F) {
+	var seed bytes.Buffer
+	g := GridGraph(2, 2, 10)
+	path := filepath.Join(f.TempDir(), "seed.gob")
+	if err := g.Serialize(path); err == nil {
+		if data, err := os.ReadFile(path); err == nil {
+			seed.Write(data)
+		}
+	}
+	f.Add(seed.Bytes())
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		path := filepath.Join(t.TempDir(), "graph.gob")
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		Deserialize(path)
+	})
+}
+
+func FuzzDijkstra(f *testing.F) {
+	f.Add(uint8(4), []byte{0, 1, 5, 1, 2, 5, 2, 3, 1, 0, 3, 20}, uint8(0), uint8(3))
+	f.Add(uint8(1), []byte{0, 0, 0}, uint8(0), uint8(0))
+	f.Add(uint8(3), []byte{}, uint8(2), uint8(1))
+	f.Fuzz(func(t *testing.T, n uint8, edges []byte, source, target uint8) {
+		if n == 0 {
+			return
+		}
+		g := EmptyGraph()
+		for i := 0; i < int(n); i++ {
+			g.AddNode(Node{})
+		}
+		for i := 0; i+2 < len(edges); i += 3 {
+			a, b := g.Nodes[int(edges[i])%int(n)], g.Nodes[int(edges[i+1])%int(n)]
+			g.RelateNodes(a, b, float32(edges[i+2]), LeftToRight, MetaData{Distance: float32(edges[i+2])})
+		}
+		s, tg := int32(int(source)%int(n)), int32(int(target)%int(n))
+
+		response := NewDijkstra(Criteria{Source: []int32{s}, Targets: []int32{tg}}).Run(g)
+		cost, err := response.Costs.GetCost(tg)
+		if s == tg && (err != nil || cost != 0) {
+			t.Fatalf("source equals target, got cost %f (%v)", cost, err)
+		}
+		buckets := NewDijkstra(Criteria{Source: []int32{s}, Targets: []int32{tg}, BucketWidth: 1}).Run(g)
+		if other, _ := buckets.Costs.GetCost(tg); err == nil && other != cost {
+			t.Fatalf("bucket queue cost %f differs from heap cost %f", other, cost)
+		}
+	})
+}
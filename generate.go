@@ -0,0 +1,86 @@
+package graph_search
+
+import (
+	"math/rand"
+
+	"github.com/golang/geo/s2"
+)
+
+// SyntheticOrigin is the south-west corner used by the synthetic graph generators.
+var SyntheticOrigin = Coordinate{Lat: 6.2, Lng: -75.6}
+
+// GridGraph generates a rows x cols grid of bidirectional residential streets, spaced by the given number
+// of meters, with its south-west corner at SyntheticOrigin. Node (r, c) has ID r*cols + c.
+//
+// Parameters:
+//   - rows: int - Number of rows of the grid
+//   - cols: int - Number of columns of the grid
+//   - spacing: float64 - Distance in meters between adjacent nodes
+//
+// Returns:
+//   - Graph: A connected grid graph weighted by distance
+func GridGraph(rows, cols int, spacing float64) Graph {
+	g := Graph{Nodes: make([]Node, 0, rows*cols)}
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			lat, lng := MetersToLatLng(x0+float64(c)*spacing, y0+float64(r)*spacing)
+			g.AddNode(Node{Location: coordinatesToCellID(lat, lng)})
+		}
+	}
+	for r := 0; r < rows; r++ {
+		for c := 0; c < cols; c++ {
+			id := r*cols + c
+			if c+1 < cols {
+				relateByDistance(&g, g.Nodes[id], g.Nodes[id+1])
+			}
+			if r+1 < rows {
+				relateByDistance(&g, g.Nodes[id], g.Nodes[id+cols])
+			}
+		}
+	}
+	return g
+}
+
+// RandomGeometricGraph generates n nodes uniformly distributed over a square of the given side, in meters,
+// and connects every pair of nodes closer than radius with a bidirectional edge weighted by distance.
+//
+// Parameters:
+//   - n: int - Number of nodes
+//   - side: float64 - Side of the square area in meters
+//   - radius: float64 - Maximum distance in meters between connected nodes
+//   - seed: int64 - Seed of the random generator, the same seed always yields the same graph
+//
+// Returns:
+//   - Graph: The generated graph, which may be disconnected for small radii
+func RandomGeometricGraph(n int, side, radius float64, seed int64) Graph {
+	rnd := rand.New(rand.NewSource(seed))
+	g := Graph{Nodes: make([]Node, 0, n)}
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	vectors := make([]Vector, 0, n)
+	for i := 0; i < n; i++ {
+		x, y := x0+rnd.Float64()*side, y0+rnd.Float64()*side
+		lat, lng := MetersToLatLng(x, y)
+		id := g.AddNode(Node{Location: coordinatesToCellID(lat, lng)})
+		vectors = append(vectors, NewVector(int(id), []float64{x, y}))
+	}
+
+	tree := BuildKDTree(append([]Vector(nil), vectors...))
+	for _, v := range vectors {
+		for _, neighbor := range tree.RangeQuery(v, radius) {
+			if neighbor.ID > v.ID {
+				relateByDistance(&g, g.Nodes[v.ID], g.Nodes[neighbor.ID])
+			}
+		}
+	}
+	return g
+}
+
+// relateByDistance connects two nodes in both directions with their distance as weight.
+func relateByDistance(g *Graph, a, b Node) {
+	distance := DistanceMeters(s2.CellID(a.Location), s2.CellID(b.Location))
+	g.RelateNodes(a, b, distance, Bidirectional, MetaData{
+		Distance: distance,
+		RoadType: Residential,
+	})
+}
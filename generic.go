@@ -0,0 +1,214 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrNegativeWeight = errors.New("negative edge weight")
+)
+
+// Number is the set of weight types a WeightedGraph can be parameterized with: float32 to match Graph,
+// float64 when accumulating millions of small weights, or integers such as milliseconds for exact sums.
+type Number interface {
+	~int32 | ~int64 | ~float32 | ~float64
+}
+
+// WeightedEdge is a directed edge of a WeightedGraph.
+type WeightedEdge[W Number] struct {
+	ID     int32 // Identifier of the destination node
+	Weight W     // Cost of traversing the edge, never negative
+}
+
+// WeightedGraph is a directed graph whose edge weights have a caller-chosen numeric type. It only holds
+// the topology and the weights; Graph remains the type carrying locations and metadata.
+type WeightedGraph[W Number] struct {
+	OutgoingEdges [][]WeightedEdge[W] // Adjacency list of outgoing edges for each node
+}
+
+// WeightedPaths is the result of WeightedGraph.ShortestPaths: the shortest path tree from a source.
+type WeightedPaths[W Number] struct {
+	Costs    []W     // Costs[v] is the cost of the shortest path to v, only meaningful when v is reached
+	Previous []int32 // Previous[v] is the node before v on its shortest path, -1 for the source and unreached nodes
+
+	reached []bool
+}
+
+// NewWeightedGraph creates a weighted graph with n nodes and no edges.
+//
+// Parameters:
+//   - n: int - Number of nodes, with IDs from 0 to n-1
+//
+// Returns:
+//   - *WeightedGraph[W]: The empty graph
+func NewWeightedGraph[W Number](n int) *WeightedGraph[W] {
+	return &WeightedGraph[W]{OutgoingEdges: make([][]WeightedEdge[W], n)}
+}
+
+// ToWeighted converts a Graph into a WeightedGraph with the same node IDs and edges.
+//
+// Parameters:
+//   - g: Graph - The graph to convert
+//   - weight: func(Edge) W - Weight of every edge in the new graph, e.g., the distance in float64 or the
+//     travel time in int64 milliseconds
+//
+// Returns:
+//   - *WeightedGraph[W]: The converted graph
+//   - error: ErrNegativeWeight if weight returns a negative value for an edge
+func ToWeighted[W Number](g Graph, weight func(Edge) W) (*WeightedGraph[W], error) {
+	wg := NewWeightedGraph[W](len(g.Nodes))
+	for from, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			if err := wg.AddEdge(int32(from), e.ID, weight(e)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return wg, nil
+}
+
+// Len returns the number of nodes of the graph.
+func (g *WeightedGraph[W]) Len() int {
+	return len(g.OutgoingEdges)
+}
+
+// AddNode appends a node without edges and returns its ID.
+func (g *WeightedGraph[W]) AddNode() int32 {
+	g.OutgoingEdges = append(g.OutgoingEdges, nil)
+	return int32(len(g.OutgoingEdges) - 1)
+}
+
+// AddEdge adds a directed edge between two nodes.
+//
+// Parameters:
+//   - from: int32 - ID of the source node
+//   - to: int32 - ID of the destination node
+//   - w: W - Weight of the edge
+//
+// Returns:
+//   - error: ErrNodeNotFound if a node does not exist, ErrNegativeWeight if w is negative
+func (g *WeightedGraph[W]) AddEdge(from, to int32, w W) error {
+	if from < 0 || int(from) >= g.Len() || to < 0 || int(to) >= g.Len() {
+		return ErrNodeNotFound
+	}
+	if w < 0 {
+		return fmt.Errorf("edge %d -> %d: %w", from, to, ErrNegativeWeight)
+	}
+	g.OutgoingEdges[from] = append(g.OutgoingEdges[from], WeightedEdge[W]{ID: to, Weight: w})
+	return nil
+}
+
+// ShortestPaths runs Dijkstra's algorithm from a source, accumulating costs in W so no precision is lost
+// to float32 rounding.
+//
+// Parameters:
+//   - source: int32 - ID of the node the search starts from
+//
+// Returns:
+//   - WeightedPaths[W]: The cost and predecessor of every reached node
+//   - error: ErrNodeNotFound if the source does not exist
+func (g *WeightedGraph[W]) ShortestPaths(source int32) (WeightedPaths[W], error) {
+	n := g.Len()
+	paths := WeightedPaths[W]{Costs: make([]W, n), Previous: make([]int32, n), reached: make([]bool, n)}
+	for v := range paths.Previous {
+		paths.Previous[v] = -1
+	}
+	if source < 0 || int(source) >= n {
+		return paths, ErrNodeNotFound
+	}
+
+	settled := make([]bool, n)
+	pq := weightedHeap[W]{}
+	paths.reached[source] = true
+	pq.push(weightedItem[W]{node: source})
+	for len(pq) > 0 {
+		min := pq.pop()
+		if settled[min.node] {
+			continue
+		}
+		settled[min.node] = true
+		for _, e := range g.OutgoingEdges[min.node] {
+			cost := min.cost + e.Weight
+			if settled[e.ID] || (paths.reached[e.ID] && paths.Costs[e.ID] <= cost) {
+				continue
+			}
+			paths.reached[e.ID] = true
+			paths.Costs[e.ID], paths.Previous[e.ID] = cost, min.node
+			pq.push(weightedItem[W]{node: e.ID, cost: cost})
+		}
+	}
+	return paths, nil
+}
+
+// Reached reports whether a node was reached by the search.
+func (p WeightedPaths[W]) Reached(id int32) bool {
+	return id >= 0 && int(id) < len(p.reached) && p.reached[id]
+}
+
+// Path returns the nodes of the shortest path from the source to a node.
+//
+// Parameters:
+//   - target: int32 - ID of the last node of the path
+//
+// Returns:
+//   - []int32: The node IDs from source to target, nil if target was not reached
+func (p WeightedPaths[W]) Path(target int32) []int32 {
+	if !p.Reached(target) {
+		return nil
+	}
+	path := make([]int32, 0)
+	for v := target; v >= 0; v = p.Previous[v] {
+		path = append(path, v)
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+// weightedItem is an entry of a weightedHeap.
+type weightedItem[W Number] struct {
+	node int32
+	cost W
+}
+
+// weightedHeap is a binary min-heap of weightedItems ordered by cost, then by node ID.
+type weightedHeap[W Number] []weightedItem[W]
+
+func (h weightedHeap[W]) less(i, j int) bool {
+	if h[i].cost != h[j].cost {
+		return h[i].cost < h[j].cost
+	}
+	return h[i].node < h[j].node
+}
+
+func (h *weightedHeap[W]) push(item weightedItem[W]) {
+	*h = append(*h, item)
+	for i := len(*h) - 1; i > 0 && h.less(i, (i-1)/2); i = (i - 1) / 2 {
+		(*h)[i], (*h)[(i-1)/2] = (*h)[(i-1)/2], (*h)[i]
+	}
+}
+
+func (h *weightedHeap[W]) pop() weightedItem[W] {
+	old := *h
+	min := old[0]
+	last := len(old) - 1
+	old[0] = old[last]
+	*h = old[:last]
+	for i := 0; ; {
+		smallest, l, r := i, 2*i+1, 2*i+2
+		if l < last && h.less(l, smallest) {
+			smallest = l
+		}
+		if r < last && h.less(r, smallest) {
+			smallest = r
+		}
+		if smallest == i {
+			break
+		}
+		(*h)[i], (*h)[smallest] = (*h)[smallest], (*h)[i]
+		i = smallest
+	}
+	return min
+}
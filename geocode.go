@@ -0,0 +1,43 @@
+package graph_search
+
+// RoadMatch describes the road closest to a coordinate, as returned by NearestRoad.
+type RoadMatch struct {
+	Name     string     // Name of the way, empty if the way is unnamed
+	RoadType string     // Classification of the way, e.g., "residential"
+	WayID    int64      // Identifier of the OSM way, 0 for edges not coming from OSM
+	Point    Coordinate // Closest point of the road to the coordinate
+	Distance float64    // Distance in meters between the coordinate and Point
+}
+
+// NearestRoad finds the road closest to a coordinate, a lightweight reverse-geocoding primitive: "the
+// user is on Carrera 43A". Among edges equally close, e.g., both directions of a two-way street, the
+// first one found is used.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex
+//   - c: Coordinate - The coordinate to reverse geocode
+//   - radius: float64 - Search radius in projected meters, DefaultSnapRadius if not positive
+//
+// Returns:
+//   - RoadMatch: The name, type and distance of the closest road
+//   - error: ErrNoCandidates if no road lies within the radius
+func (g Graph) NearestRoad(index *KDTree, c Coordinate, radius float64) (RoadMatch, error) {
+	snap, err := g.SnapToEdgeWithOptions(index, c, SnapOptions{Radius: radius})
+	if err != nil {
+		return RoadMatch{}, err
+	}
+	m := snap.Edge.Metadata
+	return RoadMatch{Name: m.Name, RoadType: m.RoadType, WayID: m.WayID, Point: snap.Point, Distance: snap.Distance}, nil
+}
+
+// NearestRoad finds the road closest to a coordinate within DefaultSnapRadius, see Graph.NearestRoad.
+//
+// Parameters:
+//   - c: Coordinate - The coordinate to reverse geocode
+//
+// Returns:
+//   - RoadMatch: The name, type and distance of the closest road
+//   - error: ErrNoCandidates if no road lies near the coordinate
+func (e *Engine) NearestRoad(c Coordinate) (RoadMatch, error) {
+	return e.graph.NearestRoad(e.Index(), c, DefaultSnapRadius)
+}
@@ -0,0 +1,483 @@
+package graph_search
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+var (
+	ErrColumnMismatch = errors.New("values do not match the table columns")
+)
+
+// ColumnKind identifies the physical type of an attribute column in a GeoTable.
+type ColumnKind int
+
+const (
+	Float64Column ColumnKind = iota // Stored as a Parquet DOUBLE
+	Int64Column                     // Stored as a Parquet INT64
+	StringColumn                    // Stored as a Parquet BYTE_ARRAY annotated as UTF8
+)
+
+// GeoColumn declares an attribute column of a GeoTable.
+type GeoColumn struct {
+	Name string
+	Kind ColumnKind
+}
+
+// GeoTable accumulates features (a geometry plus attribute values) in memory and writes them as a
+// GeoParquet file with a WKB encoded "geometry" column, loadable directly by DuckDB, Spark or GeoPandas.
+type GeoTable struct {
+	geometryType string
+	columns      []GeoColumn
+	geometries   [][]byte
+	values       [][]interface{}
+	bbox         [4]float64
+}
+
+// NewGeoTable creates an empty table for geometries of a single type.
+//
+// Parameters:
+//   - geometryType: string - GeoParquet geometry type of every row ("Point", "LineString" or "Polygon")
+//   - columns: ...GeoColumn - Attribute columns stored next to the geometry
+//
+// Returns:
+//   - *GeoTable: An empty table
+func NewGeoTable(geometryType string, columns ...GeoColumn) *GeoTable {
+	return &GeoTable{
+		geometryType: geometryType,
+		columns:      columns,
+		bbox:         [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)},
+	}
+}
+
+// NewRouteTable creates a table for route geometries with their identifier and total cost.
+func NewRouteTable() *GeoTable {
+	return NewGeoTable("LineString", GeoColumn{Name: "id", Kind: Int64Column}, GeoColumn{Name: "cost", Kind: Float64Column})
+}
+
+// NewIsochroneTable creates a table for isochrone polygons with the cost threshold they were generated for.
+func NewIsochroneTable() *GeoTable {
+	return NewGeoTable("Polygon", GeoColumn{Name: "threshold", Kind: Float64Column})
+}
+
+// NewEdgeUsageTable creates a table for edge usage layers: one line per edge with its endpoints and usage count.
+func NewEdgeUsageTable() *GeoTable {
+	return NewGeoTable("LineString",
+		GeoColumn{Name: "from", Kind: Int64Column},
+		GeoColumn{Name: "to", Kind: Int64Column},
+		GeoColumn{Name: "count", Kind: Int64Column},
+	)
+}
+
+// Len returns the number of rows in the table.
+func (t *GeoTable) Len() int {
+	return len(t.geometries)
+}
+
+// AddPoint appends a point feature.
+func (t *GeoTable) AddPoint(c Coordinate, values ...interface{}) error {
+	return t.add(wkbPoint(c), []Coordinate{c}, values)
+}
+
+// AddLineString appends a line feature.
+//
+// Parameters:
+//   - line: []Coordinate - Vertices of the line in order
+//   - values: ...interface{} - Attribute values, one per column, matching the column kinds
+//     (float64, int64 or string)
+//
+// Returns:
+//   - error: ErrColumnMismatch if the values do not match the declared columns
+func (t *GeoTable) AddLineString(line []Coordinate, values ...interface{}) error {
+	return t.add(wkbLineString(line), line, values)
+}
+
+// AddPolygon appends a polygon feature. The first ring is the exterior one, the following are holes.
+func (t *GeoTable) AddPolygon(rings [][]Coordinate, values ...interface{}) error {
+	all := make([]Coordinate, 0)
+	for _, ring := range rings {
+		all = append(all, ring...)
+	}
+	return t.add(wkbPolygon(rings), all, values)
+}
+
+// AddRoute appends a route given as the [lng, lat] pairs produced by SearchSpace.PathCoord.
+func (t *GeoTable) AddRoute(id int64, path [][]float64, cost float32) error {
+	line := make([]Coordinate, 0, len(path))
+	for _, p := range path {
+		line = append(line, Coordinate{Lat: p[1], Lng: p[0]})
+	}
+	return t.AddLineString(line, id, float64(cost))
+}
+
+// AddEdgeUsage appends the line of the edge from -> to of g together with its usage count.
+func (t *GeoTable) AddEdgeUsage(g Graph, from, to int32, count int64) error {
+	a, b := g.Nodes[from].GetPoint(), g.Nodes[to].GetPoint()
+	line := []Coordinate{
+		{Lat: a.Lat.Degrees(), Lng: a.Lng.Degrees()},
+		{Lat: b.Lat.Degrees(), Lng: b.Lng.Degrees()},
+	}
+	return t.AddLineString(line, int64(from), int64(to), count)
+}
+
+// add validates the attribute values and appends a row.
+func (t *GeoTable) add(geometry []byte, coords []Coordinate, values []interface{}) error {
+	if len(values) != len(t.columns) {
+		return ErrColumnMismatch
+	}
+	for i, c := range t.columns {
+		ok := false
+		switch c.Kind {
+		case Float64Column:
+			_, ok = values[i].(float64)
+		case Int64Column:
+			_, ok = values[i].(int64)
+		case StringColumn:
+			_, ok = values[i].(string)
+		}
+		if !ok {
+			return fmt.Errorf("column %q: %w", c.Name, ErrColumnMismatch)
+		}
+	}
+	for _, c := range coords {
+		t.bbox[0] = math.Min(t.bbox[0], c.Lng)
+		t.bbox[1] = math.Min(t.bbox[1], c.Lat)
+		t.bbox[2] = math.Max(t.bbox[2], c.Lng)
+		t.bbox[3] = math.Max(t.bbox[3], c.Lat)
+	}
+	t.geometries = append(t.geometries, geometry)
+	t.values = append(t.values, values)
+	return nil
+}
+
+// WriteFile writes the table as a GeoParquet file at the given path.
+func (t *GeoTable) WriteFile(filePath string) error {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	if err = t.WriteParquet(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteParquet encodes the table as a single row group GeoParquet file. Columns are stored uncompressed
+// with PLAIN encoding, which every Parquet reader supports.
+//
+// Parameters:
+//   - w: io.Writer - Destination of the encoded file
+//
+// Returns:
+//   - error: nil if the whole file was written, otherwise the encountered error
+func (t *GeoTable) WriteParquet(w io.Writer) error {
+	out := bytes.NewBufferString(parquetMagic)
+	chunks := make([]parquetChunk, 0, len(t.columns)+1)
+
+	geometry := new(bytes.Buffer)
+	for _, g := range t.geometries {
+		writeByteArray(geometry, g)
+	}
+	chunks = append(chunks, writeColumnChunk(out, "geometry", parquetByteArray, false, len(t.geometries), geometry.Bytes()))
+
+	for i, c := range t.columns {
+		data := new(bytes.Buffer)
+		for _, row := range t.values {
+			switch v := row[i].(type) {
+			case float64:
+				_ = binary.Write(data, binary.LittleEndian, v)
+			case int64:
+				_ = binary.Write(data, binary.LittleEndian, v)
+			case string:
+				writeByteArray(data, []byte(v))
+			}
+		}
+		physical := map[ColumnKind]int32{Float64Column: parquetDouble, Int64Column: parquetInt64, StringColumn: parquetByteArray}[c.Kind]
+		chunks = append(chunks, writeColumnChunk(out, c.Name, physical, c.Kind == StringColumn, len(t.values), data.Bytes()))
+	}
+
+	geo, err := t.geoMetadata()
+	if err != nil {
+		return err
+	}
+	footer := t.fileMetadata(chunks, geo)
+	out.Write(footer)
+	_ = binary.Write(out, binary.LittleEndian, uint32(len(footer)))
+	out.WriteString(parquetMagic)
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// geoMetadata builds the GeoParquet "geo" file metadata describing the geometry column.
+func (t *GeoTable) geoMetadata() (string, error) {
+	column := map[string]interface{}{
+		"encoding":       "WKB",
+		"geometry_types": []string{t.geometryType},
+	}
+	if t.Len() > 0 {
+		column["bbox"] = t.bbox[:]
+	}
+	geo, err := json.Marshal(map[string]interface{}{
+		"version":        "1.0.0",
+		"primary_column": "geometry",
+		"columns":        map[string]interface{}{"geometry": column},
+	})
+	return string(geo), err
+}
+
+// fileMetadata encodes the Parquet footer (FileMetaData) for the written column chunks.
+func (t *GeoTable) fileMetadata(chunks []parquetChunk, geo string) []byte {
+	tw := new(thriftWriter)
+	tw.i32(1, 1)
+
+	tw.list(2, thriftStruct, len(chunks)+1)
+	tw.beginStruct()
+	tw.binary(4, []byte("schema"))
+	tw.i32(5, int32(len(chunks)))
+	tw.endStruct()
+	for _, c := range chunks {
+		tw.beginStruct()
+		tw.i32(1, c.physical)
+		tw.i32(3, 0) // REQUIRED
+		tw.binary(4, []byte(c.name))
+		if c.utf8 {
+			tw.i32(6, 0) // UTF8
+		}
+		tw.endStruct()
+	}
+
+	tw.i64(3, int64(t.Len()))
+
+	total := int64(0)
+	for _, c := range chunks {
+		total += c.size
+	}
+	tw.list(4, thriftStruct, 1)
+	tw.beginStruct()
+	tw.list(1, thriftStruct, len(chunks))
+	for _, c := range chunks {
+		tw.beginStruct()
+		tw.i64(2, c.offset)
+		tw.field(3, thriftStruct)
+		tw.beginStruct()
+		tw.i32(1, c.physical)
+		tw.list(2, thriftI32, 1)
+		tw.varint(zigzag(parquetPlain))
+		tw.list(3, thriftBinary, 1)
+		tw.rawBinary([]byte(c.name))
+		tw.i32(4, 0) // UNCOMPRESSED
+		tw.i64(5, int64(c.values))
+		tw.i64(6, c.size)
+		tw.i64(7, c.size)
+		tw.i64(9, c.offset)
+		tw.endStruct()
+		tw.endStruct()
+	}
+	tw.i64(2, total)
+	tw.i64(3, int64(t.Len()))
+	tw.endStruct()
+
+	tw.list(5, thriftStruct, 1)
+	tw.beginStruct()
+	tw.binary(1, []byte("geo"))
+	tw.binary(2, []byte(geo))
+	tw.endStruct()
+
+	tw.binary(6, []byte("graph_search"))
+	tw.stop()
+	return tw.buf.Bytes()
+}
+
+// Parquet physical types and encodings used by the writer.
+const (
+	parquetMagic     = "PAR1"
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+	parquetPlain     = 0
+	parquetRLE       = 3
+)
+
+// parquetChunk records where a column chunk was written, to be referenced from the footer.
+type parquetChunk struct {
+	name     string
+	physical int32
+	utf8     bool
+	values   int
+	offset   int64
+	size     int64
+}
+
+// writeColumnChunk writes a single data page holding all the values of a column.
+func writeColumnChunk(out *bytes.Buffer, name string, physical int32, utf8 bool, values int, data []byte) parquetChunk {
+	offset := int64(out.Len())
+
+	tw := new(thriftWriter)
+	tw.i32(1, 0) // DATA_PAGE
+	tw.i32(2, int32(len(data)))
+	tw.i32(3, int32(len(data)))
+	tw.field(5, thriftStruct)
+	tw.beginStruct()
+	tw.i32(1, int32(values))
+	tw.i32(2, parquetPlain)
+	tw.i32(3, parquetRLE)
+	tw.i32(4, parquetRLE)
+	tw.endStruct()
+	tw.stop()
+
+	out.Write(tw.buf.Bytes())
+	out.Write(data)
+	return parquetChunk{
+		name:     name,
+		physical: physical,
+		utf8:     utf8,
+		values:   values,
+		offset:   offset,
+		size:     int64(out.Len()) - offset,
+	}
+}
+
+// writeByteArray writes a PLAIN encoded BYTE_ARRAY value: its length followed by its bytes.
+func writeByteArray(buf *bytes.Buffer, b []byte) {
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
+// WKB geometry codes.
+const (
+	wkbPointType      = 1
+	wkbLineStringType = 2
+	wkbPolygonType    = 3
+)
+
+// wkbPoint encodes a coordinate as a little endian WKB point.
+func wkbPoint(c Coordinate) []byte {
+	buf := wkbHeader(wkbPointType)
+	_ = binary.Write(buf, binary.LittleEndian, [2]float64{c.Lng, c.Lat})
+	return buf.Bytes()
+}
+
+// wkbLineString encodes a sequence of coordinates as a little endian WKB line string.
+func wkbLineString(line []Coordinate) []byte {
+	buf := wkbHeader(wkbLineStringType)
+	writeWKBPoints(buf, line)
+	return buf.Bytes()
+}
+
+// wkbPolygon encodes rings as a little endian WKB polygon.
+func wkbPolygon(rings [][]Coordinate) []byte {
+	buf := wkbHeader(wkbPolygonType)
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(rings)))
+	for _, ring := range rings {
+		writeWKBPoints(buf, ring)
+	}
+	return buf.Bytes()
+}
+
+// wkbHeader starts a WKB geometry with the little endian byte order mark and its type.
+func wkbHeader(geometryType uint32) *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(1)
+	_ = binary.Write(buf, binary.LittleEndian, geometryType)
+	return buf
+}
+
+// writeWKBPoints writes a point count followed by the x (longitude) and y (latitude) of every point.
+func writeWKBPoints(buf *bytes.Buffer, points []Coordinate) {
+	_ = binary.Write(buf, binary.LittleEndian, uint32(len(points)))
+	for _, p := range points {
+		_ = binary.Write(buf, binary.LittleEndian, [2]float64{p.Lng, p.Lat})
+	}
+}
+
+// Thrift compact protocol type identifiers.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter is a minimal encoder for the Thrift compact protocol used by Parquet metadata.
+type thriftWriter struct {
+	buf    bytes.Buffer
+	lastID int16
+	stack  []int16
+}
+
+// field writes a field header, using the short delta form when possible.
+func (tw *thriftWriter) field(id int16, typ byte) {
+	if delta := id - tw.lastID; delta > 0 && delta <= 15 {
+		tw.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		tw.buf.WriteByte(typ)
+		tw.varint(zigzag(int64(id)))
+	}
+	tw.lastID = id
+}
+
+func (tw *thriftWriter) i32(id int16, v int32) {
+	tw.field(id, thriftI32)
+	tw.varint(zigzag(int64(v)))
+}
+
+func (tw *thriftWriter) i64(id int16, v int64) {
+	tw.field(id, thriftI64)
+	tw.varint(zigzag(v))
+}
+
+func (tw *thriftWriter) binary(id int16, b []byte) {
+	tw.field(id, thriftBinary)
+	tw.rawBinary(b)
+}
+
+func (tw *thriftWriter) rawBinary(b []byte) {
+	tw.varint(uint64(len(b)))
+	tw.buf.Write(b)
+}
+
+// list writes a list field header; the caller writes the elements right after.
+func (tw *thriftWriter) list(id int16, elemType byte, size int) {
+	tw.field(id, thriftList)
+	if size < 15 {
+		tw.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	tw.buf.WriteByte(0xF0 | elemType)
+	tw.varint(uint64(size))
+}
+
+func (tw *thriftWriter) beginStruct() {
+	tw.stack = append(tw.stack, tw.lastID)
+	tw.lastID = 0
+}
+
+func (tw *thriftWriter) endStruct() {
+	tw.stop()
+	tw.lastID = tw.stack[len(tw.stack)-1]
+	tw.stack = tw.stack[:len(tw.stack)-1]
+}
+
+func (tw *thriftWriter) stop() {
+	tw.buf.WriteByte(0)
+}
+
+func (tw *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	tw.buf.Write(b[:n])
+}
+
+// zigzag maps signed integers to unsigned ones so small magnitudes encode in few bytes.
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
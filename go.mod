@@ -4,6 +4,7 @@ go 1.23
 
 require (
 	github.com/golang/geo v0.0.0-20230421003525-6adc56603217
+	github.com/klauspost/compress v1.17.9
 	github.com/paulmach/go.geojson v1.5.0
 	github.com/qedus/osmpbf v1.2.0
 	github.com/umahmood/haversine v0.0.0-20151105152445-808ab04add26
@@ -0,0 +1,79 @@
+package graph_search
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// gpxDocument is the root element of a GPX 1.1 file holding a single track.
+type gpxDocument struct {
+	XMLName xml.Name `xml:"http://www.topografix.com/GPX/1/1 gpx"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Track   gpxTrack `xml:"trk"`
+}
+
+// gpxTrack is a GPX track made of a single segment.
+type gpxTrack struct {
+	Name    string          `xml:"name,omitempty"`
+	Segment []gpxTrackPoint `xml:"trkseg>trkpt"`
+}
+
+// gpxTrackPoint is a timestamped point of a GPX track segment.
+type gpxTrackPoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Time string  `xml:"time"`
+}
+
+// GPX renders the route as a GPX 1.1 track, loadable by GPS devices and outdoor apps. Every point is
+// timestamped with the departure time plus the travel time accumulated up to it, computed as in Duration.
+//
+// Parameters:
+//   - name: string - Name of the track, omitted when empty
+//   - departure: time.Time - Time at which the first point of the route is left
+//
+// Returns:
+//   - []byte: The GPX document, including the XML header
+//   - error: The error returned by the XML encoder, if any
+func (r Route) GPX(name string, departure time.Time) ([]byte, error) {
+	doc := gpxDocument{
+		Version: "1.1",
+		Creator: "graph_search",
+		Track:   gpxTrack{Name: name, Segment: make([]gpxTrackPoint, 0, len(r.coordinates))},
+	}
+	elapsed := 0.0
+	for i, c := range r.coordinates {
+		if i > 0 {
+			elapsed += edgeDuration(r.Edges[i-1])
+		}
+		at := departure.Add(time.Duration(elapsed * float64(time.Second)))
+		doc.Track.Segment = append(doc.Track.Segment, gpxTrackPoint{
+			Lat:  c.Lat,
+			Lon:  c.Lng,
+			Time: at.UTC().Format(time.RFC3339),
+		})
+	}
+	content, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// ToGPX renders the route found by a search as a GPX track. The route ends at the last node settled by
+// the search, which is the target when the search was run with a single Criteria.Targets entry.
+//
+// Parameters:
+//   - g: Graph - The graph the search was run on
+//   - departure: time.Time - Time at which the source is left
+//
+// Returns:
+//   - []byte: The GPX document, with an empty track if the search settled no node
+//   - error: The error returned by the XML encoder, if any
+func (r Response) ToGPX(g Graph, departure time.Time) ([]byte, error) {
+	if len(r.SearchSpace.Nodes) == 0 {
+		return Route{}.GPX("", departure)
+	}
+	return r.SearchSpace.Route(int32(len(r.SearchSpace.Nodes)-1), g).GPX("", departure)
+}
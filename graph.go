@@ -1,10 +1,11 @@
 package graph_search
 
 import (
-	"encoding/gob"
+	"bufio"
 	"encoding/json"
-	"log"
+	"iter"
 	"os"
+	"sort"
 
 	"github.com/golang/geo/s2"
 	"github.com/umahmood/haversine"
@@ -16,13 +17,55 @@ type Graph struct {
 	Nodes         []Node    // Collection of all nodes in the graph
 	IncomingEdges Relations // Adjacency list of incoming edges for each node
 	OutgoingEdges Relations // Adjacency list of outgoing edges for each node
+
+	// OSMIDs maps every internal node ID to the ID of the OSM node it was built from. It is filled by
+	// BuildGraph and persisted by Serialize; it is empty for graphs not built from OSM data.
+	OSMIDs []int64
+
+	// Properties holds the custom attributes of every node, indexed by node ID. It may be shorter than
+	// Nodes, or empty, when trailing nodes carry no attributes.
+	Properties []Properties
 }
 
 // MetaData contains additional information associated with graph edges.
 type MetaData struct {
 	Speed    float32 // Speed limit or average speed for the edge in meters/second
 	Distance float32 // Physical distance of the edge in meters
+	Duration float32 // Time needed to traverse the edge at Speed in seconds, 0 when not precomputed
 	RoadType string  // Classification of the road/path type (e.g., "motorway", "residential")
+	Name     string  // Name of the way the edge belongs to, empty if the way is unnamed
+	WayID    int64   // Identifier of the OSM way the edge was built from, 0 for edges not coming from OSM
+
+	Surface    string // Value of the surface tag of the way (e.g., "asphalt", "gravel"), empty when untagged
+	Smoothness string // Value of the smoothness tag of the way (e.g., "good", "bad"), empty when untagged
+
+	Cycleway BikeInfrastructure // Best cycling facility along the edge, BikeMixed when there is none
+	Lanes    LaneInfo           // Lane count and turn indications of the edge, 0 when untagged
+
+	// Access holds the time windows restricting the use of the edge, nil when unrestricted. Time-dependent
+	// searches honor it; other searches skip the edges only open during some windows.
+	Access *ConditionalAccess
+
+	Country string // ISO 3166-1 code of the country the edge leads into, empty until AssignBoundaries
+	Region  string // ISO 3166-2 code of the region the edge leads into, empty until AssignBoundaries
+
+	Profile *SpeedProfile  // Optional hourly speeds used by time-dependent searches, nil when the edge has a static speed
+	Weekly  *WeeklyProfile // Optional historical speeds for every hour of the week, preferred over Profile
+
+	Ferry bool // Whether the edge is a ferry crossing (route=ferry)
+	Toll  bool // Whether traversing the edge requires paying a toll (toll=yes)
+
+	MaxWeight float32 // Maximum legal vehicle weight in tonnes, 0 when unrestricted
+	MaxHeight float32 // Maximum legal vehicle height in meters, 0 when unrestricted
+	MaxWidth  float32 // Maximum legal vehicle width in meters, 0 when unrestricted
+	NoHGV     bool    // Whether heavy goods vehicles are forbidden (hgv=no)
+	NoHazmat  bool    // Whether vehicles carrying hazardous materials are forbidden (hazmat=no)
+
+	Capacity float32 // Maximum flow through the edge, e.g., in vehicles per hour, used by the flow and assignment packages
+
+	TravelTimeVariance float32 // Variance of the travel time in seconds², 0 when the travel time is deterministic
+
+	Properties Properties // Custom attributes of the edge, nil when it has none
 }
 
 // Node represents a vertex in the graph with geographical positioning.
@@ -87,6 +130,7 @@ func (n Node) GetID() int {
 }
 
 // AddNode appends a new node to the graph and initializes its edge collections.
+//
 // Parameters:
 //   - n: Node - The node to be added to the graph
 //
@@ -173,6 +217,103 @@ func (g *Graph) addIncomingEdge(from, to int32, weight float32, metaData MetaDat
 	})
 }
 
+// ForEachOutgoing calls fn for every edge leaving a node, in adjacency list order, until fn returns false.
+// Iterating through it instead of indexing OutgoingEdges keeps callers independent of how adjacency
+// lists are stored.
+//
+// Parameters:
+//   - node: int32 - ID of the node whose outgoing edges are visited
+//   - fn: func(Edge) bool - Called with every edge, returning false stops the iteration
+func (g Graph) ForEachOutgoing(node int32, fn func(Edge) bool) {
+	for _, e := range g.OutgoingEdges[node] {
+		if !fn(e) {
+			return
+		}
+	}
+}
+
+// Outgoing returns an iterator over the edges leaving a node and their positions in its adjacency list,
+// for use in range-over-func loops.
+//
+// Parameters:
+//   - node: int32 - ID of the node whose outgoing edges are iterated
+//
+// Returns:
+//   - iter.Seq2[int, Edge]: The position and value of every outgoing edge
+func (g Graph) Outgoing(node int32) iter.Seq2[int, Edge] {
+	return func(yield func(int, Edge) bool) {
+		for i, e := range g.OutgoingEdges[node] {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// Incoming returns an iterator over the edges reaching a node and their positions in its adjacency list.
+// The ID of every edge is the node it comes from.
+//
+// Parameters:
+//   - node: int32 - ID of the node whose incoming edges are iterated
+//
+// Returns:
+//   - iter.Seq2[int, Edge]: The position and value of every incoming edge
+func (g Graph) Incoming(node int32) iter.Seq2[int, Edge] {
+	return func(yield func(int, Edge) bool) {
+		for i, e := range g.IncomingEdges[node] {
+			if !yield(i, e) {
+				return
+			}
+		}
+	}
+}
+
+// OSMID returns the ID of the OSM node the given internal node was built from.
+//
+// Parameters:
+//   - id: int32 - Internal node ID
+//
+// Returns:
+//   - int64: The OSM node ID
+//   - bool: false if the node is unknown or the graph carries no OSM IDs
+func (g Graph) OSMID(id int32) (int64, bool) {
+	if id < 0 || int(id) >= len(g.OSMIDs) {
+		return 0, false
+	}
+	return g.OSMIDs[id], true
+}
+
+// OSMIndex builds the reverse of OSMIDs, so clients can translate OSM node IDs into internal IDs
+// before running a search.
+//
+// Returns:
+//   - map[int64]int32: A map from OSM node IDs to internal node IDs, empty for graphs not built from OSM data
+func (g Graph) OSMIndex() map[int64]int32 {
+	index := make(map[int64]int32, len(g.OSMIDs))
+	for id, osmID := range g.OSMIDs {
+		index[osmID] = int32(id)
+	}
+	return index
+}
+
+// SortEdges orders every adjacency list by destination node, then by OSM way ID and weight, so the
+// layout of the graph does not depend on the order its edges were added in.
+func (g *Graph) SortEdges() {
+	for _, relations := range []Relations{g.OutgoingEdges, g.IncomingEdges} {
+		for _, edges := range relations {
+			sort.SliceStable(edges, func(i, j int) bool {
+				if edges[i].ID != edges[j].ID {
+					return edges[i].ID < edges[j].ID
+				}
+				if edges[i].Metadata.WayID != edges[j].Metadata.WayID {
+					return edges[i].Metadata.WayID < edges[j].Metadata.WayID
+				}
+				return edges[i].Weight < edges[j].Weight
+			})
+		}
+	}
+}
+
 // DistanceMeters calculates the great-circle distance between two geographical points using the Haversine formula.
 // Parameters:
 //   - a: s2.CellID - The S2 cell ID of the first location
@@ -196,16 +337,36 @@ func DistanceMeters(a, b s2.CellID) float32 {
 // Returns:
 //   - *RangeTree: A spatial index of the graph's nodes for efficient geographical queries
 func (g *Graph) BuildNodeIndex() *KDTree {
+	return g.BuildNodeIndexWithMetric(EuclideanMetric{})
+}
+
+// BuildNodeIndexWithMetric creates a spatial index of nodes like BuildNodeIndex, measuring distances with
+// the given metric. With HaversineMetric the index stores latitudes and longitudes and its radii are true
+// meters at any latitude; with any other metric it stores Web Mercator meters.
+//
+// Parameters:
+//   - m: Metric - The metric of the index, EuclideanMetric if nil
+//
+// Returns:
+//   - *KDTree: A spatial index of the graph's nodes
+func (g *Graph) BuildNodeIndexWithMetric(m Metric) *KDTree {
 	vectors := make([]Vector, 0)
 	for _, n := range g.Nodes {
 		if len(g.OutgoingEdges[n.ID]) > 0 {
-			latLng := s2.CellID(n.Location).LatLng()
-			x, y := LatLngToMeters(latLng.Lat.Degrees(), latLng.Lng.Degrees())
-			vector := Vector{ID: n.GetID(), Components: []float64{x, y}}
+			vector := Vector{ID: n.GetID(), Components: geoComponents(m, nodeCoordinate(n))}
 			vectors = append(vectors, vector)
 		}
 	}
-	return BuildKDTree(vectors)
+	return BuildKDTreeWithMetric(vectors, m)
+}
+
+// geoComponents returns the components of a coordinate in a node index using the given metric.
+func geoComponents(m Metric, c Coordinate) []float64 {
+	if _, ok := m.(HaversineMetric); ok {
+		return []float64{c.Lat, c.Lng}
+	}
+	x, y := LatLngToMeters(c.Lat, c.Lng)
+	return []float64{x, y}
 }
 
 // Write serializes and writes content to a JSON file.
@@ -226,7 +387,7 @@ func (g *Graph) BuildNodeIndex() *KDTree {
 //   - Create a new file, overwriting if it already exists
 //   - Marshal the content to JSON
 //   - Write the JSON data to the file
-//   - Log the number of bytes written
+//   - Log the number of bytes written through the package logger (see SetLogger)
 //   - Handle all errors appropriately with cleanup
 //   - Close the file properly in all cases
 func Write(name string, content interface{}) string {
@@ -237,14 +398,14 @@ func Write(name string, content interface{}) string {
 	d2, _ := json.Marshal(content)
 	n2, err := f.Write(d2)
 	if err != nil {
-		log.Println(err)
+		getLogger().Error("writing file", "file", name, "error", err)
 		f.Close()
 		return ""
 	}
-	log.Println(n2, "bytes written successfully")
+	getLogger().Info("file written", "file", name, "bytes", n2)
 	err = f.Close()
 	if err != nil {
-		log.Println(err)
+		getLogger().Error("closing file", "file", name, "error", err)
 		return ""
 	}
 	return f.Name()
@@ -253,8 +414,8 @@ func Write(name string, content interface{}) string {
 // Serialize encodes and writes the Graph structure to a binary file using Go's gob encoding.
 //
 // This method persists the entire Graph structure to disk in a binary format that preserves
-// all relationships and data. The gob encoder handles complex data structures and maintains
-// referential integrity.
+// all relationships and data. Every field is written as a separate gob-encoded section with its
+// length and SHA-256 checksum, so truncated or corrupted files are detected by Verify and Load.
 //
 // Parameters:
 //   - filePath: string - The full path where the serialized graph should be written
@@ -264,18 +425,51 @@ func Write(name string, content interface{}) string {
 //
 // The method will:
 //   - Create a new file at the specified path
-//   - Initialize a gob encoder
-//   - Encode the entire graph structure
+//   - Encode every section of the graph with its checksum
 //   - Handle proper file closure
 //   - Return any errors encountered during the process
 func (g Graph) Serialize(filePath string) error {
-	file, err := os.Create(filePath)
-	if err == nil {
-		encoder := gob.NewEncoder(file)
-		encoder.Encode(g)
+	return g.SerializeWithOptions(filePath, SerializeOptions{})
+}
+
+// SerializeWithOptions writes the graph like Serialize, optionally compressing and delta-encoding its
+// sections to shrink country-sized files. Load and Deserialize decompress them transparently.
+//
+// Parameters:
+//   - filePath: string - The full path where the serialized graph should be written
+//   - opts: SerializeOptions - Compression and encoding of the sections
+//
+// Returns:
+//   - error - nil if the serialization was successful, otherwise returns the encountered error
+func (g Graph) SerializeWithOptions(filePath string, opts SerializeOptions) error {
+	return g.SerializeTo(FileStorage(""), filePath, opts)
+}
+
+// SerializeTo writes the graph like SerializeWithOptions to an object of a storage, e.g., a bucket of an
+// object store. LoadFrom reads it back.
+//
+// Parameters:
+//   - s: Storage - The storage receiving the graph
+//   - name: string - The name of the object
+//   - opts: SerializeOptions - Compression and encoding of the sections
+//
+// Returns:
+//   - error - nil if the serialization was successful, otherwise returns the encountered error
+func (g Graph) SerializeTo(s Storage, name string, opts SerializeOptions) error {
+	file, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if err := writeGraph(w, &g, opts); err != nil {
+		file.Close()
+		return err
 	}
-	file.Close()
-	return err
+	if err := w.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
 }
 
 // Deserialize reads a binary file and reconstructs a Graph structure from it.
@@ -293,20 +487,16 @@ func (g Graph) Serialize(filePath string) error {
 //
 // The function will:
 //   - Open the specified file
-//   - Initialize a gob decoder
-//   - Decode the binary data into a new Graph structure
+//   - Decode every section, checking its checksum
 //   - Handle proper file closure
 //   - Return the reconstructed Graph
 //
-// Note: Error handling is internal - errors during deserialization will result
-// in an empty Graph being returned
+// Note: Error handling is internal - errors during deserialization are logged and result
+// in an empty Graph being returned. Use Load to get the error, or Verify to check a file first
 func Deserialize(filePath string) Graph {
-	var g = new(Graph)
-	file, err := os.Open(filePath)
-	if err == nil {
-		decoder := gob.NewDecoder(file)
-		err = decoder.Decode(g)
+	g, err := Load(filePath)
+	if err != nil {
+		getLogger().Error("reading graph", "file", filePath, "error", err)
 	}
-	file.Close()
-	return *g
+	return g
 }
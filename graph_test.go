@@ -1,14 +1,36 @@
 package graph_search
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"math"
+	"math/rand"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
 	"testing"
 
+	"github.com/golang/geo/s2"
 	"github.com/paulmach/go.geojson"
+	"github.com/qedus/osmpbf"
 )
 
 func TestGraphSearch(t *testing.T) {
-	graph := BuildGraph("testdata/colombia-latest.osm.pbf")
+	graph, err := BuildGraph("testdata/colombia-latest.osm.pbf")
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("missing testdata/colombia-latest.osm.pbf")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(len(graph.Nodes))
 
 	rangeTree := graph.BuildNodeIndex()
@@ -28,10 +50,949 @@ func TestGraphSearch(t *testing.T) {
 	}).Run(graph)
 	distance, _ := response.Costs.GetCost(int32(projectedTarget.ID))
 	targetSearchSpace := response.SearchSpace.Nodes[len(response.SearchSpace.Nodes)-1].ID
-	p := response.SearchSpace.PathCoord(targetSearchSpace, graph)
+	p := response.SearchSpace.Route(targetSearchSpace, graph).LngLat()
 
 	fc := geojson.NewFeatureCollection()
 	fc.AddFeature(geojson.NewLineStringFeature(p))
 	Write("testdata/route.geojson", fc)
 	fmt.Printf("Total distance: %.2f meters\n", distance)
 }
+
+func TestGraphSearch_RouteBetween(t *testing.T) {
+	graph, err := BuildGraph("testdata/colombia-latest.osm.pbf")
+	if errors.Is(err, os.ErrNotExist) {
+		t.Skip("missing testdata/colombia-latest.osm.pbf")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := Coordinate{Lat: 6.1997796925416395, Lng: -75.57815231451204}
+	target := Coordinate{Lat: 6.197606519075109, Lng: -75.55768012592779}
+
+	route, err := NewEngine(graph).RouteBetween(source, target, RouteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(route.Route.Nodes) < 2 || route.Distance <= 0 {
+		t.Fatalf("got %d points and %.2f meters, expected a route between the coordinates", len(route.Route.Nodes), route.Distance)
+	}
+}
+
+func TestGraph_OSMIDsRoundTrip(t *testing.T) {
+	g := GridGraph(2, 2, 100)
+	g.OSMIDs = []int64{901, 902, 903, 904}
+
+	path := t.TempDir() + "/graph.bin"
+	if err := g.Serialize(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := Deserialize(path)
+
+	if osmID, ok := restored.OSMID(2); !ok || osmID != 903 {
+		t.Errorf("OSMID(2) = %d, %v; want 903, true", osmID, ok)
+	}
+	if _, ok := restored.OSMID(4); ok {
+		t.Error("OSMID(4) should not exist")
+	}
+	if id, ok := restored.OSMIndex()[904]; !ok || id != 3 {
+		t.Errorf("OSMIndex()[904] = %d, %v; want 3, true", id, ok)
+	}
+}
+
+func TestGraph_PropertiesRoundTrip(t *testing.T) {
+	g := GridGraph(2, 2, 100)
+	if err := g.SetNodeProperty(1, "zone", "42"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetEdgeProperty(0, 1, "hazard", "yes"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.SetEdgeProperty(0, 3, "hazard", "yes"); !errors.Is(err, ErrEdgeNotFound) {
+		t.Fatalf("got %v, want ErrEdgeNotFound", err)
+	}
+
+	path := t.TempDir() + "/graph.bin"
+	if err := g.Serialize(path); err != nil {
+		t.Fatal(err)
+	}
+	restored := Deserialize(path)
+
+	if zone, ok := restored.NodeProperties(1).Int("zone"); !ok || zone != 42 {
+		t.Errorf("zone = %d, %v; want 42, true", zone, ok)
+	}
+	if _, ok := restored.NodeProperties(3).Get("zone"); ok {
+		t.Error("node 3 should carry no zone")
+	}
+	sp := NewDijkstra(Criteria{Source: []int32{0}, Targets: []int32{1}}).Run(restored).SearchSpace
+	route := sp.Route(int32(len(sp.Nodes)-1), restored)
+	if hazard, ok := route.Edges[0].Metadata.Properties.Bool("hazard"); !ok || !hazard {
+		t.Errorf("hazard = %v, %v; want true, true", hazard, ok)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	g.OSMIDs = make([]int64, len(g.Nodes))
+	path := t.TempDir() + "/graph.bin"
+	if err := g.Serialize(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(path); err != nil {
+		t.Fatalf("Verify of intact file: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)/2] ^= 0xff
+	for name, damaged := range map[string][]byte{
+		"truncated": data[:len(data)-10],
+		"flipped":   flipped,
+		"empty":     data[:len(graphMagic)],
+	} {
+		damagedPath := t.TempDir() + "/" + name + ".bin"
+		if err := os.WriteFile(damagedPath, damaged, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := Verify(damagedPath); !errors.Is(err, ErrCorruptGraph) {
+			t.Errorf("%s: Verify = %v, want ErrCorruptGraph", name, err)
+		}
+		if restored, err := Load(damagedPath); !errors.Is(err, ErrCorruptGraph) || len(restored.Nodes) != 0 {
+			t.Errorf("%s: Load = %d nodes, %v; want an empty graph and ErrCorruptGraph", name, len(restored.Nodes), err)
+		}
+	}
+
+	restored, err := Load(path)
+	if err != nil || len(restored.Nodes) != len(g.Nodes) || len(restored.OSMIDs) != len(g.OSMIDs) {
+		t.Errorf("Load = %d nodes, %v; want %d nodes", len(restored.Nodes), err, len(g.Nodes))
+	}
+}
+
+func TestLoadSubgraph(t *testing.T) {
+	g := GridGraph(20, 20, 500)
+	g.OSMIDs = make([]int64, len(g.Nodes))
+	for id := range g.OSMIDs {
+		g.OSMIDs[id] = int64(1000 + id)
+	}
+	dir := t.TempDir()
+	tiled, plain := dir+"/tiled.bin", dir+"/plain.bin"
+	if err := g.SerializeWithOptions(tiled, SerializeOptions{Compression: CompressionZstd, TileLevel: 13}); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Serialize(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := Verify(tiled); err != nil {
+		t.Fatalf("Verify = %v", err)
+	}
+	restored, err := Load(tiled)
+	if err != nil || !reflect.DeepEqual(restored.Nodes, g.Nodes) || !reflect.DeepEqual(restored.OutgoingEdges, g.OutgoingEdges) ||
+		!reflect.DeepEqual(restored.OSMIDs, g.OSMIDs) {
+		t.Fatalf("Load of tiled file differs from the serialized graph: %v", err)
+	}
+
+	a, b := nodeCoordinate(g.Nodes[0]), nodeCoordinate(g.Nodes[5*20+5])
+	const margin = 0.001 // about 100 m, a fifth of the spacing
+	box := BoundingBox{
+		Min: Coordinate{Lat: min(a.Lat, b.Lat) - margin, Lng: min(a.Lng, b.Lng) - margin},
+		Max: Coordinate{Lat: max(a.Lat, b.Lat) + margin, Lng: max(a.Lng, b.Lng) + margin},
+	}
+	sub, original, err := LoadSubgraph(tiled, box)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sub.Nodes) != 36 || len(original) != 36 {
+		t.Fatalf("got %d nodes, want 36", len(sub.Nodes))
+	}
+	for id, o := range original {
+		if sub.OSMIDs[id] != g.OSMIDs[o] || sub.Nodes[id].Location != g.Nodes[o].Location {
+			t.Errorf("node %d does not match node %d of the graph", id, o)
+		}
+		for _, e := range sub.OutgoingEdges[id] {
+			if !hasEdge(g, o, original[e.ID]) {
+				t.Errorf("edge %d -> %d is not in the graph", o, original[e.ID])
+			}
+		}
+	}
+	if len(sub.OutgoingEdges[0]) != 2 {
+		t.Errorf("corner node has %d edges, want 2", len(sub.OutgoingEdges[0]))
+	}
+
+	fromPlain, plainOriginal, err := LoadSubgraph(plain, box)
+	if err != nil || !reflect.DeepEqual(fromPlain, sub) || !reflect.DeepEqual(plainOriginal, original) {
+		t.Errorf("subgraph of the untiled file differs from the tiled one: %v", err)
+	}
+}
+
+func TestGraph_Subgraph(t *testing.T) {
+	g := GridGraph(4, 4, 100)
+	if err := g.SetNodeProperty(6, "zone", "a"); err != nil {
+		t.Fatal(err)
+	}
+	sub, original := g.Subgraph([]int32{6, 5, 9, 5, 42})
+	if !reflect.DeepEqual(original, []int32{5, 6, 9}) {
+		t.Fatalf("original = %v, want [5 6 9]", original)
+	}
+	for id, n := range sub.Nodes {
+		if n.ID != int32(id) || n.Location != g.Nodes[original[id]].Location {
+			t.Errorf("node %d = %+v, want the location of node %d", id, n, original[id])
+		}
+	}
+	// 5 - 6 and 5 - 9 are neighbours on the grid, 6 and 9 are not.
+	if len(sub.OutgoingEdges[0]) != 2 || len(sub.OutgoingEdges[1]) != 1 || len(sub.IncomingEdges[2]) != 1 {
+		t.Errorf("edges = %v, want 5 joined to 6 and 9 only", sub.OutgoingEdges)
+	}
+	if zone, ok := sub.NodeProperties(1).Get("zone"); !ok || zone != "a" {
+		t.Errorf("zone of node 1 = %q, %v; want a, true", zone, ok)
+	}
+	if _, ok := NewDijkstra(Criteria{Source: []int32{1}}).Run(sub).Costs[2]; !ok {
+		t.Error("9 cannot be reached from 6 through 5 in the subgraph")
+	}
+
+	c := nodeCoordinate(g.Nodes[5])
+	square := GeoJSONPolygon{Rings: [][][]float64{{
+		{c.Lng - 0.0001, c.Lat - 0.0001}, {c.Lng + 0.0001, c.Lat - 0.0001},
+		{c.Lng + 0.0001, c.Lat + 0.0001}, {c.Lng - 0.0001, c.Lat + 0.0001},
+	}}}
+	if sub, original := g.SubgraphInPolygon(square); len(sub.Nodes) != 1 || original[0] != 5 {
+		t.Errorf("SubgraphInPolygon kept %v, want [5]", original)
+	}
+}
+
+func TestGraph_SerializeWithOptions(t *testing.T) {
+	g := GridGraph(10, 10, 100)
+	g.OSMIDs = make([]int64, len(g.Nodes))
+	plain := t.TempDir() + "/plain.bin"
+	if err := g.Serialize(plain); err != nil {
+		t.Fatal(err)
+	}
+	plainInfo, err := os.Stat(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, opts := range map[string]SerializeOptions{
+		"gzip":       {Compression: CompressionGzip},
+		"zstd":       {Compression: CompressionZstd},
+		"delta":      {Delta: true},
+		"zstd+delta": {Compression: CompressionZstd, Delta: true},
+	} {
+		path := t.TempDir() + "/" + name + ".bin"
+		if err := g.SerializeWithOptions(path, opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err := Verify(path); err != nil {
+			t.Errorf("%s: Verify = %v", name, err)
+		}
+		restored, err := Load(path)
+		if err != nil {
+			t.Fatalf("%s: Load = %v", name, err)
+		}
+		if !reflect.DeepEqual(restored.Nodes, g.Nodes) || !reflect.DeepEqual(restored.OutgoingEdges, g.OutgoingEdges) ||
+			!reflect.DeepEqual(restored.IncomingEdges, g.IncomingEdges) || !reflect.DeepEqual(restored.OSMIDs, g.OSMIDs) {
+			t.Errorf("%s: restored graph differs from the serialized one", name)
+		}
+		if info, err := os.Stat(path); err != nil || info.Size() >= plainInfo.Size() {
+			t.Errorf("%s: file is not smaller than the plain %d bytes", name, plainInfo.Size())
+		}
+	}
+}
+
+func TestGraph_EdgeIterators(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	center := int32(4)
+
+	visited := 0
+	g.ForEachOutgoing(center, func(e Edge) bool {
+		visited++
+		return visited < 2
+	})
+	if visited != 2 {
+		t.Fatalf("ForEachOutgoing visited %d edges after being stopped at 2", visited)
+	}
+
+	for i, e := range g.Outgoing(center) {
+		if g.OutgoingEdges[center][i].ID != e.ID {
+			t.Fatalf("Outgoing yielded edge %d at position %d, expected %d", e.ID, i, g.OutgoingEdges[center][i].ID)
+		}
+	}
+	incoming := 0
+	for _, e := range g.Incoming(center) {
+		if e.ID == center {
+			t.Fatal("Incoming yielded a self loop")
+		}
+		incoming++
+	}
+	if incoming != len(g.IncomingEdges[center]) {
+		t.Fatalf("Incoming yielded %d edges, expected %d", incoming, len(g.IncomingEdges[center]))
+	}
+}
+
+func TestCheckNodeCount(t *testing.T) {
+	for _, tc := range []struct {
+		n        int64
+		overflow bool
+	}{
+		{n: 0},
+		{n: MaxNodes},
+		{n: MaxNodes + 1, overflow: true},
+		{n: math.MaxInt64, overflow: true},
+	} {
+		err := checkNodeCount(tc.n)
+		if tc.overflow && !errors.Is(err, ErrTooManyNodes) {
+			t.Errorf("%d nodes: got %v, expected ErrTooManyNodes", tc.n, err)
+		}
+		if !tc.overflow && err != nil {
+			t.Errorf("%d nodes: unexpected error %v", tc.n, err)
+		}
+	}
+}
+
+func TestGraphBuilder_Concurrent(t *testing.T) {
+	expected := GridGraph(20, 20, 100)
+	b := NewGraphBuilder()
+	for _, n := range expected.Nodes {
+		if _, err := b.AddNode(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for from := w; from < len(expected.Nodes); from += 8 {
+				for _, e := range expected.OutgoingEdges[from] {
+					if err := b.RelateNodes(int32(from), e.ID, e.Weight, LeftToRight, e.Metadata); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	g, err := b.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.SortEdges()
+	if !reflect.DeepEqual(g.OutgoingEdges, expected.OutgoingEdges) || !reflect.DeepEqual(g.IncomingEdges, expected.IncomingEdges) {
+		t.Fatal("built graph differs from the sequentially built one")
+	}
+	if _, err := b.AddNode(Node{}); !errors.Is(err, ErrBuilderFinalized) {
+		t.Fatalf("got %v after Finalize, expected ErrBuilderFinalized", err)
+	}
+}
+
+func TestGraph_AssignZones(t *testing.T) {
+	g := GridGraph(4, 4, 100)
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	rectangle := func(id string, minX, maxX float64) GeoJSONPolygon {
+		ring := make([][]float64, 0, 5)
+		for _, corner := range [][2]float64{{minX, -50}, {maxX, -50}, {maxX, 350}, {minX, 350}, {minX, -50}} {
+			lat, lng := MetersToLatLng(x0+corner[0], y0+corner[1])
+			ring = append(ring, []float64{lng, lat})
+		}
+		return GeoJSONPolygon{ID: id, Rings: [][][]float64{ring}}
+	}
+	fc := geojson.NewFeatureCollection()
+	for _, p := range []GeoJSONPolygon{rectangle("west", -50, 150), rectangle("east", 150, 250)} {
+		f := geojson.NewPolygonFeature(p.Rings)
+		f.SetProperty("name", p.ID)
+		fc.AddFeature(f)
+	}
+	polygons, err := PolygonsFromGeoJSON(fc, "name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zones, err := g.AssignZones(polygons)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zones["west"]) != 8 || len(zones["east"]) != 4 {
+		t.Fatalf("got %d west and %d east nodes, expected 8 and 4", len(zones["west"]), len(zones["east"]))
+	}
+	if zone, ok := g.Zone(5); !ok || zone != "west" {
+		t.Errorf("node 5 is in zone %q, %v; want west", zone, ok)
+	}
+	if _, ok := g.Zone(3); ok {
+		t.Error("node 3 lies outside every zone")
+	}
+}
+
+func TestGraph_AssignBoundaries(t *testing.T) {
+	g := GridGraph(1, 4, 100)
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	b := NewBoundaryBuilder()
+	// Two countries split at x = 150 m, each outlined by two ways, one of them drawn backwards.
+	country := func(id int64, code string, minX, maxX float64) {
+		b.AddRelation(&osmpbf.Relation{ID: id, Tags: map[string]string{BoundaryKey: Administrative, AdminLevel: "2", ISO3166Country: code},
+			Members: []osmpbf.Member{{ID: id * 10, Type: osmpbf.WayType, Role: "outer"}, {ID: id*10 + 1, Type: osmpbf.WayType, Role: "outer"}}})
+		b.AddWay(&osmpbf.Way{ID: id * 10, NodeIDs: []int64{id * 10, id*10 + 1, id*10 + 2}})
+		b.AddWay(&osmpbf.Way{ID: id*10 + 1, NodeIDs: []int64{id * 10, id*10 + 3, id*10 + 2}})
+		for i, c := range [][2]float64{{minX, -50}, {maxX, -50}, {maxX, 50}, {minX, 50}} {
+			lat, lng := MetersToLatLng(x0+c[0], y0+c[1])
+			b.AddNode(&osmpbf.Node{ID: id*10 + int64(i), Lat: lat, Lon: lng})
+		}
+	}
+	country(1, "CO", -50, 150)
+	country(2, "EC", 150, 350)
+	boundaries := b.Boundaries()
+	if len(boundaries) != 2 || boundaries[0].Code != "CO" || len(boundaries[0].Rings) != 1 {
+		t.Fatalf("got %+v, expected CO and EC with one ring each", boundaries)
+	}
+
+	g.AssignBoundaries(boundaries)
+	if e := g.OutgoingEdges[1][len(g.OutgoingEdges[1])-1]; e.ID != 2 || e.Metadata.Country != "EC" {
+		t.Fatalf("got edge 1 -> %d in %q, expected the edge to 2 to enter EC", e.ID, e.Metadata.Country)
+	}
+	r := NewDijkstra(Criteria{Source: []int32{0}, Country: "CO"}).Run(g)
+	if _, err := r.Costs.GetCost(1); err != nil {
+		t.Fatal("node 1 unreachable within CO")
+	}
+	if _, err := r.Costs.GetCost(2); err == nil {
+		t.Fatal("reached node 2, across the border")
+	}
+}
+
+func TestEdgeDirectionFromWay(t *testing.T) {
+	tests := []struct {
+		tags     map[string]string
+		profile  TravelProfile
+		expected EdgeDirection
+	}{
+		{map[string]string{Oneway: Yes}, ProfileCar, LeftToRight},
+		{map[string]string{Oneway: Yes}, ProfileBike, LeftToRight},
+		{map[string]string{Oneway: Yes}, ProfileFoot, Bidirectional},
+		{map[string]string{Oneway: Yes, OnewayBicycle: No}, ProfileCar, LeftToRight},
+		{map[string]string{Oneway: Yes, OnewayBicycle: No}, ProfileBike, Bidirectional},
+		{map[string]string{Oneway: Yes, Cycleway: OppositeLane}, ProfileBike, Bidirectional},
+		{map[string]string{Oneway: Yes, CyclewayLeft: OppositeTrack}, ProfileBike, Bidirectional},
+		{map[string]string{Junction: Roundabout, Cycleway: Lane}, ProfileBike, LeftToRight},
+		{map[string]string{OnewayBicycle: Yes}, ProfileBike, LeftToRight},
+		{map[string]string{OnewayBicycle: Yes}, ProfileCar, Bidirectional},
+	}
+	for _, tc := range tests {
+		if got := edgeDirectionFromWay(osmpbf.Way{Tags: tc.tags}, tc.profile); got != tc.expected {
+			t.Errorf("%s %v: got %v, expected %v", tc.profile, tc.tags, got, tc.expected)
+		}
+	}
+}
+
+func TestTurnRestrictions(t *testing.T) {
+	//     4
+	//     |
+	// 1 - 2 - 3
+	g := EmptyGraph()
+	nodes := map[int64]int32{1: 0, 2: 0, 3: 0, 4: 0}
+	for id, c := range map[int64][2]float64{1: {6.2, -75.581}, 2: {6.2, -75.58}, 3: {6.2, -75.579}, 4: {6.201, -75.58}} {
+		buildNode(&g, &osmpbf.Node{ID: id, Lat: c[0], Lon: c[1]}, nodes, nil)
+	}
+	ways := make(map[int64][]int32)
+	for id, ids := range map[int64][]int64{10: {1, 2}, 20: {2, 4}, 30: {2, 3}} {
+		buildWay(&g, &osmpbf.Way{ID: id, NodeIDs: ids, Tags: map[string]string{Highway: Residential}}, nodes, ways, nil)
+	}
+
+	restrictions := NewTurnRestrictions()
+	ctx := RelationContext{Graph: &g, nodes: nodes, ways: ways}
+	relation := func(id int64, restriction string, from, to int64) *osmpbf.Relation {
+		return &osmpbf.Relation{ID: id, Tags: map[string]string{TypeKey: Restriction, Restriction: restriction}, Members: []osmpbf.Member{
+			{ID: from, Type: osmpbf.WayType, Role: "from"}, {ID: 2, Type: osmpbf.NodeType, Role: "via"}, {ID: to, Type: osmpbf.WayType, Role: "to"}}}
+	}
+	for _, r := range []*osmpbf.Relation{relation(1, "no_left_turn", 10, 20), relation(2, "only_straight_on", 30, 10),
+		{ID: 3, Tags: map[string]string{TypeKey: "route"}}} {
+		if err := handleRelation(r, []RelationHandler{restrictions}, ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if restrictions.Len() != 2 {
+		t.Fatalf("got %d restricted turns, expected 2", restrictions.Len())
+	}
+
+	a, b, c, d := nodes[1], nodes[2], nodes[3], nodes[4]
+	for _, tc := range []struct {
+		from, to int32
+		allowed  bool
+	}{{a, d, false}, {a, c, true}, {c, a, true}, {c, d, false}, {d, a, true}} {
+		if _, ok := restrictions.TurnCost(tc.from, b, tc.to); ok != tc.allowed {
+			t.Errorf("turn %d -> %d -> %d: got allowed %t, expected %t", tc.from, b, tc.to, ok, tc.allowed)
+		}
+	}
+
+	failing := RelationHandlerFunc(func(*osmpbf.Relation, RelationContext) error { return ErrEdgeNotFound })
+	if err := handleRelation(relation(4, "no_u_turn", 10, 10), []RelationHandler{failing}, ctx); !errors.Is(err, ErrEdgeNotFound) {
+		t.Fatalf("got %v, expected the error of the handler", err)
+	}
+}
+
+func TestKDTree_HaversineMetric(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	metric := HaversineMetric{}
+	vectors := make([]Vector, 0)
+	for i := 0; i < 600; i++ {
+		lat, lng := rnd.Float64()*160-80, rnd.Float64()*360-180
+		if i%3 == 0 { // crowd the antimeridian and the high latitudes
+			lat, lng = 55+rnd.Float64()*25, 175+rnd.Float64()*10
+			if lng >= 180 {
+				lng -= 360
+			}
+		}
+		vectors = append(vectors, NewVector(i, []float64{lat, lng}))
+	}
+	points := append([]Vector(nil), vectors...)
+	tree := BuildKDTreeWithMetric(vectors, metric)
+
+	for q := 0; q < 200; q++ {
+		target := NewVector(-1, []float64{rnd.Float64()*160 - 80, rnd.Float64()*360 - 180})
+		if q%2 == 0 { // next to the antimeridian, on either side
+			target.Components = []float64{60 + rnd.Float64()*15, 179.5 - 359*float64(q%4/2)}
+		}
+		best := math.Inf(1)
+		within := 0
+		for _, p := range points {
+			d := metric.Distance(target, p)
+			best = math.Min(best, d)
+			if d <= 500_000 {
+				within++
+			}
+		}
+		if _, d := tree.FindNearest(target); math.Abs(d-best) > 1e-6 {
+			t.Fatalf("FindNearest(%v) at %.0f m, expected the closest point at %.0f m", target.Components, d, best)
+		}
+		if got := len(tree.RangeQuery(target, 500_000)); got != within {
+			t.Fatalf("RangeQuery(%v) found %d points, expected %d", target.Components, got, within)
+		}
+	}
+}
+
+func TestEdgeIndex(t *testing.T) {
+	g := RandomGeometricGraph(400, 5000, 400, 3)
+	// A long edge whose endpoints are far from the middle of the area.
+	a, b := g.Nodes[0], g.Nodes[1]
+	g.RelateNodes(a, b, 1, LeftToRight, MetaData{Distance: DistanceMeters(s2.CellID(a.Location), s2.CellID(b.Location))})
+	index := g.BuildEdgeIndex()
+
+	edges := 0
+	for _, out := range g.OutgoingEdges {
+		edges += len(out)
+	}
+	if index.Len() != edges {
+		t.Fatalf("Len() = %d, expected %d", index.Len(), edges)
+	}
+
+	rnd := rand.New(rand.NewSource(5))
+	x0, y0 := LatLngToMeters(SyntheticOrigin.Lat, SyntheticOrigin.Lng)
+	for q := 0; q < 50; q++ {
+		lat, lng := MetersToLatLng(x0+rnd.Float64()*5000, y0+rnd.Float64()*5000)
+		c := Coordinate{Lat: lat, Lng: lng}
+		x, y := LatLngToMeters(lat, lng)
+		distances := make([]float64, 0, edges)
+		for from := range g.OutgoingEdges {
+			for _, e := range g.OutgoingEdges[from] {
+				distances = append(distances, g.projectOntoEdge(NewVector(-1, []float64{x, y}), int32(from), e).Distance)
+			}
+		}
+		sort.Float64s(distances)
+		snaps := index.Nearest(c, 3)
+		if len(snaps) != 3 {
+			t.Fatalf("Nearest(%v, 3) returned %d edges", c, len(snaps))
+		}
+		for i, snap := range snaps {
+			if math.Abs(snap.Distance-distances[i]) > 0.01 {
+				t.Fatalf("edge %d nearest to %v at %.2f m, expected %.2f m", i, c, snap.Distance, distances[i])
+			}
+		}
+
+		box := BoundingBox{Min: c, Max: Coordinate{Lat: c.Lat + 0.005, Lng: c.Lng + 0.005}}
+		expected := 0
+		for from := range g.OutgoingEdges {
+			p := nodeCoordinate(g.Nodes[from])
+			for _, e := range g.OutgoingEdges[from] {
+				q := nodeCoordinate(g.Nodes[e.ID])
+				if math.Min(p.Lat, q.Lat) <= box.Max.Lat && math.Max(p.Lat, q.Lat) >= box.Min.Lat &&
+					math.Min(p.Lng, q.Lng) <= box.Max.Lng && math.Max(p.Lng, q.Lng) >= box.Min.Lng {
+					expected++
+				}
+			}
+		}
+		if got := len(index.InBox(box)); got != expected {
+			t.Fatalf("InBox(%v) returned %d edges, expected %d", box, got, expected)
+		}
+	}
+}
+
+func TestBuildKDTree_KeepsInput(t *testing.T) {
+	rnd := rand.New(rand.NewSource(11))
+	vectors := make([]Vector, 0)
+	for i := 0; i < 500; i++ {
+		// Few distinct values, so many vectors are equal along an axis.
+		vectors = append(vectors, NewVector(i, []float64{float64(rnd.Intn(20)), float64(rnd.Intn(20)), rnd.Float64()}))
+	}
+	input := append([]Vector(nil), vectors...)
+	tree := BuildKDTree(vectors)
+	if !reflect.DeepEqual(vectors, input) {
+		t.Fatal("BuildKDTree reordered its input")
+	}
+
+	for q := 0; q < 100; q++ {
+		target := NewVector(-1, []float64{rnd.Float64() * 20, rnd.Float64() * 20, rnd.Float64()})
+		best, within := math.Inf(1), 0
+		for _, v := range vectors {
+			d := EuclideanMetric{}.Distance(target, v)
+			best = math.Min(best, d)
+			if d <= 3 {
+				within++
+			}
+		}
+		if _, d := tree.FindNearest(target); d != best {
+			t.Fatalf("FindNearest(%v) at %f, expected %f", target.Components, d, best)
+		}
+		if got := len(tree.RangeQuery(target, 3)); got != within {
+			t.Fatalf("RangeQuery(%v) found %d vectors, expected %d", target.Components, got, within)
+		}
+	}
+}
+
+func TestKDTree_FindNearestWhere(t *testing.T) {
+	rnd := rand.New(rand.NewSource(13))
+	vectors := make([]Vector, 0)
+	for i := 0; i < 300; i++ {
+		vectors = append(vectors, NewVector(i, []float64{rnd.Float64() * 100, rnd.Float64() * 100}))
+	}
+	tree := BuildKDTree(vectors)
+	pred := func(v Vector) bool { return v.ID%7 == 0 }
+
+	for q := 0; q < 100; q++ {
+		target := NewVector(-1, []float64{rnd.Float64() * 100, rnd.Float64() * 100})
+		best := math.Inf(1)
+		for _, v := range vectors {
+			if pred(v) {
+				best = math.Min(best, EuclideanMetric{}.Distance(target, v))
+			}
+		}
+		v, d, ok := tree.FindNearestWhere(target, pred)
+		if !ok || !pred(v) || d != best {
+			t.Fatalf("FindNearestWhere(%v) = %d at %f, %v, expected a multiple of 7 at %f", target.Components, v.ID, d, ok, best)
+		}
+	}
+	if _, _, ok := tree.FindNearestWhere(vectors[0], func(Vector) bool { return false }); ok {
+		t.Error("expected no vector to satisfy a predicate always false")
+	}
+	if _, _, ok := BuildKDTree(nil).FindNearestWhere(vectors[0], pred); ok {
+		t.Error("expected no vector in an empty tree")
+	}
+}
+
+func TestGraph_Hash(t *testing.T) {
+	g := GridGraph(3, 3, 100)
+	hash := g.Hash()
+	if len(hash) != 64 || GridGraph(3, 3, 100).Hash() != hash {
+		t.Fatalf("got hash %q, expected the same 64 characters for identical graphs", hash)
+	}
+
+	path := t.TempDir() + "/grid.bin"
+	if err := g.Serialize(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Hash() != hash {
+		t.Fatal("a serialized graph should keep its hash once loaded")
+	}
+
+	changed := GridGraph(3, 3, 100)
+	changed.OutgoingEdges[0][0].Metadata.Properties = Properties{"surface": "gravel"}
+	if changed.Hash() == hash {
+		t.Fatal("changing an edge property should change the hash")
+	}
+	changed = GridGraph(3, 3, 100)
+	changed.OutgoingEdges[4][1].Weight++
+	if changed.Hash() == hash {
+		t.Fatal("changing a weight should change the hash")
+	}
+}
+
+// pbfWay is a way of a PBF file written by writeTestPBF. Ways without tags are residential roads.
+type pbfWay struct {
+	id   int64
+	refs []int64
+	tags map[string]string
+}
+
+// writeTestPBF writes an uncompressed OSM PBF file holding the given nodes, as [id, lat, lon] with
+// coordinates in 1e-7 degrees, followed by the given ways.
+func writeTestPBF(t *testing.T, path string, nodes [][3]int64, ways []pbfWay) {
+	block := func(kind string, data []byte) []byte {
+		blob := appendVarintField(appendBytesField(nil, 1, data), 2, uint64(len(data)))
+		header := appendVarintField(appendBytesField(nil, 1, []byte(kind)), 3, uint64(len(blob)))
+		return append(append(binary.BigEndian.AppendUint32(nil, uint32(len(header))), header...), blob...)
+	}
+	file := block("OSMHeader", appendBytesField(nil, 4, []byte("OsmSchema-V0.6")))
+
+	table, index := []string{""}, map[string]uint32{}
+	intern := func(s string) uint32 {
+		if _, ok := index[s]; !ok {
+			index[s] = uint32(len(table))
+			table = append(table, s)
+		}
+		return index[s]
+	}
+	nodeGroup := make([]byte, 0)
+	for _, n := range nodes {
+		node := appendVarintField(nil, 1, zigzag(n[0]))
+		node = appendVarintField(node, 8, zigzag(n[1]))
+		node = appendVarintField(node, 9, zigzag(n[2]))
+		nodeGroup = appendBytesField(nodeGroup, 1, node)
+	}
+	wayGroup := make([]byte, 0)
+	for _, w := range ways {
+		refs, previous := make([]byte, 0), int64(0)
+		for _, ref := range w.refs {
+			refs = binary.AppendUvarint(refs, zigzag(ref-previous))
+			previous = ref
+		}
+		tags := w.tags
+		if tags == nil {
+			tags = map[string]string{Highway: Residential}
+		}
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		keyIDs, valueIDs := make([]uint32, 0, len(keys)), make([]uint32, 0, len(keys))
+		for _, k := range keys {
+			keyIDs, valueIDs = append(keyIDs, intern(k)), append(valueIDs, intern(tags[k]))
+		}
+		way := appendVarintField(nil, 1, uint64(w.id))
+		way = appendBytesField(way, 2, packUint32(keyIDs))
+		way = appendBytesField(way, 3, packUint32(valueIDs))
+		wayGroup = appendBytesField(wayGroup, 3, appendBytesField(way, 8, refs))
+	}
+	stringTable := make([]byte, 0)
+	for _, s := range table {
+		stringTable = appendBytesField(stringTable, 1, []byte(s))
+	}
+	primitive := appendBytesField(nil, 1, stringTable)
+	primitive = appendBytesField(primitive, 2, nodeGroup)
+	primitive = appendBytesField(primitive, 2, wayGroup)
+	file = append(file, block("OSMData", primitive)...)
+	if err := os.WriteFile(path, file, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	t.Cleanup(func() { SetLogger(nil) })
+
+	dir := t.TempDir()
+	path := dir + "/extract.osm.pbf"
+	writeTestPBF(t, path, [][3]int64{{1, 62000000, -755800000}, {2, 62010000, -755800000}}, []pbfWay{{id: 1, refs: []int64{1, 2}}})
+	if _, err := BuildGraph(path); err != nil {
+		t.Fatal(err)
+	}
+	if Write(dir+"/content.json", []int{1, 2, 3}) == "" {
+		t.Fatal("expected the file to be written")
+	}
+
+	records := make(map[string]map[string]any)
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+		records[record[slog.MessageKey].(string)] = record
+	}
+	built, ok := records["graph built"]
+	if !ok || built["file"] != path || built["nodes"] != float64(2) || built["ways"] != float64(1) {
+		t.Fatalf("got %v, expected a graph built record with 2 nodes and 1 way of %s", built, path)
+	}
+	if duration, ok := built["duration"].(float64); !ok || duration <= 0 {
+		t.Fatalf("got duration %v, expected a positive duration", built["duration"])
+	}
+	if written, ok := records["file written"]; !ok || written["bytes"] != float64(len("[1,2,3]")) {
+		t.Fatalf("got %v, expected a file written record of %d bytes", written, len("[1,2,3]"))
+	}
+
+	SetLogger(nil)
+	if getLogger() != slog.Default() {
+		t.Fatal("expected SetLogger(nil) to restore slog.Default()")
+	}
+}
+
+func TestSilentLogger(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	SetLogger(SilentLogger())
+	t.Cleanup(func() {
+		SetLogger(nil)
+		slog.SetDefault(previous)
+	})
+
+	dir := t.TempDir()
+	path := dir + "/extract.osm.pbf"
+	writeTestPBF(t, path, [][3]int64{{1, 62000000, -755800000}, {2, 62010000, -755800000}}, []pbfWay{{id: 1, refs: []int64{1, 2}}})
+	if _, err := BuildGraph(path); err != nil {
+		t.Fatal(err)
+	}
+	Write(dir+"/content.json", []int{1, 2, 3})
+	if buf.Len() != 0 {
+		t.Fatalf("got %q, expected the silent logger to emit nothing", buf.String())
+	}
+}
+
+func TestBuildGraph_FerryAndTollTags(t *testing.T) {
+	nodes := [][3]int64{{1, 62000000, -755800000}, {2, 62010000, -755800000}, {3, 62020000, -755800000}, {4, 62030000, -755800000}}
+	ways := []pbfWay{
+		{id: 1, refs: []int64{1, 2}},
+		{id: 2, refs: []int64{2, 3}, tags: map[string]string{RouteKey: Ferry}},
+		{id: 3, refs: []int64{3, 4}, tags: map[string]string{Highway: Primary, Toll: Yes}},
+	}
+	path := t.TempDir() + "/extract.osm.pbf"
+	writeTestPBF(t, path, nodes, ways)
+	g, err := BuildGraphWithOptions(path, BuildOptions{Deterministic: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[int64]MetaData{
+		1: {RoadType: Residential},
+		2: {RoadType: Ferry, Ferry: true},
+		3: {RoadType: Primary, Toll: true},
+	}
+	seen := make(map[int64]bool)
+	for _, edges := range g.OutgoingEdges {
+		for _, e := range edges {
+			want := expected[e.Metadata.WayID]
+			if e.Metadata.RoadType != want.RoadType || e.Metadata.Ferry != want.Ferry || e.Metadata.Toll != want.Toll {
+				t.Errorf("way %d: got road type %q, ferry %t and toll %t, expected %q, %t and %t", e.Metadata.WayID,
+					e.Metadata.RoadType, e.Metadata.Ferry, e.Metadata.Toll, want.RoadType, want.Ferry, want.Toll)
+			}
+			seen[e.Metadata.WayID] = true
+		}
+	}
+	if len(seen) != len(ways) {
+		t.Fatalf("got edges of ways %v, expected edges of all %d ways", seen, len(ways))
+	}
+}
+
+func TestBuildGraphWithOptions_Deterministic(t *testing.T) {
+	nodes := [][3]int64{{30, 62000000, -755800000}, {10, 62010000, -755800000}, {20, 62010000, -755790000}, {40, 62000000, -755790000}}
+	ways := []pbfWay{{id: 7, refs: []int64{10, 20, 40}}, {id: 5, refs: []int64{30, 10}}}
+	dir := t.TempDir()
+	path, shuffled := dir+"/extract.osm.pbf", dir+"/shuffled.osm.pbf"
+	writeTestPBF(t, path, nodes, ways)
+	writeTestPBF(t, shuffled, [][3]int64{nodes[3], nodes[2], nodes[1], nodes[0]}, []pbfWay{ways[1], ways[0]})
+
+	build := func(path string, opts BuildOptions) Graph {
+		g, err := BuildGraphWithOptions(path, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return g
+	}
+	deterministic := BuildOptions{Deterministic: true}
+	g := build(path, deterministic)
+	edges := 0
+	for _, list := range g.OutgoingEdges {
+		edges += len(list)
+	}
+	if len(g.Nodes) != 4 || edges != 6 {
+		t.Fatalf("got %d nodes and %d edges, expected 4 nodes and 6 edges", len(g.Nodes), edges)
+	}
+	if !reflect.DeepEqual(g.OSMIDs, []int64{10, 20, 30, 40}) {
+		t.Fatalf("got OSM IDs %v, expected the nodes sorted by OSM ID", g.OSMIDs)
+	}
+	for _, other := range []Graph{build(path, deterministic), build(shuffled, deterministic)} {
+		if other.Hash() != g.Hash() || !reflect.DeepEqual(other.OSMIDs, g.OSMIDs) {
+			t.Fatalf("got OSM IDs %v and hash %s, expected %v and %s", other.OSMIDs, other.Hash(), g.OSMIDs, g.Hash())
+		}
+	}
+
+	// Without the option, node IDs follow the order of the file.
+	if a, b := build(path, BuildOptions{}), build(shuffled, BuildOptions{}); a.Hash() == b.Hash() {
+		t.Fatal("expected the order of the files to change the graphs built without Deterministic")
+	}
+}
+
+// bucketClient is an in-memory object store counting the bytes downloaded by ranged reads.
+type bucketClient struct {
+	objects    map[string][]byte
+	downloaded int64
+}
+
+func (c *bucketClient) Size(_ context.Context, key string) (int64, error) {
+	b, ok := c.objects[key]
+	if !ok {
+		return 0, fs.ErrNotExist
+	}
+	return int64(len(b)), nil
+}
+
+func (c *bucketClient) ReadRange(_ context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	c.downloaded += length
+	return io.NopCloser(bytes.NewReader(c.objects[key][offset : offset+length])), nil
+}
+
+func (c *bucketClient) Upload(_ context.Context, key string, r io.Reader) error {
+	b, err := io.ReadAll(r)
+	c.objects[key] = b
+	return err
+}
+
+func TestStorage(t *testing.T) {
+	g := GridGraph(20, 20, 500)
+	client := &bucketClient{objects: make(map[string][]byte)}
+	bucket := ObjectStorage{Client: client, Prefix: "graphs/"}
+	if err := g.SerializeTo(bucket, "grid.bin", SerializeOptions{TileLevel: 13}); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyFrom(bucket, "grid.bin"); err != nil {
+		t.Fatalf("VerifyFrom = %v", err)
+	}
+	restored, err := LoadFrom(bucket, "grid.bin")
+	if err != nil || restored.Hash() != g.Hash() {
+		t.Fatalf("LoadFrom differs from the serialized graph: %v", err)
+	}
+
+	client.downloaded = 0
+	a, b := nodeCoordinate(g.Nodes[0]), nodeCoordinate(g.Nodes[2*20+2])
+	box := BoundingBox{
+		Min: Coordinate{Lat: min(a.Lat, b.Lat) - 0.001, Lng: min(a.Lng, b.Lng) - 0.001},
+		Max: Coordinate{Lat: max(a.Lat, b.Lat) + 0.001, Lng: max(a.Lng, b.Lng) + 0.001},
+	}
+	sub, _, err := LoadSubgraphFrom(bucket, "grid.bin", box)
+	if err != nil || len(sub.Nodes) != 9 {
+		t.Fatalf("got %d nodes, want 9: %v", len(sub.Nodes), err)
+	}
+	if size := int64(len(client.objects["graphs/grid.bin"])); client.downloaded >= size {
+		t.Fatalf("downloaded %d bytes of %d, expected only the tiles in the box", client.downloaded, size)
+	}
+	if _, err := LoadFrom(bucket, "missing.bin"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, expected fs.ErrNotExist", err)
+	}
+
+	memory := NewMemoryStorage()
+	if err := WriteTilesTo(g, memory, DefaultTileLevel); err != nil {
+		t.Fatal(err)
+	}
+	tg, err := OpenTilesFrom(memory, 1)
+	if err != nil || tg.Len() != len(g.Nodes) {
+		t.Fatalf("got %d tiled nodes, want %d: %v", tg.Len(), len(g.Nodes), err)
+	}
+	if _, err := OpenTilesFrom(NewMemoryStorage(), 1); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("got %v, expected fs.ErrNotExist", err)
+	}
+}
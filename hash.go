@@ -0,0 +1,149 @@
+package graph_search
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"sort"
+)
+
+// graphHashVersion prefixes the hashed content, so a change of the encoding below changes every hash
+// instead of silently colliding with hashes computed by older versions.
+const graphHashVersion = "graph_search/hash/v1"
+
+// Hash returns a canonical SHA-256 hash of the content of the graph: its nodes with their locations,
+// ranks, OSM IDs and properties, and its outgoing edges in order with their weights and every metadata
+// field. Graphs with the same content have the same hash whatever the machine or the order maps are
+// iterated in, so it verifies that builds are identical and derives cache keys for preprocessing artifacts such as contraction hierarchies, landmark tables or spatial indexes.
+// Incoming edges mirror the outgoing ones and are not hashed.
+//
+// Returns:
+//   - string: The hash as 64 hexadecimal characters
+func (g Graph) Hash() string {
+	h := graphHasher{hash: sha256.New()}
+	h.string(graphHashVersion)
+	h.uint64(uint64(len(g.Nodes)))
+	for i, n := range g.Nodes {
+		h.uint64(uint64(n.ID))
+		h.uint64(n.Location)
+		h.uint64(uint64(n.Rank))
+		if i < len(g.OSMIDs) {
+			h.uint64(uint64(g.OSMIDs[i]))
+		} else {
+			h.uint64(0)
+		}
+		if i < len(g.Properties) {
+			h.properties(g.Properties[i])
+		} else {
+			h.properties(nil)
+		}
+	}
+	h.uint64(uint64(len(g.OutgoingEdges)))
+	for _, edges := range g.OutgoingEdges {
+		h.uint64(uint64(len(edges)))
+		for _, e := range edges {
+			h.uint64(uint64(e.ID))
+			h.float32(e.Weight)
+			h.metadata(e.Metadata)
+		}
+	}
+	return hex.EncodeToString(h.hash.Sum(nil))
+}
+
+// graphHasher writes values into a hash in a fixed-size or length-prefixed binary form, so no two
+// different sequences of values produce the same bytes.
+type graphHasher struct {
+	hash hash.Hash
+	buf  [8]byte
+}
+
+func (h *graphHasher) uint64(v uint64) {
+	binary.LittleEndian.PutUint64(h.buf[:], v)
+	h.hash.Write(h.buf[:])
+}
+
+func (h *graphHasher) float32(v float32) {
+	h.uint64(uint64(math.Float32bits(v)))
+}
+
+func (h *graphHasher) bool(v bool) {
+	if v {
+		h.uint64(1)
+	} else {
+		h.uint64(0)
+	}
+}
+
+func (h *graphHasher) string(s string) {
+	h.uint64(uint64(len(s)))
+	h.hash.Write([]byte(s))
+}
+
+// properties writes the attributes sorted by key, a nil map and an empty one being the same.
+func (h *graphHasher) properties(p Properties) {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h.uint64(uint64(len(keys)))
+	for _, k := range keys {
+		h.string(k)
+		h.string(p[k])
+	}
+}
+
+func (h *graphHasher) windows(windows []TimeWindow) {
+	h.uint64(uint64(len(windows)))
+	for _, w := range windows {
+		h.uint64(uint64(w.Days))
+		h.uint64(uint64(w.Start))
+		h.uint64(uint64(w.End))
+	}
+}
+
+func (h *graphHasher) metadata(m MetaData) {
+	h.float32(m.Speed)
+	h.float32(m.Distance)
+	h.float32(m.Duration)
+	h.string(m.RoadType)
+	h.string(m.Name)
+	h.uint64(uint64(m.WayID))
+	h.string(m.Surface)
+	h.string(m.Smoothness)
+	h.uint64(uint64(m.Cycleway))
+	h.uint64(uint64(m.Lanes))
+
+	h.bool(m.Access != nil)
+	if m.Access != nil {
+		h.windows(m.Access.Closed)
+		h.windows(m.Access.OpenOnly)
+	}
+
+	h.string(m.Country)
+	h.string(m.Region)
+
+	h.bool(m.Profile != nil)
+	if m.Profile != nil {
+		for _, speed := range m.Profile {
+			h.float32(speed)
+		}
+	}
+	h.bool(m.Weekly != nil)
+	if m.Weekly != nil {
+		h.string(string(m.Weekly.Runs))
+	}
+
+	h.bool(m.Ferry)
+	h.bool(m.Toll)
+	h.float32(m.MaxWeight)
+	h.float32(m.MaxHeight)
+	h.float32(m.MaxWidth)
+	h.bool(m.NoHGV)
+	h.bool(m.NoHazmat)
+	h.float32(m.Capacity)
+	h.float32(m.TravelTimeVariance)
+	h.properties(m.Properties)
+}
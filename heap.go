@@ -16,21 +16,63 @@ type HNode struct {
 	Depth    int32
 	Previous int32
 	Dist     float32
+
+	// Estimate is the weighted lower bound on the cost left to reach the target of A* searches, 0 for
+	// plain Dijkstra searches. Queues order HNodes by Cost + Estimate.
+	Estimate float32
 }
 
 type HNodes []HNode
 
+// Comparator reports whether a must leave the heap before b.
+type Comparator func(a, b HNode) bool
+
+// ByCost orders HNodes by cost plus estimate, breaking ties by depth, then by accumulated distance and finally by
+// value, so that equal cost paths are always settled in the same order across runs.
+func ByCost(a, b HNode) bool {
+	if a.Cost+a.Estimate != b.Cost+b.Estimate {
+		return a.Cost+a.Estimate < b.Cost+b.Estimate
+	}
+	if a.Depth != b.Depth {
+		return a.Depth < b.Depth
+	}
+	if a.Dist != b.Dist {
+		return a.Dist < b.Dist
+	}
+	return a.Value < b.Value
+}
+
 // Heap represents a priority heap based on the weight of its HNodes.
 type Heap struct {
 	items HNodes
 	size  int
+	less  Comparator
 }
 
 // Create creates an empty heap of capacity N.
 func Create() *Heap {
+	return CreateWithComparator(ByCost)
+}
+
+// CreateWithComparator creates an empty heap ordered by the given comparator, ByCost if nil.
+func CreateWithComparator(less Comparator) *Heap {
+	if less == nil {
+		less = ByCost
+	}
 	return &Heap{
 		items: make(HNodes, 0),
 		size:  0,
+		less:  less,
+	}
+}
+
+// CreateWithCapacity creates an empty heap able to hold n items before growing, so large searches do
+// not pay for repeated reallocations of the underlying slice.
+func CreateWithCapacity(n int) *Heap {
+	return &Heap{
+		items: make(HNodes, 0, max(n, 0)),
+		size:  0,
+		less:  ByCost,
 	}
 }
 
@@ -39,6 +81,7 @@ func CreateWithValue(value int32) *Heap {
 	h := Heap{
 		items: make(HNodes, 0),
 		size:  0,
+		less:  ByCost,
 	}
 	h.Insert(HNode{
 		Value: value,
@@ -109,7 +152,7 @@ func (h *Heap) parent(i int) HNode { return h.items[parentIndex(i)] }
 // performs a swap and keep moving.
 func (h *Heap) heapifyUp() {
 	i := h.size - 1
-	for h.hasParent(i) && h.parent(i).Cost > h.items[i].Cost {
+	for h.hasParent(i) && h.less(h.items[i], h.parent(i)) {
 		temp := h.items[i]
 		//swap
 		h.items[i] = h.parent(i)
@@ -129,13 +172,13 @@ func (h *Heap) heapifyDown(i int) {
 
 		// if results that the right child is even smaller than the left child,
 		// then that's the smaller child.
-		if h.hasRightChild(i) && h.rightChild(i).Cost < h.leftChild(i).Cost {
+		if h.hasRightChild(i) && h.less(h.rightChild(i), h.leftChild(i)) {
 			smallerChildIndex = rightChildIndex(i)
 		}
 
 		// if the current item is smaller than the smaller of its two children,
 		// then the heap condition is done.
-		if h.items[i].Cost < h.items[smallerChildIndex].Cost {
+		if h.less(h.items[i], h.items[smallerChildIndex]) {
 			break
 		} else {
 			//swap
@@ -147,6 +190,19 @@ func (h *Heap) heapifyDown(i int) {
 	}
 }
 
+// Reset removes every element of the heap, keeping the underlying slice so the heap can be reused by
+// another search without allocating.
+func (h *Heap) Reset() {
+	h.items = h.items[:0]
+	h.size = 0
+}
+
+// IsEmpty returns true if the heap has no elements.
 func (h *Heap) IsEmpty() bool {
 	return h.size == 0
 }
+
+// Len returns the number of elements in the heap.
+func (h *Heap) Len() int {
+	return h.size
+}
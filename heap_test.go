@@ -0,0 +1,51 @@
+package graph_search
+
+import "testing"
+
+func TestHeap_TieBreaking(t *testing.T) {
+	h := Create()
+	for _, n := range []HNode{
+		{Value: 4, Cost: 2, Depth: 3},
+		{Value: 3, Cost: 2, Depth: 1},
+		{Value: 2, Cost: 1, Depth: 5},
+		{Value: 1, Cost: 2, Depth: 1},
+	} {
+		h.Insert(n)
+	}
+
+	expected := []int32{2, 1, 3, 4}
+	for _, v := range expected {
+		min, _ := h.Min()
+		if min.Value != v {
+			t.Fatalf("got %d, expected %d", min.Value, v)
+		}
+		_ = h.DeleteMin()
+	}
+	if !h.IsEmpty() {
+		t.Fatal("heap should be empty")
+	}
+}
+
+func TestHeap_ResetReusesCapacity(t *testing.T) {
+	h := CreateWithCapacity(64)
+	for i := int32(0); i < 64; i++ {
+		h.Insert(HNode{Value: i, Cost: float32(64 - i)})
+	}
+	h.Reset()
+	if !h.IsEmpty() || cap(h.items) < 64 {
+		t.Fatalf("got %d items and capacity %d after reset, expected 0 and at least 64", h.Len(), cap(h.items))
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		h.Reset()
+		for i := int32(0); i < 64; i++ {
+			h.Insert(HNode{Value: i, Cost: float32(i % 7)})
+		}
+		for !h.IsEmpty() {
+			_ = h.DeleteMin()
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("got %.0f allocations per reuse, expected none", allocs)
+	}
+}
@@ -0,0 +1,156 @@
+package graph_search
+
+import (
+	"fmt"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// EdgeUsage counts how many routes of a batch travel every edge of a graph, showing which corridors the
+// queries concentrate on.
+type EdgeUsage struct {
+	graph  Graph
+	Counts [][]int64 // Counts[v][i] is the number of routes along the edge g.OutgoingEdges[v][i]
+	Routed int       // Number of queries answered with a route
+	Failed int       // Number of queries without a route, e.g., unreachable targets
+}
+
+// BatchFlow answers many origin-destination queries concurrently with RouteBatch and counts the routes
+// traveling every edge. When nodes are linked by parallel edges, the route is counted on the cheapest one.
+//
+// Parameters:
+//   - pairs: []ODPair - The queries to answer
+//   - concurrency: int - Maximum number of queries running at the same time, runtime.GOMAXPROCS(0) if not positive
+//
+// Returns:
+//   - *EdgeUsage: The number of routes along every edge
+func (e *Engine) BatchFlow(pairs []ODPair, concurrency int) *EdgeUsage {
+	u := &EdgeUsage{graph: e.graph, Counts: make([][]int64, len(e.graph.OutgoingEdges))}
+	for v, edges := range e.graph.OutgoingEdges {
+		u.Counts[v] = make([]int64, len(edges))
+	}
+	for _, result := range e.RouteBatch(pairs, concurrency) {
+		if result.Err != nil {
+			u.Failed++
+			continue
+		}
+		u.Routed++
+		u.Add(result.Route.Nodes)
+	}
+	return u
+}
+
+// Add counts a path given by its nodes, e.g., a route computed outside of BatchFlow.
+func (u *EdgeUsage) Add(path []int32) {
+	for k := 1; k < len(path); k++ {
+		best := -1
+		for i, e := range u.graph.OutgoingEdges[path[k-1]] {
+			if e.ID == path[k] && (best < 0 || e.Weight < u.graph.OutgoingEdges[path[k-1]][best].Weight) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			u.Counts[path[k-1]][best]++
+		}
+	}
+}
+
+// Max returns the largest count of an edge, 0 if no route was counted.
+func (u *EdgeUsage) Max() int64 {
+	m := int64(0)
+	for _, counts := range u.Counts {
+		for _, c := range counts {
+			m = max(m, c)
+		}
+	}
+	return m
+}
+
+// GeoJSON renders the used edges as a FeatureCollection of LineStrings, colored as a heatmap from blue
+// (least used) to red (most used). Every feature carries the endpoints of its edge, its count and its
+// count relative to the most used edge as properties.
+//
+// Returns:
+//   - *geojson.FeatureCollection: One feature per edge traveled by at least one route
+func (u *EdgeUsage) GeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	highest := u.Max()
+	for from, counts := range u.Counts {
+		for i, count := range counts {
+			if count == 0 {
+				continue
+			}
+			to := u.graph.OutgoingEdges[from][i].ID
+			a, b := u.graph.Nodes[from].GetPoint(), u.graph.Nodes[to].GetPoint()
+			f := geojson.NewLineStringFeature([][]float64{
+				{a.Lng.Degrees(), a.Lat.Degrees()},
+				{b.Lng.Degrees(), b.Lat.Degrees()},
+			})
+			ratio := float64(count) / float64(highest)
+			f.SetProperty("from", from)
+			f.SetProperty("to", to)
+			f.SetProperty("count", count)
+			f.SetProperty("ratio", ratio)
+			f.SetProperty("stroke", heatColor(ratio))
+			fc.AddFeature(f)
+		}
+	}
+	return fc
+}
+
+// Table returns the used edges as a table created with NewEdgeUsageTable, ready to be written as
+// GeoParquet.
+//
+// Returns:
+//   - *GeoTable: One line per edge traveled by at least one route
+//   - error: An error if a line could not be added
+func (u *EdgeUsage) Table() (*GeoTable, error) {
+	t := NewEdgeUsageTable()
+	for from, counts := range u.Counts {
+		for i, count := range counts {
+			if count == 0 {
+				continue
+			}
+			if err := t.AddEdgeUsage(u.graph, int32(from), u.graph.OutgoingEdges[from][i].ID, count); err != nil {
+				return nil, fmt.Errorf("edge usage: %w", err)
+			}
+		}
+	}
+	return t, nil
+}
+
+// UsageTile renders the tile z/x/y of an edge usage heat layer as an MVT (version 2) protobuf. The usage
+// layer holds one LineString per used edge, with its count and its count relative to the most used edge,
+// so the map styles the heat layer from the ratio property.
+//
+// Parameters:
+//   - z, x, y: int - Zoom level and column and row of the tile in the XYZ scheme
+//   - u: *EdgeUsage - The counts to render, computed on the graph of the renderer
+//
+// Returns:
+//   - []byte: The encoded tile, ready to be served as application/vnd.mapbox-vector-tile
+//   - error: ErrInvalidTile if the tile does not exist at that zoom level
+func (tr *TileRenderer) UsageTile(z, x, y int, u *EdgeUsage) ([]byte, error) {
+	if z < 0 || z > 30 || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return nil, fmt.Errorf("%d/%d/%d: %w", z, x, y, ErrInvalidTile)
+	}
+	t := newTileProjection(z, x, y)
+	highest := u.Max()
+	layer := newMVTLayer(MVTUsageLayer)
+	tr.visitEdges(t, func(from int32, pos int, _ Edge, points []tilePoint) {
+		count := u.Counts[from][pos]
+		if count == 0 {
+			return
+		}
+		layer.addLine(points, map[string]interface{}{
+			"count": count,
+			"ratio": float64(count) / float64(highest),
+		})
+	})
+
+	tile := make([]byte, 0)
+	if len(layer.features) > 0 {
+		tile = appendBytesField(tile, 3, layer.encode())
+	}
+	return tile, nil
+}
@@ -0,0 +1,33 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	ErrTooManyNodes = errors.New("too many nodes for 32-bit node IDs")
+)
+
+// NodeID is the type of node identifiers. Nodes, edges, heaps, bitsets and searches index nodes with
+// int32, which caps a graph at MaxNodes nodes; new code should spell node IDs as NodeID so widening it
+// to int64 for planet-scale or edge-expanded graphs stays a local change.
+type NodeID = int32
+
+// MaxNodes is the largest number of nodes a graph can hold without overflowing NodeID.
+const MaxNodes = math.MaxInt32
+
+// checkNodeCount reports whether n nodes fit in NodeID.
+//
+// Parameters:
+//   - n: int64 - Number of nodes about to be created
+//
+// Returns:
+//   - error: ErrTooManyNodes wrapping the count if n exceeds MaxNodes, nil otherwise
+func checkNodeCount(n int64) error {
+	if n > MaxNodes {
+		return fmt.Errorf("%d nodes: %w", n, ErrTooManyNodes)
+	}
+	return nil
+}
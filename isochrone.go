@@ -0,0 +1,265 @@
+package graph_search
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/paulmach/go.geojson"
+)
+
+// DefaultIsochroneCellSize is the side, in projected meters, of the raster cells isochrone polygons are
+// traced on when no cell size is given.
+const DefaultIsochroneCellSize = 100
+
+// Isochrone is the area reachable with a cost between two thresholds: a drive-time ring.
+type Isochrone struct {
+	MinThreshold time.Duration // Lower bound of the ring, exclusive; 0 for the innermost ring
+	Threshold    time.Duration // Upper bound of the ring, inclusive
+
+	// Polygons of the ring. Every polygon is a list of closed rings of coordinates, the exterior ring
+	// first and its holes after it.
+	Polygons [][][]Coordinate
+}
+
+// gridCell identifies a raster cell by its column and row.
+type gridCell struct {
+	x, y int
+}
+
+// IsochroneRings computes the drive-time rings around a source for several thresholds, e.g., 5, 10 and
+// 15 minutes, with a single search bounded by the largest one and weighted by edge travel times. Reached nodes are rasterized on a grid of the given cell size and
+// every cell is assigned to the ring of the cheapest node in or next to it, so the polygons of different
+// rings never overlap: ring k covers the area reachable within thresholds[k] but not within thresholds[k-1].
+//
+// Parameters:
+//   - source: int32 - ID of the node the rings are centered on
+//   - thresholds: []time.Duration - Travel time thresholds, in any order
+//   - cellSize: float64 - Side of the raster cells in projected meters, DefaultIsochroneCellSize if not positive
+//
+// Returns:
+//   - []Isochrone: One ring per threshold, ordered by increasing threshold
+func (g Graph) IsochroneRings(source int32, thresholds []time.Duration, cellSize float64) []Isochrone {
+	if cellSize <= 0 {
+		cellSize = DefaultIsochroneCellSize
+	}
+	sorted := append([]time.Duration(nil), thresholds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rings := make([]Isochrone, len(sorted))
+	for i, t := range sorted {
+		rings[i].Threshold = t
+		if i > 0 {
+			rings[i].MinThreshold = sorted[i-1]
+		}
+	}
+	if len(sorted) == 0 {
+		return rings
+	}
+
+	maxCost := float32(sorted[len(sorted)-1].Seconds())
+	reach := g.reachable(source, maxCost, func(e Edge) float32 { return float32(edgeDuration(e)) })
+	bands := make(map[gridCell]int)
+	for _, v := range reach.Nodes {
+		cost, _ := reach.Cost(v)
+		band := sort.Search(len(sorted), func(i int) bool { return float32(sorted[i].Seconds()) >= cost })
+		p := nodeCoordinate(g.Nodes[v])
+		x, y := LatLngToMeters(p.Lat, p.Lng)
+		center := gridCell{int(math.Floor(x / cellSize)), int(math.Floor(y / cellSize))}
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				c := gridCell{center.x + dx, center.y + dy}
+				if b, ok := bands[c]; !ok || band < b {
+					bands[c] = band
+				}
+			}
+		}
+	}
+
+	for band := range rings {
+		cells := make(map[gridCell]bool)
+		for c, b := range bands {
+			if b == band {
+				cells[c] = true
+			}
+		}
+		rings[band].Polygons = traceCells(cells, cellSize)
+	}
+	return rings
+}
+
+// IsochronesGeoJSON renders drive-time rings as a FeatureCollection with one MultiPolygon per ring,
+// carrying its bounds in seconds as the min_threshold and threshold properties.
+func IsochronesGeoJSON(rings []Isochrone) *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	for _, ring := range rings {
+		polygons := make([][][][]float64, 0, len(ring.Polygons))
+		for _, polygon := range ring.Polygons {
+			lines := make([][][]float64, 0, len(polygon))
+			for _, line := range polygon {
+				points := make([][]float64, 0, len(line))
+				for _, c := range line {
+					points = append(points, []float64{c.Lng, c.Lat})
+				}
+				lines = append(lines, points)
+			}
+			polygons = append(polygons, lines)
+		}
+		f := geojson.NewMultiPolygonFeature(polygons...)
+		f.SetProperty("min_threshold", ring.MinThreshold.Seconds())
+		f.SetProperty("threshold", ring.Threshold.Seconds())
+		fc.AddFeature(f)
+	}
+	return fc
+}
+
+// AddIsochrone appends every polygon of a drive-time ring to a table created with NewIsochroneTable.
+func (t *GeoTable) AddIsochrone(ring Isochrone) error {
+	for _, polygon := range ring.Polygons {
+		if err := t.AddPolygon(polygon, ring.Threshold.Seconds()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// traceCells returns the outline of a set of raster cells as polygons with holes. Every exposed side of
+// a cell becomes a boundary segment oriented with the cell on its left; chaining them yields
+// counter-clockwise exterior rings and clockwise holes.
+func traceCells(cells map[gridCell]bool, cellSize float64) [][][]Coordinate {
+	type segment struct{ from, to gridCell }
+	outgoing := make(map[gridCell][]segment)
+	for c := range cells {
+		corners := []gridCell{{c.x, c.y}, {c.x + 1, c.y}, {c.x + 1, c.y + 1}, {c.x, c.y + 1}}
+		neighbors := []gridCell{{c.x, c.y - 1}, {c.x + 1, c.y}, {c.x, c.y + 1}, {c.x - 1, c.y}}
+		for side, n := range neighbors {
+			if !cells[n] {
+				from, to := corners[side], corners[(side+1)%4]
+				outgoing[from] = append(outgoing[from], segment{from, to})
+			}
+		}
+	}
+
+	starts := make([]gridCell, 0, len(outgoing))
+	for v := range outgoing {
+		starts = append(starts, v)
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		if starts[i].y != starts[j].y {
+			return starts[i].y < starts[j].y
+		}
+		return starts[i].x < starts[j].x
+	})
+
+	exteriors, holes := make([][]gridCell, 0), make([][]gridCell, 0)
+	for _, start := range starts {
+		for len(outgoing[start]) > 0 {
+			loop := []gridCell{start}
+			current := outgoing[start][0]
+			outgoing[start] = outgoing[start][1:]
+			for current.to != start || len(loop) == 1 && current.from != start {
+				loop = append(loop, current.to)
+				options := outgoing[current.to]
+				// At a vertex shared by diagonal cells, turn left so every loop hugs a single component.
+				best := 0
+				for i, o := range options {
+					if turnRank(current.from, current.to, o.to) < turnRank(current.from, current.to, options[best].to) {
+						best = i
+					}
+				}
+				next := options[best]
+				outgoing[current.to] = append(options[:best:best], options[best+1:]...)
+				current = next
+			}
+			loop = simplifyLoop(loop)
+			if loopArea(loop) > 0 {
+				exteriors = append(exteriors, loop)
+			} else {
+				holes = append(holes, loop)
+			}
+		}
+	}
+
+	polygons := make([][][]gridCell, len(exteriors))
+	for i, exterior := range exteriors {
+		polygons[i] = [][]gridCell{exterior}
+	}
+	for _, hole := range holes {
+		// A point just left of the first side of the hole lies in the cells surrounding it.
+		a, b := hole[0], hole[1]
+		dx, dy := float64(b.x-a.x), float64(b.y-a.y)
+		length := math.Hypot(dx, dy)
+		px, py := (float64(a.x+b.x))/2-0.25*dy/length, (float64(a.y+b.y))/2+0.25*dx/length
+		for i, exterior := range exteriors {
+			if containsPoint(exterior, px, py) {
+				polygons[i] = append(polygons[i], hole)
+				break
+			}
+		}
+	}
+
+	result := make([][][]Coordinate, 0, len(polygons))
+	for _, polygon := range polygons {
+		rings := make([][]Coordinate, 0, len(polygon))
+		for _, loop := range polygon {
+			ring := make([]Coordinate, 0, len(loop)+1)
+			for _, v := range append(loop, loop[0]) {
+				lat, lng := MetersToLatLng(float64(v.x)*cellSize, float64(v.y)*cellSize)
+				ring = append(ring, Coordinate{Lat: lat, Lng: lng})
+			}
+			rings = append(rings, ring)
+		}
+		result = append(result, rings)
+	}
+	return result
+}
+
+// turnRank ranks the turn a -> b -> c: 0 for a left turn, 1 for going straight, 2 for a right turn
+// and 3 for going back.
+func turnRank(a, b, c gridCell) int {
+	cross := (b.x-a.x)*(c.y-b.y) - (b.y-a.y)*(c.x-b.x)
+	dot := (b.x-a.x)*(c.x-b.x) + (b.y-a.y)*(c.y-b.y)
+	switch {
+	case cross > 0:
+		return 0
+	case cross < 0:
+		return 2
+	case dot > 0:
+		return 1
+	}
+	return 3
+}
+
+// simplifyLoop removes the vertices of a closed loop lying in the middle of straight sides.
+func simplifyLoop(loop []gridCell) []gridCell {
+	simplified := make([]gridCell, 0, len(loop))
+	for i, v := range loop {
+		previous, next := loop[(i+len(loop)-1)%len(loop)], loop[(i+1)%len(loop)]
+		if turnRank(previous, v, next) != 1 {
+			simplified = append(simplified, v)
+		}
+	}
+	return simplified
+}
+
+// loopArea returns the signed area of a closed loop, positive when counter-clockwise.
+func loopArea(loop []gridCell) int {
+	area := 0
+	for i, v := range loop {
+		w := loop[(i+1)%len(loop)]
+		area += v.x*w.y - w.x*v.y
+	}
+	return area
+}
+
+// containsPoint reports whether a point lies inside a closed loop, by ray casting.
+func containsPoint(loop []gridCell, px, py float64) bool {
+	inside := false
+	for i, v := range loop {
+		w := loop[(i+1)%len(loop)]
+		vx, vy, wx, wy := float64(v.x), float64(v.y), float64(w.x), float64(w.y)
+		if (vy > py) != (wy > py) && px < vx+(py-vy)*(wx-vx)/(wy-vy) {
+			inside = !inside
+		}
+	}
+	return inside
+}
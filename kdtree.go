@@ -6,7 +6,8 @@ import (
 )
 
 type KDTree struct {
-	root *node
+	root   *node
+	metric Metric
 }
 
 // node represents a node in the k-d tree.
@@ -27,26 +28,51 @@ type node struct {
 // Returns:
 //   - A pointer to the constructed KDTree.
 //
-// Time Complexity: O(n log n), where n is the number of input vectors.
-// Space Complexity: O(n), as the tree stores all input points.
+// The vectors are copied into the tree; the order of the slice is left untouched.
+//
+// Time Complexity: O(k·n log n), where n is the number of input vectors and k their number of components.
+// Space Complexity: O(k·n), as the construction keeps the vectors sorted along every axis.
 
 func BuildKDTree(vectors []Vector) *KDTree {
+	return BuildKDTreeWithMetric(vectors, EuclideanMetric{})
+}
+
+// BuildKDTreeWithMetric constructs a KDTree like BuildKDTree, measuring distances with the given metric,
+// e.g., HaversineMetric for points given as latitudes and longitudes.
+//
+// Parameters:
+//   - vectors: A slice of Vector to build the tree from.
+//   - metric: The metric of RangeQuery and FindNearest, EuclideanMetric if nil.
+//
+// Returns:
+//   - A pointer to the constructed KDTree.
+func BuildKDTreeWithMetric(vectors []Vector, metric Metric) *KDTree {
+	if metric == nil {
+		metric = EuclideanMetric{}
+	}
 	return &KDTree{
-		root: build(vectors, 0),
+		root:   build(vectors),
+		metric: metric,
 	}
 }
 
-// build constructs a k-d tree recursively from a slice of vectors.
-// It returns the root node of the constructed (sub)tree.
+// Metric returns the metric the tree measures distances with.
+func (t *KDTree) Metric() Metric {
+	return t.metric
+}
+
+// build constructs a k-d tree from a slice of vectors and returns its root node. The caller's slice is
+// left untouched: the vectors are sorted once along every axis into arrays of positions, and every level
+// of the recursion splits these arrays around the median instead of sorting again, so building takes
+// O(k·n log n) time for n vectors of k components and O(k·n) extra space.
 //
 // Parameters:
 //   - vectors: A slice of Vector to build the tree from.
-//   - depth: The current depth in the tree, used to determine the splitting axis.
 //
 // Returns:
-//   - A pointer to the root node of the constructed (sub)tree.
-func build(vectors []Vector, depth int) *node {
-	// Base case: if the input slice is empty, return nil (empty subtree)
+//   - A pointer to the root node of the constructed tree.
+func build(vectors []Vector) *node {
+	// Base case: if the input slice is empty, return nil (empty tree)
 	if len(vectors) == 0 {
 		return nil
 	}
@@ -54,6 +80,58 @@ func build(vectors []Vector, depth int) *node {
 	// Determine the number of dimensions (k) from the first vector
 	k := len(vectors[0].Components)
 
+	// Sort the positions of the vectors along every axis, breaking ties by position so that the vectors
+	// equal along an axis are always split the same way in every array.
+	b := &kdBuilder{
+		vectors: vectors,
+		left:    make([]bool, len(vectors)),
+		scratch: make([]int32, len(vectors)),
+	}
+	sorted := make([][]int32, k)
+	for axis := range sorted {
+		positions := make([]int32, len(vectors))
+		for i := range positions {
+			positions[i] = int32(i)
+		}
+		sort.Slice(positions, func(i, j int) bool {
+			return b.less(positions[i], positions[j], axis)
+		})
+		sorted[axis] = positions
+	}
+	return b.build(sorted, 0)
+}
+
+// kdBuilder holds the state shared by the levels of the construction of a k-d tree.
+type kdBuilder struct {
+	vectors []Vector
+	left    []bool  // left[i] is set when vectors[i] goes to the left subtree of the node being built
+	scratch []int32 // Buffer of the partitions
+}
+
+// less orders the vectors at two positions along an axis, breaking ties by position.
+func (b *kdBuilder) less(i, j int32, axis int) bool {
+	u, v := b.vectors[i].Components[axis], b.vectors[j].Components[axis]
+	if u != v {
+		return u < v
+	}
+	return i < j
+}
+
+// build constructs the subtree of the vectors whose positions are in sorted, where sorted[axis] lists
+// them in order along every axis.
+//
+// Parameters:
+//   - sorted: The positions of the vectors of the subtree, sorted along every axis.
+//   - depth: The current depth in the tree, used to determine the splitting axis.
+//
+// Returns:
+//   - A pointer to the root node of the constructed (sub)tree.
+func (b *kdBuilder) build(sorted [][]int32, depth int) *node {
+	n := len(sorted[0])
+	if n == 0 {
+		return nil
+	}
+
 	// Calculate the current axis to split on, cycling through dimensions
 	// Example:
 	// If we have 3D vectors (x, y, z) and the current depth is 5:
@@ -66,22 +144,45 @@ func build(vectors []Vector, depth int) *node {
 	// depth 2: z-axis (2 % 3 = 2)
 	// depth 3: x-axis (3 % 3 = 0)
 	// and so on...
-	axis := depth % k
+	axis := depth % len(sorted)
 
-	// Sort the vectors based on their component values in the current axis
-	sort.Slice(vectors, func(i, j int) bool {
-		return vectors[i].Components[axis] < vectors[j].Components[axis]
-	})
+	// The median along the axis is read from its sorted array, the vectors before it go left
+	medianIndex := n / 2
+	median := sorted[axis][medianIndex]
+	for i, position := range sorted[axis] {
+		b.left[position] = i < medianIndex
+	}
 
-	// Find the median point
-	medianIndex := len(vectors) / 2
-	medianPoint := vectors[medianIndex]
+	// Split the arrays of the other axes stably, so both halves stay sorted: the left positions first,
+	// then the right ones, leaving the median out at the end
+	left := make([][]int32, len(sorted))
+	right := make([][]int32, len(sorted))
+	for a, positions := range sorted {
+		if a == axis {
+			left[a], right[a] = positions[:medianIndex], positions[medianIndex+1:]
+			continue
+		}
+		l, r := 0, medianIndex
+		for _, position := range positions {
+			switch {
+			case position == median:
+			case b.left[position]:
+				b.scratch[l] = position
+				l++
+			default:
+				b.scratch[r] = position
+				r++
+			}
+		}
+		copy(positions, b.scratch[:n-1])
+		left[a], right[a] = positions[:medianIndex], positions[medianIndex:n-1]
+	}
 
 	// Construct and return the current node
 	return &node{
-		v: medianPoint,                             // Store the median point in this node
-		l: build(vectors[:medianIndex], depth+1),   // Recursively build left subtree
-		r: build(vectors[medianIndex+1:], depth+1), // Recursively build right subtree
+		v: b.vectors[median],       // Store the median point in this node
+		l: b.build(left, depth+1),  // Recursively build left subtree
+		r: b.build(right, depth+1), // Recursively build right subtree
 	}
 }
 
@@ -90,12 +191,12 @@ func build(vectors []Vector, depth int) *node {
 //
 // Parameters:
 //   - center: The center point of the search range.
-//   - radius: The radius of the search range.
+//   - radius: The radius of the search range, in the unit of the metric of the tree.
 //
 // Returns:
 //   - A slice of Vector objects representing all points within the specified range.
 func (t *KDTree) RangeQuery(center Vector, radius float64) []Vector {
-	return rangeQuery(t.root, center, radius, 0)
+	return rangeQuery(t.root, center, radius, 0, t.metric)
 }
 
 // squaredDistance calculates the squared Euclidean distance between two vectors.
@@ -124,6 +225,7 @@ func squaredDistance(u, v Vector) float64 {
 //   - center: The center point of the search range.
 //   - radius: The radius of the search range.
 //   - depth: The current depth in the tree, used to determine the splitting axis.
+//   - metric: The metric distances are measured with.
 //
 // Returns:
 //   - A slice of Vector objects representing all points within the specified range.
@@ -139,7 +241,7 @@ func squaredDistance(u, v Vector) float64 {
 //	4. Check if we need to search right subtree (we do, as 4+2 >= 3)
 //	5. Recursively search both subtrees
 //	6. In the end, return [(3,4), (5,6)] as the result
-func rangeQuery(node *node, center Vector, radius float64, depth int) []Vector {
+func rangeQuery(node *node, center Vector, radius float64, depth int, metric Metric) []Vector {
 	// Base case: if the node is nil, return an empty slice
 	if node == nil {
 		return nil
@@ -153,18 +255,19 @@ func rangeQuery(node *node, center Vector, radius float64, depth int) []Vector {
 	pointsInRange := []Vector{}
 
 	// Check if the current node's point is within the search radius
-	if squaredDistance(node.v, center) <= radius*radius {
+	if metric.Distance(node.v, center) <= radius {
 		pointsInRange = append(pointsInRange, node.v)
 	}
 
 	// Determine whether to search the left and/or right subtrees
-	// Left subtree: search if the hypersphere's left bound is less than or equal to the current node's splitting value
-	if node.l != nil && center.Components[axis]-radius <= node.v.Components[axis] {
-		pointsInRange = append(pointsInRange, rangeQuery(node.l, center, radius, depth+1)...)
+	// A subtree on the other side of the splitting plane is searched if the plane is within the radius
+	split := node.v.Components[axis]
+	crosses := metric.AxisDistance(center, axis, split) <= radius
+	if node.l != nil && (center.Components[axis] <= split || crosses) {
+		pointsInRange = append(pointsInRange, rangeQuery(node.l, center, radius, depth+1, metric)...)
 	}
-	// Right subtree: search if the hypersphere's right bound is greater than or equal to the current node's splitting value
-	if node.r != nil && center.Components[axis]+radius >= node.v.Components[axis] {
-		pointsInRange = append(pointsInRange, rangeQuery(node.r, center, radius, depth+1)...)
+	if node.r != nil && (center.Components[axis] >= split || crosses) {
+		pointsInRange = append(pointsInRange, rangeQuery(node.r, center, radius, depth+1, metric)...)
 	}
 
 	return pointsInRange
@@ -180,12 +283,32 @@ func rangeQuery(node *node, center Vector, radius float64, depth int) []Vector {
 //
 // Returns:
 //   - The nearest vector found in the tree.
-//   - The Euclidean distance between the target and the nearest
+//   - The distance between the target and the nearest vector, measured with the metric of the tree.
 func (t *KDTree) FindNearest(target Vector) (Vector, float64) {
-	best, bestDist := nearest(t.root, target, 0, nil, math.MaxFloat64)
+	best, bestDist := nearest(t.root, target, 0, nil, math.MaxFloat64, t.metric, nil)
 	return best.v, bestDist
 }
 
+// FindNearestWhere finds the nearest neighbor to a target point among the vectors satisfying a predicate,
+// e.g., the closest node with outgoing edges usable by a profile. Vectors failing the predicate are
+// skipped during the search, so callers do not have to reject the nearest vector and search again.
+//
+// Parameters:
+//   - target: The target vector for which we're finding the nearest neighbor.
+//   - pred: Reports whether a vector may be returned.
+//
+// Returns:
+//   - The nearest vector satisfying pred.
+//   - The distance between the target and that vector, measured with the metric of the tree.
+//   - false if no vector of the tree satisfies pred.
+func (t *KDTree) FindNearestWhere(target Vector, pred func(Vector) bool) (Vector, float64, bool) {
+	best, bestDist := nearest(t.root, target, 0, nil, math.MaxFloat64, t.metric, pred)
+	if best == nil {
+		return Vector{}, 0, false
+	}
+	return best.v, bestDist, true
+}
+
 // nearest finds the nearest neighbor to a target point in the k-d tree.
 //
 // This function recursively traverses the k-d tree to find the node that is closest to the target
@@ -196,11 +319,13 @@ func (t *KDTree) FindNearest(target Vector) (Vector, float64) {
 //   - target: The target vector for which we're finding the nearest neighbor.
 //   - depth: The current depth in the tree, used to determine the splitting axis.
 //   - best: The current best (closest) node found so far.
-//   - bestDist: The squared distance to the current best node.
+//   - bestDist: The distance to the current best node.
+//   - metric: The metric distances are measured with.
+//   - pred: Reports whether a vector may be returned, nil to accept every vector.
 //
 // Returns:
 //   - A pointer to the nearest node found.
-//   - The squared distance to the nearest node.
+//   - The distance to the nearest node.
 //
 // Example:
 //
@@ -215,7 +340,7 @@ func (t *KDTree) FindNearest(target Vector) (Vector, float64) {
 //	6. It does, so move to (7,2), compare distance: (6-7)^2 + (5-2)^2 = 10, don't update best
 //	7. Continue this process for remaining nodes
 //	8. In the end, return (5,4) as the nearest neighbor with distance 2
-func nearest(n *node, target Vector, depth int, best *node, bestDist float64) (*node, float64) {
+func nearest(n *node, target Vector, depth int, best *node, bestDist float64, metric Metric, pred func(Vector) bool) (*node, float64) {
 	if n == nil {
 		return best, bestDist
 	}
@@ -223,8 +348,8 @@ func nearest(n *node, target Vector, depth int, best *node, bestDist float64) (*
 	axis := depth % k
 
 	// Calculate the distance from the target to the current node
-	dist := squaredDistance(n.v, target)
-	if dist < bestDist {
+	dist := metric.Distance(n.v, target)
+	if dist < bestDist && (pred == nil || pred(n.v)) {
 		bestDist = dist
 		best = n
 	}
@@ -241,11 +366,11 @@ func nearest(n *node, target Vector, depth int, best *node, bestDist float64) (*
 	}
 
 	// Recursively search the next subtree
-	best, bestDist = nearest(next, target, depth+1, best, bestDist)
+	best, bestDist = nearest(next, target, depth+1, best, bestDist, metric, pred)
 
 	// Check if we need to search the other subtree
-	if math.Abs(n.v.Components[axis]-target.Components[axis]) < math.Sqrt(bestDist) {
-		best, bestDist = nearest(other, target, depth+1, best, bestDist)
+	if metric.AxisDistance(target, axis, n.v.Components[axis]) < bestDist {
+		best, bestDist = nearest(other, target, depth+1, best, bestDist, metric, pred)
 	}
 
 	return best, bestDist
@@ -0,0 +1,109 @@
+package graph_search
+
+import (
+	"strconv"
+	"strings"
+)
+
+// TurnIndication is the set of directions a lane leads to, as painted arrows on the road. A lane without
+// indication, e.g., turn:lanes=none, has no bit set.
+type TurnIndication uint16
+
+const (
+	LaneLeft TurnIndication = 1 << iota
+	LaneSlightLeft
+	LaneSharpLeft
+	LaneThrough
+	LaneRight
+	LaneSlightRight
+	LaneSharpRight
+	LaneReverse
+	LaneMergeToLeft
+	LaneMergeToRight
+)
+
+// turnIndications maps the values of turn:lanes to their indication, in bit order.
+var turnIndications = []string{
+	"left", "slight_left", "sharp_left", "through", "right", "slight_right", "sharp_right", "reverse",
+	"merge_to_left", "merge_to_right",
+}
+
+// String renders the indication the way turn:lanes does, e.g., "left;through", or "none".
+func (t TurnIndication) String() string {
+	values := make([]string, 0, 2)
+	for i, v := range turnIndications {
+		if t&(1<<i) != 0 {
+			values = append(values, v)
+		}
+	}
+	if len(values) == 0 {
+		return "none"
+	}
+	return strings.Join(values, ";")
+}
+
+// Bit layout of LaneInfo.
+const (
+	MaxLanes      = 15 // Largest lane count LaneInfo holds
+	MaxTurnLanes  = 6  // Number of lanes whose turn indications LaneInfo holds
+	laneCountBits = 4
+	turnBits      = 10
+)
+
+// LaneInfo holds the lanes of an edge packed in 64 bits: the lane count in the lowest 4 bits, then the
+// TurnIndication of up to MaxTurnLanes lanes in 10 bits each, from the leftmost lane. It is read from
+// the lanes and turn:lanes tags of the way, which describe the lanes in the direction of the way.
+type LaneInfo uint64
+
+// ParseLanes packs the values of the lanes and turn:lanes tags of a way.
+//
+// Parameters:
+//   - lanes: string - Value of the lanes tag, e.g., "3"; the number of lanes of turnLanes when empty
+//   - turnLanes: string - Value of the turn:lanes tag, e.g., "left|through|through;right"
+//
+// Returns:
+//   - LaneInfo: The packed lanes, with a count capped at MaxLanes and the indications of the lanes
+//     beyond MaxTurnLanes dropped. Unknown indications are ignored
+func ParseLanes(lanes, turnLanes string) LaneInfo {
+	var turns []string
+	if turnLanes != "" {
+		turns = strings.Split(turnLanes, "|")
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(lanes))
+	if err != nil || count <= 0 {
+		count = len(turns)
+	}
+	info := LaneInfo(min(count, MaxLanes))
+	for i, lane := range turns[:min(len(turns), MaxTurnLanes)] {
+		var t TurnIndication
+		for _, v := range strings.Split(lane, ";") {
+			for bit, name := range turnIndications {
+				if strings.TrimSpace(v) == name {
+					t |= 1 << bit
+				}
+			}
+		}
+		info |= LaneInfo(t) << (laneCountBits + i*turnBits)
+	}
+	return info
+}
+
+// Count returns the number of lanes, 0 when unknown.
+func (l LaneInfo) Count() int {
+	return int(l & (1<<laneCountBits - 1))
+}
+
+// Turns returns the turn indication of every lane from the leftmost one, limited to MaxTurnLanes lanes.
+//
+// Returns:
+//   - []TurnIndication: The indications, nil when no lane has one
+func (l LaneInfo) Turns() []TurnIndication {
+	if l>>laneCountBits == 0 {
+		return nil
+	}
+	turns := make([]TurnIndication, min(l.Count(), MaxTurnLanes))
+	for i := range turns {
+		turns[i] = TurnIndication(l >> (laneCountBits + i*turnBits) & (1<<turnBits - 1))
+	}
+	return turns
+}
@@ -0,0 +1,43 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrSearchLimit = errors.New("search limit exceeded")
+)
+
+// SearchLimitError reports a search aborted by Criteria.MaxSettledNodes, typically after a bad snap or
+// towards a target disconnected from the source. It wraps ErrSearchLimit.
+type SearchLimitError struct {
+	Limit   int     // The MaxSettledNodes of the criteria
+	Queued  int     // Number of nodes still waiting in the queue when the search was aborted
+	Sources []int32 // Sources of the aborted search
+	Targets []int32 // Targets of the aborted search
+}
+
+func (e *SearchLimitError) Error() string {
+	return fmt.Sprintf("search from %v to %v settled %d nodes with %d still queued: %v", e.Sources, e.Targets, e.Limit, e.Queued, ErrSearchLimit)
+}
+
+func (e *SearchLimitError) Unwrap() error {
+	return ErrSearchLimit
+}
+
+// exceedsLimit reports whether settling one more node would exceed the MaxSettledNodes of the criteria,
+// and records the error returned in the Response when it does.
+func (search *DijkstraSearch) exceedsLimit(settled int) bool {
+	limit := search.criteria.MaxSettledNodes
+	if limit <= 0 || settled < limit {
+		return false
+	}
+	search.err = &SearchLimitError{
+		Limit:   limit,
+		Queued:  search.pq.Len(),
+		Sources: search.criteria.Source,
+		Targets: search.criteria.Targets,
+	}
+	return true
+}
@@ -0,0 +1,29 @@
+package graph_search
+
+import (
+	"io"
+	"log/slog"
+	"sync/atomic"
+)
+
+// logger is the structured logger used by the package, slog.Default() unless replaced with SetLogger.
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger replaces the logger used by the package. Passing nil restores slog.Default().
+// Use SilentLogger to disable logging when embedding the package in a library or service.
+func SetLogger(l *slog.Logger) {
+	logger.Store(l)
+}
+
+// SilentLogger returns a logger that discards every record.
+func SilentLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// getLogger returns the logger currently configured for the package.
+func getLogger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.Default()
+}
@@ -0,0 +1,95 @@
+package graph_search
+
+import "math"
+
+// OneToMany computes the costs from a source to several targets, e.g., a row of a distance matrix, with a
+// single search stopped once the last target is settled (Criteria.AllTargets), so rows do not scan the
+// whole graph once every target is found.
+//
+// Parameters:
+//   - source: int32 - ID of the node the costs are computed from
+//   - targets: []int32 - IDs of the nodes the costs are computed to
+//   - c: Criteria - Restrictions of the search, its Source, Targets and AllTargets are replaced
+//
+// Returns:
+//   - []float32: The cost to every target, in the order of targets, +Inf for unreachable targets
+//   - error: A *SearchLimitError if the search was aborted by c.MaxSettledNodes, nil otherwise
+func (g Graph) OneToMany(source int32, targets []int32, c Criteria) ([]float32, error) {
+	costs := make([]float32, len(targets))
+	for i := range costs {
+		costs[i] = float32(math.Inf(1))
+	}
+	if len(targets) == 0 {
+		return costs, nil
+	}
+	c.Source, c.Targets, c.AllTargets, c.AnyTarget = []int32{source}, targets, true, false
+	response := NewDijkstra(c).Run(g)
+	if response.Err != nil {
+		return costs, response.Err
+	}
+	settled := make(map[int32]bool, len(response.SearchSpace.Nodes))
+	for _, n := range response.SearchSpace.Nodes {
+		settled[n.Rank] = true
+	}
+	for i, t := range targets {
+		if cost, ok := response.Costs[t]; ok && settled[t] {
+			costs[i] = cost
+		}
+	}
+	return costs, nil
+}
+
+// bucketEntry is the cost from a node to a target of a many-to-many query, stored in the bucket of the
+// node.
+type bucketEntry struct {
+	target int
+	cost   float32
+}
+
+// Matrix computes the costs between every source and every target with the bucket-based many-to-many
+// algorithm: an upward backward search from every target leaves its cost in a bucket at every node it
+// reaches, then an upward forward search from every source scans the buckets of the nodes it reaches.
+// Every search only explores the small upward search space of its node, so a 1000x1000 table costs
+// 2000 tiny searches instead of 1000 searches settling the graph.
+//
+// Parameters:
+//   - sources: []int32 - IDs of the nodes of the rows
+//   - targets: []int32 - IDs of the nodes of the columns
+//
+// Returns:
+//   - [][]float32: The cost from sources[i] to targets[j] at [i][j], +Inf when unreachable
+//   - error: ErrNodeNotFound if a node is out of range
+func (m *CCHMetric) Matrix(sources, targets []int32) ([][]float32, error) {
+	c := m.cch
+	for _, ids := range [][]int32{sources, targets} {
+		for _, v := range ids {
+			if v < 0 || int(v) >= len(c.rank) {
+				return nil, ErrNodeNotFound
+			}
+		}
+	}
+
+	buckets := make(map[int32][]bucketEntry)
+	for j, t := range targets {
+		costs, _ := m.upwardSearch(t, m.down)
+		for v, cost := range costs {
+			buckets[v] = append(buckets[v], bucketEntry{target: j, cost: cost})
+		}
+	}
+
+	matrix := make([][]float32, len(sources))
+	for i, s := range sources {
+		row := make([]float32, len(targets))
+		for j := range row {
+			row[j] = float32(math.Inf(1))
+		}
+		costs, _ := m.upwardSearch(s, m.up)
+		for v, cost := range costs {
+			for _, entry := range buckets[v] {
+				row[entry.target] = min(row[entry.target], cost+entry.cost)
+			}
+		}
+		matrix[i] = row
+	}
+	return matrix, nil
+}
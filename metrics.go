@@ -0,0 +1,80 @@
+package graph_search
+
+import "time"
+
+// Metric names reported by the package.
+const (
+	MetricNodesSettled = "graph_search_nodes_settled"
+	MetricQueueSize    = "graph_search_max_queue_size"
+	MetricQueryLatency = "graph_search_query_latency_seconds"
+	MetricSnapDistance = "graph_search_snap_distance_meters"
+	MetricQueries      = "graph_search_queries_total"
+	MetricCacheHits    = "graph_search_route_cache_hits_total"
+)
+
+// Metrics receives measurements from searches and snapping so servers can export them, e.g. by mapping
+// counters and histograms to Prometheus collectors. Implementations must be safe for concurrent use.
+type Metrics interface {
+	// Count adds value to the counter with the given name.
+	Count(name string, value float64)
+
+	// Observe records a sample in the histogram with the given name.
+	Observe(name string, value float64)
+}
+
+// NopMetrics is a Metrics implementation discarding every measurement.
+type NopMetrics struct{}
+
+func (NopMetrics) Count(string, float64)   {}
+func (NopMetrics) Observe(string, float64) {}
+
+// SearchStats summarizes the work done by a search, to compare cost models, heuristics or graph
+// simplifications without a profiler. The settlement order itself is recorded by Criteria.Debug.
+type SearchStats struct {
+	Settled     int           // Number of nodes settled
+	Scanned     int           // Number of edges leaving a settled node that were examined
+	Relaxed     int           // Number of relaxations improving the cost of a node
+	MaxHeapSize int           // Largest number of entries in the priority queue
+	Duration    time.Duration // Wall time of the search
+}
+
+// searchStats accumulates measurements while a search runs.
+type searchStats struct {
+	start        time.Time
+	settled      int
+	scanned      int
+	relaxed      int
+	maxQueueSize int
+}
+
+// newSearchStats starts measuring a search.
+func newSearchStats() searchStats {
+	return searchStats{start: time.Now()}
+}
+
+// observeQueue updates the maximum size reached by the priority queue.
+func (s *searchStats) observeQueue(pq PriorityQueue) {
+	s.maxQueueSize = max(s.maxQueueSize, pq.Len())
+}
+
+// summary returns the measurements accumulated so far.
+func (s searchStats) summary() SearchStats {
+	return SearchStats{
+		Settled:     s.settled,
+		Scanned:     s.scanned,
+		Relaxed:     s.relaxed,
+		MaxHeapSize: s.maxQueueSize,
+		Duration:    time.Since(s.start),
+	}
+}
+
+// report sends the accumulated measurements to m, if not nil.
+func (s searchStats) report(m Metrics) {
+	if m == nil {
+		return
+	}
+	m.Count(MetricQueries, 1)
+	m.Observe(MetricNodesSettled, float64(s.settled))
+	m.Observe(MetricQueueSize, float64(s.maxQueueSize))
+	m.Observe(MetricQueryLatency, time.Since(s.start).Seconds())
+}
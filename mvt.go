@@ -0,0 +1,314 @@
+package graph_search
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/s2"
+)
+
+var (
+	ErrInvalidTile = errors.New("invalid tile coordinates")
+)
+
+const (
+	// MVTExtent is the number of units along the side of a vector tile.
+	MVTExtent = 4096
+
+	// MVTBuffer is the number of units around a tile within which edges are still drawn, so lines crossing
+	// tile borders join seamlessly once clipped by the renderer.
+	MVTBuffer = 64
+
+	// Layer names of the tiles produced by TileRenderer.
+	MVTEdgesLayer  = "edges"
+	MVTRoutesLayer = "routes"
+	MVTUsageLayer  = "usage"
+
+	// mercatorHalfSize is half the side of the Web Mercator square, in projected meters.
+	mercatorHalfSize = math.Pi * 6378137.0
+)
+
+// TileRenderer renders the edges of a graph and computed routes into Mapbox Vector Tiles (MVT), so web
+// maps can display the network straight from the routing server. It indexes the nodes once; every tile
+// then only visits the edges near it.
+type TileRenderer struct {
+	graph   Graph
+	index   *KDTree
+	maxEdge float64 // Projected length of the longest edge, bounding how far an edge crossing a tile can reach
+}
+
+// NewTileRenderer indexes a graph for vector tile rendering.
+//
+// Parameters:
+//   - g: Graph - The graph to render
+//
+// Returns:
+//   - *TileRenderer: The renderer
+func NewTileRenderer(g Graph) *TileRenderer {
+	vectors := make([]Vector, 0, len(g.Nodes))
+	maxEdge := 0.0
+	for _, n := range g.Nodes {
+		x, y := nodeMeters(g, n.ID)
+		vectors = append(vectors, Vector{ID: n.GetID(), Components: []float64{x, y}})
+		for _, e := range g.OutgoingEdges[n.ID] {
+			ex, ey := nodeMeters(g, e.ID)
+			maxEdge = math.Max(maxEdge, math.Hypot(ex-x, ey-y))
+		}
+	}
+	return &TileRenderer{graph: g, index: BuildKDTree(vectors), maxEdge: maxEdge}
+}
+
+// Tile renders the tile z/x/y as an MVT (version 2) protobuf. The edges layer holds one LineString per
+// edge, with its road_type, name and speed; a pair of opposite edges is drawn once. The routes layer holds
+// one LineString per route, with its index in routes, its length in meters and its duration in seconds.
+//
+// Parameters:
+//   - z, x, y: int - Zoom level and column and row of the tile in the XYZ scheme
+//   - routes: ...Route - Routes drawn in the routes layer
+//
+// Returns:
+//   - []byte: The encoded tile, ready to be served as application/vnd.mapbox-vector-tile
+//   - error: ErrInvalidTile if the tile does not exist at that zoom level
+func (tr *TileRenderer) Tile(z, x, y int, routes ...Route) ([]byte, error) {
+	if z < 0 || z > 30 || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return nil, fmt.Errorf("%d/%d/%d: %w", z, x, y, ErrInvalidTile)
+	}
+	t := newTileProjection(z, x, y)
+	g := tr.graph
+
+	edges := newMVTLayer(MVTEdgesLayer)
+	tr.visitEdges(t, func(from int32, _ int, e Edge, points []tilePoint) {
+		if e.ID < from && hasEdge(g, e.ID, from) {
+			return // drawn from the other endpoint
+		}
+		edges.addLine(points, map[string]interface{}{
+			"road_type": e.Metadata.RoadType,
+			"name":      e.Metadata.Name,
+			"speed":     float64(e.Metadata.Speed),
+		})
+	})
+
+	lines := newMVTLayer(MVTRoutesLayer)
+	for i, r := range routes {
+		points := t.project(r.Nodes, g)
+		if len(points) < 2 || !t.intersects(points) {
+			continue
+		}
+		lines.addLine(points, map[string]interface{}{
+			"index":    int64(i),
+			"length":   r.Length(),
+			"duration": r.Duration(),
+		})
+	}
+
+	tile := make([]byte, 0)
+	for _, layer := range []*mvtLayer{edges, lines} {
+		if len(layer.features) > 0 {
+			tile = appendBytesField(tile, 3, layer.encode())
+		}
+	}
+	return tile, nil
+}
+
+// visitEdges calls visit for every edge crossing a tile or its buffer, with its source node, its position
+// in the adjacency list of the source and its projection onto the tile.
+func (tr *TileRenderer) visitEdges(t tileProjection, visit func(from int32, pos int, e Edge, points []tilePoint)) {
+	center := Vector{Components: []float64{t.minX + t.size/2, t.maxY - t.size/2}}
+	radius := t.size*math.Sqrt2/2*(1+2*float64(MVTBuffer)/MVTExtent) + tr.maxEdge
+	for _, v := range tr.index.RangeQuery(center, radius) {
+		from := int32(v.ID)
+		for pos, e := range tr.graph.OutgoingEdges[from] {
+			points := t.project([]int32{from, e.ID}, tr.graph)
+			if len(points) < 2 || !t.intersects(points) {
+				continue
+			}
+			visit(from, pos, e, points)
+		}
+	}
+}
+
+// hasEdge reports whether the graph has an edge from one node to another.
+func hasEdge(g Graph, from, to int32) bool {
+	for _, e := range g.OutgoingEdges[from] {
+		if e.ID == to {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeMeters returns the projected coordinates of a node.
+func nodeMeters(g Graph, id int32) (float64, float64) {
+	p := s2.CellID(g.Nodes[id].Location).LatLng()
+	return LatLngToMeters(p.Lat.Degrees(), p.Lng.Degrees())
+}
+
+// tileProjection maps projected meters to the integer coordinates of a tile.
+type tileProjection struct {
+	minX, maxY, size float64
+}
+
+// tilePoint is a position in tile coordinates, from 0 to MVTExtent inside the tile.
+type tilePoint struct {
+	x, y int64
+}
+
+func newTileProjection(z, x, y int) tileProjection {
+	size := 2 * mercatorHalfSize / float64(int64(1)<<z)
+	return tileProjection{
+		minX: -mercatorHalfSize + float64(x)*size,
+		maxY: mercatorHalfSize - float64(y)*size,
+		size: size,
+	}
+}
+
+// project converts the nodes of a path into tile coordinates, dropping consecutive duplicates.
+func (t tileProjection) project(nodes []int32, g Graph) []tilePoint {
+	points := make([]tilePoint, 0, len(nodes))
+	for _, id := range nodes {
+		mx, my := nodeMeters(g, id)
+		p := tilePoint{
+			x: int64(math.Round((mx - t.minX) / t.size * MVTExtent)),
+			y: int64(math.Round((t.maxY - my) / t.size * MVTExtent)),
+		}
+		if len(points) == 0 || points[len(points)-1] != p {
+			points = append(points, p)
+		}
+	}
+	return points
+}
+
+// intersects reports whether the bounding box of a line overlaps the tile and its buffer.
+func (t tileProjection) intersects(points []tilePoint) bool {
+	minX, minY, maxX, maxY := int64(math.MaxInt64), int64(math.MaxInt64), int64(math.MinInt64), int64(math.MinInt64)
+	for _, p := range points {
+		minX, maxX = min(minX, p.x), max(maxX, p.x)
+		minY, maxY = min(minY, p.y), max(maxY, p.y)
+	}
+	return maxX >= -MVTBuffer && minX <= MVTExtent+MVTBuffer && maxY >= -MVTBuffer && minY <= MVTExtent+MVTBuffer
+}
+
+// mvtLayer accumulates the features of a layer along with its deduplicated keys and values.
+type mvtLayer struct {
+	name     string
+	features [][]byte
+	keys     []string
+	keyIndex map[string]uint32
+	values   [][]byte
+	valIndex map[interface{}]uint32
+}
+
+func newMVTLayer(name string) *mvtLayer {
+	return &mvtLayer{name: name, keyIndex: make(map[string]uint32), valIndex: make(map[interface{}]uint32)}
+}
+
+// addLine appends a LineString feature with the given properties, sorted by key for stable output.
+func (l *mvtLayer) addLine(points []tilePoint, properties map[string]interface{}) {
+	tags := make([]uint32, 0, 2*len(properties))
+	for _, key := range sortedKeys(properties) {
+		tags = append(tags, l.key(key), l.value(properties[key]))
+	}
+
+	// MoveTo the first point, then LineTo the others, with zigzag-encoded deltas.
+	geometry := []uint32{mvtCommand(1, 1)}
+	geometry = append(geometry, uint32(zigzag(points[0].x)), uint32(zigzag(points[0].y)))
+	geometry = append(geometry, mvtCommand(2, len(points)-1))
+	for i := 1; i < len(points); i++ {
+		geometry = append(geometry, uint32(zigzag(points[i].x-points[i-1].x)), uint32(zigzag(points[i].y-points[i-1].y)))
+	}
+
+	feature := appendVarintField(nil, 1, uint64(len(l.features)+1))
+	feature = appendBytesField(feature, 2, packUint32(tags))
+	feature = appendVarintField(feature, 3, 2) // LINESTRING
+	feature = appendBytesField(feature, 4, packUint32(geometry))
+	l.features = append(l.features, feature)
+}
+
+func (l *mvtLayer) key(k string) uint32 {
+	if i, ok := l.keyIndex[k]; ok {
+		return i
+	}
+	l.keyIndex[k] = uint32(len(l.keys))
+	l.keys = append(l.keys, k)
+	return l.keyIndex[k]
+}
+
+func (l *mvtLayer) value(v interface{}) uint32 {
+	if i, ok := l.valIndex[v]; ok {
+		return i
+	}
+	var encoded []byte
+	switch v := v.(type) {
+	case string:
+		encoded = appendBytesField(nil, 1, []byte(v))
+	case float64:
+		encoded = binary.LittleEndian.AppendUint64(appendKey(nil, 3, 1), math.Float64bits(v))
+	case int64:
+		encoded = appendVarintField(nil, 4, uint64(v))
+	case bool:
+		b := uint64(0)
+		if v {
+			b = 1
+		}
+		encoded = appendVarintField(nil, 7, b)
+	}
+	l.valIndex[v] = uint32(len(l.values))
+	l.values = append(l.values, encoded)
+	return l.valIndex[v]
+}
+
+// encode serializes the layer as an MVT Layer message.
+func (l *mvtLayer) encode() []byte {
+	b := appendVarintField(nil, 15, 2)
+	b = appendBytesField(b, 1, []byte(l.name))
+	for _, f := range l.features {
+		b = appendBytesField(b, 2, f)
+	}
+	for _, k := range l.keys {
+		b = appendBytesField(b, 3, []byte(k))
+	}
+	for _, v := range l.values {
+		b = appendBytesField(b, 4, v)
+	}
+	return appendVarintField(b, 5, MVTExtent)
+}
+
+// sortedKeys returns the keys of a property map in increasing order.
+func sortedKeys(properties map[string]interface{}) []string {
+	keys := make([]string, 0, len(properties))
+	for k := range properties {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// mvtCommand encodes a geometry command with its repeat count.
+func mvtCommand(id, count int) uint32 {
+	return uint32(id&0x7) | uint32(count)<<3
+}
+
+func packUint32(values []uint32) []byte {
+	b := make([]byte, 0, len(values))
+	for _, v := range values {
+		b = binary.AppendUvarint(b, uint64(v))
+	}
+	return b
+}
+
+// appendKey appends a protobuf field key.
+func appendKey(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	return binary.AppendUvarint(appendKey(b, field, 0), v)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = binary.AppendUvarint(appendKey(b, field, 2), uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,138 @@
+package graph_search
+
+// OffRouteFixes is the number of consecutive fixes matched outside the active route after which a
+// navigation session considers the vehicle off-route and reroutes. A single stray fix is usually GPS noise.
+const OffRouteFixes = 2
+
+// NavigationUpdate describes the state of a navigation session after a position update.
+type NavigationUpdate struct {
+	Snap      EdgeSnap // Position of the vehicle matched on the graph
+	OnRoute   bool     // Whether the vehicle follows the active route
+	Rerouted  bool     // Whether this update replaced the active route
+	Route     Route    // The active route, from the start of the trip or of the last reroute
+	Remaining float64  // Distance in meters left to the destination along the active route
+}
+
+// NavigationSession follows a vehicle along a route to a destination: it matches every position update
+// onto the graph with a Tracker, tracks the progress along the active route and reroutes when the vehicle
+// leaves it. It is not safe for concurrent use; use one session per vehicle.
+type NavigationSession struct {
+	engine   *Engine
+	tracker  *Tracker
+	target   int32
+	criteria Criteria
+
+	route    Route
+	progress int // Index in route.Edges of the edge the vehicle was last matched on
+	offRoute int // Number of consecutive fixes matched outside the route
+}
+
+// NewNavigationSession plans the initial route of a trip and starts following it.
+//
+// Parameters:
+//   - e: *Engine - Engine answering the routing queries
+//   - source: int32 - Node the trip starts from
+//   - target: int32 - Destination node
+//   - c: Criteria - Restrictions applied to the initial route and every reroute
+//
+// Returns:
+//   - *NavigationSession: The session, positioned at the start of the route
+//   - error: ErrNoRoute or ErrNodeNotFound if the initial route cannot be planned
+func NewNavigationSession(e *Engine, source, target int32, c Criteria) (*NavigationSession, error) {
+	r := e.Route(ODPair{Source: source, Target: target}, c)
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return &NavigationSession{
+		engine:   e,
+		tracker:  NewTracker(e.graph, e.Index()),
+		target:   target,
+		criteria: c,
+		route:    r.Route,
+	}, nil
+}
+
+// Route returns the active route.
+func (s *NavigationSession) Route() Route {
+	return s.route
+}
+
+// Update feeds a new position of the vehicle to the session.
+//
+// When the vehicle is off-route for OffRouteFixes consecutive fixes, a new route is planned from the end
+// of the edge it drives on. Prior work is reused when possible: if that edge leads back onto the rest of
+// the active route, the route is spliced instead of searched, and otherwise the query goes through the
+// engine, whose route cache answers repeated detours.
+//
+// Parameters:
+//   - fix: Coordinate - The latest GPS position of the vehicle
+//
+// Returns:
+//   - NavigationUpdate: The matched position and the state of the route
+//   - error: ErrNoCandidates if the fix is far from any road, or ErrNoRoute if the destination cannot
+//     be reached from the new position; the active route is kept in both cases
+func (s *NavigationSession) Update(fix Coordinate) (NavigationUpdate, error) {
+	snap, err := s.tracker.Update(fix)
+	if err != nil {
+		return NavigationUpdate{}, err
+	}
+	update := NavigationUpdate{Snap: snap}
+	if i, ok := s.routeEdge(snap); ok {
+		s.progress, s.offRoute = i, 0
+		update.OnRoute = true
+	} else if s.offRoute++; s.offRoute >= OffRouteFixes {
+		if err := s.reroute(snap); err != nil {
+			return NavigationUpdate{}, err
+		}
+		update.OnRoute, update.Rerouted = true, true
+	}
+	update.Route, update.Remaining = s.route, s.remaining(snap, update.OnRoute)
+	return update, nil
+}
+
+// routeEdge returns the index of the route edge matching a snap, looking from the current progress on
+// so that routes going twice through the same edge are followed in order.
+func (s *NavigationSession) routeEdge(snap EdgeSnap) (int, bool) {
+	for i := s.progress; i < len(s.route.Edges); i++ {
+		if s.route.Nodes[i] == snap.From && s.route.Nodes[i+1] == snap.To {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// reroute replaces the active route with one starting on the edge the vehicle drives on.
+func (s *NavigationSession) reroute(snap EdgeSnap) error {
+	nodes := []int32{snap.From}
+	rejoined := false
+	for i := s.progress + 1; i < len(s.route.Nodes); i++ {
+		if s.route.Nodes[i] == snap.To {
+			nodes, rejoined = append(nodes, s.route.Nodes[i:]...), true
+			break
+		}
+	}
+	if !rejoined {
+		r := s.engine.Route(ODPair{Source: snap.To, Target: s.target}, s.criteria)
+		if r.Err != nil {
+			return r.Err
+		}
+		nodes = append(nodes, r.Route.Nodes...)
+	}
+	s.route, s.progress, s.offRoute = NewRoute(nodes, s.engine.graph), 0, 0
+	return nil
+}
+
+// remaining returns the distance left to the destination from a matched position.
+func (s *NavigationSession) remaining(snap EdgeSnap, onRoute bool) float64 {
+	distance := 0.0
+	start := s.progress + 1
+	if onRoute {
+		distance = snap.Remaining()
+	} else {
+		start = s.progress
+	}
+	for _, e := range s.route.Edges[min(start, len(s.route.Edges)):] {
+		distance += float64(e.Metadata.Distance)
+	}
+	return distance
+}
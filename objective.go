@@ -0,0 +1,45 @@
+package graph_search
+
+// Objective selects which of the weights precomputed on every edge a search minimizes, so the same graph
+// answers shortest and fastest queries without being rebuilt.
+type Objective int
+
+const (
+	ObjectiveWeight   Objective = iota // Minimize Edge.Weight, whatever the graph was built with
+	ObjectiveDistance                  // Minimize the physical distance in meters
+	ObjectiveTime                      // Minimize the travel time in seconds at static speeds
+)
+
+// Weight returns the cost of an edge under the objective.
+//
+// Parameters:
+//   - e: Edge - The edge to weigh
+//
+// Returns:
+//   - float32: The distance in meters for ObjectiveDistance, the travel time in seconds for ObjectiveTime,
+//     Edge.Weight otherwise
+func (o Objective) Weight(e Edge) float32 {
+	switch o {
+	case ObjectiveDistance:
+		return e.Metadata.Distance
+	case ObjectiveTime:
+		if e.Metadata.Duration > 0 {
+			return e.Metadata.Duration
+		}
+		return float32(edgeDuration(e))
+	default:
+		return e.Weight
+	}
+}
+
+// String returns the name of the objective.
+func (o Objective) String() string {
+	switch o {
+	case ObjectiveDistance:
+		return "distance"
+	case ObjectiveTime:
+		return "time"
+	default:
+		return "weight"
+	}
+}
@@ -0,0 +1,251 @@
+package graph_search
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+// OSRM geometry encodings, matching the values of the geometries parameter of the OSRM HTTP API.
+const (
+	OSRMPolyline  = "polyline"
+	OSRMPolyline6 = "polyline6"
+	OSRMGeoJSON   = "geojson"
+)
+
+// OSRMOptions mirrors the OSRM /route/v1 query parameters that change the shape of the response.
+type OSRMOptions struct {
+	Geometries string // OSRMPolyline (default), OSRMPolyline6 or OSRMGeoJSON
+	Steps      bool   // Whether legs include turn-by-turn steps
+}
+
+// OSRMResponse is the body returned by the OSRM /route/v1 service.
+type OSRMResponse struct {
+	Code      string         `json:"code"`
+	Message   string         `json:"message,omitempty"`
+	Routes    []OSRMRoute    `json:"routes"`
+	Waypoints []OSRMWaypoint `json:"waypoints"`
+}
+
+// OSRMRoute is a route of an OSRM response.
+type OSRMRoute struct {
+	Geometry   json.RawMessage `json:"geometry"`
+	Legs       []OSRMLeg       `json:"legs"`
+	Distance   float64         `json:"distance"`
+	Duration   float64         `json:"duration"`
+	Weight     float64         `json:"weight"`
+	WeightName string          `json:"weight_name"`
+}
+
+// OSRMLeg is the part of an OSRM route between two waypoints.
+type OSRMLeg struct {
+	Steps    []OSRMStep `json:"steps"`
+	Summary  string     `json:"summary"`
+	Distance float64    `json:"distance"`
+	Duration float64    `json:"duration"`
+	Weight   float64    `json:"weight"`
+}
+
+// OSRMStep is a turn-by-turn instruction of an OSRM leg.
+type OSRMStep struct {
+	Distance float64      `json:"distance"`
+	Duration float64      `json:"duration"`
+	Name     string       `json:"name"`
+	Mode     string       `json:"mode"`
+	Maneuver OSRMManeuver `json:"maneuver"`
+}
+
+// OSRMManeuver describes the maneuver at the start of an OSRM step.
+type OSRMManeuver struct {
+	Location      [2]float64 `json:"location"`
+	BearingBefore int        `json:"bearing_before"`
+	BearingAfter  int        `json:"bearing_after"`
+	Type          string     `json:"type"`
+	Modifier      string     `json:"modifier,omitempty"`
+}
+
+// OSRMWaypoint is an input coordinate snapped to the road network.
+type OSRMWaypoint struct {
+	Name     string     `json:"name"`
+	Location [2]float64 `json:"location"`
+	Distance float64    `json:"distance"`
+}
+
+// OSRM converts the result of a search into the OSRM /route/v1 JSON schema, so frontends built for OSRM,
+// such as Leaflet Routing Machine, can consume it unchanged. The route ends at the last node settled by the
+// search, which is the target when the search was run with a single Criteria.Targets entry. Step durations
+// are not tracked per edge by the searches and are distributed in proportion to the step distances.
+//
+// Parameters:
+//   - g: Graph - The graph the search was run on
+//   - opts: OSRMOptions - Geometry encoding and whether to include steps
+//
+// Returns:
+//   - OSRMResponse: A response with code "Ok" and a single route, or code "NoRoute" if the search found none
+func (r Response) OSRM(g Graph, opts OSRMOptions) OSRMResponse {
+	settled := r.SearchSpace.Nodes
+	if len(settled) == 0 {
+		return OSRMResponse{Code: "NoRoute", Message: "Impossible route between points", Routes: []OSRMRoute{}, Waypoints: []OSRMWaypoint{}}
+	}
+	route := r.SearchSpace.Route(int32(len(settled)-1), g)
+	coordinates := route.Coordinates()
+	cost, _ := r.Costs.GetCost(route.Nodes[len(route.Nodes)-1])
+	distance, duration := route.Length(), route.Duration()
+
+	leg := OSRMLeg{Steps: []OSRMStep{}, Summary: routeSummary(route), Distance: distance, Duration: duration, Weight: float64(cost)}
+	if opts.Steps {
+		leg.Steps = osrmSteps(Directions(r, g, route.Nodes[len(route.Nodes)-1]), distance, duration)
+	}
+	first, last := "", ""
+	if len(route.Edges) > 0 {
+		first, last = route.Edges[0].Metadata.Name, route.Edges[len(route.Edges)-1].Metadata.Name
+	}
+	return OSRMResponse{
+		Code: "Ok",
+		Routes: []OSRMRoute{{
+			Geometry:   osrmGeometry(coordinates, opts.Geometries),
+			Legs:       []OSRMLeg{leg},
+			Distance:   distance,
+			Duration:   duration,
+			Weight:     float64(cost),
+			WeightName: "routability",
+		}},
+		Waypoints: []OSRMWaypoint{
+			osrmWaypoint(coordinates[0], first),
+			osrmWaypoint(coordinates[len(coordinates)-1], last),
+		},
+	}
+}
+
+// osrmSteps converts turn-by-turn steps into OSRM steps. Step.Distance is the distance traveled before
+// the maneuver while OSRM expects the distance traveled after it, hence the shift by one.
+func osrmSteps(steps []Step, distance, duration float64) []OSRMStep {
+	result := make([]OSRMStep, 0, len(steps))
+	for i, s := range steps {
+		after := 0.0
+		if i+1 < len(steps) {
+			after = steps[i+1].Distance
+		}
+		stepDuration := 0.0
+		if distance > 0 {
+			stepDuration = duration * after / distance
+		}
+		before := 0.0
+		if i > 0 {
+			before = steps[i-1].Bearing
+		}
+		osrmType, modifier := osrmManeuver(s.Maneuver)
+		result = append(result, OSRMStep{
+			Distance: after,
+			Duration: stepDuration,
+			Name:     s.Name,
+			Mode:     "driving",
+			Maneuver: OSRMManeuver{
+				Location:      [2]float64{s.Location.Lng, s.Location.Lat},
+				BearingBefore: int(math.Round(before)),
+				BearingAfter:  int(math.Round(s.Bearing)),
+				Type:          osrmType,
+				Modifier:      modifier,
+			},
+		})
+	}
+	return result
+}
+
+// osrmManeuver maps a Maneuver to the OSRM maneuver type and modifier.
+func osrmManeuver(m Maneuver) (string, string) {
+	switch m {
+	case Depart, Arrive:
+		return string(m), ""
+	case Continue:
+		return "continue", "straight"
+	case UTurn:
+		return "continue", "uturn"
+	}
+	return "turn", strings.TrimPrefix(string(m), "turn ")
+}
+
+// osrmWaypoint builds the waypoint of a route endpoint, named after the road it lies on.
+func osrmWaypoint(c Coordinate, name string) OSRMWaypoint {
+	return OSRMWaypoint{Name: name, Location: [2]float64{c.Lng, c.Lat}}
+}
+
+// routeSummary names the two longest named roads of a route, as OSRM does in leg summaries.
+func routeSummary(r Route) string {
+	lengths := make(map[string]float64)
+	order := make([]string, 0)
+	for _, e := range r.Edges {
+		if name := e.Metadata.Name; name != "" {
+			if _, seen := lengths[name]; !seen {
+				order = append(order, name)
+			}
+			lengths[name] += float64(e.Metadata.Distance)
+		}
+	}
+	best := make([]string, 0, 2)
+	for _, name := range order {
+		best = append(best, name)
+		for i := len(best) - 1; i > 0 && lengths[best[i]] > lengths[best[i-1]]; i-- {
+			best[i], best[i-1] = best[i-1], best[i]
+		}
+		if len(best) > 2 {
+			best = best[:2]
+		}
+	}
+	return strings.Join(best, ", ")
+}
+
+// osrmGeometry encodes the coordinates of a route in the requested OSRM geometry format.
+func osrmGeometry(coordinates []Coordinate, format string) json.RawMessage {
+	var encoded []byte
+	switch format {
+	case OSRMGeoJSON:
+		line := make([][2]float64, 0, len(coordinates))
+		for _, c := range coordinates {
+			line = append(line, [2]float64{c.Lng, c.Lat})
+		}
+		encoded, _ = json.Marshal(struct {
+			Type        string       `json:"type"`
+			Coordinates [][2]float64 `json:"coordinates"`
+		}{"LineString", line})
+	case OSRMPolyline6:
+		encoded, _ = json.Marshal(EncodePolyline(coordinates, 6))
+	default:
+		encoded, _ = json.Marshal(EncodePolyline(coordinates, 5))
+	}
+	return encoded
+}
+
+// EncodePolyline encodes coordinates with the Google encoded polyline algorithm used by OSRM.
+//
+// Parameters:
+//   - coordinates: []Coordinate - Points of the line
+//   - precision: int - Number of decimal digits kept, 5 for "polyline" and 6 for "polyline6"
+//
+// Returns:
+//   - string: The encoded polyline
+func EncodePolyline(coordinates []Coordinate, precision int) string {
+	factor := math.Pow10(precision)
+	var sb strings.Builder
+	var lastLat, lastLng int64
+	for _, c := range coordinates {
+		lat, lng := int64(math.Round(c.Lat*factor)), int64(math.Round(c.Lng*factor))
+		encodePolylineValue(&sb, lat-lastLat)
+		encodePolylineValue(&sb, lng-lastLng)
+		lastLat, lastLng = lat, lng
+	}
+	return sb.String()
+}
+
+// encodePolylineValue appends a signed delta to an encoded polyline in 5-bit chunks.
+func encodePolylineValue(sb *strings.Builder, v int64) {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	sb.WriteByte(byte(u + 63))
+}
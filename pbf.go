@@ -5,15 +5,37 @@ package graph_search
 
 import (
 	"io"
-	"log"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/golang/geo/s2"
 	"github.com/qedus/osmpbf"
 )
 
+// BuildOptions customizes how BuildGraphWithOptions turns an OSM PBF file into a graph.
+type BuildOptions struct {
+	// Deterministic guarantees that two builds of the same file produce identical graphs: internal node IDs
+	// are assigned in increasing OSM node ID order and adjacency lists are sorted by destination node.
+	// It costs one extra pass over the file.
+	Deterministic bool
+
+	// NodeTags and WayTags list the OSM tags copied into the Properties of nodes and edges, e.g., a zone
+	// or hazard tag the caller wants to read back from search results.
+	NodeTags []string
+	WayTags  []string
+
+	// RelationHandlers receive every relation of the file once its nodes and ways are in the graph, e.g.,
+	// a TurnRestrictions collecting the turn restrictions.
+	RelationHandlers []RelationHandler
+
+	// Boundaries tags the edges with the countries and regions of the administrative boundaries of the
+	// file, see AssignBoundaries. It costs three extra passes over the file.
+	Boundaries bool
+}
+
 // BuildGraph constructs a graph from an OSM PBF file, processing nodes and ways to create a connected road network.
 // It filters ways based on road type tags and builds edges between connected nodes.
 //
@@ -26,11 +48,43 @@ import (
 //   - Nodes with geographical coordinates stored as S2 cell IDs
 //   - Edges with weights based on travel time/distance
 //   - Metadata including speed limits, distances, and road types
-func BuildGraph(path string) Graph {
-	decoder, file := openAndDecodePBF(path)
-	nodes := buildCoverageNodes(path)
+//   - error: nil on success, otherwise the error that interrupted reading or decoding the file.
+//     An empty Graph is returned along with it
+func BuildGraph(path string) (Graph, error) {
+	return BuildGraphWithOptions(path, BuildOptions{})
+}
+
+// BuildGraphWithOptions constructs a graph from an OSM PBF file like BuildGraph, with the given options.
+//
+// Parameters:
+//   - path: string - File path to the OSM PBF file to process
+//   - opts: BuildOptions - Options controlling the build
+//
+// Returns:
+//   - Graph: The constructed graph
+//   - error: nil on success, otherwise the error that interrupted reading or decoding the file
+func BuildGraphWithOptions(path string, opts BuildOptions) (Graph, error) {
+	start := time.Now()
+	nodes, err := buildCoverageNodes(path, validWay)
+	if err != nil {
+		return EmptyGraph(), err
+	}
+	if err := checkNodeCount(int64(len(nodes))); err != nil {
+		return EmptyGraph(), err
+	}
+	g := Graph{Nodes: make([]Node, 0, len(nodes)), OSMIDs: make([]int64, 0, len(nodes))}
+	if opts.Deterministic {
+		if err := buildSortedNodes(&g, path, nodes, opts.NodeTags); err != nil {
+			return EmptyGraph(), err
+		}
+	}
+
+	decoder, file, err := openAndDecodePBF(path)
+	if err != nil {
+		return EmptyGraph(), err
+	}
+	defer file.Close()
 	ways := make(map[int64][]int32)
-	g := Graph{Nodes: make([]Node, 0, len(nodes))}
 
 	for {
 		obj, err := decoder.Decode()
@@ -38,21 +92,92 @@ func BuildGraph(path string) Graph {
 			if err == io.EOF {
 				break
 			}
-			log.Fatal(err)
+			return EmptyGraph(), err
 		}
 		switch obj := obj.(type) {
 		case *osmpbf.Node:
-			buildNode(&g, obj, nodes)
+			if !opts.Deterministic {
+				buildNode(&g, obj, nodes, opts.NodeTags)
+			}
 		case *osmpbf.Way:
 			if validWay(*obj) {
-				buildWay(&g, obj, nodes, ways)
+				buildWay(&g, obj, nodes, ways, opts.WayTags)
+			}
+		case *osmpbf.Relation:
+			ctx := RelationContext{Graph: &g, nodes: nodes, ways: ways}
+			if err := handleRelation(obj, opts.RelationHandlers, ctx); err != nil {
+				return EmptyGraph(), err
 			}
 		}
 	}
+	if opts.Deterministic {
+		g.SortEdges()
+	}
+	if opts.Boundaries {
+		boundaries, err := ReadBoundaries(path)
+		if err != nil {
+			return EmptyGraph(), err
+		}
+		g.AssignBoundaries(boundaries)
+	}
 
-	_ = file.Close()
-	nodes = nil
-	return g
+	getLogger().Info("graph built", "file", path, "nodes", len(g.Nodes), "ways", len(ways), "duration", time.Since(start))
+	return g, nil
+}
+
+// buildSortedNodes adds every valid node found in the file to the graph in increasing OSM ID order,
+// so internal IDs do not depend on the order the decoder yields nodes. Valid nodes missing from the
+// file are removed from the nodes map so ways referencing them skip the missing segments.
+//
+// Parameters:
+//   - g: *Graph - Pointer to the graph being constructed
+//   - path: string - Path to the OSM PBF file
+//   - nodes: map[int64]int32 - Map of valid OSM node IDs, updated with the assigned internal IDs
+//   - tags: []string - OSM tags copied into the node properties
+//
+// Returns:
+//   - error: The error encountered while reading the file, if any
+func buildSortedNodes(g *Graph, path string, nodes map[int64]int32, tags []string) error {
+	d, f, err := openAndDecodePBF(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	locations := make(map[int64]uint64, len(nodes))
+	properties := make(map[int64]Properties)
+	for {
+		o, err := d.Decode()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if n, ok := o.(*osmpbf.Node); ok {
+			if _, valid := nodes[n.ID]; valid {
+				locations[n.ID] = coordinatesToCellID(n.Lat, n.Lon)
+				if p := tagProperties(n.Tags, tags); p != nil {
+					properties[n.ID] = p
+				}
+			}
+		}
+	}
+
+	osmIDs := make([]int64, 0, len(locations))
+	for id := range nodes {
+		if _, found := locations[id]; found {
+			osmIDs = append(osmIDs, id)
+		} else {
+			delete(nodes, id)
+		}
+	}
+	sort.Slice(osmIDs, func(i, j int) bool { return osmIDs[i] < osmIDs[j] })
+	for _, id := range osmIDs {
+		nodes[id] = g.AddNode(Node{Location: locations[id]})
+		g.OSMIDs = append(g.OSMIDs, id)
+		addNodeProperties(g, nodes[id], properties[id])
+	}
+	return nil
 }
 
 // buildNode creates and adds a node to the graph based on OSM node data.
@@ -62,16 +187,32 @@ func BuildGraph(path string) Graph {
 //   - g: *Graph - Pointer to the graph being constructed
 //   - node: *osmpbf.Node - OSM node data containing location information
 //   - nodes: map[int64]int32 - Map of valid OSM node IDs to internal graph IDs
+//   - tags: []string - OSM tags copied into the node properties
 //
-// The function modifies the graph by adding nodes and updates the nodes map with internal IDs
-func buildNode(g *Graph, node *osmpbf.Node, nodes map[int64]int32) {
+// The function modifies the graph by adding nodes, records their OSM ID in g.OSMIDs and updates the
+// nodes map with internal IDs
+func buildNode(g *Graph, node *osmpbf.Node, nodes map[int64]int32, tags []string) {
 	osmID := node.ID
 	if _, ok := nodes[osmID]; ok {
 		id := g.AddNode(Node{
 			Location: coordinatesToCellID(node.Lat, node.Lon),
 		})
 		nodes[osmID] = id
+		g.OSMIDs = append(g.OSMIDs, osmID)
+		addNodeProperties(g, id, tagProperties(node.Tags, tags))
+	}
+}
+
+// addNodeProperties records the properties of a node just added to the graph, growing g.Properties only
+// when there is something to store.
+func addNodeProperties(g *Graph, id int32, p Properties) {
+	if p == nil {
+		return
+	}
+	if len(g.Properties) <= int(id) {
+		g.Properties = append(g.Properties, make([]Properties, int(id)+1-len(g.Properties))...)
 	}
+	g.Properties[id] = p
 }
 
 // buildWay creates edges in the graph based on OSM way data. It processes sequences of nodes
@@ -82,13 +223,19 @@ func buildNode(g *Graph, node *osmpbf.Node, nodes map[int64]int32) {
 //   - way: *osmpbf.Way - OSM way data containing node sequences and tags
 //   - nodes: map[int64]int32 - Map of valid node IDs
 //   - ways: map[int64][]int32 - Map to store processed way segments
+//   - tags: []string - OSM tags copied into the edge properties
 //
 // The function modifies the graph by:
 //   - Adding edges between consecutive nodes in the way
 //   - Setting edge weights based on distance and speed limits
 //   - Including metadata about road type and travel characteristics
-func buildWay(g *Graph, way *osmpbf.Way, nodes map[int64]int32, ways map[int64][]int32) {
+//   - Restricting the edges to the time windows of the conditional access and oneway tags
+//   - Recording the OSM way ID and name so routes can be attributed back to OSM features
+func buildWay(g *Graph, way *osmpbf.Way, nodes map[int64]int32, ways map[int64][]int32, tags []string) {
 	speed := 50 // Default speed in km/h
+	properties := tagProperties(way.Tags, tags)
+	dir := edgeDirectionFromWay(*way, ProfileCar)
+	forward, backward := wayAccess(*way, dir)
 	for i := 0; i < len(way.NodeIDs)-1; i++ {
 		idA, ok1 := nodes[way.NodeIDs[i]]
 		idB, ok2 := nodes[way.NodeIDs[i+1]]
@@ -100,15 +247,18 @@ func buildWay(g *Graph, way *osmpbf.Way, nodes map[int64]int32, ways map[int64][
 		nodeA := g.Nodes[idA]
 		nodeB := g.Nodes[idB]
 		distance := DistanceMeters(s2.CellID(nodeA.Location), s2.CellID(nodeB.Location))
-		roadType := "n/a"
-		if highwayTag, found := way.Tags[Highway]; found {
-			roadType = strings.ToLower(highwayTag)
+		meta := wayMetaData(way, distance, float32(speed), properties)
+		if forward == nil && backward == nil {
+			g.RelateNodes(nodeA, nodeB, distance, dir, meta)
+		} else {
+			// Both directions carry their own conditional access.
+			meta.Access = forward
+			g.RelateNodes(nodeA, nodeB, distance, LeftToRight, meta)
+			if dir == Bidirectional || !backward.usuallyOpen() {
+				meta.Access = backward
+				g.RelateNodes(nodeA, nodeB, distance, RightToLeft, meta)
+			}
 		}
-		g.RelateNodes(nodeA, nodeB, distance, edgeDirectionFromWay(*way), MetaData{
-			Speed:    float32(speed),
-			Distance: distance,
-			RoadType: roadType,
-		})
 		ways[way.ID] = append(ways[way.ID], nodeA.ID)
 		if i == len(way.NodeIDs)-2 {
 			ways[way.ID] = append(ways[way.ID], nodeB.ID)
@@ -116,19 +266,65 @@ func buildWay(g *Graph, way *osmpbf.Way, nodes map[int64]int32, ways map[int64][
 	}
 }
 
+// wayMetaData builds the metadata of an edge of a way from the tags of the way.
+//
+// Parameters:
+//   - way: *osmpbf.Way - The way the edge belongs to
+//   - distance: float32 - Length of the edge in meters
+//   - speed: float32 - Speed on the edge in km/h
+//   - properties: Properties - Tags of the way copied into the edge
+//
+// Returns:
+//   - MetaData: The metadata of the edge
+func wayMetaData(way *osmpbf.Way, distance, speed float32, properties Properties) MetaData {
+	roadType := "n/a"
+	if highwayTag, found := way.Tags[Highway]; found {
+		roadType = strings.ToLower(highwayTag)
+	} else if isFerry(*way) {
+		roadType = Ferry
+	}
+	return MetaData{
+		Speed:     speed,
+		Distance:  distance,
+		Duration:  distance / (speed * MetersInAKilometer / SecondsInAnHour),
+		RoadType:  roadType,
+		Name:      way.Tags[Name],
+		WayID:     way.ID,
+		Ferry:     isFerry(*way),
+		Toll:      way.Tags[Toll] == Yes,
+		MaxWeight: parseWeight(way.Tags[MaxWeight]),
+		MaxHeight: parseLength(way.Tags[MaxHeight]),
+		MaxWidth:  parseLength(way.Tags[MaxWidth]),
+		NoHGV:     way.Tags[HGV] == No,
+		NoHazmat:  way.Tags[Hazmat] == No,
+
+		Surface:    way.Tags[Surface],
+		Smoothness: way.Tags[Smoothness],
+		Cycleway:   bikeInfrastructure(*way),
+		Lanes:      ParseLanes(way.Tags[Lanes], way.Tags[TurnLanes]),
+
+		Properties: properties,
+	}
+}
+
 // buildCoverageNodes creates a map of valid nodes from the input file.
 // It processes the file to identify nodes that are part of valid road segments.
 //
 // Parameters:
 //   - path: string - Path to the OSM PBF file to process
+//   - valid: func(osmpbf.Way) bool - Selects the ways whose nodes are kept
 //
 // Returns:
 //   - map[int64]int32: A map where keys are OSM node IDs and values are internal graph node IDs
-func buildCoverageNodes(path string) map[int64]int32 {
-	nodes := determineValidNodesFromFile(path)
-	log.Println("Valid nodes from file: ", len(nodes))
+//   - error: The error encountered while reading the file, if any
+func buildCoverageNodes(path string, valid func(osmpbf.Way) bool) (map[int64]int32, error) {
+	nodes, err := determineValidNodesFromFile(path, valid)
+	if err != nil {
+		return nil, err
+	}
+	getLogger().Info("valid nodes read", "file", path, "nodes", len(nodes))
 
-	return nodes
+	return nodes, nil
 }
 
 // calculateTimeAndDistance computes travel time and physical distance between two geographical points.
@@ -153,13 +349,19 @@ func calculateTimeAndDistance(origin, target s2.CellID, velocityKMH float64) (fl
 //
 // Parameters:
 //   - path: string - Path to the OSM PBF file
+//   - valid: func(osmpbf.Way) bool - Selects the ways whose nodes are kept
 //
 // Returns:
 //   - map[int64]int32: Map of valid OSM node IDs to sequential internal IDs
+//   - error: The error encountered while opening or decoding the file, if any
 //
 // The function filters nodes based on their presence in valid ways (roads, paths, etc.)
-func determineValidNodesFromFile(path string) map[int64]int32 {
-	d, f := openAndDecodePBF(path)
+func determineValidNodesFromFile(path string, valid func(osmpbf.Way) bool) (map[int64]int32, error) {
+	d, f, err := openAndDecodePBF(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
 
 	result := make(map[int64]int32)
 	i := 0
@@ -167,12 +369,12 @@ func determineValidNodesFromFile(path string) map[int64]int32 {
 		if o, err := d.Decode(); err == io.EOF {
 			break
 		} else if err != nil {
-			log.Fatal(err)
+			return nil, err
 		} else {
 			switch o := o.(type) {
 			case *osmpbf.Way:
 				w := *o
-				if validWay(w) {
+				if valid(w) {
 					for _, n := range w.NodeIDs {
 						if _, ok := result[n]; !ok {
 							result[n] = int32(i)
@@ -183,8 +385,7 @@ func determineValidNodesFromFile(path string) map[int64]int32 {
 			}
 		}
 	}
-	_ = f.Close()
-	return result
+	return result, nil
 }
 
 // coordinatesToCellID converts latitude and longitude coordinates to an S2 cell ID.
@@ -208,8 +409,13 @@ func coordinatesToCellID(lat, lng float64) uint64 {
 // Returns:
 //   - bool: true if the way represents a valid road type, false otherwise
 //
-// Valid road types include: motorway, trunk, primary, secondary, tertiary, residential, and their variants
+// Valid road types include: motorway, trunk, primary, secondary, tertiary, residential, and their variants,
+// as well as ferry routes
 func validWay(w osmpbf.Way) bool {
+	if isFerry(w) {
+		return true
+	}
+
 	tags := map[string]struct{}{
 		Motorway: {}, MotorwayLink: {}, Trunk: {},
 		TrunkLink: {}, Primary: {}, PrimaryLink: {},
@@ -222,19 +428,51 @@ func validWay(w osmpbf.Way) bool {
 	return ok
 }
 
-// edgeDirectionFromWay determines the directionality of a road segment based on OSM tags.
+// isFerry reports whether an OSM way is a ferry route (route=ferry).
 //
 // Parameters:
 //   - w: osmpbf.Way - OSM way to analyze
 //
 // Returns:
+//   - bool: true if the way is tagged as a ferry route
+func isFerry(w osmpbf.Way) bool {
+	return w.Tags[RouteKey] == Ferry
+}
+
+// edgeDirectionFromWay determines the directionality of a road segment based on OSM tags, as seen by a
+// travel profile.
+//
+// Parameters:
+//   - w: osmpbf.Way - OSM way to analyze
+//   - p: TravelProfile - Profile traveling the way. Cars follow oneway and roundabouts; bikes also follow
+//     oneway:bicycle, which overrides oneway, and ride against one-way streets with contraflow cycleways
+//     (cycleway=opposite, opposite_lane or opposite_track on any side); pedestrians walk both ways
+//
+// Returns:
 //   - EdgeDirection: One of:
 //   - LeftToRight: One-way from start to end
 //   - Bidirectional: Two-way traffic allowed
 //
 // The direction is determined by oneway tags and special cases like roundabouts
-func edgeDirectionFromWay(w osmpbf.Way) EdgeDirection {
+func edgeDirectionFromWay(w osmpbf.Way, p TravelProfile) EdgeDirection {
 	tags := w.Tags
+	if p == ProfileFoot {
+		return Bidirectional
+	}
+	if p == ProfileBike {
+		switch tags[OnewayBicycle] {
+		case Yes:
+			return LeftToRight
+		case No:
+			return Bidirectional
+		}
+		for _, key := range []string{Cycleway, CyclewayLeft, CyclewayRight, CyclewayBoth} {
+			switch tags[key] {
+			case Opposite, OppositeLane, OppositeTrack:
+				return Bidirectional
+			}
+		}
+	}
 	if oneWay, ok := tags[Oneway]; ok && oneWay == Yes {
 		return LeftToRight
 	}
@@ -251,22 +489,25 @@ func edgeDirectionFromWay(w osmpbf.Way) EdgeDirection {
 //
 // Returns:
 //   - *osmpbf.Decoder: Configured PBF decoder
-//   - *os.File: Open file handle
+//   - *os.File: Open file handle, to be closed by the caller
+//   - error: The error encountered while opening the file or starting the decoder, if any.
+//     The file is already closed when an error is returned
 //
 // The function configures the decoder for optimal performance using maximum buffer size
 // and parallel processing based on available CPU cores
-func openAndDecodePBF(path string) (*osmpbf.Decoder, *os.File) {
+func openAndDecodePBF(path string) (*osmpbf.Decoder, *os.File, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		log.Fatal(err)
+		return nil, nil, err
 	}
 
 	d := osmpbf.NewDecoder(f)
 	d.SetBufferSize(osmpbf.MaxBlobSize)
 	err = d.Start(runtime.GOMAXPROCS(-1))
 	if err != nil {
-		log.Fatal(err)
+		f.Close()
+		return nil, nil, err
 	}
 
-	return d, f
+	return d, f, nil
 }
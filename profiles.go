@@ -0,0 +1,304 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+
+	"github.com/golang/geo/s2"
+	"github.com/qedus/osmpbf"
+)
+
+var (
+	ErrUnknownProfile = errors.New("unknown travel profile")
+)
+
+// TravelProfile names a mode of transport a MultiProfileGraph computes edge weights for.
+type TravelProfile string
+
+const (
+	ProfileCar  TravelProfile = "car"
+	ProfileBike TravelProfile = "bike"
+	ProfileFoot TravelProfile = "foot"
+)
+
+// Default speeds in km/h of the travel profiles.
+const (
+	SpeedCar  = 50
+	SpeedBike = 15
+	SpeedFoot = 5
+)
+
+// MultiProfileGraph serves several travel profiles from one network. Nodes, geometry and edge metadata are
+// stored once in Graph, which holds the union of the edges usable by any profile; every profile only adds
+// an array of edge weights aligned with Graph.OutgoingEdges. Hosting car, bike and foot routing thus costs
+// one float32 per edge and profile instead of three graphs.
+type MultiProfileGraph struct {
+	Graph    Graph           // Shared network, Edge.Weight is the length of the edge in meters
+	Profiles []TravelProfile // Profiles with weights, in the order they were requested
+
+	// BikeStressTolerance is the tolerance of cyclists to traffic stress, from 0 to 1, see BikeStressFactor.
+	// Ways added while it is below 1 have their ProfileBike weights scaled to prefer bike infrastructure
+	// and avoid busy roads. NewMultiProfileGraph sets it to 1, which only minimizes travel time.
+	BikeStressTolerance float64
+
+	// weights[p][from][i] is the travel time in seconds of OutgoingEdges[from][i] for profile p,
+	// +Inf when the profile may not use the edge.
+	weights map[TravelProfile][][]float32
+}
+
+// BuildMultiProfileGraph constructs a graph for several travel profiles from an OSM PBF file. Ways usable by
+// any profile are read in the same pass, and every edge gets the travel time of each profile allowed on it.
+//
+// Parameters:
+//   - path: string - File path to the OSM PBF file to process
+//   - profiles: ...TravelProfile - Profiles to build, ProfileCar, ProfileBike and ProfileFoot if none
+//
+// Returns:
+//   - *MultiProfileGraph: The graph shared by the profiles
+//   - error: An error wrapping ErrUnknownProfile, or the error that interrupted reading the file
+func BuildMultiProfileGraph(path string, profiles ...TravelProfile) (*MultiProfileGraph, error) {
+	start := time.Now()
+	m, err := NewMultiProfileGraph(profiles...)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := buildCoverageNodes(path, m.validWay)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNodeCount(int64(len(nodes))); err != nil {
+		return nil, err
+	}
+
+	decoder, file, err := openAndDecodePBF(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	for {
+		obj, err := decoder.Decode()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		switch obj := obj.(type) {
+		case *osmpbf.Node:
+			m.AddNode(obj, nodes)
+		case *osmpbf.Way:
+			m.AddWay(obj, nodes)
+		}
+	}
+
+	getLogger().Info("multi-profile graph built", "file", path, "nodes", len(m.Graph.Nodes), "profiles", len(m.Profiles), "duration", time.Since(start))
+	return m, nil
+}
+
+// NewMultiProfileGraph creates an empty multi-profile graph, filled with AddNode and AddWay.
+//
+// Parameters:
+//   - profiles: ...TravelProfile - Profiles to build, ProfileCar, ProfileBike and ProfileFoot if none
+//
+// Returns:
+//   - *MultiProfileGraph: The empty graph
+//   - error: An error wrapping ErrUnknownProfile if a profile is not supported
+func NewMultiProfileGraph(profiles ...TravelProfile) (*MultiProfileGraph, error) {
+	if len(profiles) == 0 {
+		profiles = []TravelProfile{ProfileCar, ProfileBike, ProfileFoot}
+	}
+	m := &MultiProfileGraph{
+		Graph:               EmptyGraph(),
+		BikeStressTolerance: 1,
+		weights:             make(map[TravelProfile][][]float32, len(profiles)),
+	}
+	for _, p := range profiles {
+		if p.speed() == 0 {
+			return nil, fmt.Errorf("%q: %w", p, ErrUnknownProfile)
+		}
+		if _, ok := m.weights[p]; !ok {
+			m.Profiles = append(m.Profiles, p)
+			m.weights[p] = make([][]float32, 0)
+		}
+	}
+	return m, nil
+}
+
+// AddNode adds an OSM node to the graph if it belongs to the coverage of the graph.
+//
+// Parameters:
+//   - node: *osmpbf.Node - The node read from the PBF file
+//   - nodes: map[int64]int32 - OSM IDs of the covered nodes, updated with the internal ID of the node
+func (m *MultiProfileGraph) AddNode(node *osmpbf.Node, nodes map[int64]int32) {
+	before := len(m.Graph.Nodes)
+	buildNode(&m.Graph, node, nodes, nil)
+	if len(m.Graph.Nodes) == before {
+		return
+	}
+	for _, p := range m.Profiles {
+		m.weights[p] = append(m.weights[p], make([]float32, 0))
+	}
+}
+
+// AddWay adds the edges of an OSM way usable by at least one profile. An edge is created in every
+// direction some profile may travel it, and weighted with the travel time of each profile.
+//
+// Parameters:
+//   - way: *osmpbf.Way - The way read from the PBF file
+//   - nodes: map[int64]int32 - Internal IDs of the nodes added so far, by OSM ID
+func (m *MultiProfileGraph) AddWay(way *osmpbf.Way, nodes map[int64]int32) {
+	forward := make(map[TravelProfile]bool, len(m.Profiles))
+	backward := make(map[TravelProfile]bool, len(m.Profiles))
+	var speed float32
+	for _, p := range m.Profiles {
+		forward[p], backward[p] = p.access(*way)
+		if speed == 0 && (forward[p] || backward[p]) {
+			speed = p.speed()
+		}
+	}
+	if speed == 0 {
+		return
+	}
+
+	for i := 0; i < len(way.NodeIDs)-1; i++ {
+		idA, ok1 := nodes[way.NodeIDs[i]]
+		idB, ok2 := nodes[way.NodeIDs[i+1]]
+		if !ok1 || !ok2 {
+			continue
+		}
+		a, b := m.Graph.Nodes[idA], m.Graph.Nodes[idB]
+		distance := DistanceMeters(s2.CellID(a.Location), s2.CellID(b.Location))
+		meta := wayMetaData(way, distance, speed, nil)
+		m.addEdge(idA, idB, distance, meta, forward)
+		m.addEdge(idB, idA, distance, meta, backward)
+	}
+}
+
+// addEdge adds the directed edge from -> to when at least one profile may travel it.
+func (m *MultiProfileGraph) addEdge(from, to int32, distance float32, meta MetaData, allowed map[TravelProfile]bool) {
+	usable := false
+	for _, ok := range allowed {
+		usable = usable || ok
+	}
+	if !usable {
+		return
+	}
+	m.Graph.addOutgoingEdge(from, to, distance, meta)
+	m.Graph.addIncomingEdge(from, to, distance, meta)
+	for _, p := range m.Profiles {
+		w := float32(math.Inf(1))
+		if allowed[p] {
+			w = m.weight(p, meta, float64(p.speed()))
+		}
+		m.weights[p][from] = append(m.weights[p][from], w)
+	}
+}
+
+// weight returns the travel time in seconds of an edge for a profile riding it at the given speed in km/h,
+// scaled by the traffic stress of the edge for bikes.
+func (m *MultiProfileGraph) weight(p TravelProfile, meta MetaData, speed float64) float32 {
+	w := float64(meta.Distance) / (speed * MetersInAKilometer / SecondsInAnHour)
+	if p == ProfileBike {
+		w *= BikeStressFactor(meta, m.BikeStressTolerance)
+	}
+	return float32(w)
+}
+
+// Criteria returns a copy of the criteria minimizing the travel time of a profile on Graph. Edges the
+// profile may not use are never followed.
+//
+// Parameters:
+//   - p: TravelProfile - The profile to route
+//   - c: Criteria - The criteria to adapt
+//
+// Returns:
+//   - Criteria: The criteria with the EdgeWeights of the profile
+//   - error: An error wrapping ErrUnknownProfile if the graph has no weights for the profile
+func (m *MultiProfileGraph) Criteria(p TravelProfile, c Criteria) (Criteria, error) {
+	weights, ok := m.weights[p]
+	if !ok {
+		return c, fmt.Errorf("%q: %w", p, ErrUnknownProfile)
+	}
+	c.EdgeWeights = weights
+	return c, nil
+}
+
+// Route runs a shortest travel time search for a profile on the shared graph.
+//
+// Parameters:
+//   - p: TravelProfile - The profile to route
+//   - c: Criteria - The sources, targets and restrictions of the search
+//
+// Returns:
+//   - Response: The result of the search, with costs in seconds
+//   - error: An error wrapping ErrUnknownProfile if the graph has no weights for the profile
+func (m *MultiProfileGraph) Route(p TravelProfile, c Criteria) (Response, error) {
+	c, err := m.Criteria(p, c)
+	if err != nil {
+		return Response{}, err
+	}
+	return NewDijkstra(c).Run(m.Graph), nil
+}
+
+// validWay reports whether any profile of the graph may use a way.
+func (m *MultiProfileGraph) validWay(w osmpbf.Way) bool {
+	for _, p := range m.Profiles {
+		if forward, backward := p.access(w); forward || backward {
+			return true
+		}
+	}
+	return false
+}
+
+// speed returns the default speed of the profile in km/h, 0 for unknown profiles.
+func (p TravelProfile) speed() float32 {
+	switch p {
+	case ProfileCar:
+		return SpeedCar
+	case ProfileBike:
+		return SpeedBike
+	case ProfileFoot:
+		return SpeedFoot
+	}
+	return 0
+}
+
+// access reports whether the profile may travel a way along and against the order of its nodes. Cars use
+// the roads accepted by BuildGraph; bikes and pedestrians are kept off motorways and trunks, bikes ride
+// cycleways, paths and tracks and respect one-way streets unless contraflow cycling is allowed, and
+// pedestrians walk footways, steps and paths in both directions, see edgeDirectionFromWay. An explicit bicycle or foot tag overrides the road type.
+func (p TravelProfile) access(w osmpbf.Way) (bool, bool) {
+	if isFerry(w) {
+		return true, true
+	}
+	highway := w.Tags[Highway]
+	var allowed bool
+	switch p {
+	case ProfileCar:
+		allowed = validWay(w)
+	case ProfileBike:
+		allowed = bikeRoads[highway] || w.Tags[Bicycle] == Yes || w.Tags[Bicycle] == Designated
+		allowed = allowed && w.Tags[Bicycle] != No
+	case ProfileFoot:
+		allowed = footRoads[highway] || w.Tags[Foot] == Yes
+		allowed = allowed && w.Tags[Foot] != No
+	}
+	return allowed, allowed && edgeDirectionFromWay(w, p) != LeftToRight
+}
+
+// Road types bikes and pedestrians are allowed on by default.
+var (
+	bikeRoads = map[string]bool{
+		Primary: true, PrimaryLink: true, Secondary: true, SecondaryLink: true, Tertiary: true,
+		TertiaryLink: true, Residential: true, Unclassified: true, LivingStreet: true, Service: true,
+		Road: true, Cycleway: true, Path: true, Track: true,
+	}
+	footRoads = map[string]bool{
+		Primary: true, PrimaryLink: true, Secondary: true, SecondaryLink: true, Tertiary: true,
+		TertiaryLink: true, Residential: true, Unclassified: true, LivingStreet: true, Service: true,
+		Road: true, Footway: true, Pedestrian: true, Path: true, Steps: true, Track: true,
+	}
+)
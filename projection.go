@@ -1,6 +1,10 @@
 package graph_search
 
-import "math"
+import (
+	"math"
+
+	"github.com/golang/geo/s2"
+)
 
 // LatLngToMeters converts latitude and longitude to X and Y coordinates in meters.
 //
@@ -37,3 +41,54 @@ func MetersToLatLng(x, y float64) (lat, lng float64) {
 	lng = λ * (180.0 / math.Pi)
 	return lat, lng
 }
+
+// bearing computes the initial great-circle bearing from a to b.
+//
+// Parameters:
+//   - a: s2.LatLng - The starting point
+//   - b: s2.LatLng - The destination point
+//
+// Returns:
+//   - float64 - The bearing in degrees clockwise from north, in the range [0, 360)
+func bearing(a, b s2.LatLng) float64 {
+	φ1, φ2 := a.Lat.Radians(), b.Lat.Radians()
+	Δλ := b.Lng.Radians() - a.Lng.Radians()
+	y := math.Sin(Δλ) * math.Cos(φ2)
+	x := math.Cos(φ1)*math.Sin(φ2) - math.Sin(φ1)*math.Cos(φ2)*math.Cos(Δλ)
+	return math.Mod(math.Atan2(y, x)*(180.0/math.Pi)+360, 360)
+}
+
+// EarthRadiusMeters is the mean radius of the Earth used by the great-circle computations of the package,
+// the radius DistanceMeters uses too.
+const EarthRadiusMeters = 6371000.0
+
+// Bearing computes the initial great-circle bearing from one coordinate to another.
+//
+// Parameters:
+//   - a: Coordinate - The starting point
+//   - b: Coordinate - The destination point
+//
+// Returns:
+//   - float64 - The bearing in degrees clockwise from north, in the range [0, 360)
+func Bearing(a, b Coordinate) float64 {
+	return bearing(s2.LatLngFromDegrees(a.Lat, a.Lng), s2.LatLngFromDegrees(b.Lat, b.Lng))
+}
+
+// DestinationPoint computes the point reached by following a great circle from a start point.
+//
+// Parameters:
+//   - start: Coordinate - The starting point
+//   - heading: float64 - The initial bearing in degrees clockwise from north
+//   - distance: float64 - The distance to travel in meters
+//
+// Returns:
+//   - Coordinate - The point reached, with its longitude normalized to [-180, 180)
+func DestinationPoint(start Coordinate, heading, distance float64) Coordinate {
+	φ1, λ1 := start.Lat*(math.Pi/180.0), start.Lng*(math.Pi/180.0)
+	θ := heading * (math.Pi / 180.0)
+	δ := distance / EarthRadiusMeters
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(δ) + math.Cos(φ1)*math.Sin(δ)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(δ)*math.Cos(φ1), math.Cos(δ)-math.Sin(φ1)*math.Sin(φ2))
+	lng := math.Mod(λ2*(180.0/math.Pi)+540, 360) - 180
+	return Coordinate{Lat: φ2 * (180.0 / math.Pi), Lng: lng}
+}
@@ -0,0 +1,169 @@
+package graph_search
+
+import "strconv"
+
+// Properties holds custom attributes attached to a node or an edge, e.g., zone IDs or hazard flags.
+// Values are stored as strings and read back with the typed accessors. Properties are part of the graph,
+// so they are persisted by Serialize and carried by the edges of search results.
+type Properties map[string]string
+
+// Get returns the value of a property.
+//
+// Parameters:
+//   - key: string - Name of the property
+//
+// Returns:
+//   - string: The value of the property
+//   - bool: false if the property is not set
+func (p Properties) Get(key string) (string, bool) {
+	v, ok := p[key]
+	return v, ok
+}
+
+// Int returns the value of a property parsed as an integer.
+//
+// Parameters:
+//   - key: string - Name of the property
+//
+// Returns:
+//   - int64: The value of the property
+//   - bool: false if the property is not set or is not an integer
+func (p Properties) Int(key string) (int64, bool) {
+	v, err := strconv.ParseInt(p[key], 10, 64)
+	return v, err == nil
+}
+
+// Float returns the value of a property parsed as a floating point number.
+//
+// Parameters:
+//   - key: string - Name of the property
+//
+// Returns:
+//   - float64: The value of the property
+//   - bool: false if the property is not set or is not a number
+func (p Properties) Float(key string) (float64, bool) {
+	v, err := strconv.ParseFloat(p[key], 64)
+	return v, err == nil
+}
+
+// Bool returns the value of a property parsed as a boolean. Besides the values accepted by
+// strconv.ParseBool, the OSM values "yes" and "no" are recognized.
+//
+// Parameters:
+//   - key: string - Name of the property
+//
+// Returns:
+//   - bool: The value of the property
+//   - bool: false if the property is not set or is not a boolean
+func (p Properties) Bool(key string) (bool, bool) {
+	switch v := p[key]; v {
+	case Yes:
+		return true, true
+	case No:
+		return false, true
+	default:
+		b, err := strconv.ParseBool(v)
+		return b, err == nil
+	}
+}
+
+// Set sets the value of a property, allocating the map if needed.
+//
+// Parameters:
+//   - key: string - Name of the property
+//   - value: string - Value of the property
+func (p *Properties) Set(key, value string) {
+	if *p == nil {
+		*p = make(Properties)
+	}
+	(*p)[key] = value
+}
+
+// NodeProperties returns the custom attributes of a node.
+//
+// Parameters:
+//   - id: int32 - ID of the node
+//
+// Returns:
+//   - Properties: The attributes of the node, nil if it has none
+func (g Graph) NodeProperties(id int32) Properties {
+	if id < 0 || int(id) >= len(g.Properties) {
+		return nil
+	}
+	return g.Properties[id]
+}
+
+// SetNodeProperty sets a custom attribute of a node.
+//
+// Parameters:
+//   - id: int32 - ID of the node
+//   - key: string - Name of the property
+//   - value: string - Value of the property
+//
+// Returns:
+//   - error: ErrNodeNotFound if the node is not part of the graph
+func (g *Graph) SetNodeProperty(id int32, key, value string) error {
+	if id < 0 || int(id) >= len(g.Nodes) {
+		return ErrNodeNotFound
+	}
+	if len(g.Properties) < len(g.Nodes) {
+		g.Properties = append(g.Properties, make([]Properties, len(g.Nodes)-len(g.Properties))...)
+	}
+	g.Properties[id].Set(key, value)
+	return nil
+}
+
+// SetEdgeProperty sets a custom attribute of every edge going from one node to another, in both the
+// outgoing and incoming adjacency lists.
+//
+// Parameters:
+//   - from: int32 - ID of the source node of the edge
+//   - to: int32 - ID of the destination node of the edge
+//   - key: string - Name of the property
+//   - value: string - Value of the property
+//
+// Returns:
+//   - error: ErrNodeNotFound if a node is not part of the graph, ErrEdgeNotFound if no edge joins them
+func (g *Graph) SetEdgeProperty(from, to int32, key, value string) error {
+	if from < 0 || int(from) >= len(g.Nodes) || to < 0 || int(to) >= len(g.Nodes) {
+		return ErrNodeNotFound
+	}
+	found := false
+	for i, e := range g.OutgoingEdges[from] {
+		if e.ID == to {
+			g.OutgoingEdges[from][i].Metadata.Properties = withProperty(e.Metadata.Properties, key, value)
+			found = true
+		}
+	}
+	for i, e := range g.IncomingEdges[to] {
+		if e.ID == from {
+			g.IncomingEdges[to][i].Metadata.Properties = withProperty(e.Metadata.Properties, key, value)
+		}
+	}
+	if !found {
+		return ErrEdgeNotFound
+	}
+	return nil
+}
+
+// withProperty returns a copy of p with a property set. Edges built from the same way share their
+// Properties map, so it is never modified in place.
+func withProperty(p Properties, key, value string) Properties {
+	c := make(Properties, len(p)+1)
+	for k, v := range p {
+		c[k] = v
+	}
+	c[key] = value
+	return c
+}
+
+// tagProperties returns the OSM tags listed in keys as Properties, nil if none of them is set.
+func tagProperties(tags map[string]string, keys []string) Properties {
+	var p Properties
+	for _, k := range keys {
+		if v, ok := tags[k]; ok {
+			p.Set(k, v)
+		}
+	}
+	return p
+}
@@ -0,0 +1,91 @@
+package graph_search
+
+import "math"
+
+// Quality of the surfaces, smoothness grades and road classes of OSM, from 0 (impassable) to 1 (smooth
+// asphalt).
+var (
+	surfaceQuality = map[string]float64{
+		Asphalt: 1, Concrete: 0.95, Paved: 0.9, Cement: 0.9, PavingStones: 0.8, Tartan: 0.8, Metal: 0.7,
+		Wood: 0.7, Bricks: 0.7, Compacted: 0.7, FineGravel: 0.6, Sett: 0.5, Pebblestone: 0.4, Gravel: 0.4,
+		GrassPaver: 0.4, Unpaved: 0.4, Cobblestone: 0.3, Ground: 0.3, Dirt: 0.3, Earth: 0.3, Clay: 0.3,
+		Grass: 0.2, Stone: 0.2, Rocky: 0.15, Sand: 0.1, Mud: 0.05,
+	}
+	smoothnessQuality = map[string]float64{
+		"excellent": 1, "good": 0.85, "intermediate": 0.65, "bad": 0.4, "very_bad": 0.25, "horrible": 0.1,
+		"very_horrible": 0.05, "impassable": 0,
+	}
+	roadClassQuality = map[string]float64{
+		Track: 0.4, Path: 0.5, Steps: 0.1, Service: 0.9, LivingStreet: 0.9, Footway: 0.9, Pedestrian: 0.9,
+		Cycleway: 0.9,
+	}
+)
+
+// Weights of the surface, the smoothness and the road class in EdgeQuality.
+const (
+	surfaceWeight    = 0.6
+	smoothnessWeight = 0.25
+	roadClassWeight  = 0.15
+)
+
+// EdgeQuality scores how comfortable an edge is to ride or roll on, from 0 (impassable) to 1 (smooth
+// asphalt). The score mixes the quality of the surface, the smoothness and the road class of the edge.
+// An untagged surface takes the quality typical of the road class, e.g., 0.4 for tracks, and an untagged
+// smoothness the quality of the surface.
+//
+// Parameters:
+//   - m: MetaData - Metadata of the edge
+//
+// Returns:
+//   - float64: The quality of the edge
+func EdgeQuality(m MetaData) float64 {
+	class, ok := roadClassQuality[m.RoadType]
+	if !ok {
+		class = 1
+	}
+	surface, ok := surfaceQuality[m.Surface]
+	if !ok {
+		surface = class
+	}
+	smoothness, ok := smoothnessQuality[m.Smoothness]
+	if !ok {
+		smoothness = surface
+	}
+	return surfaceWeight*surface + smoothnessWeight*smoothness + roadClassWeight*class
+}
+
+// RouteQuality summarizes the surfaces along a route, for gravel bikes, road bikes or wheelchairs.
+type RouteQuality struct {
+	Score    float64            // Average EdgeQuality of the edges weighted by their length, 1 for empty routes
+	Worst    float64            // Lowest EdgeQuality of the edges, 1 for empty routes
+	Surfaces map[string]float64 // Meters of every surface, untagged surfaces being counted under ""
+}
+
+// Quality scores the surfaces of the route.
+//
+// Returns:
+//   - RouteQuality: The average and worst edge quality with the length of every surface
+func (r Route) Quality() RouteQuality {
+	q := RouteQuality{Score: 1, Worst: 1, Surfaces: make(map[string]float64)}
+	total, weighted := 0.0, 0.0
+	for _, e := range r.Edges {
+		score, length := EdgeQuality(e.Metadata), float64(e.Metadata.Distance)
+		total += length
+		weighted += score * length
+		q.Worst = math.Min(q.Worst, score)
+		q.Surfaces[e.Metadata.Surface] += length
+	}
+	if total > 0 {
+		q.Score = weighted / total
+	}
+	return q
+}
+
+// surfaceFactor returns the factor the cost of an edge is multiplied by to prefer good surfaces, 1 when
+// the criteria have no SurfaceTradeoff.
+func (c Criteria) surfaceFactor(e Edge) float32 {
+	if c.SurfaceTradeoff <= 0 {
+		return 1
+	}
+	return float32(1 + c.SurfaceTradeoff*(1-EdgeQuality(e.Metadata)))
+}
@@ -0,0 +1,152 @@
+package graph_search
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"github.com/golang/geo/s2"
+)
+
+// rasterRadius is the distance, in cells, over which the cost of a node is spread by CostRaster.
+const rasterRadius = 2
+
+// CostRaster is a grid of travel costs over the bounding box of a search, like the band of a GeoTIFF.
+// Cells are square in projected (Web Mercator) meters and stored row by row from north to south.
+type CostRaster struct {
+	MinX, MaxY    float64   // Projected coordinates of the north-west corner of the grid
+	Resolution    float64   // Side of a cell in projected meters
+	Width, Height int       // Number of columns and rows
+	Values        []float32 // Values[row*Width+col] is the cost of a cell, NaN when no node lies near it
+}
+
+// CostRaster interpolates the costs of the settled nodes on a grid covering their bounding box, for
+// visualizing accessibility surfaces. Every cell takes the inverse distance weighted average of the nodes
+// within rasterRadius cells of its center; cells far from every node are left empty.
+//
+// Parameters:
+//   - g: Graph - The graph the search was run on
+//   - resolution: float64 - Side of a cell in projected meters
+//
+// Returns:
+//   - CostRaster: The interpolated costs, with no cells when resolution is not positive or nothing was settled
+func (r Response) CostRaster(g Graph, resolution float64) CostRaster {
+	raster := CostRaster{Resolution: resolution}
+	if resolution <= 0 || len(r.SearchSpace.Nodes) == 0 {
+		return raster
+	}
+
+	type sample struct {
+		x, y float64
+		cost float32
+	}
+	samples := make([]sample, 0, len(r.SearchSpace.Nodes))
+	minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	for _, n := range r.SearchSpace.Nodes {
+		cost, err := r.Costs.GetCost(n.Rank)
+		if err != nil {
+			continue
+		}
+		p := s2.CellID(g.Nodes[n.Rank].Location).LatLng()
+		x, y := LatLngToMeters(p.Lat.Degrees(), p.Lng.Degrees())
+		samples = append(samples, sample{x, y, cost})
+		minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+		minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+	}
+	raster.MinX, raster.MaxY = minX, maxY
+	raster.Width = int(math.Floor((maxX-minX)/resolution)) + 1
+	raster.Height = int(math.Floor((maxY-minY)/resolution)) + 1
+
+	sums := make([]float64, raster.Width*raster.Height)
+	weights := make([]float64, raster.Width*raster.Height)
+	for _, s := range samples {
+		col := int((s.x - minX) / resolution)
+		row := int((maxY - s.y) / resolution)
+		for r := max(row-rasterRadius, 0); r <= min(row+rasterRadius, raster.Height-1); r++ {
+			for c := max(col-rasterRadius, 0); c <= min(col+rasterRadius, raster.Width-1); c++ {
+				cx, cy := raster.cellCenter(r, c)
+				d := math.Hypot(cx-s.x, cy-s.y) / resolution
+				if d > rasterRadius {
+					continue
+				}
+				w := 1 / (d*d + 1e-6)
+				sums[r*raster.Width+c] += w * float64(s.cost)
+				weights[r*raster.Width+c] += w
+			}
+		}
+	}
+	raster.Values = make([]float32, len(sums))
+	for i := range sums {
+		raster.Values[i] = float32(math.NaN())
+		if weights[i] > 0 {
+			raster.Values[i] = float32(sums[i] / weights[i])
+		}
+	}
+	return raster
+}
+
+// At returns the cost of the cell containing a coordinate.
+//
+// Parameters:
+//   - c: Coordinate - The location to look up
+//
+// Returns:
+//   - float32: The cost of the cell
+//   - bool: false if the coordinate is outside the grid or its cell is empty
+func (cr CostRaster) At(c Coordinate) (float32, bool) {
+	if cr.Resolution <= 0 {
+		return 0, false
+	}
+	x, y := LatLngToMeters(c.Lat, c.Lng)
+	col, row := int(math.Floor((x-cr.MinX)/cr.Resolution)), int(math.Floor((cr.MaxY-y)/cr.Resolution))
+	if col < 0 || col >= cr.Width || row < 0 || row >= cr.Height {
+		return 0, false
+	}
+	v := cr.Values[row*cr.Width+col]
+	return v, !math.IsNaN(float64(v))
+}
+
+// Bounds returns the south-west and north-east corners of the grid.
+func (cr CostRaster) Bounds() (Coordinate, Coordinate) {
+	south, west := MetersToLatLng(cr.MinX, cr.MaxY-float64(cr.Height)*cr.Resolution)
+	north, east := MetersToLatLng(cr.MinX+float64(cr.Width)*cr.Resolution, cr.MaxY)
+	return Coordinate{Lat: south, Lng: west}, Coordinate{Lat: north, Lng: east}
+}
+
+// WritePNG renders the raster as a PNG image, one pixel per cell, shading cells from green for no cost to
+// red for maxCost and above. Empty cells are transparent.
+//
+// Parameters:
+//   - w: io.Writer - Where the image is written
+//   - maxCost: float32 - Cost rendered as pure red, the largest cost of the grid if not positive
+//
+// Returns:
+//   - error: The error returned by the PNG encoder, if any
+func (cr CostRaster) WritePNG(w io.Writer, maxCost float32) error {
+	if maxCost <= 0 {
+		for _, v := range cr.Values {
+			if !math.IsNaN(float64(v)) {
+				maxCost = max(maxCost, v)
+			}
+		}
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, cr.Width, cr.Height))
+	for i, v := range cr.Values {
+		if math.IsNaN(float64(v)) {
+			continue
+		}
+		t := 1.0
+		if maxCost > 0 {
+			t = math.Min(float64(v/maxCost), 1)
+		}
+		img.SetNRGBA(i%cr.Width, i/cr.Width, color.NRGBA{R: uint8(255 * t), G: uint8(255 * (1 - t)), A: 255})
+	}
+	return png.Encode(w, img)
+}
+
+// cellCenter returns the projected coordinates of the center of a cell.
+func (cr CostRaster) cellCenter(row, col int) (float64, float64) {
+	return cr.MinX + (float64(col)+0.5)*cr.Resolution, cr.MaxY - (float64(row)+0.5)*cr.Resolution
+}
@@ -0,0 +1,86 @@
+package graph_search
+
+// Reachability is the result of Graph.Reachable: the nodes reachable from a source within a cost budget.
+// Unlike a Response it keeps no shortest path tree, only what coverage studies need.
+type Reachability struct {
+	// Reached holds the reachable node IDs.
+	Reached Bitset
+
+	// Nodes lists the reachable node IDs in increasing cost order.
+	Nodes []int32
+
+	// costs[v] is the cost of reaching v, only meaningful when v is in Reached
+	costs []float32
+}
+
+// Cost returns the cost of reaching a node.
+//
+// Parameters:
+//   - id: int32 - ID of the node
+//
+// Returns:
+//   - float32: The shortest path cost from the source to the node
+//   - bool: false if the node is not reachable within the budget
+func (r Reachability) Cost(id int32) (float32, bool) {
+	if id < 0 || !r.Reached.Exists(id) {
+		return 0, false
+	}
+	return r.costs[id], true
+}
+
+// Len returns the number of reachable nodes.
+func (r Reachability) Len() int {
+	return len(r.Nodes)
+}
+
+// Reachable runs a one-to-all search from source bounded by maxCost and returns every node it reaches.
+// It is meant to be called many times, e.g., once per ambulance station, so it works on flat slices
+// and skips the bookkeeping DijkstraSearch does to rebuild paths.
+//
+// Parameters:
+//   - source: int32 - ID of the node the search starts from
+//   - maxCost: float32 - Largest cost, in edge weight units, a node may be reached with
+//
+// Returns:
+//   - Reachability: The reachable nodes and their costs, empty if source is not a node of the graph
+func (g Graph) Reachable(source int32, maxCost float32) Reachability {
+	return g.reachable(source, maxCost, func(e Edge) float32 { return e.Weight })
+}
+
+// reachable is Reachable with edges weighted by an arbitrary function.
+func (g Graph) reachable(source int32, maxCost float32, weight func(Edge) float32) Reachability {
+	r := Reachability{
+		Reached: NewBigInt(),
+		Nodes:   make([]int32, 0),
+		costs:   make([]float32, len(g.Nodes)),
+	}
+	if source < 0 || int(source) >= len(g.Nodes) || maxCost < 0 {
+		return r
+	}
+
+	queued := NewBigInt()
+	pq := Create()
+	pq.Insert(HNode{Value: source})
+	queued.Set(source, true)
+	for !pq.IsEmpty() {
+		min, _ := pq.Min()
+		pq.DeleteMin()
+		if r.Reached.Exists(min.Value) {
+			continue
+		}
+		r.Reached.Set(min.Value, true)
+		r.Nodes = append(r.Nodes, min.Value)
+		r.costs[min.Value] = min.Cost
+
+		for _, e := range g.OutgoingEdges[min.Value] {
+			cost := min.Cost + weight(e)
+			if cost > maxCost || r.Reached.Exists(e.ID) || (queued.Exists(e.ID) && r.costs[e.ID] <= cost) {
+				continue
+			}
+			queued.Set(e.ID, true)
+			r.costs[e.ID] = cost
+			pq.Insert(HNode{Value: e.ID, Cost: cost, Depth: min.Depth + 1})
+		}
+	}
+	return r
+}
@@ -0,0 +1,162 @@
+package graph_search
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/qedus/osmpbf"
+)
+
+// RelationHandler receives the relations of an OSM file while BuildGraphWithOptions builds the graph, so
+// importers can use turn restrictions, route relations or boundaries without forking BuildGraph. OSM files
+// list relations after nodes and ways, so the graph is complete when the handlers are called.
+type RelationHandler interface {
+	// HandleRelation is called once for every relation of the file, in file order. An error aborts the
+	// build.
+	HandleRelation(r *osmpbf.Relation, ctx RelationContext) error
+}
+
+// RelationHandlerFunc adapts an ordinary function to a RelationHandler.
+type RelationHandlerFunc func(r *osmpbf.Relation, ctx RelationContext) error
+
+// HandleRelation calls f(r, ctx).
+func (f RelationHandlerFunc) HandleRelation(r *osmpbf.Relation, ctx RelationContext) error {
+	return f(r, ctx)
+}
+
+// RelationContext resolves the members of a relation to the graph being built.
+type RelationContext struct {
+	Graph *Graph // The graph built from the nodes and ways of the file
+
+	nodes map[int64]int32
+	ways  map[int64][]int32
+}
+
+// Node returns the internal ID of an OSM node.
+//
+// Parameters:
+//   - osmID: int64 - OSM ID of the node
+//
+// Returns:
+//   - int32: The ID of the node in the graph
+//   - bool: false if the node is not part of the graph
+func (ctx RelationContext) Node(osmID int64) (int32, bool) {
+	id, ok := ctx.nodes[osmID]
+	if !ok || int(id) >= len(ctx.Graph.OSMIDs) || ctx.Graph.OSMIDs[id] != osmID {
+		return 0, false
+	}
+	return id, true
+}
+
+// Way returns the internal IDs of the nodes of an OSM way, in the order of the way.
+//
+// Parameters:
+//   - osmID: int64 - OSM ID of the way
+//
+// Returns:
+//   - []int32: The IDs of the nodes of the way in the graph
+//   - bool: false if the way is not part of the graph
+func (ctx RelationContext) Way(osmID int64) ([]int32, bool) {
+	nodes, ok := ctx.ways[osmID]
+	return nodes, ok
+}
+
+// handleRelation passes a relation to every handler, stopping at the first error.
+func handleRelation(r *osmpbf.Relation, handlers []RelationHandler, ctx RelationContext) error {
+	for _, h := range handlers {
+		if err := h.HandleRelation(r, ctx); err != nil {
+			return fmt.Errorf("relation %d: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// TurnRestrictions is a RelationHandler collecting the turn restrictions of a file, the relations tagged
+// type=restriction with a from way, a via node and a to way. Prohibitory restrictions (no_left_turn,
+// no_u_turn...) forbid the turn from the from way onto the to way; mandatory ones (only_straight_on...)
+// forbid every other turn from the from way at the via node. Restrictions through via ways and
+// conditional restrictions are ignored.
+type TurnRestrictions struct {
+	forbidden map[[3]int32]bool
+	mandatory map[[2]int32]map[int32]bool // allowed destinations of the turns from [from, via]
+}
+
+// NewTurnRestrictions creates a handler without restrictions.
+func NewTurnRestrictions() *TurnRestrictions {
+	return &TurnRestrictions{forbidden: make(map[[3]int32]bool), mandatory: make(map[[2]int32]map[int32]bool)}
+}
+
+// HandleRelation records the relation if it is a turn restriction whose members are all in the graph.
+func (t *TurnRestrictions) HandleRelation(r *osmpbf.Relation, ctx RelationContext) error {
+	restriction := r.Tags[Restriction]
+	if r.Tags[TypeKey] != Restriction || restriction == "" {
+		return nil
+	}
+	var from, to []int32
+	via, found := int32(0), false
+	for _, m := range r.Members {
+		switch {
+		case m.Role == "from" && m.Type == osmpbf.WayType:
+			from, _ = ctx.Way(m.ID)
+		case m.Role == "to" && m.Type == osmpbf.WayType:
+			to, _ = ctx.Way(m.ID)
+		case m.Role == "via" && m.Type == osmpbf.NodeType:
+			via, found = ctx.Node(m.ID)
+		}
+	}
+	if !found || len(from) == 0 || len(to) == 0 {
+		return nil
+	}
+	for _, f := range wayNeighbors(from, via) {
+		for _, d := range wayNeighbors(to, via) {
+			if strings.HasPrefix(restriction, "only_") {
+				key := [2]int32{f, via}
+				if t.mandatory[key] == nil {
+					t.mandatory[key] = make(map[int32]bool)
+				}
+				t.mandatory[key][d] = true
+			} else if strings.HasPrefix(restriction, "no_") {
+				t.forbidden[[3]int32{f, via, d}] = true
+			}
+		}
+	}
+	return nil
+}
+
+// wayNeighbors returns the nodes next to a node along a way.
+func wayNeighbors(way []int32, node int32) []int32 {
+	neighbors := make([]int32, 0, 2)
+	for i, n := range way {
+		if n != node {
+			continue
+		}
+		if i > 0 {
+			neighbors = append(neighbors, way[i-1])
+		}
+		if i+1 < len(way) {
+			neighbors = append(neighbors, way[i+1])
+		}
+	}
+	return neighbors
+}
+
+// Len returns the number of turns forbidden explicitly, and of turns made mandatory.
+func (t *TurnRestrictions) Len() int {
+	n := len(t.forbidden)
+	for _, allowed := range t.mandatory {
+		n += len(allowed)
+	}
+	return n
+}
+
+// TurnCost is a TurnCostFunc for Graph.EdgeExpanded allowing every turn the restrictions do not forbid,
+// for free. U-turns are allowed unless a restriction forbids them.
+func (t *TurnRestrictions) TurnCost(from, via, to int32) (float32, bool) {
+	if t.forbidden[[3]int32{from, via, to}] {
+		return 0, false
+	}
+	if allowed, ok := t.mandatory[[2]int32{from, via}]; ok && !allowed[to] {
+		return 0, false
+	}
+	return 0, true
+}
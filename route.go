@@ -0,0 +1,284 @@
+package graph_search
+
+import (
+	"math"
+
+	"github.com/golang/geo/s2"
+)
+
+// Route is the result of a path reconstruction: the sequence of nodes visited from source to target
+// and the edges used between them. It replaces the positional [][]float64 returned by PathCoord with
+// a stable type shared by every search algorithm.
+type Route struct {
+	Nodes       []int32      // IDs of the visited nodes in the original graph, ordered from source to target
+	Edges       []Edge       // Edges[i] connects Nodes[i] to Nodes[i+1]
+	coordinates []Coordinate // Location of every node in Nodes
+}
+
+// NewRoute builds a Route from a sequence of node IDs, picking the cheapest edge between consecutive nodes.
+// The route is truncated at the first pair of nodes that are not connected in the graph.
+//
+// Parameters:
+//   - nodes: []int32 - IDs of the nodes of the route, ordered from source to target
+//   - g: Graph - The graph the nodes belong to
+//
+// Returns:
+//   - Route: The route with its edges and coordinates resolved
+func NewRoute(nodes []int32, g Graph) Route {
+	return newRoute(nodes, g, func(_ int, e Edge) float64 { return float64(e.Weight) })
+}
+
+// newRoute builds a Route from a sequence of node IDs, picking between parallel edges the one ranked
+// lowest by rank, which receives the position of the edge in the route.
+func newRoute(nodes []int32, g Graph, rank func(i int, e Edge) float64) Route {
+	edges := pathEdges(nodes, g, rank)
+	nodes = nodes[:min(len(nodes), len(edges)+1)]
+	coordinates := make([]Coordinate, 0, len(nodes))
+	for _, id := range nodes {
+		coordinates = append(coordinates, nodeCoordinate(g.Nodes[id]))
+	}
+	return Route{Nodes: nodes, Edges: edges, coordinates: coordinates}
+}
+
+// Route reconstructs the route leading from the source of the search to the given node of the search space.
+// Between parallel edges, the route takes the one whose distance matches the distance the search recorded,
+// so it follows the edges chosen under CostModel or EdgeWeights rather than the cheapest by Weight.
+//
+// Parameters:
+//   - target: int32 - ID of the node in the search space where the path ends
+//   - g: Graph - The graph the search was run on
+//
+// Returns:
+//   - Route: The reconstructed route from source to target
+func (sp SearchSpace) Route(target int32, g Graph) Route {
+	distances := sp.pathDistances(target)
+	return newRoute(sp.PathNodes(target), g, func(i int, e Edge) float64 {
+		return math.Abs(float64(e.Metadata.Distance) - float64(distances[i+1]-distances[i]))
+	})
+}
+
+// Coordinates returns the location of every node of the route, ordered from source to target.
+func (r Route) Coordinates() []Coordinate {
+	return r.coordinates
+}
+
+// LngLat returns the coordinates of the route as [longitude, latitude] pairs, the layout expected by GeoJSON.
+func (r Route) LngLat() [][]float64 {
+	result := make([][]float64, 0, len(r.coordinates))
+	for _, c := range r.coordinates {
+		result = append(result, []float64{c.Lng, c.Lat})
+	}
+	return result
+}
+
+// Length returns the total distance of the route in meters.
+func (r Route) Length() float64 {
+	length := 0.0
+	for _, e := range r.Edges {
+		length += float64(e.Metadata.Distance)
+	}
+	return length
+}
+
+// Duration returns the total travel time of the route in seconds, computed from the distance and speed of
+// every edge. Edges without speed are assumed to be traveled at AvgSpeedCar.
+func (r Route) Duration() float64 {
+	duration := 0.0
+	for _, e := range r.Edges {
+		duration += edgeDuration(e)
+	}
+	return duration
+}
+
+// Reverse returns the route traveling the same nodes in the opposite order, with the edges leading
+// backwards between them, so Edges[i] still connects Nodes[i] to Nodes[i+1]. Like NewRoute, the reversed
+// route is truncated at the first pair of nodes without an edge in the opposite direction, e.g., when the
+// route follows a one-way street.
+//
+// Parameters:
+//   - g: Graph - The graph the route belongs to
+//
+// Returns:
+//   - Route: The reversed route
+func (r Route) Reverse(g Graph) Route {
+	nodes := make([]int32, len(r.Nodes))
+	for i, id := range r.Nodes {
+		nodes[len(r.Nodes)-1-i] = id
+	}
+	return NewRoute(nodes, g)
+}
+
+// Simplify returns the coordinates of the route simplified with the Douglas–Peucker algorithm: vertices
+// closer than tolerance to the line joining the vertices kept around them are dropped. The first and last
+// coordinates are always kept. Tolerances of a few meters suit street level zooms; a tolerance of about
+// the ground size of a pixel suits any zoom level.
+//
+// Parameters:
+//   - tolerance: float64 - Largest distance in meters between the route and its simplification
+//
+// Returns:
+//   - []Coordinate: The kept coordinates, ordered from source to target
+func (r Route) Simplify(tolerance float64) []Coordinate {
+	n := len(r.coordinates)
+	if n <= 2 || tolerance <= 0 {
+		return append([]Coordinate(nil), r.coordinates...)
+	}
+	// Mercator meters are stretched by 1/cos(latitude), so the tolerance is stretched alike.
+	tolerance /= math.Cos(r.coordinates[0].Lat * math.Pi / 180)
+	points := make([][2]float64, n)
+	for i, c := range r.coordinates {
+		points[i][0], points[i][1] = LatLngToMeters(c.Lat, c.Lng)
+	}
+
+	keep := make([]bool, n)
+	keep[0], keep[n-1] = true, true
+	ranges := [][2]int{{0, n - 1}}
+	for len(ranges) > 0 {
+		first, last := ranges[len(ranges)-1][0], ranges[len(ranges)-1][1]
+		ranges = ranges[:len(ranges)-1]
+		farthest, distance := -1, tolerance
+		for i := first + 1; i < last; i++ {
+			if d := segmentDistance(points[i], points[first], points[last]); d > distance {
+				farthest, distance = i, d
+			}
+		}
+		if farthest >= 0 {
+			keep[farthest] = true
+			ranges = append(ranges, [2]int{first, farthest}, [2]int{farthest, last})
+		}
+	}
+
+	simplified := make([]Coordinate, 0)
+	for i, c := range r.coordinates {
+		if keep[i] {
+			simplified = append(simplified, c)
+		}
+	}
+	return simplified
+}
+
+// PointAlongRoute returns the point located at some distance from the start of a route, measured along its
+// edges like Route.Length. It places simulated vehicles and guidance announcements on the route.
+//
+// Parameters:
+//   - r: Route - The route to follow
+//   - meters: float64 - Distance from the start of the route, clamped to [0, r.Length()]
+//
+// Returns:
+//   - Coordinate: The point of the route, the zero Coordinate for an empty route
+func PointAlongRoute(r Route, meters float64) Coordinate {
+	if len(r.coordinates) == 0 {
+		return Coordinate{}
+	}
+	if meters <= 0 {
+		return r.coordinates[0]
+	}
+	for i, e := range r.Edges {
+		length := float64(e.Metadata.Distance)
+		if meters < length {
+			a, b := r.coordinates[i], r.coordinates[i+1]
+			p := s2.Interpolate(meters/length,
+				s2.PointFromLatLng(s2.LatLngFromDegrees(a.Lat, a.Lng)), s2.PointFromLatLng(s2.LatLngFromDegrees(b.Lat, b.Lng)))
+			ll := s2.LatLngFromPoint(p)
+			return Coordinate{Lat: ll.Lat.Degrees(), Lng: ll.Lng.Degrees()}
+		}
+		meters -= length
+	}
+	return r.coordinates[len(r.coordinates)-1]
+}
+
+// segmentDistance returns the distance between a point and the segment a-b, in the unit of the points.
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	ratio := 0.0
+	if length := dx*dx + dy*dy; length > 0 {
+		ratio = math.Min(math.Max(((p[0]-a[0])*dx+(p[1]-a[1])*dy)/length, 0), 1)
+	}
+	return math.Hypot(p[0]-a[0]-ratio*dx, p[1]-a[1]-ratio*dy)
+}
+
+// PathNodes reconstructs the sequence of original graph node IDs leading from the source of the search
+// to the given node of the search space.
+//
+// Parameters:
+//   - target: int32 - ID of the node in the search space where the path ends
+//
+// Returns:
+//   - []int32: IDs of the nodes in the original graph ordered from source to target
+func (sp SearchSpace) PathNodes(target int32) []int32 {
+	reversed := make([]int32, 0)
+	for current, steps := target, 0; steps < len(sp.Nodes); steps++ {
+		reversed = append(reversed, sp.Nodes[current].Rank)
+		if len(sp.IncomingEdges[current]) == 0 {
+			break
+		}
+		current = sp.IncomingEdges[current][0].ID
+	}
+	path := make([]int32, len(reversed))
+	for i, id := range reversed {
+		path[len(reversed)-1-i] = id
+	}
+	return path
+}
+
+// pathDistances returns the distance traveled by the search up to every node of the path returned by
+// PathNodes for the same target.
+func (sp SearchSpace) pathDistances(target int32) []float32 {
+	reversed := make([]float32, 0)
+	for current, steps := target, 0; steps < len(sp.Nodes); steps++ {
+		if len(sp.IncomingEdges[current]) == 0 {
+			reversed = append(reversed, 0)
+			break
+		}
+		reversed = append(reversed, sp.IncomingEdges[current][0].Metadata.Distance)
+		current = sp.IncomingEdges[current][0].ID
+	}
+	distances := make([]float32, len(reversed))
+	for i, d := range reversed {
+		distances[len(reversed)-1-i] = d
+	}
+	return distances
+}
+
+// settledIndex returns the index in the search space of the given node of the original graph, and false
+// if the search did not settle it.
+func (sp SearchSpace) settledIndex(id int32) (int32, bool) {
+	for i := len(sp.Nodes) - 1; i >= 0; i-- {
+		if sp.Nodes[i].Rank == id {
+			return int32(i), true
+		}
+	}
+	return 0, false
+}
+
+// pathEdges returns the edge ranked lowest by rank connecting each pair of consecutive nodes of a path,
+// the cheapest one between edges of equal rank.
+func pathEdges(path []int32, g Graph, rank func(i int, e Edge) float64) []Edge {
+	edges := make([]Edge, 0, len(path))
+	for i := 0; i+1 < len(path); i++ {
+		found, bestRank := false, 0.0
+		var best Edge
+		for _, e := range g.OutgoingEdges[path[i]] {
+			if e.ID != path[i+1] {
+				continue
+			}
+			if r := rank(i, e); !found || r < bestRank || r == bestRank && e.Weight < best.Weight {
+				best, bestRank, found = e, r, true
+			}
+		}
+		if !found {
+			break
+		}
+		edges = append(edges, best)
+	}
+	return edges
+}
+
+// edgeDuration returns the time in seconds needed to traverse an edge at its static speed.
+func edgeDuration(e Edge) float64 {
+	speed := float64(e.Metadata.Speed)
+	if speed <= 0 {
+		speed = AvgSpeedCar
+	}
+	return float64(e.Metadata.Distance) / (speed * MetersInAKilometer / SecondsInAnHour)
+}
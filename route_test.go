@@ -0,0 +1,58 @@
+package graph_search
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	// 0 <-> 1 -> 2 -> 3, with two parallel edges from 1 to 2 and a one-way street from 2 to 3.
+	g := EmptyGraph()
+	for _, c := range []Coordinate{{6.200, -75.580}, {6.201, -75.580}, {6.202, -75.580}, {6.203, -75.580}} {
+		g.AddNode(Node{Location: coordinatesToCellID(c.Lat, c.Lng)})
+	}
+	g.RelateNodes(g.Nodes[0], g.Nodes[1], 100, Bidirectional, MetaData{Distance: 100, Speed: 36})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 300, Bidirectional, MetaData{Distance: 300, Speed: 72})
+	g.RelateNodes(g.Nodes[1], g.Nodes[2], 200, Bidirectional, MetaData{Distance: 200})
+	g.RelateNodes(g.Nodes[2], g.Nodes[3], 50, LeftToRight, MetaData{Distance: 50, Speed: 18})
+
+	r := NewRoute([]int32{0, 1, 2, 3}, g)
+	if len(r.Edges) != 3 || r.Edges[1].Weight != 200 {
+		t.Fatalf("got edges %v, expected the cheapest of the parallel edges", r.Edges)
+	}
+	for i, e := range r.Edges {
+		if e.ID != r.Nodes[i+1] {
+			t.Fatalf("edge %d leads to %d, expected %d", i, e.ID, r.Nodes[i+1])
+		}
+	}
+	if r.Length() != 350 {
+		t.Fatalf("got length %f, expected 350", r.Length())
+	}
+	// 100 m at 36 km/h, 200 m at AvgSpeedCar and 50 m at 18 km/h.
+	if expected := 10 + 200/(AvgSpeedCar/3.6) + 10; math.Abs(r.Duration()-expected) > 1e-6 {
+		t.Fatalf("got duration %f, expected %f", r.Duration(), expected)
+	}
+	if c := r.Coordinates(); len(c) != 4 || math.Abs(c[3].Lat-6.203) > 1e-6 || math.Abs(r.LngLat()[3][0]+75.58) > 1e-6 {
+		t.Fatalf("got coordinates %v", c)
+	}
+
+	if truncated := NewRoute([]int32{0, 1, 3}, g); len(truncated.Nodes) != 2 || len(truncated.Edges) != 1 {
+		t.Fatalf("got %v, expected the route to stop at the missing edge 1 -> 3", truncated.Nodes)
+	}
+
+	back := NewRoute([]int32{0, 1, 2}, g).Reverse(g)
+	if len(back.Nodes) != 3 || back.Nodes[0] != 2 || back.Nodes[2] != 0 {
+		t.Fatalf("got reversed nodes %v", back.Nodes)
+	}
+	for i, e := range back.Edges {
+		if e.ID != back.Nodes[i+1] {
+			t.Fatalf("reversed edge %d leads to %d, expected %d", i, e.ID, back.Nodes[i+1])
+		}
+	}
+	if c := back.Coordinates(); math.Abs(c[0].Lat-6.202) > 1e-6 {
+		t.Fatalf("got reversed coordinates %v", c)
+	}
+	if oneWay := r.Reverse(g); len(oneWay.Nodes) != 1 || len(oneWay.Edges) != 0 {
+		t.Fatalf("got %v, expected the reversal to stop at the one-way street", oneWay.Nodes)
+	}
+}
@@ -0,0 +1,701 @@
+package graph_search
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/geo/s2"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	ErrCorruptGraph = errors.New("corrupt serialized graph")
+)
+
+// graphMagic starts every file written by Serialize. Files without it are read as a single gob value, the
+// format of older releases.
+const graphMagic = "GSGRAPH1"
+
+// A serialized graph is graphMagic followed by one section per field of the Graph. Every section is
+//
+//	[uint8 name length][name][uint8 flags][uint64 payload length][sha256 of the payload][payload]
+//
+// so a truncated or altered file is detected, and reported with the section it damaged, before the graph
+// is used. The payload is a gob value, possibly delta-encoded and compressed as told by the flags; the
+// checksum covers the stored bytes, so files are verified without being decompressed. Readers skip
+// sections they do not know.
+//
+// Graphs written with a TileLevel replace the field sections by a layout section followed by one section
+// per S2 cell, holding the nodes of the cell with their edges; see spatialLayout.
+const (
+	sectionNodes      = "nodes"
+	sectionOutgoing   = "outgoing"
+	sectionIncoming   = "incoming"
+	sectionOSMIDs     = "osmids"
+	sectionProperties = "properties"
+	sectionLayout     = "layout"
+	sectionTile       = "tile:" // followed by the index of the tile in the layout
+)
+
+// Flags of a section.
+const (
+	flagGzip  = 1 << 0 // payload compressed with gzip
+	flagZstd  = 1 << 1 // payload compressed with zstd
+	flagDelta = 1 << 2 // payload is a deltaNodes or deltaRelations
+)
+
+// Compression selects how SerializeWithOptions compresses the sections of a graph.
+type Compression uint8
+
+const (
+	CompressionNone Compression = iota // Sections are stored as they are encoded
+	CompressionGzip                    // Sections are compressed with gzip, widely supported
+	CompressionZstd                    // Sections are compressed with zstd, smaller and faster to load than gzip
+)
+
+// SerializeOptions configures how SerializeWithOptions writes a graph. The zero value writes plain gob
+// sections like Serialize. Whatever the options, Load and Deserialize read the file transparently.
+type SerializeOptions struct {
+	Compression Compression // Compression of the sections
+	// Delta stores node locations and edge targets as differences from the previous node and from the
+	// source of the edge. Nearby nodes have close S2 cell IDs and edges mostly join nodes with close IDs,
+	// so the varints of gob become a few bytes long and compress much better.
+	Delta bool
+	// TileLevel, when positive, groups the nodes in S2 cells of this level stored in separate sections,
+	// so LoadSubgraph only reads the cells overlapping its bounding box. Levels 8 to 10 suit country
+	// graphs. Delta applies to untiled graphs only.
+	TileLevel int
+}
+
+// spatialLayout is the index of a tiled graph file.
+type spatialLayout struct {
+	Level   int       // S2 level of the tiles
+	Nodes   int32     // Total number of nodes
+	Cells   []uint64  // S2 cell of every tile
+	Members [][]int32 // Members[i] are the IDs of the nodes of tile i, in increasing order
+}
+
+// spatialTile holds the nodes of a tile with everything attached to them, aligned with the members of the
+// tile. Edges keep the IDs of the full graph.
+type spatialTile struct {
+	Nodes      []Node
+	Outgoing   [][]Edge
+	Incoming   [][]Edge
+	OSMIDs     []int64      // Empty if the graph has no OSM IDs
+	Properties []Properties // Empty if no node of the tile has properties
+}
+
+// tileSection returns the name of the section of tile i.
+func tileSection(i int) string {
+	return sectionTile + strconv.Itoa(i)
+}
+
+// graphSections returns the sections of a graph in the order they are written, each with a pointer to the
+// field it holds.
+func graphSections(g *Graph) []struct {
+	name  string
+	value any
+} {
+	return []struct {
+		name  string
+		value any
+	}{
+		{sectionNodes, &g.Nodes},
+		{sectionOutgoing, &g.OutgoingEdges},
+		{sectionIncoming, &g.IncomingEdges},
+		{sectionOSMIDs, &g.OSMIDs},
+		{sectionProperties, &g.Properties},
+	}
+}
+
+// deltaNodes is the delta encoding of the nodes of a graph.
+type deltaNodes struct {
+	IDs       []int32 // ID of every node minus its index, usually 0
+	Locations []int64 // Location of every node minus the one of the previous node
+	Ranks     []int32
+}
+
+// deltaRelations is the delta encoding of the edges of a graph, stored by column.
+type deltaRelations struct {
+	Degrees  []int32 // Number of edges of every node
+	IDs      []int32 // ID of every edge minus the ID of the node it belongs to
+	Weights  []float32
+	Metadata []MetaData
+}
+
+// encodeDelta returns the delta encoding of a section, or its value if it has none.
+func encodeDelta(value any) (any, bool) {
+	switch v := value.(type) {
+	case *[]Node:
+		d := deltaNodes{IDs: make([]int32, len(*v)), Locations: make([]int64, len(*v)), Ranks: make([]int32, len(*v))}
+		previous := uint64(0)
+		for i, n := range *v {
+			d.IDs[i] = n.ID - int32(i)
+			d.Locations[i] = int64(n.Location - previous)
+			d.Ranks[i] = n.Rank
+			previous = n.Location
+		}
+		return &d, true
+	case *Relations:
+		d := deltaRelations{Degrees: make([]int32, len(*v))}
+		for from, edges := range *v {
+			d.Degrees[from] = int32(len(edges))
+			for _, e := range edges {
+				d.IDs = append(d.IDs, e.ID-int32(from))
+				d.Weights = append(d.Weights, e.Weight)
+				d.Metadata = append(d.Metadata, e.Metadata)
+			}
+		}
+		return &d, true
+	}
+	return value, false
+}
+
+// decodeDelta decodes a delta-encoded section into its field.
+func decodeDelta(dec *gob.Decoder, value any) error {
+	switch v := value.(type) {
+	case *[]Node:
+		var d deltaNodes
+		if err := dec.Decode(&d); err != nil {
+			return err
+		}
+		if len(d.Locations) != len(d.IDs) || len(d.Ranks) != len(d.IDs) {
+			return errors.New("inconsistent node columns")
+		}
+		*v = make([]Node, len(d.IDs))
+		location := uint64(0)
+		for i := range *v {
+			location += uint64(d.Locations[i])
+			(*v)[i] = Node{ID: d.IDs[i] + int32(i), Location: location, Rank: d.Ranks[i]}
+		}
+		return nil
+	case *Relations:
+		var d deltaRelations
+		if err := dec.Decode(&d); err != nil {
+			return err
+		}
+		if len(d.Weights) != len(d.IDs) || len(d.Metadata) != len(d.IDs) {
+			return errors.New("inconsistent edge columns")
+		}
+		*v = make(Relations, len(d.Degrees))
+		next := 0
+		for from, degree := range d.Degrees {
+			if degree < 0 || int(degree) > len(d.IDs)-next {
+				return errors.New("inconsistent edge degrees")
+			}
+			if degree == 0 {
+				continue
+			}
+			edges := make([]Edge, degree)
+			for i := range edges {
+				edges[i] = Edge{ID: d.IDs[next] + int32(from), Weight: d.Weights[next], Metadata: d.Metadata[next]}
+				next++
+			}
+			(*v)[from] = edges
+		}
+		return nil
+	}
+	return dec.Decode(value)
+}
+
+// writeGraph writes a graph in the sectioned format.
+func writeGraph(w io.Writer, g *Graph, opts SerializeOptions) error {
+	if _, err := io.WriteString(w, graphMagic); err != nil {
+		return err
+	}
+	if opts.TileLevel > 0 {
+		return writeTiles(w, g, opts)
+	}
+	for _, s := range graphSections(g) {
+		if err := writeSection(w, s.name, s.value, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeTiles writes the layout and the tiles of a graph grouped in S2 cells of opts.TileLevel.
+func writeTiles(w io.Writer, g *Graph, opts SerializeOptions) error {
+	layout := spatialLayout{Level: opts.TileLevel, Nodes: int32(len(g.Nodes))}
+	tiles := make(map[uint64]int)
+	for id, n := range g.Nodes {
+		cell := uint64(s2.CellID(n.Location).Parent(opts.TileLevel))
+		i, ok := tiles[cell]
+		if !ok {
+			i = len(layout.Cells)
+			tiles[cell] = i
+			layout.Cells = append(layout.Cells, cell)
+			layout.Members = append(layout.Members, nil)
+		}
+		layout.Members[i] = append(layout.Members[i], int32(id))
+	}
+	if err := writeSection(w, sectionLayout, &layout, opts); err != nil {
+		return err
+	}
+
+	for i, members := range layout.Members {
+		t := spatialTile{
+			Nodes:    make([]Node, len(members)),
+			Outgoing: make([][]Edge, len(members)),
+			Incoming: make([][]Edge, len(members)),
+		}
+		for j, id := range members {
+			t.Nodes[j] = g.Nodes[id]
+			if int(id) < len(g.OutgoingEdges) {
+				t.Outgoing[j] = g.OutgoingEdges[id]
+			}
+			if int(id) < len(g.IncomingEdges) {
+				t.Incoming[j] = g.IncomingEdges[id]
+			}
+			if int(id) < len(g.OSMIDs) {
+				if t.OSMIDs == nil {
+					t.OSMIDs = make([]int64, len(members))
+				}
+				t.OSMIDs[j] = g.OSMIDs[id]
+			}
+			if int(id) < len(g.Properties) && len(g.Properties[id]) > 0 {
+				if t.Properties == nil {
+					t.Properties = make([]Properties, len(members))
+				}
+				t.Properties[j] = g.Properties[id]
+			}
+		}
+		if err := writeSection(w, tileSection(i), &t, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSection encodes a value as the named section, with the encodings selected by opts.
+func writeSection(w io.Writer, name string, value any, opts SerializeOptions) error {
+	var payload bytes.Buffer
+	flags := byte(0)
+	if opts.Delta {
+		var delta bool
+		if value, delta = encodeDelta(value); delta {
+			flags |= flagDelta
+		}
+	}
+	if err := encodeSection(&payload, value, opts.Compression); err != nil {
+		return fmt.Errorf("section %s: %w", name, err)
+	}
+	switch opts.Compression {
+	case CompressionGzip:
+		flags |= flagGzip
+	case CompressionZstd:
+		flags |= flagZstd
+	}
+
+	sum := sha256.Sum256(payload.Bytes())
+	header := make([]byte, 0, 1+len(name)+1+8+len(sum))
+	header = append(header, byte(len(name)))
+	header = append(header, name...)
+	header = append(header, flags)
+	header = binary.BigEndian.AppendUint64(header, uint64(payload.Len()))
+	header = append(header, sum[:]...)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+// encodeSection gob-encodes a value into w, compressed as requested.
+func encodeSection(w io.Writer, value any, c Compression) error {
+	var compressor io.WriteCloser
+	switch c {
+	case CompressionNone:
+		return gob.NewEncoder(w).Encode(value)
+	case CompressionGzip:
+		compressor = gzip.NewWriter(w)
+	case CompressionZstd:
+		z, err := zstd.NewWriter(w)
+		if err != nil {
+			return err
+		}
+		compressor = z
+	default:
+		return fmt.Errorf("unknown compression %d", c)
+	}
+	if err := gob.NewEncoder(compressor).Encode(value); err != nil {
+		compressor.Close()
+		return err
+	}
+	return compressor.Close()
+}
+
+// decodeSection decodes the payload of a section into its field, undoing the encodings told by flags.
+func decodeSection(payload io.Reader, flags byte, value any) error {
+	switch {
+	case flags&flagGzip != 0:
+		z, err := gzip.NewReader(payload)
+		if err != nil {
+			return err
+		}
+		defer z.Close()
+		payload = z
+	case flags&flagZstd != 0:
+		z, err := zstd.NewReader(payload)
+		if err != nil {
+			return err
+		}
+		defer z.Close()
+		payload = z
+	}
+	dec := gob.NewDecoder(payload)
+	if flags&flagDelta != 0 {
+		return decodeDelta(dec, value)
+	}
+	return dec.Decode(value)
+}
+
+// readSections reads the sections following the magic of a serialized graph and checks their checksums.
+// decode, when not nil, is given the flags and the stored payload of every section as it is read.
+//
+// Returns:
+//   - map[string]bool: The names of the sections read
+//   - error: An error wrapping ErrCorruptGraph, or the error returned by the reader itself
+func readSections(r *bufio.Reader, decode func(name string, flags byte, payload io.Reader) error) (map[string]bool, error) {
+	seen := make(map[string]bool)
+	for {
+		n, err := r.ReadByte()
+		if err == io.EOF {
+			return seen, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		header := make([]byte, int(n)+1+8+sha256.Size)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, sectionError("header", err)
+		}
+		name := string(header[:n])
+		flags := header[n]
+		length := binary.BigEndian.Uint64(header[n+1:])
+		if err := readPayload(r, name, flags, length, header[int(n)+1+8:], decode); err != nil {
+			return nil, err
+		}
+		seen[name] = true
+	}
+}
+
+// readPayload reads the payload of a section from r, handing it to decode if not nil. The section is only
+// valid once decode returned and the whole payload matched its checksum.
+func readPayload(r io.Reader, name string, flags byte, length uint64, sum []byte, decode func(name string, flags byte, payload io.Reader) error) error {
+	hash := sha256.New()
+	body := &countingReader{r: io.LimitReader(r, int64(min(length, 1<<62)))}
+	if decode != nil {
+		if err := decode(name, flags, io.TeeReader(body, hash)); err != nil {
+			return sectionError(name, err)
+		}
+	}
+	if _, err := io.Copy(hash, body); err != nil {
+		return sectionError(name, err)
+	}
+	if uint64(body.n) != length {
+		return fmt.Errorf("section %s: truncated after %d of %d bytes: %w", name, body.n, length, ErrCorruptGraph)
+	}
+	if !bytes.Equal(hash.Sum(nil), sum) {
+		return fmt.Errorf("section %s: checksum mismatch: %w", name, ErrCorruptGraph)
+	}
+	return nil
+}
+
+// checkSections reports the first section missing from a file, given its layout if it is tiled.
+func checkSections(seen map[string]bool, layout *spatialLayout) error {
+	var required []string
+	if layout == nil {
+		for _, s := range graphSections(&Graph{}) {
+			required = append(required, s.name)
+		}
+	} else {
+		for i := range layout.Cells {
+			required = append(required, tileSection(i))
+		}
+	}
+	for _, name := range required {
+		if !seen[name] {
+			return fmt.Errorf("section %s: missing: %w", name, ErrCorruptGraph)
+		}
+	}
+	return nil
+}
+
+// decodeLayout decodes and checks the layout section of a tiled graph.
+func decodeLayout(payload io.Reader, flags byte) (*spatialLayout, error) {
+	layout := new(spatialLayout)
+	if err := decodeSection(payload, flags, layout); err != nil {
+		return nil, err
+	}
+	if len(layout.Members) != len(layout.Cells) || layout.Nodes < 0 {
+		return nil, errors.New("inconsistent layout")
+	}
+	for _, members := range layout.Members {
+		for _, id := range members {
+			if id < 0 || id >= layout.Nodes {
+				return nil, errors.New("inconsistent layout")
+			}
+		}
+	}
+	return layout, nil
+}
+
+// decodeTile decodes the section of a tile and checks it matches the members of the tile.
+func decodeTile(payload io.Reader, flags byte, members []int32) (*spatialTile, error) {
+	t := new(spatialTile)
+	if err := decodeSection(payload, flags, t); err != nil {
+		return nil, err
+	}
+	n := len(members)
+	if len(t.Nodes) != n || len(t.Outgoing) != n || len(t.Incoming) != n ||
+		len(t.OSMIDs) != 0 && len(t.OSMIDs) != n || len(t.Properties) != 0 && len(t.Properties) != n {
+		return nil, errors.New("tile does not match the layout")
+	}
+	return t, nil
+}
+
+// tileIndex returns the index of the tile a section holds, false if it holds no tile of the layout.
+func tileIndex(name string, layout *spatialLayout) (int, bool) {
+	suffix, ok := strings.CutPrefix(name, sectionTile)
+	if !ok || layout == nil {
+		return 0, false
+	}
+	i, err := strconv.Atoi(suffix)
+	return i, err == nil && i >= 0 && i < len(layout.Cells)
+}
+
+// sectionError wraps an error met while reading a section with ErrCorruptGraph.
+func sectionError(name string, err error) error {
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return fmt.Errorf("section %s: %w: %w", name, ErrCorruptGraph, err)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// openGraph opens a serialized graph and reports whether it starts with graphMagic. The reader is
+// positioned after the magic, or at the start of legacy files.
+func openGraph(s Storage, name string) (StorageObject, *bufio.Reader, bool, error) {
+	object, err := s.Open(name)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	r := bufio.NewReader(io.NewSectionReader(object, 0, object.Size()))
+	magic, err := r.Peek(len(graphMagic))
+	if err != nil && err != io.EOF {
+		object.Close()
+		return nil, nil, false, err
+	}
+	if string(magic) != graphMagic {
+		return object, r, false, nil
+	}
+	r.Discard(len(graphMagic))
+	return object, r, true, nil
+}
+
+// Verify checks the integrity of a serialized graph without decoding it: every section written by
+// Serialize must be present, complete and match its checksum. Routing servers should verify a file, or
+// load it with Load, before serving it, since Deserialize returns an empty graph for damaged files.
+//
+// Parameters:
+//   - filePath: string - The path to the file written by Serialize
+//
+// Returns:
+//   - error: nil if the file is intact, an error wrapping ErrCorruptGraph naming the damaged section,
+//     or the error returned when opening or reading the file. Files written before checksums were
+//     added cannot be verified and wrap ErrCorruptGraph too
+func Verify(filePath string) error {
+	return VerifyFrom(FileStorage(""), filePath)
+}
+
+// VerifyFrom checks the integrity of a serialized graph kept in a storage, like Verify.
+//
+// Parameters:
+//   - s: Storage - The storage holding the graph
+//   - name: string - The name of the object written by SerializeTo
+//
+// Returns:
+//   - error: nil if the object is intact, an error wrapping ErrCorruptGraph naming the damaged section,
+//     or the error returned when opening or reading the object
+func VerifyFrom(s Storage, name string) error {
+	file, r, sectioned, err := openGraph(s, name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if !sectioned {
+		return fmt.Errorf("%s: no checksums: %w", name, ErrCorruptGraph)
+	}
+	var layout *spatialLayout
+	seen, err := readSections(r, func(name string, flags byte, payload io.Reader) (err error) {
+		if name == sectionLayout {
+			layout, err = decodeLayout(payload, flags)
+		}
+		return err
+	})
+	if err == nil {
+		err = checkSections(seen, layout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+// Load reads a graph written by Serialize, checking the checksum of every section as it is decoded.
+// Files written before checksums were added are decoded as they were, without verification.
+//
+// Parameters:
+//   - filePath: string - The path to the file written by Serialize
+//
+// Returns:
+//   - Graph: The decoded graph, empty if an error occurred
+//   - error: An error wrapping ErrCorruptGraph if the file is truncated or altered, or the error
+//     returned when opening or reading the file
+func Load(filePath string) (Graph, error) {
+	return LoadFrom(FileStorage(""), filePath)
+}
+
+// LoadFrom reads a graph kept in a storage, like Load.
+//
+// Parameters:
+//   - s: Storage - The storage holding the graph
+//   - name: string - The name of the object written by SerializeTo
+//
+// Returns:
+//   - Graph: The decoded graph, empty if an error occurred
+//   - error: An error wrapping ErrCorruptGraph if the object is truncated or altered, or the error
+//     returned when opening or reading the object
+func LoadFrom(s Storage, name string) (Graph, error) {
+	file, r, sectioned, err := openGraph(s, name)
+	if err != nil {
+		return Graph{}, err
+	}
+	defer file.Close()
+
+	var g Graph
+	if !sectioned {
+		if err := gob.NewDecoder(r).Decode(&g); err != nil {
+			return Graph{}, fmt.Errorf("%s: %w: %w", name, ErrCorruptGraph, err)
+		}
+		return g, nil
+	}
+	fields := make(map[string]any)
+	for _, section := range graphSections(&g) {
+		fields[section.name] = section.value
+	}
+	var layout *spatialLayout
+	seen, err := readSections(r, func(name string, flags byte, payload io.Reader) error {
+		if field, ok := fields[name]; ok {
+			return decodeSection(payload, flags, field)
+		}
+		if name == sectionLayout {
+			var err error
+			if layout, err = decodeLayout(payload, flags); err != nil {
+				return err
+			}
+			g.Nodes = make([]Node, layout.Nodes)
+			g.OutgoingEdges = make(Relations, layout.Nodes)
+			g.IncomingEdges = make(Relations, layout.Nodes)
+			return nil
+		}
+		if i, ok := tileIndex(name, layout); ok {
+			t, err := decodeTile(payload, flags, layout.Members[i])
+			if err != nil {
+				return err
+			}
+			g.addTile(t, layout.Members[i], int(layout.Nodes))
+		}
+		return nil
+	})
+	if err == nil {
+		err = checkSections(seen, layout)
+	}
+	if err != nil {
+		return Graph{}, fmt.Errorf("%s: %w", name, err)
+	}
+	return g, nil
+}
+
+// addTile stores the nodes of a tile at their IDs in a graph of n nodes.
+func (g *Graph) addTile(t *spatialTile, members []int32, n int) {
+	if len(t.OSMIDs) > 0 && len(g.OSMIDs) == 0 {
+		g.OSMIDs = make([]int64, n)
+	}
+	if len(t.Properties) > 0 && len(g.Properties) == 0 {
+		g.Properties = make([]Properties, n)
+	}
+	for j, id := range members {
+		g.Nodes[id] = t.Nodes[j]
+		g.OutgoingEdges[id] = t.Outgoing[j]
+		g.IncomingEdges[id] = t.Incoming[j]
+		if len(t.OSMIDs) > 0 {
+			g.OSMIDs[id] = t.OSMIDs[j]
+		}
+		if len(t.Properties) > 0 {
+			g.Properties[id] = t.Properties[j]
+		}
+	}
+}
+
+// sectionEntry locates a section in a serialized graph file.
+type sectionEntry struct {
+	flags  byte
+	offset int64 // Offset of the payload in the file
+	length uint64
+	sum    []byte
+}
+
+// indexSections reads the section headers of a serialized graph, seeking over the payloads.
+func indexSections(file StorageObject) (map[string]sectionEntry, error) {
+	entries := make(map[string]sectionEntry)
+	for offset := int64(len(graphMagic)); offset < file.Size(); {
+		var n [1]byte
+		if _, err := file.ReadAt(n[:], offset); err != nil {
+			return nil, sectionError("header", err)
+		}
+		header := make([]byte, int(n[0])+1+8+sha256.Size)
+		if _, err := file.ReadAt(header, offset+1); err != nil {
+			return nil, sectionError("header", err)
+		}
+		name := string(header[:n[0]])
+		e := sectionEntry{
+			flags:  header[n[0]],
+			offset: offset + 1 + int64(len(header)),
+			length: binary.BigEndian.Uint64(header[n[0]+1:]),
+			sum:    header[int(n[0])+1+8:],
+		}
+		if e.length > uint64(file.Size()-e.offset) {
+			return nil, fmt.Errorf("section %s: truncated after %d of %d bytes: %w", name, file.Size()-e.offset, e.length, ErrCorruptGraph)
+		}
+		entries[name] = e
+		offset = e.offset + int64(e.length)
+	}
+	return entries, nil
+}
+
+// readEntry reads a section located by indexSections, handing its payload to decode.
+func readEntry(file io.ReaderAt, name string, e sectionEntry, decode func(name string, flags byte, payload io.Reader) error) error {
+	return readPayload(io.NewSectionReader(file, e.offset, int64(e.length)), name, e.flags, e.length, e.sum, decode)
+}
@@ -0,0 +1,204 @@
+package graph_search
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoTrips = errors.New("no random trip could be generated")
+)
+
+// DefaultTripAttempts is the number of random coordinates drawn per trip endpoint before RandomTrips gives
+// up, e.g., when the bounding box mostly covers water.
+const DefaultTripAttempts = 100
+
+// Trip is a random query between two coordinates, generated by RandomTrips.
+type Trip struct {
+	From Coordinate
+	To   Coordinate
+}
+
+// SimulationConfig configures a load test run by Engine.Simulate.
+type SimulationConfig struct {
+	// Queries is the number of random trips replayed.
+	Queries int
+
+	// QPS is the rate at which queries are started, as fast as the workers allow when zero.
+	QPS float64
+
+	// Concurrency is the maximum number of queries running at the same time, runtime.GOMAXPROCS(0) if not positive.
+	Concurrency int
+
+	// Seed makes the generated trips reproducible.
+	Seed int64
+
+	// Box is the area where trips start and end, the bounds of the graph when zero.
+	Box BoundingBox
+
+	// Route configures the replayed queries; its Snap.Components is set to the components of the engine.
+	Route RouteOptions
+}
+
+// SimulationReport summarizes the latencies observed during a load test.
+type SimulationReport struct {
+	Queries int           // Number of queries replayed
+	Errors  int           // Number of queries that failed
+	Elapsed time.Duration // Wall time of the replay
+	QPS     float64       // Queries completed per second
+	Mean    time.Duration // Mean latency
+	P50     time.Duration // Median latency
+	P90     time.Duration // 90th percentile latency
+	P99     time.Duration // 99th percentile latency
+	Max     time.Duration // Slowest query
+}
+
+// RandomTrips generates random trips between coordinates of a bounding box that are routable: both
+// endpoints are snapped onto edges of the largest strongly connected component, so every trip has a route.
+// Coordinates far from any road are drawn again.
+//
+// Parameters:
+//   - n: int - Number of trips
+//   - box: BoundingBox - The area where trips start and end, the bounds of the graph when zero
+//   - rng: *rand.Rand - Source of randomness
+//
+// Returns:
+//   - []Trip: The trips, between the snapped coordinates
+//   - error: ErrNoTrips if no coordinate of the box could be snapped after DefaultTripAttempts draws
+func (e *Engine) RandomTrips(n int, box BoundingBox, rng *rand.Rand) ([]Trip, error) {
+	if box == (BoundingBox{}) {
+		box = e.graph.Bounds()
+	}
+	opts := SnapOptions{Components: e.Components()}
+	draw := func() (Coordinate, error) {
+		for attempt := 0; attempt < DefaultTripAttempts; attempt++ {
+			c := Coordinate{
+				Lat: box.Min.Lat + rng.Float64()*(box.Max.Lat-box.Min.Lat),
+				Lng: box.Min.Lng + rng.Float64()*(box.Max.Lng-box.Min.Lng),
+			}
+			if snap, err := e.SnapWithOptions(c, opts); err == nil {
+				return snap.Point, nil
+			}
+		}
+		return Coordinate{}, ErrNoTrips
+	}
+
+	trips := make([]Trip, 0, n)
+	for len(trips) < n {
+		from, err := draw()
+		if err != nil {
+			return nil, err
+		}
+		to, err := draw()
+		if err != nil {
+			return nil, err
+		}
+		trips = append(trips, Trip{From: from, To: to})
+	}
+	return trips, nil
+}
+
+// Simulate load tests the engine: it generates random routable trips with RandomTrips and replays them
+// with RouteBetween at the configured rate, measuring the latency of every query. Trips are generated
+// before the clock starts, so snapping them is not measured.
+//
+// Parameters:
+//   - ctx: context.Context - Stops the replay early when cancelled
+//   - cfg: SimulationConfig - Number of queries, rate, concurrency and area
+//
+// Returns:
+//   - SimulationReport: The latency percentiles of the queries replayed
+//   - error: An error wrapping ErrNoTrips if no trip could be generated, or the error of ctx if cancelled
+func (e *Engine) Simulate(ctx context.Context, cfg SimulationConfig) (SimulationReport, error) {
+	trips, err := e.RandomTrips(cfg.Queries, cfg.Box, rand.New(rand.NewSource(cfg.Seed)))
+	if err != nil {
+		return SimulationReport{}, fmt.Errorf("simulation: %w", err)
+	}
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = runtime.GOMAXPROCS(0)
+	}
+	opts := cfg.Route
+	opts.Snap.Components = e.Components()
+
+	latencies := make([]time.Duration, 0, len(trips))
+	errs := 0
+	var mu sync.Mutex
+	jobs := make(chan Trip)
+	var wg sync.WaitGroup
+	for w := 0; w < min(concurrency, len(trips)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for trip := range jobs {
+				start := time.Now()
+				_, err := e.RouteBetween(trip.From, trip.To, opts)
+				latency := time.Since(start)
+				mu.Lock()
+				latencies = append(latencies, latency)
+				if err != nil {
+					errs++
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if cfg.QPS > 0 {
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / cfg.QPS))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	start := time.Now()
+	for i, trip := range trips {
+		if tick != nil && i > 0 {
+			select {
+			case <-ctx.Done():
+			case <-tick:
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- trip
+	}
+	close(jobs)
+	wg.Wait()
+
+	report := summarizeLatencies(latencies, time.Since(start))
+	report.Errors = errs
+	return report, ctx.Err()
+}
+
+// summarizeLatencies computes the statistics of a report from the latencies of its queries.
+func summarizeLatencies(latencies []time.Duration, elapsed time.Duration) SimulationReport {
+	r := SimulationReport{Queries: len(latencies), Elapsed: elapsed}
+	if len(latencies) == 0 {
+		return r
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	total := time.Duration(0)
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) time.Duration {
+		// Nearest-rank method: the smallest latency greater than or equal to p of the queries.
+		rank := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return latencies[max(min(rank, len(latencies)-1), 0)]
+	}
+	r.Mean = total / time.Duration(len(latencies))
+	r.P50, r.P90, r.P99 = percentile(0.5), percentile(0.9), percentile(0.99)
+	r.Max = latencies[len(latencies)-1]
+	if elapsed > 0 {
+		r.QPS = float64(len(latencies)) / elapsed.Seconds()
+	}
+	return r
+}
@@ -0,0 +1,310 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/golang/geo/s2"
+)
+
+var (
+	ErrNoCandidates = errors.New("no edge found near the coordinate")
+	ErrSnapTooFar   = errors.New("snap distance exceeded")
+)
+
+// DefaultSnapRadius is the search radius, in projected meters, used to collect candidate edges around a coordinate.
+const DefaultSnapRadius = 200
+
+// DefaultBearingTolerance is the largest difference, in degrees, between the heading of a vehicle and the
+// bearing of an edge for the edge to be considered aligned with the vehicle.
+const DefaultBearingTolerance = 45
+
+// SnapOptions customizes how SnapToEdgeWithOptions picks the edge a coordinate is snapped onto.
+type SnapOptions struct {
+	// Radius is the search radius in projected meters, or in meters for indexes using HaversineMetric,
+	// DefaultSnapRadius when zero.
+	Radius float64
+
+	// UseBearing enables heading-aware snapping: edges whose bearing differs from Bearing by more than
+	// BearingTolerance are only picked when no aligned edge is found, so a moving vehicle is not snapped
+	// onto the opposite carriageway of a divided road.
+	UseBearing bool
+
+	// Bearing is the heading of the vehicle in degrees clockwise from north.
+	Bearing float64
+
+	// BearingTolerance is the largest accepted heading difference in degrees, DefaultBearingTolerance when zero.
+	BearingTolerance float64
+
+	// MaxSnapDistance is the largest distance in meters between a coordinate and the edge it is snapped
+	// onto, no limit when zero. Beyond it snapping fails with a *SnapDistanceError describing the closest
+	// road, even when that road lies outside Radius, so a point in the ocean or outside the extract is
+	// reported instead of being routed from a road kilometers away.
+	MaxSnapDistance float64
+
+	// Components, when set, restricts snapping to the edges joining two nodes of the largest strongly
+	// connected component, so a coordinate is never snapped onto a disconnected service road from which
+	// nothing can be reached. Compute it once with StronglyConnectedComponents, or use Engine.Components.
+	Components *Components
+}
+
+// EdgeSnap describes the projection of a coordinate onto a directed edge of the graph.
+type EdgeSnap struct {
+	Input    Coordinate // Coordinate that was snapped
+	From     int32      // ID of the node where the edge starts
+	To       int32      // ID of the node where the edge ends
+	Edge     Edge       // The edge the coordinate was projected onto
+	Point    Coordinate // Projected coordinate lying on the edge
+	Offset   float64    // Distance in meters from the start of the edge to the projected point
+	Distance float64    // Distance in meters between the original coordinate and the projected point
+}
+
+// SnapDiagnostics summarizes where a coordinate was snapped, to explain suspicious routes.
+type SnapDiagnostics struct {
+	Input    Coordinate // Coordinate that was snapped
+	Snapped  Coordinate // Projected coordinate on the road
+	Distance float64    // Distance in meters between Input and Snapped
+	RoadType string     // Road type of the edge snapped onto
+	Name     string     // Name of the way snapped onto, empty if unnamed
+}
+
+// Diagnostics returns the diagnostics of the snap.
+func (s EdgeSnap) Diagnostics() SnapDiagnostics {
+	return SnapDiagnostics{
+		Input:    s.Input,
+		Snapped:  s.Point,
+		Distance: s.Distance,
+		RoadType: s.Edge.Metadata.RoadType,
+		Name:     s.Edge.Metadata.Name,
+	}
+}
+
+// SnapDistanceError reports a coordinate farther than SnapOptions.MaxSnapDistance from the closest road.
+// It wraps ErrSnapTooFar.
+type SnapDistanceError struct {
+	MaxDistance float64         // The MaxSnapDistance of the options
+	Closest     SnapDiagnostics // Snap onto the closest road found
+}
+
+func (e *SnapDistanceError) Error() string {
+	return fmt.Sprintf("coordinate (%f, %f) is %.0f m from the closest road (%s), more than %.0f m: %v",
+		e.Closest.Input.Lat, e.Closest.Input.Lng, e.Closest.Distance, e.Closest.RoadType, e.MaxDistance, ErrSnapTooFar)
+}
+
+func (e *SnapDistanceError) Unwrap() error {
+	return ErrSnapTooFar
+}
+
+// Remaining returns the distance in meters from the projected point to the end of the edge.
+func (s EdgeSnap) Remaining() float64 {
+	return math.Max(float64(s.Edge.Metadata.Distance)-s.Offset, 0)
+}
+
+// SnapToEdge projects a coordinate onto the closest edge of the graph.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex
+//   - c: Coordinate - The coordinate to snap
+//   - radius: float64 - Search radius in projected meters around c used to collect candidate edges
+//
+// Returns:
+//   - EdgeSnap: The projection onto the closest edge
+//   - error: ErrNoCandidates if no edge starts inside the search radius
+func (g Graph) SnapToEdge(index *KDTree, c Coordinate, radius float64) (EdgeSnap, error) {
+	return g.SnapToEdgeWithOptions(index, c, SnapOptions{Radius: radius})
+}
+
+// SnapToEdgeWithOptions projects a coordinate onto the closest edge of the graph, preferring edges aligned
+// with the heading of the vehicle when SnapOptions.UseBearing is set.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex
+//   - c: Coordinate - The coordinate to snap
+//   - opts: SnapOptions - Search radius and heading of the vehicle
+//
+// Returns:
+//   - EdgeSnap: The projection onto the closest aligned edge, or onto the closest edge if none is aligned
+//   - error: ErrNoCandidates if no edge starts inside the search radius, or a *SnapDistanceError if the
+//     edge is farther than opts.MaxSnapDistance
+func (g Graph) SnapToEdgeWithOptions(index *KDTree, c Coordinate, opts SnapOptions) (EdgeSnap, error) {
+	if opts.Radius <= 0 {
+		opts.Radius = DefaultSnapRadius
+	}
+	if opts.BearingTolerance <= 0 {
+		opts.BearingTolerance = DefaultBearingTolerance
+	}
+	candidates := opts.filter(g.SnapCandidates(index, c, opts.Radius))
+	if len(candidates) == 0 && opts.MaxSnapDistance > 0 {
+		candidates = opts.filter(g.nearestCandidates(index, c, opts))
+	}
+	if len(candidates) == 0 {
+		return EdgeSnap{}, ErrNoCandidates
+	}
+	var best, bestAligned *EdgeSnap
+	for i := range candidates {
+		candidate := &candidates[i]
+		if best == nil || candidate.Distance < best.Distance {
+			best = candidate
+		}
+		if opts.UseBearing && g.aligned(*candidate, opts.Bearing, opts.BearingTolerance) &&
+			(bestAligned == nil || candidate.Distance < bestAligned.Distance) {
+			bestAligned = candidate
+		}
+	}
+	if bestAligned != nil {
+		best = bestAligned
+	}
+	if opts.MaxSnapDistance > 0 && best.Distance > opts.MaxSnapDistance {
+		return EdgeSnap{}, &SnapDistanceError{MaxDistance: opts.MaxSnapDistance, Closest: best.Diagnostics()}
+	}
+	return *best, nil
+}
+
+// NearestSnaps returns up to k candidate snaps of a coordinate, closest first, so callers can try another
+// candidate when the route from the first one fails. With SnapOptions.UseBearing, candidates aligned with
+// the heading come first; with SnapOptions.Components, only candidates in the largest component are kept.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex
+//   - c: Coordinate - The coordinate to snap
+//   - k: int - Maximum number of candidates
+//   - opts: SnapOptions - Search radius, heading and component restriction
+//
+// Returns:
+//   - []EdgeSnap: The candidates within opts.Radius and opts.MaxSnapDistance, at most k
+func (g Graph) NearestSnaps(index *KDTree, c Coordinate, k int, opts SnapOptions) []EdgeSnap {
+	if opts.Radius <= 0 {
+		opts.Radius = DefaultSnapRadius
+	}
+	if opts.BearingTolerance <= 0 {
+		opts.BearingTolerance = DefaultBearingTolerance
+	}
+	candidates := opts.filter(g.SnapCandidates(index, c, opts.Radius))
+	aligned := make([]bool, len(candidates))
+	for i, candidate := range candidates {
+		aligned[i] = opts.UseBearing && g.aligned(candidate, opts.Bearing, opts.BearingTolerance)
+	}
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if aligned[a] != aligned[b] {
+			return aligned[a]
+		}
+		return candidates[a].Distance < candidates[b].Distance
+	})
+
+	snaps := make([]EdgeSnap, 0, min(k, len(candidates)))
+	for _, i := range order {
+		if len(snaps) == k {
+			break
+		}
+		if opts.MaxSnapDistance <= 0 || candidates[i].Distance <= opts.MaxSnapDistance {
+			snaps = append(snaps, candidates[i])
+		}
+	}
+	return snaps
+}
+
+// filter keeps the candidates joining two nodes of the largest component when Components is set.
+func (opts SnapOptions) filter(candidates []EdgeSnap) []EdgeSnap {
+	if opts.Components == nil {
+		return candidates
+	}
+	kept := candidates[:0]
+	for _, candidate := range candidates {
+		if opts.Components.InLargest(candidate.From) && opts.Components.InLargest(candidate.To) {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// nearestCandidates projects a coordinate onto the edges leaving the node closest to it, however far. With
+// opts.Components, the closest node of the largest component is used.
+func (g Graph) nearestCandidates(index *KDTree, c Coordinate, opts SnapOptions) []EdgeSnap {
+	x, y := LatLngToMeters(c.Lat, c.Lng)
+	point := NewVector(-1, []float64{x, y})
+	nearest, _, found := index.FindNearestWhere(NewVector(-1, geoComponents(index.Metric(), c)), func(v Vector) bool {
+		return opts.Components == nil || opts.Components.InLargest(int32(v.ID))
+	})
+	if !found || nearest.ID < 0 || nearest.ID >= len(g.Nodes) {
+		return nil
+	}
+	candidates := make([]EdgeSnap, 0)
+	from := int32(nearest.ID)
+	for _, e := range g.OutgoingEdges[from] {
+		candidate := g.projectOntoEdge(point, from, e)
+		candidate.Input = c
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// aligned reports whether the bearing of a snapped edge is within tolerance degrees of a heading.
+func (g Graph) aligned(s EdgeSnap, heading, tolerance float64) bool {
+	edgeBearing := bearing(g.Nodes[s.From].GetPoint(), g.Nodes[s.To].GetPoint())
+	return math.Abs(turnAngle(heading, edgeBearing)) <= tolerance
+}
+
+// SnapCandidates projects a coordinate onto every edge leaving a node located within the given radius.
+// Edges whose endpoints both lie outside the radius are not considered, so the radius should be larger
+// than the typical edge length of the graph.
+//
+// Parameters:
+//   - index: *KDTree - Spatial index of the graph built with BuildNodeIndex
+//   - c: Coordinate - The coordinate to snap
+//   - radius: float64 - Search radius in the unit of the metric of the index, projected meters by default
+//
+// Returns:
+//   - []EdgeSnap: One projection per candidate edge, in no particular order
+func (g Graph) SnapCandidates(index *KDTree, c Coordinate, radius float64) []EdgeSnap {
+	x, y := LatLngToMeters(c.Lat, c.Lng)
+	point := NewVector(-1, []float64{x, y})
+
+	candidates := make([]EdgeSnap, 0)
+	for _, v := range index.RangeQuery(NewVector(-1, geoComponents(index.Metric(), c)), radius) {
+		from := int32(v.ID)
+		for _, e := range g.OutgoingEdges[from] {
+			candidate := g.projectOntoEdge(point, from, e)
+			candidate.Input = c
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+// projectOntoEdge computes the orthogonal projection of a point, in projected meters, onto an edge segment.
+func (g Graph) projectOntoEdge(point Vector, from int32, e Edge) EdgeSnap {
+	a := g.Nodes[from].vector()
+	b := g.Nodes[e.ID].vector()
+
+	segment := b.Subtract(a)
+	ratio := 0.0
+	if length := segment.Dot(segment); length > 0 {
+		ratio = math.Min(math.Max(point.Subtract(a).Dot(segment)/length, 0), 1)
+	}
+	projected := a.Add(segment.Scale(ratio))
+	lat, lng := MetersToLatLng(projected.Components[0], projected.Components[1])
+	px, py := MetersToLatLng(point.Components[0], point.Components[1])
+
+	return EdgeSnap{
+		From:     from,
+		To:       e.ID,
+		Edge:     e,
+		Point:    Coordinate{Lat: lat, Lng: lng},
+		Offset:   ratio * float64(e.Metadata.Distance),
+		Distance: float64(DistanceMeters(s2.CellID(coordinatesToCellID(px, py)), s2.CellID(coordinatesToCellID(lat, lng)))),
+	}
+}
+
+// vector returns the node location as a projected Vector in meters.
+func (n Node) vector() Vector {
+	latLng := n.GetPoint()
+	x, y := LatLngToMeters(latLng.Lat.Degrees(), latLng.Lng.Degrees())
+	return NewVector(n.GetID(), []float64{x, y})
+}
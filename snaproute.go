@@ -0,0 +1,131 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// RouteOptions configures a coordinate to coordinate query answered by Engine.RouteBetween.
+type RouteOptions struct {
+	Criteria Criteria    // Restrictions of the search, its Source and Targets are set from the snapped coordinates
+	Snap     SnapOptions // How the origin and the destination are snapped onto the graph
+}
+
+// CoordinateRoute is the answer to a coordinate to coordinate query: the route between the points the
+// origin and the destination were snapped onto, with the portions of the first and last edges actually
+// traveled accounted for.
+type CoordinateRoute struct {
+	// Route runs from the start of the origin edge to the end of the destination edge; its first and last
+	// coordinates are the snapped points.
+	Route       Route
+	Origin      EdgeSnap // Projection of the origin onto the first edge of the route
+	Destination EdgeSnap // Projection of the destination onto the last edge of the route
+	Cost        float32  // Cost of the route under the criteria, prorated on the first and last edges
+	Distance    float64  // Distance in meters from the snapped origin to the snapped destination
+	Duration    float64  // Travel time in seconds from the snapped origin to the snapped destination
+}
+
+// RouteBetween answers a query between two coordinates: both are snapped onto the closest edge with the
+// spatial index of the engine, the route between the edges is searched like Route, and the path is
+// reconstructed from the snapped origin to the snapped destination. When a coordinate is snapped onto a
+// two-way street, both directions of the street are tried, so the route does not start with a U-turn.
+//
+// Parameters:
+//   - from: Coordinate - The origin of the trip
+//   - to: Coordinate - The destination of the trip
+//   - opts: RouteOptions - Criteria of the search and snapping options
+//
+// Returns:
+//   - CoordinateRoute: The route between the snapped coordinates
+//   - error: An error wrapping ErrNoCandidates if a coordinate is far from any road, ErrNoRoute if the
+//     destination cannot be reached, or the error of the search
+func (e *Engine) RouteBetween(from, to Coordinate, opts RouteOptions) (CoordinateRoute, error) {
+	origin, err := e.SnapWithOptions(from, opts.Snap)
+	if err != nil {
+		return CoordinateRoute{}, fmt.Errorf("origin: %w", err)
+	}
+	destination, err := e.SnapWithOptions(to, opts.Snap)
+	if err != nil {
+		return CoordinateRoute{}, fmt.Errorf("destination: %w", err)
+	}
+
+	var best *CoordinateRoute
+	err = ErrNoRoute
+	for _, o := range e.graph.snapDirections(origin) {
+		for _, d := range e.graph.snapDirections(destination) {
+			r, routeErr := e.routeBetweenSnaps(o, d, opts.Criteria)
+			if routeErr != nil {
+				if !errors.Is(routeErr, ErrNoRoute) {
+					err = routeErr
+				}
+				continue
+			}
+			if best == nil || r.Cost < best.Cost {
+				best = &r
+			}
+		}
+	}
+	if best == nil {
+		return CoordinateRoute{}, err
+	}
+	return *best, nil
+}
+
+// routeBetweenSnaps returns the route leaving the origin along its edge and reaching the destination along
+// its edge.
+func (e *Engine) routeBetweenSnaps(origin, destination EdgeSnap, c Criteria) (CoordinateRoute, error) {
+	r := CoordinateRoute{Origin: origin, Destination: destination}
+	if origin.From == destination.From && origin.To == destination.To && origin.Offset <= destination.Offset {
+		r.Route = NewRoute([]int32{origin.From, origin.To}, e.graph)
+		r.Distance = destination.Offset - origin.Offset
+		r.Cost = partialWeight(origin.Edge, r.Distance)
+		r.Duration = partialDuration(origin.Edge, r.Distance)
+	} else {
+		result := e.Route(ODPair{Source: origin.To, Target: destination.From}, c)
+		if result.Err != nil {
+			return r, result.Err
+		}
+		nodes := append([]int32{origin.From}, result.Route.Nodes...)
+		r.Route = NewRoute(append(nodes, destination.To), e.graph)
+		r.Distance = origin.Remaining() + result.Route.Length() + destination.Offset
+		r.Cost = partialWeight(origin.Edge, origin.Remaining()) + result.Cost + partialWeight(destination.Edge, destination.Offset)
+		r.Duration = partialDuration(origin.Edge, origin.Remaining()) + result.Route.Duration() +
+			partialDuration(destination.Edge, destination.Offset)
+	}
+	if n := len(r.Route.coordinates); n > 0 {
+		r.Route.coordinates[0], r.Route.coordinates[n-1] = origin.Point, destination.Point
+	}
+	return r, nil
+}
+
+// snapDirections returns a snap together with the same projection onto the opposite edge, when the
+// snapped edge is part of a two-way street.
+func (g Graph) snapDirections(s EdgeSnap) []EdgeSnap {
+	snaps := []EdgeSnap{s}
+	for _, e := range g.OutgoingEdges[s.To] {
+		if e.ID == s.From {
+			reverse := s
+			reverse.From, reverse.To, reverse.Edge = s.To, s.From, e
+			reverse.Offset = math.Max(float64(e.Metadata.Distance)-s.Offset, 0)
+			return append(snaps, reverse)
+		}
+	}
+	return snaps
+}
+
+// partialWeight returns the weight of the given length of an edge, prorated on the length of the edge.
+func partialWeight(e Edge, meters float64) float32 {
+	if e.Metadata.Distance <= 0 {
+		return 0
+	}
+	return float32(float64(e.Weight) * meters / float64(e.Metadata.Distance))
+}
+
+// partialDuration returns the travel time in seconds of the given length of an edge.
+func partialDuration(e Edge, meters float64) float64 {
+	if e.Metadata.Distance <= 0 {
+		return 0
+	}
+	return edgeDuration(e) * meters / float64(e.Metadata.Distance)
+}
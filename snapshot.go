@@ -0,0 +1,123 @@
+package graph_search
+
+import (
+	"encoding/gob"
+	"io"
+)
+
+// SearchState is a paused Dijkstra search: its frontier, the costs found so far, the settled nodes and
+// the shortest path tree. It is plain data encoded with gob, so it can be kept in memory or on disk and
+// resumed later, e.g., to grow an isochrone by another 5 minutes without recomputing it from scratch.
+type SearchState struct {
+	Source   []int32 // Sources of the search
+	Targets  []int32 // Targets of the search
+	MaxCost  float32 // Cost up to which every node was settled
+	Frontier HNodes  // Nodes waiting in the queue, possibly with stale entries
+	Costs    Costs   // Best known cost of every reached node
+	Visited  []byte  // Big-endian bitset of the settled nodes
+	Tree     Graph   // Shortest path tree of the settled nodes, as in Response.SearchSpace
+}
+
+// RunUntil runs the search like Run, but pauses before settling a node costing more than maxCost.
+// Settled nodes are final; the returned state resumes the search with ResumeDijkstra. Searches with a
+// Heuristic or Epsilon order nodes by estimate, so they may pause before every node within maxCost
+// is settled.
+//
+// Parameters:
+//   - g: Graph - The graph to search through
+//   - maxCost: float32 - Largest cost of the nodes settled before pausing
+//
+// Returns:
+//   - Response: The nodes settled so far, with their costs
+//   - *SearchState: The state of the paused search, with an empty Frontier if nothing is left to settle
+func (search DijkstraSearch) RunUntil(g Graph, maxCost float32) (Response, *SearchState) {
+	search.pauseAt = maxCost
+	response := search.run(g)
+
+	state := &SearchState{
+		Source:  search.criteria.Source,
+		Targets: search.criteria.Targets,
+		MaxCost: maxCost,
+		Costs:   make(Costs, len(response.Costs)),
+		Visited: search.visited.Bytes(),
+		Tree:    search.previous,
+	}
+	for id, c := range response.Costs {
+		state.Costs[id] = c
+	}
+	state.Frontier = make(HNodes, 0, search.pq.Len())
+	for !search.pq.IsEmpty() {
+		n, _ := search.pq.Min()
+		if !search.wasVisited(n.Value) {
+			state.Frontier = append(state.Frontier, n)
+		}
+		search.pq.DeleteMin()
+	}
+	return response, state
+}
+
+// ResumeDijkstra recreates a paused search, ready to be run again with Run or RunUntil. Settled nodes
+// are not searched again and the Response of the resumed search covers both runs.
+//
+// Parameters:
+//   - state: *SearchState - The state returned by RunUntil
+//   - c: Criteria - Options of the resumed search, which must restrict edges like the paused one did.
+//     Its Source and Targets are replaced by those of the state
+//
+// Returns:
+//   - DijkstraSearch: The search, continuing from the frontier of the state
+func ResumeDijkstra(state *SearchState, c Criteria) DijkstraSearch {
+	c.Source, c.Targets = nil, state.Targets
+	search := NewDijkstra(c)
+	search.criteria.Source = state.Source
+	for _, s := range state.Source {
+		search.sources.Set(s, true)
+	}
+	for id, cost := range state.Costs {
+		search.costs[id] = cost
+	}
+	search.visited.SetBytes(state.Visited)
+	// The tree grows as the search goes on, so it must not share memory with the state.
+	search.previous = Graph{
+		Nodes:         append([]Node(nil), state.Tree.Nodes...),
+		IncomingEdges: copyRelations(state.Tree.IncomingEdges),
+		OutgoingEdges: copyRelations(state.Tree.OutgoingEdges),
+	}
+	for _, n := range state.Frontier {
+		search.pq.Insert(n)
+	}
+	return search
+}
+
+// Encode writes the state with gob.
+func (s *SearchState) Encode(w io.Writer) error {
+	return gob.NewEncoder(w).Encode(s)
+}
+
+// DecodeSearchState reads a state written by SearchState.Encode.
+//
+// Parameters:
+//   - r: io.Reader - Source of the encoded state
+//
+// Returns:
+//   - *SearchState: The decoded state
+//   - error: The error returned by the gob decoder, if any
+func DecodeSearchState(r io.Reader) (*SearchState, error) {
+	s := new(SearchState)
+	if err := gob.NewDecoder(r).Decode(s); err != nil {
+		return nil, err
+	}
+	if s.Costs == nil {
+		s.Costs = make(Costs)
+	}
+	return s, nil
+}
+
+// copyRelations returns a deep copy of the edges of a graph.
+func copyRelations(r Relations) Relations {
+	c := make(Relations, len(r))
+	for v, edges := range r {
+		c[v] = append([]Edge(nil), edges...)
+	}
+	return c
+}
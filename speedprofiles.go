@@ -0,0 +1,184 @@
+package graph_search
+
+import (
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrInvalidSpeedProfile = errors.New("invalid speed profile")
+)
+
+// HoursInAWeek is the number of hourly buckets of a WeeklyProfile.
+const HoursInAWeek = 168
+
+// WeeklyProfile holds the historical speed of an edge for every hour of the week, from Monday 00:00 to
+// Sunday 23:59, in km/h. Speeds are rounded to tenths of km/h and delta-compressed: Runs stores the runs
+// of equal consecutive speeds, each as the zigzag varint difference between its speed and the speed of
+// the previous run followed by the varint length of the run. A flat profile thus takes a few bytes and a
+// typical rush hour profile a few dozen, instead of 672.
+type WeeklyProfile struct {
+	Runs []byte
+}
+
+// NewWeeklyProfile compresses the hourly speeds of a week.
+//
+// Parameters:
+//   - speeds: [HoursInAWeek]float32 - Speed in km/h for every hour, index 0 being Monday 00:00-00:59
+//
+// Returns:
+//   - *WeeklyProfile: The compressed profile
+func NewWeeklyProfile(speeds [HoursInAWeek]float32) *WeeklyProfile {
+	runs := make([]byte, 0, 16)
+	previous := int64(0)
+	for h := 0; h < HoursInAWeek; {
+		tenths := int64(math.Round(float64(speeds[h]) * 10))
+		length := 1
+		for h+length < HoursInAWeek && int64(math.Round(float64(speeds[h+length])*10)) == tenths {
+			length++
+		}
+		runs = binary.AppendUvarint(runs, zigzag(tenths-previous))
+		runs = binary.AppendUvarint(runs, uint64(length))
+		previous = tenths
+		h += length
+	}
+	return &WeeklyProfile{Runs: runs}
+}
+
+// Speeds decompresses the profile.
+//
+// Returns:
+//   - [HoursInAWeek]float32: Speed in km/h for every hour, index 0 being Monday 00:00-00:59. Hours a
+//     corrupted profile does not cover are 0
+func (p *WeeklyProfile) Speeds() [HoursInAWeek]float32 {
+	var speeds [HoursInAWeek]float32
+	p.scan(func(h int, speed float32) bool {
+		speeds[h] = speed
+		return true
+	})
+	return speeds
+}
+
+// At returns the speed of the profile for the hour of the week of the given time, in its location.
+func (p *WeeklyProfile) At(t time.Time) float32 {
+	hour := (int(t.Weekday())+6)%7*24 + t.Hour()
+	var speed float32
+	p.scan(func(h int, s float32) bool {
+		if h < hour {
+			return true
+		}
+		speed = s
+		return false
+	})
+	return speed
+}
+
+// scan decodes the runs in order, calling fn with the first hour and the speed of every run until it
+// returns false.
+func (p *WeeklyProfile) scan(fn func(hour int, speed float32) bool) {
+	runs, tenths := p.Runs, int64(0)
+	for h := 0; h < HoursInAWeek && len(runs) > 0; {
+		delta, n := binary.Uvarint(runs)
+		if n <= 0 {
+			return
+		}
+		length, m := binary.Uvarint(runs[n:])
+		if m <= 0 || length == 0 {
+			return
+		}
+		runs = runs[n+m:]
+		tenths += unzigzag(delta)
+		for i := 0; i < int(length) && h < HoursInAWeek; i, h = i+1, h+1 {
+			if !fn(h, float32(tenths)/10) {
+				return
+			}
+		}
+	}
+}
+
+// unzigzag reverses zigzag.
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// LoadSpeedProfiles attaches the weekly speed profiles of a CSV file to the edges of a graph, so time-
+// dependent searches produce realistic peak and off-peak ETAs. Every row reads from,to followed by the
+// HoursInAWeek hourly speeds in km/h starting on Monday 00:00, or by 24 hourly speeds repeated every day.
+// Nodes are OSM node IDs when the graph has OSMIDs, internal node IDs otherwise. A header row is skipped
+// when its first field is not a number, and rows of edges missing from the graph are ignored. The
+// profiles are kept by Serialize.
+//
+// Parameters:
+//   - g: *Graph - The graph whose edges receive the profiles
+//   - r: io.Reader - Source of the CSV file
+//
+// Returns:
+//   - int: The number of rows whose edge was found in the graph
+//   - error: An error wrapping the line number and ErrInvalidSpeedProfile if a row is malformed
+func LoadSpeedProfiles(g *Graph, r io.Reader) (int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	nodes := make(map[int64]int32, len(g.OSMIDs))
+	for id, osmID := range g.OSMIDs {
+		nodes[osmID] = int32(id)
+	}
+	node := func(field string) (int32, bool) {
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		if len(g.OSMIDs) > 0 {
+			internal, ok := nodes[id]
+			return internal, ok
+		}
+		return int32(id), id >= 0 && id < int64(len(g.Nodes))
+	}
+
+	updated := 0
+	for row := 0; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return updated, err
+		}
+		line, _ := reader.FieldPos(0)
+		if row == 0 && len(record) > 0 {
+			if _, err := strconv.ParseFloat(record[0], 64); err != nil {
+				continue
+			}
+		}
+		if hours := len(record) - 2; hours != HoursInAWeek && hours != 24 {
+			return updated, fmt.Errorf("line %d: %w: got %d speeds, expected %d or 24", line, ErrInvalidSpeedProfile, hours, HoursInAWeek)
+		}
+		var speeds [HoursInAWeek]float32
+		for h := range speeds {
+			field := record[2+h%(len(record)-2)]
+			speed, err := strconv.ParseFloat(field, 32)
+			if err != nil || speed < 0 {
+				return updated, fmt.Errorf("line %d: %w: speed %q", line, ErrInvalidSpeedProfile, field)
+			}
+			speeds[h] = float32(speed)
+		}
+		from, ok1 := node(record[0])
+		to, ok2 := node(record[1])
+		if !ok1 || !ok2 {
+			continue
+		}
+		profile := NewWeeklyProfile(speeds)
+		if g.updateEdge(from, to, func(e *Edge) { e.Metadata.Weekly = profile }) {
+			updated++
+		}
+	}
+	return updated, nil
+}
@@ -0,0 +1,42 @@
+package graph_search
+
+import "math"
+
+// CostModel computes the cost of traversing an edge, replacing Edge.Weight in the searches that accept it.
+type CostModel func(e Edge) float32
+
+// PercentileTravelTime returns a cost model minimizing the p-th percentile of the travel time instead
+// of its mean, for routes that must arrive on time rather than early on average. Travel times are
+// modeled as independent normal variables, with the mean given by the edge distance and speed and the
+// variance by MetaData.TravelTimeVariance. Summing per-edge percentiles overestimates the percentile of
+// the route, so the chosen routes are conservative; use Route.TravelTimePercentile to report the
+// percentile of the route itself.
+//
+// Parameters:
+//   - p: float64 - Percentile in (0, 1), e.g., 0.9 for the 90th percentile. 0.5 minimizes the mean
+//
+// Returns:
+//   - CostModel: The cost model, in seconds
+func PercentileTravelTime(p float64) CostModel {
+	z := normalQuantile(p)
+	return func(e Edge) float32 {
+		return float32(edgeDuration(e) + z*math.Sqrt(float64(e.Metadata.TravelTimeVariance)))
+	}
+}
+
+// TravelTimePercentile returns the p-th percentile of the travel time of the route in seconds, assuming
+// independent normally distributed edge travel times: the route mean is the sum of the edge means and
+// its variance the sum of the edge variances.
+func (r Route) TravelTimePercentile(p float64) float64 {
+	mean, variance := 0.0, 0.0
+	for _, e := range r.Edges {
+		mean += edgeDuration(e)
+		variance += float64(e.Metadata.TravelTimeVariance)
+	}
+	return mean + normalQuantile(p)*math.Sqrt(variance)
+}
+
+// normalQuantile returns the p-th quantile of the standard normal distribution.
+func normalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
@@ -0,0 +1,254 @@
+package graph_search
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+var (
+	ErrStorageClosed = errors.New("storage object closed")
+)
+
+// Storage is where serialized graphs and tiles are persisted. Names are slash-separated paths relative to
+// the root of the storage, e.g., "eu/france.bin" or "tiles/manifest.gob". Objects are read at arbitrary
+// offsets, so a file written with SerializeOptions.TileLevel is read tile by tile by LoadSubgraphFrom,
+// even from object storage, and written at once, like object stores expect.
+type Storage interface {
+	// Open opens an object for reading. Missing objects are reported with an error wrapping fs.ErrNotExist.
+	Open(name string) (StorageObject, error)
+
+	// Create opens an object for writing, replacing any object of the same name once the writer is
+	// closed without error.
+	Create(name string) (io.WriteCloser, error)
+}
+
+// StorageObject is an object of a Storage opened for reading.
+type StorageObject interface {
+	io.ReaderAt
+	io.Closer
+
+	// Size returns the length of the object in bytes.
+	Size() int64
+}
+
+// FileStorage stores objects as files under a directory of the local file system. The empty FileStorage
+// resolves names against the working directory and accepts absolute paths, which is how Serialize, Load
+// and WriteTiles use it.
+type FileStorage string
+
+// Open opens the file of an object.
+func (s FileStorage) Open(name string) (StorageObject, error) {
+	f, err := os.Open(s.path(name))
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fileObject{File: f, size: info.Size()}, nil
+}
+
+// Create creates the file of an object, and the directories leading to it.
+func (s FileStorage) Create(name string) (io.WriteCloser, error) {
+	p := s.path(name)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	return os.Create(p)
+}
+
+func (s FileStorage) path(name string) string {
+	if s == "" {
+		return filepath.FromSlash(name)
+	}
+	return filepath.Join(string(s), filepath.FromSlash(name))
+}
+
+// fileObject is a file opened by FileStorage, together with its size.
+type fileObject struct {
+	*os.File
+	size int64
+}
+
+func (f fileObject) Size() int64 {
+	return f.size
+}
+
+// MemoryStorage keeps objects in memory, e.g., for tests or to hold a graph downloaded once. It is safe
+// for concurrent use.
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+// NewMemoryStorage creates an empty in-memory storage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string][]byte)}
+}
+
+// Open returns a reader over the content of an object. Later writes to the object do not affect it.
+func (s *MemoryStorage) Open(name string) (StorageObject, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.objects[path.Clean(name)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return memoryObject{bytes.NewReader(b)}, nil
+}
+
+// Create returns a writer buffering the content of an object, stored when the writer is closed.
+func (s *MemoryStorage) Create(name string) (io.WriteCloser, error) {
+	return &memoryWriter{storage: s, name: path.Clean(name)}, nil
+}
+
+// Names returns the names of the objects stored, in no particular order.
+func (s *MemoryStorage) Names() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.objects))
+	for name := range s.objects {
+		names = append(names, name)
+	}
+	return names
+}
+
+type memoryObject struct {
+	*bytes.Reader
+}
+
+func (memoryObject) Close() error {
+	return nil
+}
+
+// memoryWriter buffers an object written to a MemoryStorage.
+type memoryWriter struct {
+	storage *MemoryStorage
+	name    string
+	buf     bytes.Buffer
+	closed  bool
+}
+
+func (w *memoryWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrStorageClosed
+	}
+	return w.buf.Write(p)
+}
+
+func (w *memoryWriter) Close() error {
+	if w.closed {
+		return ErrStorageClosed
+	}
+	w.closed = true
+	w.storage.mu.Lock()
+	defer w.storage.mu.Unlock()
+	w.storage.objects[w.name] = w.buf.Bytes()
+	return nil
+}
+
+// ObjectClient is the part of an object store client used by ObjectStorage. Adapting the client of
+// S3, GCS or any other store takes a few lines: Size maps to HeadObject or Attrs, ReadRange to a ranged
+// GetObject or NewRangeReader, and Upload to an upload manager or Writer.
+type ObjectClient interface {
+	// Size returns the length of an object, with an error wrapping fs.ErrNotExist if it does not exist.
+	Size(ctx context.Context, key string) (int64, error)
+
+	// ReadRange returns a reader over length bytes of an object starting at offset.
+	ReadRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+
+	// Upload stores an object with the content of a reader, read until io.EOF.
+	Upload(ctx context.Context, key string, r io.Reader) error
+}
+
+// ObjectStorage stores objects in a bucket of an object store, under a key prefix. Reads are ranged
+// requests, so only the sections of a graph actually decoded are downloaded.
+type ObjectStorage struct {
+	Client  ObjectClient
+	Prefix  string          // Prepended to every name, e.g., "graphs/" to store them in a folder of the bucket
+	Context context.Context // Context of every request, context.Background() when nil
+}
+
+// Open returns an object reading ranges of the stored object on demand.
+func (s ObjectStorage) Open(name string) (StorageObject, error) {
+	ctx := s.context()
+	key := s.Prefix + name
+	size, err := s.Client.Size(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", key, err)
+	}
+	return &remoteObject{ctx: ctx, client: s.Client, key: key, size: size}, nil
+}
+
+// Create returns a writer streaming the object to the store, the upload completing when it is closed.
+func (s ObjectStorage) Create(name string) (io.WriteCloser, error) {
+	r, w := io.Pipe()
+	upload := &uploadWriter{PipeWriter: w, done: make(chan error, 1)}
+	go func() {
+		err := s.Client.Upload(s.context(), s.Prefix+name, r)
+		r.CloseWithError(err) // unblocks the writer if the upload fails early
+		upload.done <- err
+	}()
+	return upload, nil
+}
+
+func (s ObjectStorage) context() context.Context {
+	if s.Context == nil {
+		return context.Background()
+	}
+	return s.Context
+}
+
+// remoteObject is an object of an ObjectStorage opened for reading.
+type remoteObject struct {
+	ctx    context.Context
+	client ObjectClient
+	key    string
+	size   int64
+}
+
+func (o *remoteObject) Size() int64 {
+	return o.size
+}
+
+func (o *remoteObject) ReadAt(p []byte, offset int64) (int, error) {
+	if offset >= o.size {
+		return 0, io.EOF
+	}
+	length := min(int64(len(p)), o.size-offset)
+	r, err := o.client.ReadRange(o.ctx, o.key, offset, length)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", o.key, err)
+	}
+	defer r.Close()
+	n, err := io.ReadFull(r, p[:length])
+	if err == nil && int(length) < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (o *remoteObject) Close() error {
+	return nil
+}
+
+// uploadWriter streams an object to an ObjectClient, waiting for the upload to complete on Close.
+type uploadWriter struct {
+	*io.PipeWriter
+	done chan error
+}
+
+func (w *uploadWriter) Close() error {
+	w.PipeWriter.Close()
+	return <-w.done
+}
@@ -0,0 +1,282 @@
+package graph_search
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/golang/geo/s2"
+)
+
+// BoundingBox is the area between two parallels and two meridians. Boxes crossing the antimeridian are
+// not supported.
+type BoundingBox struct {
+	Min Coordinate // South-west corner
+	Max Coordinate // North-east corner
+}
+
+// Contains reports whether a coordinate lies within the box, borders included.
+func (b BoundingBox) Contains(c Coordinate) bool {
+	return c.Lat >= b.Min.Lat && c.Lat <= b.Max.Lat && c.Lng >= b.Min.Lng && c.Lng <= b.Max.Lng
+}
+
+// Bounds returns the smallest bounding box containing every node of the graph, the zero box for an
+// empty graph.
+func (g Graph) Bounds() BoundingBox {
+	var b BoundingBox
+	for i, n := range g.Nodes {
+		p := n.GetPoint()
+		c := Coordinate{Lat: p.Lat.Degrees(), Lng: p.Lng.Degrees()}
+		if i == 0 {
+			b.Min, b.Max = c, c
+			continue
+		}
+		b.Min.Lat, b.Min.Lng = min(b.Min.Lat, c.Lat), min(b.Min.Lng, c.Lng)
+		b.Max.Lat, b.Max.Lng = max(b.Max.Lat, c.Lat), max(b.Max.Lng, c.Lng)
+	}
+	return b
+}
+
+// rect returns the box as an S2 rectangle.
+func (b BoundingBox) rect() s2.Rect {
+	return s2.RectFromLatLng(s2.LatLngFromDegrees(b.Min.Lat, b.Min.Lng)).AddPoint(s2.LatLngFromDegrees(b.Max.Lat, b.Max.Lng))
+}
+
+// LoadSubgraph reads the nodes inside a bounding box from a serialized graph, with the edges joining
+// them. Graphs written with SerializeOptions.TileLevel are read tile by tile: only the tiles overlapping
+// the box are decoded, so extracting a city from a country graph costs a fraction of Load. Other files
+// are loaded whole before the box is extracted.
+//
+// Parameters:
+//   - filePath: string - The path to the file written by Serialize or SerializeWithOptions
+//   - box: BoundingBox - The area to extract
+//
+// Returns:
+//   - Graph: The nodes inside the box, numbered from 0 in the order of their IDs in the file
+//   - []int32: The ID every node of the subgraph has in the file
+//   - error: An error wrapping ErrCorruptGraph if a section read is truncated or altered, or the error
+//     returned when opening or reading the file
+func LoadSubgraph(filePath string, box BoundingBox) (Graph, []int32, error) {
+	return LoadSubgraphFrom(FileStorage(""), filePath, box)
+}
+
+// LoadSubgraphFrom reads the nodes inside a bounding box from a serialized graph kept in a storage, like
+// LoadSubgraph. Tiles are read with ranged reads of the object, so only the tiles overlapping the box are
+// downloaded from object storage.
+//
+// Parameters:
+//   - s: Storage - The storage holding the graph
+//   - name: string - The name of the object written by SerializeTo or SerializeWithOptions
+//   - box: BoundingBox - The area to extract
+//
+// Returns:
+//   - Graph: The nodes inside the box, numbered from 0 in the order of their IDs in the object
+//   - []int32: The ID every node of the subgraph has in the object
+//   - error: An error wrapping ErrCorruptGraph if a section read is truncated or altered, or the error
+//     returned when opening or reading the object
+func LoadSubgraphFrom(s Storage, name string, box BoundingBox) (Graph, []int32, error) {
+	file, _, sectioned, err := openGraph(s, name)
+	if err != nil {
+		return Graph{}, nil, err
+	}
+	defer file.Close()
+
+	var entries map[string]sectionEntry
+	if sectioned {
+		if entries, err = indexSections(file); err != nil {
+			return Graph{}, nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	e, tiled := entries[sectionLayout]
+	if !tiled {
+		g, err := LoadFrom(s, name)
+		if err != nil {
+			return Graph{}, nil, err
+		}
+		sub, original := g.SubgraphInBox(box)
+		return sub, original, nil
+	}
+
+	var layout *spatialLayout
+	err = readEntry(file, sectionLayout, e, func(_ string, flags byte, payload io.Reader) (err error) {
+		layout, err = decodeLayout(payload, flags)
+		return err
+	})
+	if err != nil {
+		return Graph{}, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	rect := box.rect()
+	var nodes []subgraphNode
+	for i, cell := range layout.Cells {
+		if !s2.CellFromCellID(s2.CellID(cell)).RectBound().Intersects(rect) {
+			continue
+		}
+		section := tileSection(i)
+		e, ok := entries[section]
+		if !ok {
+			return Graph{}, nil, fmt.Errorf("%s: section %s: missing: %w", name, section, ErrCorruptGraph)
+		}
+		var t *spatialTile
+		err := readEntry(file, section, e, func(_ string, flags byte, payload io.Reader) (err error) {
+			t, err = decodeTile(payload, flags, layout.Members[i])
+			return err
+		})
+		if err != nil {
+			return Graph{}, nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for j, id := range layout.Members[i] {
+			if !box.Contains(nodeCoordinate(t.Nodes[j])) {
+				continue
+			}
+			n := subgraphNode{id: id, node: t.Nodes[j], outgoing: t.Outgoing[j], incoming: t.Incoming[j]}
+			if len(t.OSMIDs) > 0 {
+				n.osmID, n.hasOSMID = t.OSMIDs[j], true
+			}
+			if len(t.Properties) > 0 {
+				n.properties = t.Properties[j]
+			}
+			nodes = append(nodes, n)
+		}
+	}
+	sub, original := buildSubgraph(nodes)
+	return sub, original, nil
+}
+
+// Subgraph extracts some nodes of the graph with the edges joining them, their OSM IDs and properties.
+// The result is a self-contained graph, small enough to share as the reproduction of a wrong route or to
+// use as a test fixture.
+//
+// Parameters:
+//   - nodeIDs: []int32 - IDs of the nodes to keep, in any order; duplicates and unknown IDs are ignored
+//
+// Returns:
+//   - Graph: The kept nodes, numbered from 0 in the order of their IDs in the graph
+//   - []int32: The ID every node of the subgraph has in the graph
+func (g Graph) Subgraph(nodeIDs []int32) (Graph, []int32) {
+	return buildSubgraph(g.subgraphNodes(nodeIDs))
+}
+
+// SubgraphInBox extracts the nodes inside a bounding box like Subgraph.
+//
+// Parameters:
+//   - box: BoundingBox - The area to extract
+//
+// Returns:
+//   - Graph: The nodes inside the box, numbered from 0 in the order of their IDs in the graph
+//   - []int32: The ID every node of the subgraph has in the graph
+func (g Graph) SubgraphInBox(box BoundingBox) (Graph, []int32) {
+	return g.subgraphWhere(box.Contains)
+}
+
+// SubgraphInPolygon extracts the nodes inside a polygon like Subgraph.
+//
+// Parameters:
+//   - polygon: GeoJSONPolygon - The area to extract; nodes in its holes are left out
+//
+// Returns:
+//   - Graph: The nodes inside the polygon, numbered from 0 in the order of their IDs in the graph
+//   - []int32: The ID every node of the subgraph has in the graph
+func (g Graph) SubgraphInPolygon(polygon GeoJSONPolygon) (Graph, []int32) {
+	return g.subgraphWhere(polygon.Contains)
+}
+
+// subgraphWhere extracts the nodes whose location satisfies inside.
+func (g Graph) subgraphWhere(inside func(Coordinate) bool) (Graph, []int32) {
+	ids := make([]int32, 0)
+	for id, n := range g.Nodes {
+		if inside(nodeCoordinate(n)) {
+			ids = append(ids, int32(id))
+		}
+	}
+	return g.Subgraph(ids)
+}
+
+// subgraphNode is a node kept in a subgraph, with everything attached to it in the original graph.
+type subgraphNode struct {
+	id         int32 // ID in the original graph
+	node       Node
+	outgoing   []Edge
+	incoming   []Edge
+	osmID      int64
+	hasOSMID   bool
+	properties Properties
+}
+
+// subgraphNodes returns the nodes of a graph with the given IDs, skipping unknown IDs.
+func (g Graph) subgraphNodes(ids []int32) []subgraphNode {
+	nodes := make([]subgraphNode, 0, len(ids))
+	for _, id := range ids {
+		if id < 0 || int(id) >= len(g.Nodes) {
+			continue
+		}
+		n := subgraphNode{id: id, node: g.Nodes[id]}
+		if int(id) < len(g.OutgoingEdges) {
+			n.outgoing = g.OutgoingEdges[id]
+		}
+		if int(id) < len(g.IncomingEdges) {
+			n.incoming = g.IncomingEdges[id]
+		}
+		if int(id) < len(g.OSMIDs) {
+			n.osmID, n.hasOSMID = g.OSMIDs[id], true
+		}
+		if int(id) < len(g.Properties) {
+			n.properties = g.Properties[id]
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// buildSubgraph numbers the nodes from 0 in the order of their original IDs and keeps the edges joining
+// them.
+//
+// Returns:
+//   - Graph: The subgraph
+//   - []int32: The original ID of every node of the subgraph
+func buildSubgraph(nodes []subgraphNode) (Graph, []int32) {
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].id < nodes[j].id })
+	remap := make(map[int32]int32, len(nodes))
+	original := make([]int32, 0, len(nodes))
+	for _, n := range nodes {
+		if _, ok := remap[n.id]; !ok {
+			remap[n.id] = int32(len(original))
+			original = append(original, n.id)
+		}
+	}
+
+	g := Graph{
+		Nodes:         make([]Node, len(original)),
+		OutgoingEdges: make(Relations, len(original)),
+		IncomingEdges: make(Relations, len(original)),
+	}
+	remapEdges := func(edges []Edge) []Edge {
+		kept := make([]Edge, 0, len(edges))
+		for _, e := range edges {
+			if id, ok := remap[e.ID]; ok {
+				e.ID = id
+				kept = append(kept, e)
+			}
+		}
+		return kept
+	}
+	for _, n := range nodes {
+		id := remap[n.id]
+		g.Nodes[id] = n.node
+		g.Nodes[id].ID = id
+		g.OutgoingEdges[id] = remapEdges(n.outgoing)
+		g.IncomingEdges[id] = remapEdges(n.incoming)
+		if n.hasOSMID {
+			if g.OSMIDs == nil {
+				g.OSMIDs = make([]int64, len(original))
+			}
+			g.OSMIDs[id] = n.osmID
+		}
+		if len(n.properties) > 0 {
+			if g.Properties == nil {
+				g.Properties = make([]Properties, len(original))
+			}
+			g.Properties[id] = n.properties
+		}
+	}
+	return g, original
+}
@@ -0,0 +1,214 @@
+// Package testkit runs golden-route regression tests: a fixture graph is loaded, a set of named
+// coordinate to coordinate queries is routed, and every route is compared to the one recorded in a
+// golden file committed next to the test, so changes to cost models or search code show their impact on
+// actual routes. Run the tests with the GOLDEN_UPDATE environment variable set to rewrite the golden files
+// once a change is intended.
+package testkit
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"graph_search"
+)
+
+var (
+	ErrUnknownFixture = errors.New("unknown fixture format")
+)
+
+// UpdateEnv is the environment variable that makes Harness.Check rewrite the golden file instead of
+// comparing against it.
+const UpdateEnv = "GOLDEN_UPDATE"
+
+// DefaultTolerance accepts differences of a thousandth of the recorded distances, durations and costs,
+// absorbing floating point noise while catching any change of route.
+var DefaultTolerance = Tolerance{Distance: 1e-3, Duration: 1e-3, Cost: 1e-3}
+
+// Query is a named coordinate to coordinate query.
+type Query struct {
+	Name string
+	From graph_search.Coordinate
+	To   graph_search.Coordinate
+}
+
+// Result is the route answering a query, as recorded in golden files.
+type Result struct {
+	Name     string  `json:"name"`
+	Distance float64 `json:"distance"`        // Meters between the snapped coordinates
+	Duration float64 `json:"duration"`        // Seconds between the snapped coordinates
+	Cost     float32 `json:"cost"`            // Cost under the criteria of the harness
+	Geometry string  `json:"geometry"`        // Hash of the coordinates of the route, see GeometryHash
+	Error    string  `json:"error,omitempty"` // Why the query failed, empty when it was routed
+}
+
+// Tolerance bounds the relative differences accepted between a result and its golden record.
+type Tolerance struct {
+	Distance float64
+	Duration float64
+	Cost     float64
+
+	// IgnoreGeometry accepts routes of a different shape as long as their measures are within tolerance.
+	IgnoreGeometry bool
+}
+
+// Harness routes queries on a fixture graph.
+type Harness struct {
+	Engine    *graph_search.Engine
+	Options   graph_search.RouteOptions // Criteria and snapping of every query
+	Tolerance Tolerance                 // DefaultTolerance for harnesses created by NewHarness
+}
+
+// NewHarness loads a fixture graph: an edge list with a header row when its extension is .csv or .tsv,
+// see graph_search.LoadEdgeList, or a graph written by Graph.Serialize when it is .bin or .gob.
+//
+// Parameters:
+//   - fixture: string - Path to the fixture graph
+//
+// Returns:
+//   - *Harness: A harness routing on the fixture with the default criteria and tolerance
+//   - error: ErrUnknownFixture if the extension is not recognized, or the error of loading the file
+func NewHarness(fixture string) (*Harness, error) {
+	var g graph_search.Graph
+	switch ext := strings.ToLower(filepath.Ext(fixture)); ext {
+	case ".csv", ".tsv":
+		file, err := os.Open(fixture)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		opts := graph_search.EdgeListOptions{Header: true}
+		if ext == ".tsv" {
+			opts.Comma = '\t'
+		}
+		if g, _, err = graph_search.LoadEdgeList(file, opts); err != nil {
+			return nil, fmt.Errorf("%s: %w", fixture, err)
+		}
+	case ".bin", ".gob":
+		var err error
+		if g, err = graph_search.Load(fixture); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: %w", fixture, ErrUnknownFixture)
+	}
+	return &Harness{Engine: graph_search.NewEngine(g), Tolerance: DefaultTolerance}, nil
+}
+
+// Run routes every query with Engine.RouteBetween.
+//
+// Parameters:
+//   - queries: []Query - The queries to route
+//
+// Returns:
+//   - []Result: One result per query, in the same order, failed queries carrying their error
+func (h *Harness) Run(queries []Query) []Result {
+	results := make([]Result, 0, len(queries))
+	for _, q := range queries {
+		r := Result{Name: q.Name}
+		route, err := h.Engine.RouteBetween(q.From, q.To, h.Options)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Distance, r.Duration, r.Cost = route.Distance, route.Duration, route.Cost
+			r.Geometry = GeometryHash(route.Route.Coordinates())
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Check routes the queries and compares the results to the golden file, reporting every difference
+// beyond the tolerance as a test error. With the UpdateEnv environment variable set, the golden file is
+// rewritten with the results instead.
+//
+// Parameters:
+//   - t: testing.TB - The test reporting the differences
+//   - golden: string - Path to the golden file, a JSON array of results
+//   - queries: []Query - The queries to route
+func (h *Harness) Check(t testing.TB, golden string, queries []Query) {
+	t.Helper()
+	results := h.Run(queries)
+	if os.Getenv(UpdateEnv) != "" {
+		b, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, append(b, '\n'), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	b, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (set %s=1 to create it)", err, UpdateEnv)
+	}
+	var want []Result
+	if err := json.Unmarshal(b, &want); err != nil {
+		t.Fatalf("%s: %v", golden, err)
+	}
+	recorded := make(map[string]Result, len(want))
+	for _, r := range want {
+		recorded[r.Name] = r
+	}
+	for _, got := range results {
+		w, ok := recorded[got.Name]
+		if !ok {
+			t.Errorf("%s: no golden record (set %s=1 to add it)", got.Name, UpdateEnv)
+			continue
+		}
+		for _, d := range Diff(w, got, h.Tolerance) {
+			t.Errorf("%s: %s", got.Name, d)
+		}
+	}
+}
+
+// Diff describes the differences between a golden record and a result that exceed the tolerance.
+//
+// Parameters:
+//   - want: Result - The golden record
+//   - got: Result - The result of the current code
+//   - tol: Tolerance - The accepted relative differences
+//
+// Returns:
+//   - []string: One description per difference, empty when the result matches
+func Diff(want, got Result, tol Tolerance) []string {
+	diffs := make([]string, 0)
+	if want.Error != got.Error {
+		return append(diffs, fmt.Sprintf("error %q, expected %q", got.Error, want.Error))
+	}
+	measure := func(name string, w, g, tolerance float64) {
+		if math.Abs(g-w) > tolerance*math.Abs(w) {
+			diffs = append(diffs, fmt.Sprintf("%s %.3f, expected %.3f (%+.2f%%)", name, g, w, 100*(g-w)/math.Max(math.Abs(w), 1e-9)))
+		}
+	}
+	measure("distance", want.Distance, got.Distance, tol.Distance)
+	measure("duration", want.Duration, got.Duration, tol.Duration)
+	measure("cost", float64(want.Cost), float64(got.Cost), tol.Cost)
+	if !tol.IgnoreGeometry && want.Geometry != got.Geometry {
+		diffs = append(diffs, fmt.Sprintf("geometry %s, expected %s", got.Geometry, want.Geometry))
+	}
+	return diffs
+}
+
+// GeometryHash returns a short hash of a line, with coordinates rounded to 1e-6 degrees (about 10 cm) so
+// the hash does not depend on floating point noise.
+func GeometryHash(line []graph_search.Coordinate) string {
+	h := sha256.New()
+	buf := make([]byte, 0, 16)
+	for _, c := range line {
+		buf = binary.LittleEndian.AppendUint64(buf[:0], uint64(int64(math.Round(c.Lat*1e6))))
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(int64(math.Round(c.Lng*1e6))))
+		h.Write(buf)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
@@ -0,0 +1,49 @@
+package testkit
+
+import (
+	"testing"
+
+	"graph_search"
+)
+
+var gridQueries = []Query{
+	{Name: "west-to-east", From: graph_search.Coordinate{Lat: 48.8510, Lng: 2.3502}, To: graph_search.Coordinate{Lat: 48.8510, Lng: 2.3518}},
+	{Name: "diagonal", From: graph_search.Coordinate{Lat: 48.8500, Lng: 2.3504}, To: graph_search.Coordinate{Lat: 48.8520, Lng: 2.3516}},
+	{Name: "offshore", From: graph_search.Coordinate{Lat: 48.8500, Lng: 2.3504}, To: graph_search.Coordinate{Lat: 48.9, Lng: 2.4}},
+}
+
+func TestHarness_Check(t *testing.T) {
+	h, err := NewHarness("testdata/grid.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Options.Snap.MaxSnapDistance = 500
+	h.Check(t, "testdata/grid.golden.json", gridQueries)
+
+	results := h.Run(gridQueries)
+	if results[0].Error != "" || results[2].Error == "" {
+		t.Fatalf("expected only the offshore query to fail, got %+v", results)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	want := Result{Name: "q", Distance: 1000, Duration: 100, Cost: 1000, Geometry: "abc"}
+	if d := Diff(want, want, DefaultTolerance); len(d) != 0 {
+		t.Fatalf("got differences %v for identical results", d)
+	}
+	got := want
+	got.Distance, got.Geometry = 1000.5, "def"
+	if d := Diff(want, got, DefaultTolerance); len(d) != 1 {
+		t.Fatalf("got differences %v, expected only the geometry", d)
+	}
+	got.Duration = 110
+	if d := Diff(want, got, Tolerance{Distance: 1e-3, Duration: 1e-3, Cost: 1e-3, IgnoreGeometry: true}); len(d) != 1 {
+		t.Fatalf("got differences %v, expected only the duration", d)
+	}
+}
+
+func TestNewHarness_UnknownFixture(t *testing.T) {
+	if _, err := NewHarness("testdata/grid.txt"); err == nil {
+		t.Fatal("expected an error for an unknown fixture format")
+	}
+}
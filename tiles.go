@@ -0,0 +1,399 @@
+package graph_search
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/golang/geo/s2"
+)
+
+// DefaultTileLevel is the S2 level of the tiles written by WriteTiles when no level is given. Level 8
+// cells are about 40 km wide, which keeps a tile of a dense urban area within a few megabytes.
+const DefaultTileLevel = 8
+
+// tileManifestFile is the name of the file describing the tiles of a tile directory.
+const tileManifestFile = "manifest.gob"
+
+var (
+	ErrTileNotFound = errors.New("no tile holds the node")
+)
+
+// tileManifest lists the tiles of a tiled graph. Nodes are renumbered so every tile holds a contiguous
+// range of IDs, which lets the manifest locate the tile of a node without storing one entry per node.
+type tileManifest struct {
+	Level int      // S2 level of the tiles
+	Nodes int32    // Total number of nodes
+	Cells []uint64 // S2 cell of every tile, ordered by First
+	First []int32  // First[i] is the ID of the first node of tile i
+}
+
+// tile is the unit of storage and loading of a tiled graph.
+type tile struct {
+	Cell     uint64
+	First    int32    // ID of Nodes[0] in the tiled graph
+	Nodes    []Node   // Nodes of the tile, with their tiled graph IDs
+	Original []int32  // Original[i] is the ID Nodes[i] had in the graph given to WriteTiles
+	Edges    [][]Edge // Edges[i] are the outgoing edges of Nodes[i], pointing to tiled graph IDs
+}
+
+// WriteTiles splits a graph into S2 cell tiles stored as separate files in dir, so an engine can keep in
+// memory only the tiles its searches go through. Nodes are renumbered tile by tile; the tiles remember
+// the original ID of every node.
+//
+// Parameters:
+//   - g: Graph - The graph to split
+//   - dir: string - Directory the tiles and their manifest are written to, created if needed
+//   - level: int - S2 level of the tiles, DefaultTileLevel if not positive
+//
+// Returns:
+//   - error: The error raised while writing a file, if any
+func WriteTiles(g Graph, dir string, level int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return WriteTilesTo(g, FileStorage(dir), level)
+}
+
+// WriteTilesTo splits a graph into tiles like WriteTiles, storing every tile and the manifest as
+// objects of a storage.
+//
+// Parameters:
+//   - g: Graph - The graph to split
+//   - s: Storage - The storage receiving the tiles and their manifest
+//   - level: int - S2 level of the tiles, DefaultTileLevel if not positive
+//
+// Returns:
+//   - error: The error raised while writing an object, if any
+func WriteTilesTo(g Graph, s Storage, level int) error {
+	if level <= 0 {
+		level = DefaultTileLevel
+	}
+
+	order := make([]int32, len(g.Nodes))
+	cells := make([]uint64, len(g.Nodes))
+	for i, n := range g.Nodes {
+		order[i] = int32(i)
+		cells[i] = uint64(s2.CellID(n.Location).Parent(level))
+	}
+	sort.SliceStable(order, func(i, j int) bool { return cells[order[i]] < cells[order[j]] })
+	renumbered := make([]int32, len(g.Nodes))
+	for newID, oldID := range order {
+		renumbered[oldID] = int32(newID)
+	}
+
+	m := tileManifest{Level: level, Nodes: int32(len(g.Nodes))}
+	for start := 0; start < len(order); {
+		cell := cells[order[start]]
+		end := start
+		for end < len(order) && cells[order[end]] == cell {
+			end++
+		}
+		t := tile{Cell: cell, First: int32(start)}
+		for i, oldID := range order[start:end] {
+			n := g.Nodes[oldID]
+			n.ID = int32(start + i)
+			edges := make([]Edge, 0, len(g.OutgoingEdges[oldID]))
+			for _, e := range g.OutgoingEdges[oldID] {
+				e.ID = renumbered[e.ID]
+				edges = append(edges, e)
+			}
+			t.Nodes = append(t.Nodes, n)
+			t.Original = append(t.Original, oldID)
+			t.Edges = append(t.Edges, edges)
+		}
+		if err := writeGob(s, tileFile(cell), t); err != nil {
+			return err
+		}
+		m.Cells, m.First = append(m.Cells, cell), append(m.First, t.First)
+		start = end
+	}
+	return writeGob(s, tileManifestFile, m)
+}
+
+// TiledGraph gives access to a graph written by WriteTiles, loading tiles on demand and keeping at
+// most a fixed number of them in memory. It is safe for concurrent use.
+type TiledGraph struct {
+	storage  Storage
+	manifest tileManifest
+	maxTiles int
+
+	mu     sync.Mutex
+	loaded map[int]*list.Element // Loaded tiles by index in the manifest
+	order  *list.List            // Front is the most recently used tile
+	loads  int
+}
+
+// OpenTiles opens a directory written by WriteTiles.
+//
+// Parameters:
+//   - dir: string - Directory holding the tiles
+//   - maxTiles: int - Maximum number of tiles kept in memory, at least 1
+//
+// Returns:
+//   - *TiledGraph: The tiled graph, with no tile loaded yet
+//   - error: The error raised while reading the manifest, if any
+func OpenTiles(dir string, maxTiles int) (*TiledGraph, error) {
+	return OpenTilesFrom(FileStorage(dir), maxTiles)
+}
+
+// OpenTilesFrom opens tiles written to a storage by WriteTilesTo, like OpenTiles.
+//
+// Parameters:
+//   - s: Storage - The storage holding the tiles
+//   - maxTiles: int - Maximum number of tiles kept in memory, at least 1
+//
+// Returns:
+//   - *TiledGraph: The tiled graph, with no tile loaded yet
+//   - error: The error raised while reading the manifest, if any
+func OpenTilesFrom(s Storage, maxTiles int) (*TiledGraph, error) {
+	var m tileManifest
+	if err := readGob(s, tileManifestFile, &m); err != nil {
+		return nil, err
+	}
+	return &TiledGraph{
+		storage:  s,
+		manifest: m,
+		maxTiles: max(maxTiles, 1),
+		loaded:   make(map[int]*list.Element),
+		order:    list.New(),
+	}, nil
+}
+
+// Len returns the number of nodes of the graph.
+func (tg *TiledGraph) Len() int {
+	return int(tg.manifest.Nodes)
+}
+
+// Loads returns how many times a tile was read from disk, including reloads after eviction.
+func (tg *TiledGraph) Loads() int {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	return tg.loads
+}
+
+// Node returns a node and its outgoing edges, loading its tile if needed.
+//
+// Parameters:
+//   - id: int32 - ID of the node in the tiled graph
+//
+// Returns:
+//   - Node: The node
+//   - []Edge: Its outgoing edges
+//   - error: ErrTileNotFound if the ID is out of range, or the error raised while loading the tile
+func (tg *TiledGraph) Node(id int32) (Node, []Edge, error) {
+	t, err := tg.tileOf(id)
+	if err != nil {
+		return Node{}, nil, err
+	}
+	return t.Nodes[id-t.First], t.Edges[id-t.First], nil
+}
+
+// OriginalID returns the ID the node had in the graph given to WriteTiles.
+func (tg *TiledGraph) OriginalID(id int32) (int32, error) {
+	t, err := tg.tileOf(id)
+	if err != nil {
+		return 0, err
+	}
+	return t.Original[id-t.First], nil
+}
+
+// tileOf returns the tile holding a node, loading it and evicting the least recently used tile if needed.
+func (tg *TiledGraph) tileOf(id int32) (*tile, error) {
+	if id < 0 || id >= tg.manifest.Nodes {
+		return nil, fmt.Errorf("%w %d", ErrTileNotFound, id)
+	}
+	index := sort.Search(len(tg.manifest.First), func(i int) bool { return tg.manifest.First[i] > id }) - 1
+
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	t, _, err := tg.load(index)
+	return t, err
+}
+
+// load returns a tile, loading it and evicting the least recently used tile if needed. It reports
+// whether the tile was read from storage. The caller must hold tg.mu.
+func (tg *TiledGraph) load(index int) (*tile, bool, error) {
+	if el, ok := tg.loaded[index]; ok {
+		tg.order.MoveToFront(el)
+		return el.Value.(loadedTile).tile, false, nil
+	}
+	t := new(tile)
+	if err := readGob(tg.storage, tileFile(tg.manifest.Cells[index]), t); err != nil {
+		return nil, false, err
+	}
+	tg.loads++
+	tg.loaded[index] = tg.order.PushFront(loadedTile{index: index, tile: t})
+	if tg.order.Len() > tg.maxTiles {
+		oldest := tg.order.Remove(tg.order.Back()).(loadedTile)
+		delete(tg.loaded, oldest.index)
+	}
+	return t, true, nil
+}
+
+// Prefetch loads the tiles covering locations, e.g., the nodes of popular routes, so the first searches
+// going through them do not wait for storage. Tiles are loaded in the order of the locations; when they
+// outnumber the tiles kept in memory, the last ones stay loaded.
+//
+// Parameters:
+//   - locations: []uint64 - S2 cell IDs, such as Node.Location. Locations outside every tile are skipped
+//
+// Returns:
+//   - int: The number of tiles read from storage, tiles already in memory not counted
+//   - error: The error raised while loading a tile, if any
+func (tg *TiledGraph) Prefetch(locations []uint64) (int, error) {
+	tg.mu.Lock()
+	defer tg.mu.Unlock()
+	read := 0
+	for _, location := range locations {
+		cell := uint64(s2.CellID(location).Parent(tg.manifest.Level))
+		index := sort.Search(len(tg.manifest.Cells), func(i int) bool { return tg.manifest.Cells[i] >= cell })
+		if index == len(tg.manifest.Cells) || tg.manifest.Cells[index] != cell {
+			continue
+		}
+		_, loaded, err := tg.load(index)
+		if err != nil {
+			return read, err
+		}
+		if loaded {
+			read++
+		}
+	}
+	return read, nil
+}
+
+// loadedTile is the value stored in the elements of TiledGraph.order.
+type loadedTile struct {
+	index int
+	tile  *tile
+}
+
+// TiledEngine answers routing queries over a TiledGraph, so graphs too large for memory, such as
+// continental extracts, can be searched with bounded memory.
+type TiledEngine struct {
+	graph *TiledGraph
+}
+
+// NewTiledEngine creates an engine over the tiles written in dir.
+//
+// Parameters:
+//   - dir: string - Directory written by WriteTiles
+//   - maxTiles: int - Maximum number of tiles kept in memory
+//
+// Returns:
+//   - *TiledEngine: The engine
+//   - error: The error raised while opening the tiles, if any
+func NewTiledEngine(dir string, maxTiles int) (*TiledEngine, error) {
+	tg, err := OpenTiles(dir, maxTiles)
+	if err != nil {
+		return nil, err
+	}
+	return &TiledEngine{graph: tg}, nil
+}
+
+// Graph returns the tiled graph the engine searches.
+func (e *TiledEngine) Graph() *TiledGraph {
+	return e.graph
+}
+
+// Route runs a Dijkstra search between two nodes of the tiled graph, loading the tiles it reaches.
+//
+// Parameters:
+//   - p: ODPair - Source and target, as tiled graph IDs
+//
+// Returns:
+//   - BatchResult: The shortest path, ErrNoRoute if the target is unreachable, or the error raised
+//     while loading a tile
+func (e *TiledEngine) Route(p ODPair) BatchResult {
+	result := BatchResult{Pair: p}
+	costs := map[int32]float32{p.Source: 0}
+	previous := make(map[int32]Edge)
+	parent := make(map[int32]int32)
+	settled := make(map[int32]bool)
+
+	pq := Create()
+	pq.Insert(HNode{Value: p.Source})
+	for !pq.IsEmpty() {
+		min, _ := pq.Min()
+		pq.DeleteMin()
+		if settled[min.Value] {
+			continue
+		}
+		settled[min.Value] = true
+		_, edges, err := e.graph.Node(min.Value)
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		if min.Value == p.Target {
+			break
+		}
+		for _, edge := range edges {
+			cost := min.Cost + edge.Weight
+			if c, ok := costs[edge.ID]; !settled[edge.ID] && (!ok || cost < c) {
+				costs[edge.ID], previous[edge.ID], parent[edge.ID] = cost, edge, min.Value
+				pq.Insert(HNode{Value: edge.ID, Cost: cost})
+			}
+		}
+	}
+	if !settled[p.Target] {
+		result.Err = ErrNoRoute
+		return result
+	}
+
+	nodes := []int32{p.Target}
+	edges := make([]Edge, 0)
+	for current := p.Target; current != p.Source; current = parent[current] {
+		nodes = append(nodes, parent[current])
+		edges = append(edges, previous[current])
+	}
+	route := Route{Nodes: make([]int32, 0, len(nodes)), Edges: make([]Edge, 0, len(edges))}
+	for i := len(nodes) - 1; i >= 0; i-- {
+		n, _, err := e.graph.Node(nodes[i])
+		if err != nil {
+			result.Err = err
+			return result
+		}
+		route.Nodes = append(route.Nodes, nodes[i])
+		route.coordinates = append(route.coordinates, nodeCoordinate(n))
+	}
+	for i := len(edges) - 1; i >= 0; i-- {
+		route.Edges = append(route.Edges, edges[i])
+	}
+	result.Cost, result.Route = costs[p.Target], route
+	return result
+}
+
+// tileFile returns the name of the file storing the tile of a cell.
+func tileFile(cell uint64) string {
+	return s2.CellID(cell).ToToken() + ".tile"
+}
+
+// writeGob encodes a value to a new object of a storage.
+func writeGob(s Storage, name string, v interface{}) error {
+	f, err := s.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readGob decodes a value from an object of a storage.
+func readGob(s Storage, name string, v interface{}) error {
+	f, err := s.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return gob.NewDecoder(bufio.NewReader(io.NewSectionReader(f, 0, f.Size()))).Decode(v)
+}
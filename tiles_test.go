@@ -0,0 +1,40 @@
+package graph_search
+
+import "testing"
+
+func TestTiledEngine_Route(t *testing.T) {
+	g := GridGraph(30, 30, 2000) // 60 km wide, spanning several level 8 tiles
+	dir := t.TempDir()
+	if err := WriteTiles(g, dir, DefaultTileLevel); err != nil {
+		t.Fatal(err)
+	}
+	e, err := NewTiledEngine(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toTiled := make(map[int32]int32)
+	for id := int32(0); id < int32(e.Graph().Len()); id++ {
+		original, err := e.Graph().OriginalID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		toTiled[original] = id
+	}
+	source, target := int32(0), int32(len(g.Nodes)-1)
+	expected, _ := NewDijkstra(Criteria{Source: []int32{source}, Targets: []int32{target}}).Run(g).Costs.GetCost(target)
+
+	r := e.Route(ODPair{Source: toTiled[source], Target: toTiled[target]})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.Cost != expected {
+		t.Fatalf("got cost %f, expected %f", r.Cost, expected)
+	}
+	if len(r.Route.Nodes) != len(r.Route.Edges)+1 {
+		t.Fatalf("got %d nodes for %d edges", len(r.Route.Nodes), len(r.Route.Edges))
+	}
+	if loads := e.Graph().Loads(); loads < 3 {
+		t.Fatalf("got %d tile loads, expected the search to span several tiles", loads)
+	}
+}
@@ -0,0 +1,167 @@
+package graph_search
+
+import "time"
+
+// SecondsInAnHour is used to convert travel times between hours and seconds.
+const SecondsInAnHour = 3600
+
+// SpeedProfile holds the expected speed of an edge, in km/h, for every hour of the day.
+// Index 0 corresponds to the 00:00-00:59 window and index 23 to 23:00-23:59.
+type SpeedProfile [24]float32
+
+// At returns the speed of the profile for the hour of the given time.
+func (p SpeedProfile) At(t time.Time) float32 {
+	return p[t.Hour()]
+}
+
+// WeightFunc computes the travel time, in seconds, of an edge when it is entered at the given moment.
+type WeightFunc func(e Edge, at time.Time) float32
+
+// ProfileWeight is the default WeightFunc. It derives the travel time from the edge distance and the
+// speed of its weekly or daily profile for the hour of entry, falling back to the static speed of the
+// edge and finally to AvgSpeedCar when no speed is known.
+//
+// Parameters:
+//   - e: Edge - The edge being traversed
+//   - at: time.Time - The moment the edge is entered
+//
+// Returns:
+//   - float32: Travel time in seconds, or INFINITE if the edge speed is zero for that hour
+func ProfileWeight(e Edge, at time.Time) float32 {
+	speed := e.Metadata.Speed
+	if e.Metadata.Weekly != nil {
+		speed = e.Metadata.Weekly.At(at)
+		if speed <= 0 {
+			return INFINITE
+		}
+	} else if e.Metadata.Profile != nil {
+		speed = e.Metadata.Profile.At(at)
+		if speed <= 0 {
+			return INFINITE
+		}
+	}
+	if speed <= 0 {
+		speed = AvgSpeedCar
+	}
+	return e.Metadata.Distance / (speed * MetersInAKilometer / SecondsInAnHour)
+}
+
+// TimeDependentDijkstra runs Dijkstra's algorithm over edges whose travel time depends on the moment
+// they are entered. Instead of accumulating static weights it propagates arrival times, so the same
+// query yields different routes and costs when leaving at 8am or at 2am.
+type TimeDependentDijkstra struct {
+	DijkstraSearch
+
+	// departure is the moment the trip starts at the source nodes
+	departure time.Time
+
+	// weight computes the travel time of an edge given its entry time
+	weight WeightFunc
+
+	// profiled reports whether weight is ProfileWeight, whose travel times bound the default heuristic
+	profiled bool
+}
+
+// NewTimeDependentDijkstra creates a time-dependent search for the given criteria.
+//
+// Parameters:
+//   - c: Criteria - Search parameters; Departure sets the start time of the trip
+//   - weight: WeightFunc - Travel time function for edges, ProfileWeight is used when nil
+//
+// Returns:
+//   - TimeDependentDijkstra: A search instance ready to run. Costs in its Response are expressed
+//     in seconds elapsed since the departure time
+func NewTimeDependentDijkstra(c Criteria, weight WeightFunc) TimeDependentDijkstra {
+	profiled := weight == nil
+	if profiled {
+		weight = ProfileWeight
+	}
+	return TimeDependentDijkstra{
+		DijkstraSearch: NewDijkstra(c),
+		departure:      c.Departure,
+		weight:         weight,
+		profiled:       profiled,
+	}
+}
+
+// Run executes the time-dependent search on the provided graph.
+//
+// Parameters:
+//   - g: Graph - The graph to search through
+//
+// Returns:
+//   - Response: The explored search space and the travel time, in seconds, to every settled node
+func (search TimeDependentDijkstra) Run(g Graph) Response {
+	stats := newSearchStats()
+	defer func() { stats.report(search.criteria.Metrics) }()
+	search.selectCosts(g)
+	search.ellipse = newDetourEllipse(g, search.criteria)
+	search.selectHeuristic(func() Heuristic {
+		if !search.profiled || search.criteria.Overlay.lowers() {
+			return nil
+		}
+		return speedHeuristic(g, g.fastestSpeed(profileSeconds))
+	})
+
+	currentID := int32(0)
+	for !search.isFinished() {
+		stats.observeQueue(search.pq)
+		min, _ := search.pq.Min()
+		settled := !search.wasVisited(min.Value)
+		if settled && search.exceedsLimit(stats.settled) {
+			break
+		}
+		if settled {
+			currentID = search.addPrevious()
+			stats.settled++
+		}
+		search.visited.Set(min.Value, true)
+
+		if search.reachTarget(min.Value) {
+			search.trace.record(g, min, search.pq.Len(), 0)
+			break
+		}
+		cost, _ := search.cost(min.Value)
+		arrival := search.ArrivalTime(cost)
+		relaxed := 0
+		for i, e := range g.OutgoingEdges[min.Value] {
+			stats.scanned++
+			if !search.traversable(min.Value, i, e) || !search.ellipse.allows(g, e.ID) || !e.Metadata.Access.OpenAt(arrival) {
+				continue
+			}
+			if search.Relax(g.Nodes[e.ID], currentID, search.travelTime(min.Value, e, arrival)*search.criteria.overlayFactor(min.Value, i)*search.criteria.surfaceFactor(e), e.Metadata.Distance) {
+				relaxed++
+			}
+		}
+		stats.relaxed += relaxed
+		if settled {
+			search.trace.record(g, min, search.pq.Len(), relaxed)
+		}
+		search.pq.DeleteMin()
+	}
+	r := search.response()
+	r.Stats = stats.summary()
+	return r
+}
+
+// travelTime returns the travel time in seconds of an edge entered at a moment, from the traffic provider
+// of the criteria when it knows the speed on the edge, from the weight function otherwise.
+func (search TimeDependentDijkstra) travelTime(from int32, e Edge, at time.Time) float32 {
+	if search.criteria.Traffic != nil {
+		if w, ok := trafficWeight(search.criteria.Traffic, from, e, at); ok {
+			return w
+		}
+	}
+	return search.weight(e, at)
+}
+
+// ArrivalTime converts a cost produced by the search into an absolute arrival time.
+//
+// Parameters:
+//   - cost: float32 - Seconds elapsed since the departure time
+//
+// Returns:
+//   - time.Time: The moment the node is reached
+func (search TimeDependentDijkstra) ArrivalTime(cost float32) time.Time {
+	return search.departure.Add(time.Duration(float64(cost) * float64(time.Second)))
+}
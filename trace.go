@@ -0,0 +1,81 @@
+package graph_search
+
+import (
+	"fmt"
+
+	"github.com/golang/geo/s2"
+	"github.com/paulmach/go.geojson"
+)
+
+// TraceEntry describes the settlement of a single node during a search.
+type TraceEntry struct {
+	Node        int32   // ID of the settled node in the original graph
+	Location    uint64  // S2 cell ID of the settled node
+	Cost        float32 // Cost at which the node was settled
+	Frontier    int     // Size of the priority queue right after the node was expanded
+	Relaxations int     // Number of neighbors whose cost improved when the node was expanded
+}
+
+// SearchTrace records how a search explored the graph, to help explaining why a route looks wrong.
+type SearchTrace struct {
+	// Settled lists the settled nodes in settlement order
+	Settled []TraceEntry
+
+	// Relaxations is the total number of successful relaxations performed by the search
+	Relaxations int
+}
+
+// record appends the settlement of a node to the trace. It is a no-op on a nil trace so the search
+// loop does not need to check whether debugging is enabled.
+func (t *SearchTrace) record(g Graph, n HNode, frontier, relaxations int) {
+	if t == nil {
+		return
+	}
+	t.Settled = append(t.Settled, TraceEntry{
+		Node:        n.Value,
+		Location:    g.Nodes[n.Value].Location,
+		Cost:        n.Cost,
+		Frontier:    frontier,
+		Relaxations: relaxations,
+	})
+	t.Relaxations += relaxations
+}
+
+// DebugGeoJSON renders the explored search space as a FeatureCollection of points, one per settled node,
+// colored as a heatmap from blue (settled first) to red (settled last). Every feature carries the
+// settlement order, cost, frontier size and relaxation count as properties.
+//
+// Returns:
+//   - *geojson.FeatureCollection: The rendered search space, empty if the search did not run with Criteria.Debug
+func (r Response) DebugGeoJSON() *geojson.FeatureCollection {
+	fc := geojson.NewFeatureCollection()
+	if r.Trace == nil {
+		return fc
+	}
+	total := len(r.Trace.Settled)
+	for i, entry := range r.Trace.Settled {
+		p := s2.CellID(entry.Location).LatLng()
+		f := geojson.NewPointFeature([]float64{p.Lng.Degrees(), p.Lat.Degrees()})
+		color := heatColor(float64(i) / float64(max(total-1, 1)))
+		f.SetProperty("order", i)
+		f.SetProperty("node", entry.Node)
+		f.SetProperty("cost", entry.Cost)
+		f.SetProperty("frontier", entry.Frontier)
+		f.SetProperty("relaxations", entry.Relaxations)
+		f.SetProperty("marker-color", color)
+		f.SetProperty("marker-size", "small")
+		fc.AddFeature(f)
+	}
+	return fc
+}
+
+// heatColor interpolates from blue (0) to red (1) through green and returns the color as a hex string.
+func heatColor(ratio float64) string {
+	var r, g, b float64
+	if ratio < 0.5 {
+		g, b = ratio*2, 1-ratio*2
+	} else {
+		r, g = (ratio-0.5)*2, 1-(ratio-0.5)*2
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(r*255), int(g*255), int(b*255))
+}
@@ -0,0 +1,116 @@
+package graph_search
+
+// TrackerTransitionPenalty is the extra distance, in meters, added to candidate edges that are not reachable
+// from the currently tracked edge, so that noisy fixes do not make the position jump between parallel roads.
+const TrackerTransitionPenalty = 25
+
+// Tracker keeps the best estimate of a moving vehicle's position on the graph. Instead of matching a whole
+// trace in batch, it is fed GPS fixes one at a time and updates the current edge and offset incrementally,
+// preferring edges that continue the previously tracked one.
+type Tracker struct {
+	graph   Graph
+	index   *KDTree
+	radius  float64
+	current *EdgeSnap
+}
+
+// NewTracker creates a Tracker over the given graph and its spatial index.
+//
+// Parameters:
+//   - g: Graph - The road network the vehicle moves on
+//   - index: *KDTree - Spatial index of g built with BuildNodeIndex
+//
+// Returns:
+//   - *Tracker: A tracker without position, waiting for its first fix
+func NewTracker(g Graph, index *KDTree) *Tracker {
+	return &Tracker{graph: g, index: index, radius: DefaultSnapRadius}
+}
+
+// Update feeds a new GPS fix to the tracker and returns the updated position.
+//
+// Parameters:
+//   - fix: Coordinate - The latest position reported by the GPS
+//
+// Returns:
+//   - EdgeSnap: The edge and offset that best explain the fix given the previous position
+//   - error: ErrNoCandidates if no edge lies near the fix; the previous position is kept in that case
+func (t *Tracker) Update(fix Coordinate) (EdgeSnap, error) {
+	candidates := t.graph.SnapCandidates(t.index, fix, t.radius)
+	if len(candidates) == 0 {
+		return EdgeSnap{}, ErrNoCandidates
+	}
+
+	best, bestScore := candidates[0], t.score(candidates[0])
+	for _, candidate := range candidates[1:] {
+		if score := t.score(candidate); score < bestScore {
+			best, bestScore = candidate, score
+		}
+	}
+	t.current = &best
+	return best, nil
+}
+
+// score rates a candidate by its distance to the fix plus a penalty when it does not follow the tracked edge.
+func (t *Tracker) score(candidate EdgeSnap) float64 {
+	if t.current == nil {
+		return candidate.Distance
+	}
+	sameEdge := candidate.From == t.current.From && candidate.To == t.current.To
+	nextEdge := candidate.From == t.current.To && candidate.To != t.current.From
+	if sameEdge || nextEdge {
+		return candidate.Distance
+	}
+	return candidate.Distance + TrackerTransitionPenalty
+}
+
+// Current returns the tracked position, if any fix has been matched yet.
+func (t *Tracker) Current() (EdgeSnap, bool) {
+	if t.current == nil {
+		return EdgeSnap{}, false
+	}
+	return *t.current, true
+}
+
+// Road returns the metadata of the edge the vehicle is currently on.
+func (t *Tracker) Road() MetaData {
+	if t.current == nil {
+		return MetaData{}
+	}
+	return t.current.Edge.Metadata
+}
+
+// DistanceToNextManeuver returns the distance in meters from the tracked position to the next point where
+// the driver has to make a decision: an intersection, a change of road type, or a dead end.
+//
+// Returns:
+//   - float64: Distance in meters, or 0 if the tracker has no position yet
+func (t *Tracker) DistanceToNextManeuver() float64 {
+	if t.current == nil {
+		return 0
+	}
+	distance := t.current.Remaining()
+	previous, node, road := t.current.From, t.current.To, t.current.Edge.Metadata.RoadType
+	for steps := 0; steps < len(t.graph.Nodes); steps++ {
+		next, ok := t.continuation(previous, node)
+		if !ok || next.Metadata.RoadType != road {
+			break
+		}
+		distance += float64(next.Metadata.Distance)
+		previous, node = node, next.ID
+	}
+	return distance
+}
+
+// continuation returns the only edge leaving node that does not go back to previous, if the node is not a decision point.
+func (t *Tracker) continuation(previous, node int32) (Edge, bool) {
+	var next Edge
+	count := 0
+	for _, e := range t.graph.OutgoingEdges[node] {
+		if e.ID == previous {
+			continue
+		}
+		next = e
+		count++
+	}
+	return next, count == 1
+}
@@ -0,0 +1,90 @@
+package graph_search
+
+import (
+	"sync"
+	"time"
+)
+
+// EdgeID identifies a directed edge of a graph by its endpoints.
+type EdgeID struct {
+	From int32 // ID of the node the edge leaves from
+	To   int32 // ID of the node the edge leads to
+}
+
+// TrafficProvider supplies live or historical speeds, e.g., from HERE, TomTom or a fleet's own probe data.
+// Set as Criteria.Traffic, it overrides the speeds of the weight function of time-dependent searches.
+type TrafficProvider interface {
+	// GetSpeed returns the speed in km/h on an edge at a moment, 0 when the provider has no data for it.
+	GetSpeed(edge EdgeID, t time.Time) float32
+}
+
+// MemoryTraffic is a TrafficProvider keeping speeds in memory, bucketed by time. It is safe for concurrent
+// use, so a goroutine consuming a probe feed can update it while searches read it.
+type MemoryTraffic struct {
+	bucket time.Duration
+
+	mu     sync.RWMutex
+	speeds map[EdgeID]map[int64]float32 // speeds[edge][bucket] in km/h
+}
+
+// NewMemoryTraffic creates an empty provider.
+//
+// Parameters:
+//   - bucket: time.Duration - Length of the time windows speeds are recorded for, e.g., 15 minutes.
+//     A non-positive length keeps a single speed per edge, valid at any time
+//
+// Returns:
+//   - *MemoryTraffic: A provider without data
+func NewMemoryTraffic(bucket time.Duration) *MemoryTraffic {
+	return &MemoryTraffic{bucket: bucket, speeds: make(map[EdgeID]map[int64]float32)}
+}
+
+// Set records the speed on an edge for the time window containing a moment, replacing any previous one.
+//
+// Parameters:
+//   - edge: EdgeID - The edge
+//   - t: time.Time - A moment of the window
+//   - speed: float32 - The speed in km/h, 0 forgets the speed of the window
+func (m *MemoryTraffic) Set(edge EdgeID, t time.Time, speed float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	windows, ok := m.speeds[edge]
+	if !ok {
+		windows = make(map[int64]float32)
+		m.speeds[edge] = windows
+	}
+	if speed <= 0 {
+		delete(windows, m.window(t))
+		return
+	}
+	windows[m.window(t)] = speed
+}
+
+// GetSpeed returns the speed recorded on an edge for the time window containing a moment.
+func (m *MemoryTraffic) GetSpeed(edge EdgeID, t time.Time) float32 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.speeds[edge][m.window(t)]
+}
+
+// window returns the index of the time window containing a moment.
+func (m *MemoryTraffic) window(t time.Time) int64 {
+	if m.bucket <= 0 {
+		return 0
+	}
+	return t.UnixNano() / int64(m.bucket)
+}
+
+// trafficWeight returns the travel time in seconds of an edge entered at a moment according to a traffic
+// provider.
+//
+// Returns:
+//   - float32: The travel time in seconds
+//   - bool: false if the provider has no speed for the edge at that moment
+func trafficWeight(p TrafficProvider, from int32, e Edge, at time.Time) (float32, bool) {
+	speed := p.GetSpeed(EdgeID{From: from, To: e.ID}, at)
+	if speed <= 0 {
+		return 0, false
+	}
+	return e.Metadata.Distance / (speed * MetersInAKilometer / SecondsInAnHour), true
+}
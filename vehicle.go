@@ -0,0 +1,116 @@
+package graph_search
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Vehicle describes the physical characteristics of the routed vehicle, used to exclude edges
+// it cannot legally traverse (low bridges, weight-limited roads, truck bans).
+type Vehicle struct {
+	Weight float32 // Gross weight in tonnes
+	Height float32 // Height in meters
+	Width  float32 // Width in meters
+	HGV    bool    // Whether the vehicle is a heavy goods vehicle
+	Hazmat bool    // Whether the vehicle carries hazardous materials
+}
+
+// CanTraverse reports whether the vehicle is allowed on an edge with the given metadata.
+// Restrictions that are not set on the edge (zero values) never exclude the vehicle.
+//
+// Parameters:
+//   - m: MetaData - Metadata of the edge to check
+//
+// Returns:
+//   - bool: true if the vehicle satisfies every restriction of the edge
+func (v Vehicle) CanTraverse(m MetaData) bool {
+	if m.MaxWeight > 0 && v.Weight > m.MaxWeight {
+		return false
+	}
+	if m.MaxHeight > 0 && v.Height > m.MaxHeight {
+		return false
+	}
+	if m.MaxWidth > 0 && v.Width > m.MaxWidth {
+		return false
+	}
+	if m.NoHGV && v.HGV {
+		return false
+	}
+	if m.NoHazmat && v.Hazmat {
+		return false
+	}
+	return true
+}
+
+// parseWeight converts an OSM maxweight value to tonnes. Values without unit are tonnes as per OSM
+// conventions; "t", "kg", "lbs" and "st" (short tons) suffixes are understood.
+//
+// Parameters:
+//   - value: string - Raw tag value (e.g., "3.5", "7.5 t", "12000 kg")
+//
+// Returns:
+//   - float32: Weight in tonnes, 0 if the value is empty or cannot be parsed
+func parseWeight(value string) float32 {
+	number, unit := splitMeasure(value)
+	switch unit {
+	case "", "t":
+		return number
+	case "kg":
+		return number / KilogramsInATonne
+	case "lbs":
+		return number * 0.45359237 / KilogramsInATonne
+	case "st":
+		return number * 0.90718474
+	}
+	return 0
+}
+
+// parseLength converts an OSM maxheight/maxwidth value to meters. Values without unit are meters;
+// "m", "cm", "ft" and the imperial feet/inches notation (e.g., 12'6") are understood.
+//
+// Parameters:
+//   - value: string - Raw tag value (e.g., "4", "4.2 m", "13'6\"")
+//
+// Returns:
+//   - float32: Length in meters, 0 if the value is empty or cannot be parsed
+func parseLength(value string) float32 {
+	value = strings.TrimSpace(value)
+	if feet, inches, ok := strings.Cut(value, "'"); ok {
+		f, err := strconv.ParseFloat(strings.TrimSpace(feet), 32)
+		if err != nil {
+			return 0
+		}
+		in := 0.0
+		if inches = strings.TrimSpace(strings.TrimSuffix(inches, "\"")); inches != "" {
+			if in, err = strconv.ParseFloat(inches, 32); err != nil {
+				return 0
+			}
+		}
+		return float32((f + in/InchesPerFoot) * MetersPerFoot)
+	}
+
+	number, unit := splitMeasure(value)
+	switch unit {
+	case "", "m":
+		return number
+	case "cm":
+		return number / 100
+	case "ft":
+		return number * MetersPerFoot
+	}
+	return 0
+}
+
+// splitMeasure separates the numeric part of a tag value from its unit suffix.
+func splitMeasure(value string) (float32, string) {
+	value = strings.TrimSpace(strings.ToLower(value))
+	end := 0
+	for end < len(value) && (value[end] >= '0' && value[end] <= '9' || value[end] == '.') {
+		end++
+	}
+	number, err := strconv.ParseFloat(value[:end], 32)
+	if err != nil || number < 0 {
+		return 0, ""
+	}
+	return float32(number), strings.TrimSpace(value[end:])
+}
@@ -0,0 +1,37 @@
+package graph_search
+
+import "testing"
+
+func TestParseVehicleRestrictions(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		parse    func(string) float32
+		expected float32
+	}{
+		{value: "3.5", parse: parseWeight, expected: 3.5},
+		{value: "7.5 t", parse: parseWeight, expected: 7.5},
+		{value: "12000 kg", parse: parseWeight, expected: 12},
+		{value: "none", parse: parseWeight, expected: 0},
+		{value: "4.2 m", parse: parseLength, expected: 4.2},
+		{value: "250 cm", parse: parseLength, expected: 2.5},
+		{value: "10'", parse: parseLength, expected: 3.048},
+		{value: "default", parse: parseLength, expected: 0},
+	} {
+		if got := tc.parse(tc.value); got-tc.expected > 1e-4 || tc.expected-got > 1e-4 {
+			t.Errorf("%q: got %f, expected %f", tc.value, got, tc.expected)
+		}
+	}
+}
+
+func TestVehicleCanTraverse(t *testing.T) {
+	truck := Vehicle{Weight: 18, Height: 4, Width: 2.5, HGV: true}
+	if truck.CanTraverse(MetaData{MaxHeight: 3.8}) {
+		t.Fatal("truck should not fit under a 3.8 m bridge")
+	}
+	if truck.CanTraverse(MetaData{NoHGV: true}) {
+		t.Fatal("truck should not enter a road with hgv=no")
+	}
+	if !truck.CanTraverse(MetaData{MaxWeight: 20, MaxWidth: 3}) {
+		t.Fatal("truck should be allowed on a road within its limits")
+	}
+}
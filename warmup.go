@@ -0,0 +1,203 @@
+package graph_search
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultWarmupCheckInterval is how often the background scheduler checks whether a warm-up run is due.
+const DefaultWarmupCheckInterval = time.Minute
+
+// WarmupTask is a unit of precomputation run by the warm-up scheduler, such as replaying popular
+// corridors, filling landmark tables or prefetching tiles.
+type WarmupTask struct {
+	Name string
+	Run  func(ctx context.Context, e *Engine) error
+}
+
+// WarmupConfig configures what the warm-up scheduler precomputes and when.
+type WarmupConfig struct {
+	// Tasks are run sequentially, in order, on every warm-up run.
+	Tasks []WarmupTask
+
+	// AtStartup runs the tasks as soon as the scheduler starts, regardless of the off-peak window.
+	AtStartup bool
+
+	// OffPeakStart and OffPeakEnd delimit, in local hours [start, end), the window where periodic runs
+	// are allowed. The window may wrap around midnight (e.g., 22 to 5). Equal values disable periodic runs.
+	OffPeakStart int
+	OffPeakEnd   int
+
+	// Interval is the minimum time between two periodic runs.
+	Interval time.Duration
+
+	// CheckEvery is how often the scheduler checks whether a run is due, DefaultWarmupCheckInterval if zero.
+	CheckEvery time.Duration
+}
+
+// WarmupProgress reports the state of the warm-up scheduler.
+type WarmupProgress struct {
+	Running   bool      // Whether a warm-up run is in progress
+	Task      string    // Name of the task being run, empty when idle
+	Completed int       // Tasks completed in the current (or last) run
+	Total     int       // Tasks in the current (or last) run
+	Runs      int       // Number of finished runs
+	LastRun   time.Time // When the last run finished
+	LastError error     // First error returned by a task during the last run
+}
+
+// CorridorWarmup returns a task that answers the n most popular origin-destination pairs of the query log
+// like Route, filling the route cache of the engine, so the first queries after a deploy or a cache purge
+// are answered from it. The replayed queries are not recorded in the query log, so warm-up runs leave
+// the popularity of the pairs unchanged. It has no effect on engines without route cache.
+func CorridorWarmup(n int) WarmupTask {
+	return WarmupTask{
+		Name: "corridors",
+		Run: func(ctx context.Context, e *Engine) error {
+			for _, p := range e.Queries().Top(n) {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				e.route(p, Criteria{}, false)
+			}
+			return nil
+		},
+	}
+}
+
+// IndexWarmup returns a task building the spatial indexes of the engine, see Engine.Index and Engine.EdgeIndex.
+func IndexWarmup() WarmupTask {
+	return WarmupTask{
+		Name: "indexes",
+		Run: func(_ context.Context, e *Engine) error {
+			e.Index()
+			e.EdgeIndex()
+			return nil
+		},
+	}
+}
+
+// LandmarkWarmup returns a task building the landmark table of the engine, see Engine.Landmarks.
+func LandmarkWarmup() WarmupTask {
+	return WarmupTask{
+		Name: "landmarks",
+		Run: func(_ context.Context, e *Engine) error {
+			_, err := e.Landmarks()
+			return err
+		},
+	}
+}
+
+// TilePrefetchWarmup returns a task loading into a tiled graph the tiles crossed by the routes of the n
+// most popular origin-destination pairs of the query log, which are not recorded again. The tiled graph
+// must be written by WriteTiles from the graph of the engine, so the routes of the engine locate its tiles.
+func TilePrefetchWarmup(tg *TiledGraph, n int) WarmupTask {
+	return WarmupTask{
+		Name: "tiles",
+		Run: func(ctx context.Context, e *Engine) error {
+			for _, p := range e.Queries().Top(n) {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				r := e.route(p, Criteria{}, false)
+				if r.Err != nil {
+					continue
+				}
+				locations := make([]uint64, 0, len(r.Route.Nodes))
+				for _, id := range r.Route.Nodes {
+					locations = append(locations, e.graph.Nodes[id].Location)
+				}
+				if _, err := tg.Prefetch(locations); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// StartWarmup launches the warm-up scheduler in a background goroutine. It stops when ctx is cancelled.
+//
+// Parameters:
+//   - ctx: context.Context - Controls the lifetime of the scheduler and of the running tasks
+//   - cfg: WarmupConfig - What to precompute and when
+func (e *Engine) StartWarmup(ctx context.Context, cfg WarmupConfig) {
+	if cfg.CheckEvery <= 0 {
+		cfg.CheckEvery = DefaultWarmupCheckInterval
+	}
+	go func() {
+		if cfg.AtStartup {
+			_ = e.RunWarmup(ctx, cfg.Tasks)
+		}
+		ticker := time.NewTicker(cfg.CheckEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				if cfg.due(now, e.WarmupProgress().LastRun) {
+					_ = e.RunWarmup(ctx, cfg.Tasks)
+				}
+			}
+		}
+	}()
+}
+
+// RunWarmup runs the given tasks synchronously, updating the progress reported by WarmupProgress.
+//
+// Parameters:
+//   - ctx: context.Context - Cancels the run between tasks
+//   - tasks: []WarmupTask - Tasks to run in order
+//
+// Returns:
+//   - error: The first error returned by a task; the remaining tasks still run unless ctx is cancelled
+func (e *Engine) RunWarmup(ctx context.Context, tasks []WarmupTask) error {
+	e.updateWarmup(func(p *WarmupProgress) {
+		p.Running, p.Completed, p.Total, p.LastError = true, 0, len(tasks), nil
+	})
+	var first error
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			first = ctx.Err()
+			break
+		}
+		e.updateWarmup(func(p *WarmupProgress) { p.Task = task.Name })
+		if err := task.Run(ctx, e); err != nil && first == nil {
+			first = err
+		}
+		e.updateWarmup(func(p *WarmupProgress) { p.Completed++ })
+	}
+	e.updateWarmup(func(p *WarmupProgress) {
+		p.Running, p.Task, p.LastError, p.LastRun = false, "", first, time.Now()
+		p.Runs++
+	})
+	getLogger().Info("warm-up finished", "tasks", len(tasks), "error", first)
+	return first
+}
+
+// WarmupProgress returns a snapshot of the warm-up scheduler state.
+func (e *Engine) WarmupProgress() WarmupProgress {
+	e.warmupMu.Lock()
+	defer e.warmupMu.Unlock()
+	return e.warmup
+}
+
+// updateWarmup applies fn to the warm-up progress while holding the engine lock.
+func (e *Engine) updateWarmup(fn func(*WarmupProgress)) {
+	e.warmupMu.Lock()
+	fn(&e.warmup)
+	e.warmupMu.Unlock()
+}
+
+// due reports whether a periodic run should start at the given moment.
+func (cfg WarmupConfig) due(now, lastRun time.Time) bool {
+	if cfg.OffPeakStart == cfg.OffPeakEnd || now.Sub(lastRun) < cfg.Interval {
+		return false
+	}
+	hour := now.Hour()
+	if cfg.OffPeakStart < cfg.OffPeakEnd {
+		return hour >= cfg.OffPeakStart && hour < cfg.OffPeakEnd
+	}
+	return hour >= cfg.OffPeakStart || hour < cfg.OffPeakEnd
+}
@@ -0,0 +1,128 @@
+package graph_search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWarmupConfig_Due(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 5, 6, hour, 30, 0, 0, time.UTC) }
+	tests := []struct {
+		name       string
+		start, end int
+		hour       int
+		lastRun    time.Time
+		due        bool
+	}{
+		{"inside window", 2, 5, 3, time.Time{}, true},
+		{"window end excluded", 2, 5, 5, time.Time{}, false},
+		{"before window", 2, 5, 1, time.Time{}, false},
+		{"wrapping window before midnight", 22, 5, 23, time.Time{}, true},
+		{"wrapping window after midnight", 22, 5, 4, time.Time{}, true},
+		{"outside wrapping window", 22, 5, 12, time.Time{}, false},
+		{"disabled", 3, 3, 3, time.Time{}, false},
+		{"ran too recently", 2, 5, 3, at(3).Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		cfg := WarmupConfig{OffPeakStart: tt.start, OffPeakEnd: tt.end, Interval: time.Hour}
+		if got := cfg.due(at(tt.hour), tt.lastRun); got != tt.due {
+			t.Errorf("%s: got due %v, expected %v", tt.name, got, tt.due)
+		}
+	}
+}
+
+func TestEngine_RunWarmup(t *testing.T) {
+	e := NewEngine(GridGraph(3, 3, 100))
+	failure := errors.New("task failed")
+	var during WarmupProgress
+	tasks := []WarmupTask{
+		{Name: "first", Run: func(context.Context, *Engine) error { return failure }},
+		{Name: "second", Run: func(_ context.Context, e *Engine) error {
+			during = e.WarmupProgress()
+			return nil
+		}},
+	}
+	if err := e.RunWarmup(context.Background(), tasks); !errors.Is(err, failure) {
+		t.Fatalf("got %v, expected the error of the first task", err)
+	}
+	if !during.Running || during.Task != "second" || during.Completed != 1 || during.Total != 2 {
+		t.Fatalf("got progress %+v while running the second task", during)
+	}
+	p := e.WarmupProgress()
+	if p.Running || p.Completed != 2 || p.Runs != 1 || !errors.Is(p.LastError, failure) || p.LastRun.IsZero() {
+		t.Fatalf("got progress %+v after the run", p)
+	}
+
+	// Cancelling the context stops the run before the next task.
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := false
+	tasks = []WarmupTask{
+		{Name: "cancel", Run: func(context.Context, *Engine) error { cancel(); return nil }},
+		{Name: "skipped", Run: func(context.Context, *Engine) error { ran = true; return nil }},
+	}
+	if err := e.RunWarmup(ctx, tasks); !errors.Is(err, context.Canceled) || ran {
+		t.Fatalf("got %v (second task ran: %v), expected the run to stop", err, ran)
+	}
+	if p := e.WarmupProgress(); p.Completed != 1 || p.Runs != 2 {
+		t.Fatalf("got progress %+v after the cancelled run", p)
+	}
+}
+
+func TestEngine_StartWarmup(t *testing.T) {
+	e := NewEngine(GridGraph(3, 3, 100))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.StartWarmup(ctx, WarmupConfig{Tasks: []WarmupTask{LandmarkWarmup()}, AtStartup: true})
+	deadline := time.Now().Add(5 * time.Second)
+	for e.WarmupProgress().Runs == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the startup warm-up did not run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if p := e.WarmupProgress(); p.LastError != nil || p.Completed != 1 {
+		t.Fatalf("got progress %+v", p)
+	}
+}
+
+func TestCorridorWarmup(t *testing.T) {
+	g := GridGraph(30, 30, 2000)
+	e := NewEngine(g)
+	e.EnableRouteCache(10)
+	e.Queries().Record(ODPair{Source: 0, Target: 899})
+	e.Queries().Record(ODPair{Source: 0, Target: 899})
+	e.Queries().Record(ODPair{Source: 29, Target: 870})
+
+	dir := t.TempDir()
+	if err := WriteTiles(g, dir, DefaultTileLevel); err != nil {
+		t.Fatal(err)
+	}
+	tg, err := OpenTiles(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tasks := []WarmupTask{CorridorWarmup(2), TilePrefetchWarmup(tg, 1)}
+	if err := e.RunWarmup(context.Background(), tasks); err != nil {
+		t.Fatal(err)
+	}
+	if n := e.RouteCache().Len(); n != 2 {
+		t.Fatalf("got %d cached routes, expected the 2 corridors", n)
+	}
+	if a, b := e.Queries().Count(ODPair{Source: 0, Target: 899}), e.Queries().Count(ODPair{Source: 29, Target: 870}); a != 2 || b != 1 {
+		t.Fatalf("got %d and %d queries, expected the warm-up to leave the counts at 2 and 1", a, b)
+	}
+	loads := tg.Loads()
+	if loads < 2 {
+		t.Fatalf("got %d tiles prefetched, expected the route to cross several tiles", loads)
+	}
+	r := e.Route(ODPair{Source: 0, Target: 899}, Criteria{})
+	locations := make([]uint64, 0, len(r.Route.Nodes))
+	for _, id := range r.Route.Nodes {
+		locations = append(locations, g.Nodes[id].Location)
+	}
+	if read, err := tg.Prefetch(locations); err != nil || read != 0 {
+		t.Fatalf("read %d tiles (%v), expected every tile of the route to be loaded already", read, err)
+	}
+}
@@ -0,0 +1,140 @@
+package graph_search
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/golang/geo/s2"
+	"github.com/paulmach/go.geojson"
+)
+
+var (
+	ErrInvalidPolygon = errors.New("invalid polygon")
+)
+
+// ZoneProperty is the node property AssignZones stores the zone ID of every node in.
+const ZoneProperty = "zone"
+
+// GeoJSONPolygon is a zone such as a pricing zone or a census tract: a GeoJSON polygon and its ID.
+type GeoJSONPolygon struct {
+	ID string // Identifier of the zone, stored in the ZoneProperty of the nodes inside it
+
+	// Rings of the polygon in GeoJSON order: [lng, lat] positions, the exterior ring first and its holes
+	// after it.
+	Rings [][][]float64
+}
+
+// PolygonsFromGeoJSON extracts the Polygon and MultiPolygon features of a collection as zones, reading
+// their IDs from a feature property. Every polygon of a MultiPolygon becomes a zone with the same ID.
+//
+// Parameters:
+//   - fc: *geojson.FeatureCollection - The features to read
+//   - idProperty: string - Name of the property holding the zone ID
+//
+// Returns:
+//   - []GeoJSONPolygon: The zones, in feature order
+//   - error: An error wrapping ErrInvalidPolygon if a polygon feature has no ID
+func PolygonsFromGeoJSON(fc *geojson.FeatureCollection, idProperty string) ([]GeoJSONPolygon, error) {
+	polygons := make([]GeoJSONPolygon, 0)
+	for i, f := range fc.Features {
+		if f.Geometry == nil || (!f.Geometry.IsPolygon() && !f.Geometry.IsMultiPolygon()) {
+			continue
+		}
+		id, ok := f.Properties[idProperty]
+		if !ok {
+			return nil, fmt.Errorf("feature %d: %w: missing property %q", i, ErrInvalidPolygon, idProperty)
+		}
+		if f.Geometry.IsPolygon() {
+			polygons = append(polygons, GeoJSONPolygon{ID: fmt.Sprint(id), Rings: f.Geometry.Polygon})
+			continue
+		}
+		for _, rings := range f.Geometry.MultiPolygon {
+			polygons = append(polygons, GeoJSONPolygon{ID: fmt.Sprint(id), Rings: rings})
+		}
+	}
+	return polygons, nil
+}
+
+// AssignZones tags every node lying inside a polygon with the ID of the polygon, in its ZoneProperty, so
+// zone-to-zone matrices and pricing-zone aware routing can group nodes by zone. Candidate nodes of every
+// polygon come from a range query on a KDTree of the nodes, then go through a point-in-polygon test.
+// When polygons overlap, nodes keep the first zone containing them.
+//
+// Parameters:
+//   - polygons: []GeoJSONPolygon - The zones
+//
+// Returns:
+//   - map[string][]int32: The IDs of the nodes of every zone, in increasing order for each zone
+//   - error: An error wrapping ErrInvalidPolygon if a polygon has no exterior ring with at least 3 positions
+func (g *Graph) AssignZones(polygons []GeoJSONPolygon) (map[string][]int32, error) {
+	for i, p := range polygons {
+		if len(p.Rings) == 0 || len(p.Rings[0]) < 3 {
+			return nil, fmt.Errorf("polygon %d (%s): %w", i, p.ID, ErrInvalidPolygon)
+		}
+	}
+	vectors := make([]Vector, 0, len(g.Nodes))
+	for _, n := range g.Nodes {
+		p := s2.CellID(n.Location).LatLng()
+		x, y := LatLngToMeters(p.Lat.Degrees(), p.Lng.Degrees())
+		vectors = append(vectors, Vector{ID: n.GetID(), Components: []float64{x, y}})
+	}
+	tree := BuildKDTree(vectors)
+
+	assigned := make([]bool, len(g.Nodes))
+	zones := make(map[string][]int32)
+	for _, polygon := range polygons {
+		minX, minY, maxX, maxY := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, position := range polygon.Rings[0] {
+			x, y := LatLngToMeters(position[1], position[0])
+			minX, maxX = math.Min(minX, x), math.Max(maxX, x)
+			minY, maxY = math.Min(minY, y), math.Max(maxY, y)
+		}
+		center := Vector{Components: []float64{(minX + maxX) / 2, (minY + maxY) / 2}}
+		for _, candidate := range tree.RangeQuery(center, math.Hypot(maxX-minX, maxY-minY)/2) {
+			id := int32(candidate.ID)
+			if assigned[id] {
+				continue
+			}
+			p := s2.CellID(g.Nodes[id].Location).LatLng()
+			if !polygon.Contains(Coordinate{Lat: p.Lat.Degrees(), Lng: p.Lng.Degrees()}) {
+				continue
+			}
+			assigned[id] = true
+			_ = g.SetNodeProperty(id, ZoneProperty, polygon.ID)
+		}
+	}
+	for id, ok := range assigned {
+		if ok {
+			zone := g.Properties[id][ZoneProperty]
+			zones[zone] = append(zones[zone], int32(id))
+		}
+	}
+	return zones, nil
+}
+
+// Zone returns the zone AssignZones tagged a node with.
+//
+// Parameters:
+//   - id: int32 - ID of the node
+//
+// Returns:
+//   - string: The ID of the zone containing the node
+//   - bool: false if the node lies in no zone
+func (g Graph) Zone(id int32) (string, bool) {
+	return g.NodeProperties(id).Get(ZoneProperty)
+}
+
+// Contains reports whether a coordinate lies inside the polygon and outside its holes, by ray casting.
+func (p GeoJSONPolygon) Contains(c Coordinate) bool {
+	inside := false
+	for _, ring := range p.Rings {
+		for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+			a, b := ring[i], ring[j]
+			if (a[1] > c.Lat) != (b[1] > c.Lat) && c.Lng < a[0]+(c.Lat-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}